@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+)
+
+const (
+	embeddingDimensions = 1536
+
+	fakeTranscript = "This is a transcript of a voice memo."
+	fakeImage      = "A photo of a sunset over the water."
+)
+
+// NewOpenAI returns a fake of the parts of the OpenAI API the lambdas use.
+// Replies are canned, but shaped like the real thing, so the rest of the
+// pipeline can be exercised without an API key.
+func NewOpenAI() http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/chat/completions", chatCompletion)
+	mux.HandleFunc("/embeddings", embeddings)
+	mux.HandleFunc("/audio/transcriptions", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, openai.AudioResponse{Text: fakeTranscript})
+	})
+
+	return mux
+}
+
+func chatCompletion(w http.ResponseWriter, r *http.Request) {
+
+	var request openai.ChatCompletionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]string{"message": err.Error(), "type": "invalid_request_error"},
+		})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, openai.ChatCompletionResponse{
+		ID:      "chatcmpl-" + newID(),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   request.Model,
+		Choices: []openai.ChatCompletionChoice{{
+			Message: openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleAssistant,
+				Content: reply(request.Messages),
+			},
+			FinishReason: openai.FinishReasonStop,
+		}},
+	})
+}
+
+// reply picks a canned completion for [messages].
+func reply(messages []openai.ChatCompletionMessage) string {
+
+	var last string
+
+	for _, message := range messages {
+
+		// The fact agent asks for a JSON list of facts
+		if message.Role == openai.ChatMessageRoleSystem && strings.Contains(message.Content, "JSON") {
+			return "[]"
+		}
+
+		// Image descriptions are sent as multi part messages
+		if len(message.MultiContent) > 0 {
+			return fakeImage
+		}
+
+		if message.Role == openai.ChatMessageRoleUser {
+			last = message.Content
+		}
+	}
+
+	if last == "" {
+		return "Hi there! How has your day been?"
+	}
+
+	return fmt.Sprintf("It sounds like a lot is on your mind. Tell me more about \"%s\".", summarize(last))
+}
+
+func summarize(text string) string {
+
+	words := strings.Fields(text)
+
+	if len(words) > 8 {
+		words = append(words[:8], "...")
+	}
+
+	return strings.Join(words, " ")
+}
+
+func embeddings(w http.ResponseWriter, r *http.Request) {
+
+	var request openai.EmbeddingRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+			"error": map[string]string{"message": err.Error(), "type": "invalid_request_error"},
+		})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, openai.EmbeddingResponse{
+		Object: "list",
+		Model:  request.Model,
+		Data: []openai.Embedding{{
+			Object:    "embedding",
+			Embedding: embed(fmt.Sprint(request.Input)),
+		}},
+	})
+}
+
+// embed returns a unit vector derived from [text], so the same text
+// always has the same embedding.
+func embed(text string) []float32 {
+
+	vector := make([]float32, embeddingDimensions)
+
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(word))
+		vector[h.Sum32()%embeddingDimensions] += 1
+	}
+
+	var norm float64
+
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+
+	if norm == 0 {
+		return vector
+	}
+
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / math.Sqrt(norm))
+	}
+
+	return vector
+}
+
+// emotionWords is a tiny stand in for the NRC emotion lexicon.
+var emotionWords = map[string][]string{
+	"anger":        {"angry", "mad", "furious", "annoyed", "hate"},
+	"anticipation": {"soon", "tomorrow", "hope", "waiting", "excited"},
+	"disgust":      {"gross", "disgusting", "sick"},
+	"fear":         {"afraid", "scared", "anxious", "worried", "nervous"},
+	"joy":          {"happy", "glad", "great", "love", "good"},
+	"sadness":      {"sad", "lonely", "tired", "cry", "miss"},
+	"surprise":     {"surprised", "wow", "sudden", "unexpected"},
+	"trust":        {"trust", "friend", "safe", "believe"},
+}
+
+// NRCLex fakes the sentiment API by counting words from a small lexicon.
+func NRCLex(w http.ResponseWriter, r *http.Request) {
+
+	text := r.FormValue("text")
+	counts := map[string]float64{}
+	total := 0.0
+
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+
+		word = strings.Trim(word, ".,!?;:\"'")
+
+		for emotion, words := range emotionWords {
+			for _, w := range words {
+				if w == word {
+					counts[emotion]++
+					total++
+				}
+			}
+		}
+	}
+
+	if total > 0 {
+		for emotion := range counts {
+			counts[emotion] /= total
+		}
+	}
+
+	positive := counts["joy"] + counts["trust"] + counts["anticipation"]
+	negative := counts["anger"] + counts["disgust"] + counts["fear"] + counts["sadness"]
+
+	writeJSON(w, http.StatusOK, nrclex.APIResponse{
+		Text: text,
+		EmotionScore: nrclex.EmotionScores{
+			Anger:        counts["anger"],
+			Anticipation: counts["anticipation"],
+			Disgust:      counts["disgust"],
+			Fear:         counts["fear"],
+			Joy:          counts["joy"],
+			Sadness:      counts["sadness"],
+			Surprise:     counts["surprise"],
+			Trust:        counts["trust"],
+			Positive:     positive,
+			Negative:     negative,
+		},
+		VaderEmotionScore: nrclex.VaderEmotionScore{
+			Compound: positive - negative,
+			Pos:      positive,
+			Neg:      negative,
+			Neu:      1 - math.Min(1, positive+negative),
+		},
+	})
+}
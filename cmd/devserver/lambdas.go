@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda/messages"
+)
+
+// The lambdas the devserver runs. The dead letter lambda is left out as
+// there is no local dead letter queue to feed it, and the export lambdas
+// as there is no local bucket to keep exports in.
+const (
+	LambdaAuthorizer = "authorizer"
+	LambdaLogin      = "login"
+	LambdaManageUser = "manage_user"
+	LambdaSignupOTP  = "signup_otp"
+	LambdaReceiveSMS = "receive_sms"
+	LambdaStatusSMS  = "status_sms"
+	LambdaSendSMS    = "send_sms"
+	LambdaFactFinder = "factfinder"
+	LambdaEmotions   = "emotions"
+	LambdaNudgeSMS   = "nudge_sms"
+	LambdaWebChat    = "webchat"
+
+	LambdaAnalytics       = "analytics"
+	LambdaAnalyticsRollup = "analytics_rollup"
+	LambdaMood            = "mood"
+)
+
+var Lambdas = []string{
+	LambdaAuthorizer,
+	LambdaLogin,
+	LambdaManageUser,
+	LambdaSignupOTP,
+	LambdaReceiveSMS,
+	LambdaStatusSMS,
+	LambdaSendSMS,
+	LambdaFactFinder,
+	LambdaEmotions,
+	LambdaNudgeSMS,
+	LambdaWebChat,
+	LambdaAnalytics,
+	LambdaAnalyticsRollup,
+	LambdaMood,
+}
+
+const (
+	envTwilioAuthToken   = "TWILIO_AUTH_TOKEN"
+	envTwilioPhoneNumber = "TWILIO_PHONE_NUMBER"
+
+	// The AWS Lambda Go runtime serves the handler over net/rpc on this
+	// port when it is set, which is how the go1.x runtime invoked it.
+	envLambdaServerPort = "_LAMBDA_SERVER_PORT"
+
+	startTimeout = 10 * time.Second
+)
+
+// Environment returns the environment the lambdas run with. Settings
+// from the devserver's own environment, such as the database, are passed
+// through. Anything that would reach AWS or a third party is pointed at
+// the devserver.
+func Environment(baseURL, outboxPath string) map[string]string {
+
+	env := map[string]string{}
+
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+
+	defaults := map[string]string{
+		"OPENAI_API_KEY":            "devserver",
+		"SMS_QUEUE_URL":             "devserver",
+		"SNS_TOPIC_ARN":             InboundTopicARN,
+		"OUTBOUND_SNS_TOPIC_ARN":    OutboundTopicARN,
+		"TWILIO_SID":                "ACdevserver",
+		envTwilioAuthToken:          "devserver",
+		envTwilioPhoneNumber:        "+15555550000",
+		"TWILIO_VERIFY_SERVICE_SID": "VAdevserver",
+		"CHAT_MODEL_NAME":           "gpt-4o",
+		"AWS_REGION":                "us-west-2",
+		"TRACE_EXPORTER":            "stdout",
+		"METRICS_FORMAT":            "prometheus",
+	}
+
+	for key, value := range defaults {
+		if env[key] == "" {
+			env[key] = value
+		}
+	}
+
+	overrides := map[string]string{
+		"AWS_ACCESS_KEY_ID":          "devserver",
+		"AWS_SECRET_ACCESS_KEY":      "devserver",
+		"AWS_SESSION_TOKEN":          "",
+		"AWS_ENDPOINT_URL_SNS":       baseURL + snsPath,
+		"AWS_ENDPOINT_URL_SSM":       baseURL + ssmPath,
+		"OPENAI_BASE_URL":            baseURL + openAIPath,
+		"NRCLEX_URL":                 baseURL + nrclexPath,
+		"CONNECTIVITY_CHECK_URL":     baseURL + healthPath,
+		"TWILIO_STATUS_CALLBACK_URL": baseURL + "/sms-status",
+		"MESSAGING_PROVIDER":         "fake",
+		"MESSAGING_FAKE_OUTBOX_PATH": outboxPath,
+	}
+
+	for key, value := range overrides {
+		env[key] = value
+	}
+
+	return env
+}
+
+// Build compiles [lambdas] from the repository at [root] into [dir].
+func Build(root, dir string, lambdas []string) error {
+
+	args := []string{"build", "-o", dir + string(filepath.Separator)}
+
+	for _, name := range lambdas {
+		args = append(args, "./lambdas/"+name)
+	}
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error building lambdas: %w", err)
+	}
+
+	return nil
+}
+
+// Function is a running lambda.
+type Function struct {
+	Name string
+
+	cmd    *exec.Cmd
+	client *rpc.Client
+
+	// A lambda instance handles one event at a time
+	mu sync.Mutex
+}
+
+// Functions runs the lambda binaries in a directory.
+type Functions struct {
+	dir     string
+	env     []string
+	log     io.Writer
+	logMu   sync.Mutex
+	timeout time.Duration
+
+	mu        sync.Mutex
+	functions map[string]*Function
+}
+
+func NewFunctions(dir string, env map[string]string, log io.Writer, timeout time.Duration) *Functions {
+
+	environ := make([]string, 0, len(env))
+
+	for key, value := range env {
+		environ = append(environ, key+"="+value)
+	}
+
+	return &Functions{
+		dir:       dir,
+		env:       environ,
+		log:       log,
+		timeout:   timeout,
+		functions: map[string]*Function{},
+	}
+}
+
+// Start runs each of [lambdas] and waits for them to accept invocations.
+func (f *Functions) Start(lambdas []string) error {
+
+	for _, name := range lambdas {
+
+		function, err := f.start(name)
+
+		if err != nil {
+			return fmt.Errorf("error starting %s: %w", name, err)
+		}
+
+		f.mu.Lock()
+		f.functions[name] = function
+		f.mu.Unlock()
+	}
+
+	return nil
+}
+
+func (f *Functions) start(name string) (*Function, error) {
+
+	port, err := freePort()
+
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command(filepath.Join(f.dir, name))
+	cmd.Env = append(f.env, envLambdaServerPort+"="+strconv.Itoa(port))
+
+	logger := &logWriter{functions: f, name: name}
+	cmd.Stdout = logger
+	cmd.Stderr = logger
+
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	exited := make(chan error, 1)
+
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	address := "localhost:" + strconv.Itoa(port)
+	deadline := time.Now().Add(startTimeout)
+
+	for {
+		client, err := rpc.Dial("tcp", address)
+
+		if err == nil {
+			return &Function{Name: name, cmd: cmd, client: client}, nil
+		}
+
+		select {
+		case err := <-exited:
+			return nil, fmt.Errorf("exited before it was ready (%v), see the log for details", err)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		if time.Now().After(deadline) {
+			_ = cmd.Process.Kill()
+
+			return nil, fmt.Errorf("not ready after %s", startTimeout)
+		}
+	}
+}
+
+// logWriter prefixes each line a lambda logs with its name.
+type logWriter struct {
+	functions *Functions
+	name      string
+	partial   []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+
+	w.partial = append(w.partial, p...)
+
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+
+		if i < 0 {
+			return len(p), nil
+		}
+
+		w.functions.logMu.Lock()
+		_, _ = fmt.Fprintf(w.functions.log, "[%s] %s\n", w.name, w.partial[:i])
+		w.functions.logMu.Unlock()
+
+		w.partial = w.partial[i+1:]
+	}
+}
+
+// Invoke sends [event] to the lambda called [name] and unmarshals its
+// response into [response], which may be nil.
+func (f *Functions) Invoke(name string, event, response interface{}) error {
+
+	f.mu.Lock()
+	function, ok := f.functions[name]
+	f.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown lambda %s", name)
+	}
+
+	payload, err := json.Marshal(event)
+
+	if err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(f.timeout)
+
+	request := &messages.InvokeRequest{
+		Payload:   payload,
+		RequestId: newID(),
+		Deadline: messages.InvokeRequest_Timestamp{
+			Seconds: deadline.Unix(),
+			Nanos:   int64(deadline.Nanosecond()),
+		},
+		InvokedFunctionArn: "arn:aws:lambda:us-west-2:000000000000:function:" + name,
+	}
+
+	var result messages.InvokeResponse
+
+	function.mu.Lock()
+	err = function.client.Call("Function.Invoke", request, &result)
+	function.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("error invoking %s: %w", name, err)
+	}
+
+	if result.Error != nil {
+		return fmt.Errorf("%s failed: %s", name, result.Error.Message)
+	}
+
+	if response == nil || len(result.Payload) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(result.Payload, response)
+}
+
+// Stop kills every running lambda.
+func (f *Functions) Stop() {
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, function := range f.functions {
+		_ = function.client.Close()
+		_ = function.cmd.Process.Kill()
+	}
+}
+
+func freePort() (int, error) {
+
+	listener, err := net.Listen("tcp", "localhost:0")
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer listener.Close()
+
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+// newID returns a random identifier for requests and messages.
+func newID() string {
+
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
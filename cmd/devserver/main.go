@@ -0,0 +1,125 @@
+// Command devserver runs the whole system on a developer's machine.
+//
+// It builds every lambda, runs each one as a local process and puts an
+// HTTP server in front of them that stands in for API Gateway, the custom
+// authorizer, SNS and the SQS queues subscribed to it. Twilio, OpenAI,
+// NRCLex and Parameter Store are replaced by local fakes, so the only
+// thing it needs is the MySQL database from docker-compose.
+//
+// Start the database, source your .env and run it from the repository root:
+//
+//	docker-compose up -d
+//	go run ./cmd/devserver -from +15555550100
+//
+// Each line typed at the prompt is texted to the bot from the -from
+// number, and the bot's replies are printed as they are sent. Lambda logs
+// are written to the file named by -log.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func main() {
+
+	var (
+		addr    = flag.String("addr", "localhost:3000", "address to serve the API on")
+		from    = flag.String("from", "+15555550100", "phone number to text the bot from")
+		root    = flag.String("root", ".", "repository root")
+		logPath = flag.String("log", "", "file to write lambda logs to (default devserver.log in the work directory)")
+		timeout = flag.Duration("timeout", 60*time.Second, "lambda invocation timeout")
+	)
+
+	flag.Parse()
+
+	if err := run(*addr, *from, *root, *logPath, *timeout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(addr, from, root, logPath string, timeout time.Duration) error {
+
+	workDir, err := os.MkdirTemp("", "equilibria-devserver")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.RemoveAll(workDir)
+
+	if logPath == "" {
+		logPath = filepath.Join(workDir, "devserver.log")
+	}
+
+	logFile, err := os.Create(logPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer logFile.Close()
+
+	listener, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return err
+	}
+
+	baseURL := "http://" + listener.Addr().String()
+	outboxPath := filepath.Join(workDir, "outbox.jsonl")
+	env := Environment(baseURL, outboxPath)
+
+	fmt.Printf("Building lambdas into %s...\n", workDir)
+
+	if err := Build(root, workDir, Lambdas); err != nil {
+		return err
+	}
+
+	keys, err := NewParameterStore()
+
+	if err != nil {
+		return err
+	}
+
+	functions := NewFunctions(workDir, env, logFile, timeout)
+	defer functions.Stop()
+
+	fmt.Printf("Starting lambdas, logging to %s...\n", logPath)
+
+	if err := functions.Start(Lambdas); err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Handler: NewServer(functions, keys),
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}()
+
+	defer server.Close()
+
+	phone := &Phone{
+		BaseURL:    baseURL,
+		From:       from,
+		To:         env[envTwilioPhoneNumber],
+		AuthToken:  env[envTwilioAuthToken],
+		OutboxPath: outboxPath,
+		Functions:  functions,
+	}
+
+	fmt.Printf("Serving on %s. Texting the bot from %s.\n", baseURL, from)
+
+	return phone.Run(os.Stdin, os.Stdout)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+)
+
+const (
+	outboxPollInterval = 250 * time.Millisecond
+
+	prompt = "you> "
+	help   = `Type a message to text the bot. Commands:
+  /nudge   run the nudger, as the hourly schedule would
+  /rollup  roll up today's analytics, and the two days before
+  /help    show this help
+  /quit    stop the devserver
+`
+)
+
+// Phone is the developer's handset. It texts the bot through the
+// receive webhook, prints what the fake messaging provider sends and
+// reports each message as delivered, as Twilio would.
+type Phone struct {
+	BaseURL    string
+	From       string
+	To         string
+	AuthToken  string
+	OutboxPath string
+	Functions  Invoker
+	Client     *http.Client
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// Run reads messages from [in] until it is closed or the user quits.
+func (p *Phone) Run(in io.Reader, out io.Writer) error {
+
+	p.out = out
+
+	if p.Client == nil {
+		p.Client = http.DefaultClient
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go p.watchOutbox(done)
+
+	p.print("%s%s", help, prompt)
+
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		switch line {
+		case "":
+		case "/quit":
+			return nil
+		case "/help":
+			p.print("%s", help)
+		case "/nudge":
+			if err := p.Nudge(); err != nil {
+				p.print("Error running the nudger: %s\n", err)
+			}
+		case "/rollup":
+			if err := p.Rollup(); err != nil {
+				p.print("Error rolling up analytics: %s\n", err)
+			}
+		default:
+			if err := p.Text(line); err != nil {
+				p.print("Error sending message: %s\n", err)
+			}
+		}
+
+		p.print("%s", prompt)
+	}
+
+	return scanner.Err()
+}
+
+// Text sends [body] to the bot as an inbound SMS.
+func (p *Phone) Text(body string) error {
+
+	sid := "SM" + newID()
+
+	form := url.Values{
+		"AccountSid":    {"ACdevserver"},
+		"ApiVersion":    {"2010-04-01"},
+		"Body":          {body},
+		"From":          {p.From},
+		"MessageSid":    {sid},
+		"NumMedia":      {"0"},
+		"NumSegments":   {"1"},
+		"SmsMessageSid": {sid},
+		"SmsSid":        {sid},
+		"SmsStatus":     {"received"},
+		"To":            {p.To},
+	}
+
+	return p.post("/sms-receive", form)
+}
+
+// Nudge invokes the nudger with the event its schedule sends.
+func (p *Phone) Nudge() error {
+	return p.Functions.Invoke(LambdaNudgeSMS, scheduledEvent("{}"), nil)
+}
+
+// Rollup invokes the analytics rollup for the last few days, including
+// today, so metrics can be checked without waiting for midnight.
+func (p *Phone) Rollup() error {
+
+	from := time.Now().UTC().AddDate(0, 0, -2).Format("2006-01-02")
+	to := time.Now().UTC().Format("2006-01-02")
+
+	return p.Functions.Invoke(LambdaAnalyticsRollup,
+		scheduledEvent(fmt.Sprintf(`{"from": %q, "to": %q}`, from, to)), nil)
+}
+
+// scheduledEvent is the event EventBridge sends on a schedule.
+func scheduledEvent(detail string) events.EventBridgeEvent {
+
+	return events.EventBridgeEvent{
+		Version:    "0",
+		ID:         newID(),
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		AccountID:  "000000000000",
+		Time:       time.Now().UTC(),
+		Region:     "us-west-2",
+		Detail:     json.RawMessage(detail),
+	}
+}
+
+// post sends a signed webhook to [path].
+func (p *Phone) post(path string, form url.Values) error {
+
+	endpoint := p.BaseURL + path
+	body := form.Encode()
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("X-Twilio-Signature", Sign(p.AuthToken, p.signedURL(path), form))
+
+	response, err := p.Client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		message, _ := io.ReadAll(response.Body)
+
+		return fmt.Errorf("%s returned %d: %s", path, response.StatusCode, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
+
+// signedURL is the URL the lambdas validate signatures against, which
+// includes the API Gateway stage.
+func (p *Phone) signedURL(path string) string {
+	return p.BaseURL + "/" + stage + path
+}
+
+// Sign computes the X-Twilio-Signature for a form posted to [endpoint].
+// See: https://www.twilio.com/docs/usage/webhooks/webhooks-security
+func Sign(authToken, endpoint string, form url.Values) string {
+
+	keys := make([]string, 0, len(form))
+
+	for key := range form {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	payload := endpoint
+
+	for _, key := range keys {
+		payload += key + form.Get(key)
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(payload))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// watchOutbox prints messages as the fake provider sends them and posts
+// a delivered status callback for each.
+func (p *Phone) watchOutbox(done <-chan struct{}) {
+
+	var offset int64
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-time.After(outboxPollInterval):
+		}
+
+		sent, next, err := ReadOutbox(p.OutboxPath, offset)
+
+		if err != nil {
+			p.print("Error reading outbox: %s\n", err)
+
+			continue
+		}
+
+		offset = next
+
+		for _, msg := range sent {
+
+			p.print("\rbot> %s\n%s", msg.Body, prompt)
+
+			msg.Status = "delivered"
+			callback := messaging.StatusCallbackRequest(msg)
+			form, _ := url.ParseQuery(callback.Body)
+
+			if err := p.post(callback.Path, form); err != nil {
+				p.print("Error posting status for %s: %s\n", msg.ReferenceID, err)
+			}
+		}
+	}
+}
+
+// ReadOutbox returns the messages written to the outbox at [path] after
+// [offset], and the offset to read from next time.
+func ReadOutbox(path string, offset int64) ([]messaging.FakeMessage, int64, error) {
+
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return nil, offset, nil
+	}
+
+	if err != nil {
+		return nil, offset, err
+	}
+
+	defer file.Close()
+
+	if _, err = file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, err
+	}
+
+	var sent []messaging.FakeMessage
+
+	reader := bufio.NewReader(file)
+
+	for {
+		line, err := reader.ReadBytes('\n')
+
+		// Leave a partly written line for the next read
+		if err == io.EOF {
+			return sent, offset, nil
+		}
+
+		if err != nil {
+			return sent, offset, err
+		}
+
+		offset += int64(len(line))
+
+		var msg messaging.FakeMessage
+
+		if err := json.Unmarshal(line, &msg); err != nil {
+			return sent, offset, err
+		}
+
+		sent = append(sent, msg)
+	}
+}
+
+func (p *Phone) print(format string, args ...interface{}) {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, _ = fmt.Fprintf(p.out, format, args...)
+}
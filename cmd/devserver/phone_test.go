@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/twilio/twilio-go/client"
+)
+
+func TestSign(t *testing.T) {
+
+	form := url.Values{
+		"Body":       {"Hello there"},
+		"From":       {"+15555550100"},
+		"MessageSid": {"SM123"},
+		"To":         {"+15555550000"},
+	}
+
+	endpoint := "http://localhost:3000/dev/sms-receive"
+	signature := Sign("devserver", endpoint, form)
+
+	validator := client.NewRequestValidator("devserver")
+
+	require.True(t, validator.ValidateBody(endpoint, []byte(form.Encode()), signature))
+	require.False(t, validator.ValidateBody(endpoint, []byte(form.Encode()), Sign("wrong", endpoint, form)))
+}
+
+func TestReadOutbox(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+
+	sent, offset, err := ReadOutbox(path, 0)
+	require.NoError(t, err)
+	require.Empty(t, sent)
+	require.Zero(t, offset)
+
+	first := `{"reference_id":"SM1","to":"+15555550100","body":"Hi"}` + "\n"
+	partial := `{"reference_id":"SM2"`
+
+	require.NoError(t, os.WriteFile(path, []byte(first+partial), 0o600))
+
+	sent, offset, err = ReadOutbox(path, 0)
+	require.NoError(t, err)
+	require.Len(t, sent, 1)
+	require.Equal(t, "Hi", sent[0].Body)
+	require.Equal(t, int64(len(first)), offset)
+
+	sent, _, err = ReadOutbox(path, offset)
+	require.NoError(t, err)
+	require.Empty(t, sent)
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	snsPath    = "/aws/sns"
+	ssmPath    = "/aws/ssm"
+	openAIPath = "/openai/v1"
+	nrclexPath = "/nrclex"
+	healthPath = "/healthz"
+
+	stage = "dev"
+)
+
+// Invoker invokes a lambda by name.
+type Invoker interface {
+	Invoke(name string, event, response interface{}) error
+}
+
+// Route maps an API Gateway resource to the lambda that handles it,
+// mirroring the api_gateway_*.tf files.
+type Route struct {
+	Resource string
+	Lambda   string
+
+	// Methods that go through the custom authorizer
+	Authorized []string
+}
+
+var Routes = []Route{
+	{Resource: "/sms-receive", Lambda: LambdaReceiveSMS},
+	{Resource: "/sms-status", Lambda: LambdaStatusSMS},
+	{Resource: "/login", Lambda: LambdaLogin},
+	{Resource: "/signup-otp", Lambda: LambdaSignupOTP},
+	{Resource: "/users", Lambda: LambdaManageUser},
+	{Resource: "/users/{userId}", Lambda: LambdaManageUser, Authorized: []string{http.MethodPut}},
+	{Resource: "/chat", Lambda: LambdaWebChat, Authorized: []string{http.MethodGet, http.MethodPost}},
+	{Resource: "/analytics", Lambda: LambdaAnalytics, Authorized: []string{http.MethodGet}},
+	{Resource: "/mood", Lambda: LambdaMood, Authorized: []string{http.MethodGet}},
+}
+
+// NewServer returns the devserver's HTTP handler. It serves the API and
+// the fakes the lambdas are pointed at.
+func NewServer(functions Invoker, keys *ParameterStore) http.Handler {
+
+	bus := &Bus{
+		Subscribers: map[string][]string{
+			InboundTopicARN:  {LambdaSendSMS, LambdaFactFinder, LambdaEmotions},
+			OutboundTopicARN: {LambdaEmotions},
+		},
+		Deliver: func(subscriber string, event events.SQSEvent) {
+			if err := functions.Invoke(subscriber, event, nil); err != nil {
+				fmt.Fprintf(os.Stderr, "Error delivering to %s: %s\n", subscriber, err)
+			}
+		},
+	}
+
+	mux := http.NewServeMux()
+
+	// Version 1 of the SDK appends a slash to the endpoint, version 2 doesn't
+	for _, path := range []string{snsPath, snsPath + "/"} {
+		mux.Handle(path, bus)
+	}
+
+	for _, path := range []string{ssmPath, ssmPath + "/"} {
+		mux.Handle(path, keys)
+	}
+	mux.Handle(openAIPath+"/", http.StripPrefix(openAIPath, NewOpenAI()))
+	mux.HandleFunc(nrclexPath, NRCLex)
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle("/", &Gateway{Functions: functions, Routes: Routes})
+
+	return mux
+}
+
+// Gateway stands in for API Gateway, converting HTTP requests to proxy
+// events for the lambda that owns the route.
+type Gateway struct {
+	Functions Invoker
+	Routes    []Route
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	route, pathParameters, ok := g.match(r.URL.Path)
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Missing Authentication Token"})
+
+		return
+	}
+
+	request, err := NewProxyRequest(r, route.Resource, pathParameters)
+
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+
+		return
+	}
+
+	if route.requiresAuthorization(r.Method) {
+
+		authorizer, err := g.authorize(request)
+
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+
+			return
+		}
+
+		if authorizer == nil {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"message": "User is not authorized to access this resource",
+			})
+
+			return
+		}
+
+		request.RequestContext.Authorizer = authorizer
+	}
+
+	var response events.APIGatewayProxyResponse
+
+	if err = g.Functions.Invoke(route.Lambda, request, &response); err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": err.Error()})
+
+		return
+	}
+
+	WriteProxyResponse(w, response)
+}
+
+// authorize runs the custom authorizer for [request], returning the
+// context it adds to the request or nil if access was denied.
+func (g *Gateway) authorize(request events.APIGatewayProxyRequest) (map[string]interface{}, error) {
+
+	authRequest := events.APIGatewayCustomAuthorizerRequest{
+		Type:               "TOKEN",
+		AuthorizationToken: header(request.Headers, "Authorization"),
+		MethodArn: fmt.Sprintf(
+			"arn:aws:execute-api:us-west-2:000000000000:devserver/%s/%s%s",
+			stage, request.HTTPMethod, request.Path,
+		),
+	}
+
+	var response events.APIGatewayCustomAuthorizerResponse
+
+	if err := g.Functions.Invoke(LambdaAuthorizer, authRequest, &response); err != nil {
+		return nil, err
+	}
+
+	for _, statement := range response.PolicyDocument.Statement {
+		if statement.Effect != "Allow" {
+			return nil, nil
+		}
+	}
+
+	if len(response.PolicyDocument.Statement) == 0 {
+		return nil, nil
+	}
+
+	authorizer := map[string]interface{}{"principalId": response.PrincipalID}
+
+	for key, value := range response.Context {
+		authorizer[key] = value
+	}
+
+	return authorizer, nil
+}
+
+func (g *Gateway) match(path string) (Route, map[string]string, bool) {
+
+	path = strings.TrimPrefix(path, "/"+stage)
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	for _, route := range g.Routes {
+
+		resource := strings.Split(strings.Trim(route.Resource, "/"), "/")
+
+		if len(resource) != len(parts) {
+			continue
+		}
+
+		parameters := map[string]string{}
+		matched := true
+
+		for i, segment := range resource {
+
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				parameters[strings.Trim(segment, "{}")] = parts[i]
+
+				continue
+			}
+
+			if segment != parts[i] {
+				matched = false
+
+				break
+			}
+		}
+
+		if matched {
+			return route, parameters, true
+		}
+	}
+
+	return Route{}, nil, false
+}
+
+func (r Route) requiresAuthorization(method string) bool {
+
+	for _, m := range r.Authorized {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewProxyRequest converts [r] to the event API Gateway would send.
+func NewProxyRequest(r *http.Request, resource string, pathParameters map[string]string) (events.APIGatewayProxyRequest, error) {
+
+	body, err := io.ReadAll(r.Body)
+
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	headers := map[string]string{}
+
+	for key, values := range r.Header {
+		headers[key] = values[0]
+	}
+
+	// The Twilio signature is computed over the public URL, which the
+	// lambdas rebuild from these headers
+	headers["Host"] = r.Host
+	headers["X-Forwarded-Proto"] = "http"
+
+	query := map[string]string{}
+
+	for key, values := range r.URL.Query() {
+		query[key] = values[0]
+	}
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            strings.TrimPrefix(r.URL.Path, "/"+stage),
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           query,
+		MultiValueQueryStringParameters: r.URL.Query(),
+		PathParameters:                  pathParameters,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Stage:      stage,
+			RequestID:  newID(),
+			HTTPMethod: r.Method,
+			Path:       "/" + stage + strings.TrimPrefix(r.URL.Path, "/"+stage),
+		},
+		Body: string(body),
+	}, nil
+}
+
+// WriteProxyResponse writes a lambda's proxy response to [w].
+func WriteProxyResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+
+	for key, value := range response.Headers {
+		w.Header().Set(key, value)
+	}
+
+	for key, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+
+	body := []byte(response.Body)
+
+	if response.IsBase64Encoded {
+		if decoded, err := base64.StdEncoding.DecodeString(response.Body); err == nil {
+			body = decoded
+		}
+	}
+
+	if response.StatusCode == 0 {
+		response.StatusCode = http.StatusOK
+	}
+
+	w.WriteHeader(response.StatusCode)
+	_, _ = w.Write(body)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func header(headers map[string]string, name string) string {
+
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
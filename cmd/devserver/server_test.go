@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+)
+
+// fakeInvoker records the events it receives and answers with canned
+// responses.
+type fakeInvoker struct {
+	effect  string
+	request events.APIGatewayProxyRequest
+}
+
+func (f *fakeInvoker) Invoke(name string, event, response interface{}) error {
+
+	var payload interface{}
+
+	switch name {
+	case LambdaAuthorizer:
+		payload = events.APIGatewayCustomAuthorizerResponse{
+			PrincipalID: "user",
+			PolicyDocument: events.APIGatewayCustomAuthorizerPolicy{
+				Statement: []events.IAMPolicyStatement{{Effect: f.effect}},
+			},
+			Context: map[string]interface{}{lib.AuthorizerUserIDKey: "42"},
+		}
+	default:
+		f.request = event.(events.APIGatewayProxyRequest)
+		payload = events.APIGatewayProxyResponse{
+			StatusCode: http.StatusCreated,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       `{"lambda":"` + name + `"}`,
+		}
+	}
+
+	body, err := json.Marshal(payload)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, response)
+}
+
+func TestGateway_PathParameters(t *testing.T) {
+
+	invoker := &fakeInvoker{effect: "Allow"}
+	gateway := &Gateway{Functions: invoker, Routes: Routes}
+
+	request := httptest.NewRequest(http.MethodGet, "/dev/users/7", nil)
+	recorder := httptest.NewRecorder()
+
+	gateway.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.JSONEq(t, `{"lambda":"manage_user"}`, recorder.Body.String())
+	require.Equal(t, "/users/{userId}", invoker.request.Resource)
+	require.Equal(t, "/users/7", invoker.request.Path)
+	require.Equal(t, "7", invoker.request.PathParameters["userId"])
+	require.Nil(t, invoker.request.RequestContext.Authorizer)
+}
+
+func TestGateway_Authorized(t *testing.T) {
+
+	invoker := &fakeInvoker{effect: "Allow"}
+	gateway := &Gateway{Functions: invoker, Routes: Routes}
+
+	request := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"body":"hi"}`))
+	request.Header.Set("Authorization", "Bearer token")
+	recorder := httptest.NewRecorder()
+
+	gateway.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusCreated, recorder.Code)
+	require.Equal(t, `{"body":"hi"}`, invoker.request.Body)
+	require.Equal(t, "42", invoker.request.RequestContext.Authorizer[lib.AuthorizerUserIDKey])
+}
+
+func TestGateway_Denied(t *testing.T) {
+
+	invoker := &fakeInvoker{effect: "Deny"}
+	gateway := &Gateway{Functions: invoker, Routes: Routes}
+
+	request := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(`{"body":"hi"}`))
+	recorder := httptest.NewRecorder()
+
+	gateway.ServeHTTP(recorder, request)
+
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+	require.Empty(t, invoker.request.Body)
+}
+
+func TestGateway_NotFound(t *testing.T) {
+
+	gateway := &Gateway{Functions: &fakeInvoker{}, Routes: Routes}
+	recorder := httptest.NewRecorder()
+
+	gateway.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nope", nil))
+
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+)
+
+// The topics the lambdas publish to.
+const (
+	InboundTopicARN  = "arn:aws:sns:us-west-2:000000000000:sms-inbound-topic"
+	OutboundTopicARN = "arn:aws:sns:us-west-2:000000000000:sms-outbound-topic"
+)
+
+// Bus stands in for the SNS topics and the SQS queues subscribed to them.
+// It speaks just enough of the SNS query API for the SDK's Publish call,
+// and delivers each message to every subscriber of its topic the way
+// their queue's event source mapping would.
+type Bus struct {
+	// Subscribers are the lambdas subscribed to each topic, by ARN
+	Subscribers map[string][]string
+	Deliver     func(subscriber string, event events.SQSEvent)
+}
+
+type publishResponse struct {
+	XMLName   xml.Name `xml:"PublishResponse"`
+	Namespace string   `xml:"xmlns,attr"`
+	MessageID string   `xml:"PublishResult>MessageId"`
+	RequestID string   `xml:"ResponseMetadata>RequestId"`
+}
+
+type errorResponse struct {
+	XMLName   xml.Name `xml:"ErrorResponse"`
+	Type      string   `xml:"Error>Type"`
+	Code      string   `xml:"Error>Code"`
+	Message   string   `xml:"Error>Message"`
+	RequestID string   `xml:"RequestId"`
+}
+
+func (b *Bus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if err := r.ParseForm(); err != nil {
+		writeXML(w, http.StatusBadRequest, errorResponse{
+			Type: "Sender", Code: "MalformedQueryString", Message: err.Error(), RequestID: newID(),
+		})
+
+		return
+	}
+
+	if action := r.PostForm.Get("Action"); action != "Publish" {
+		writeXML(w, http.StatusBadRequest, errorResponse{
+			Type: "Sender", Code: "InvalidAction", Message: "unsupported action " + action, RequestID: newID(),
+		})
+
+		return
+	}
+
+	topicARN := r.PostForm.Get("TopicArn")
+	subscribers, ok := b.Subscribers[topicARN]
+
+	if !ok {
+		writeXML(w, http.StatusNotFound, errorResponse{
+			Type: "Sender", Code: "NotFound", Message: "Topic does not exist", RequestID: newID(),
+		})
+
+		return
+	}
+
+	messageID := newID()
+
+	record := sqs.SQSEventRecord{
+		Type:      "Notification",
+		MessageId: messageID,
+		TopicArn:  topicARN,
+		Message:   r.PostForm.Get("Message"),
+		Timestamp: time.Now().UTC(),
+
+		MessageAttributes: MessageAttributes(r.PostForm),
+	}
+
+	for _, subscriber := range subscribers {
+
+		event, err := NewSQSEvent(subscriber, record)
+
+		if err != nil {
+			writeXML(w, http.StatusInternalServerError, errorResponse{
+				Type: "Receiver", Code: "InternalError", Message: err.Error(), RequestID: newID(),
+			})
+
+			return
+		}
+
+		// Queues deliver asynchronously, so the publisher doesn't wait
+		go b.Deliver(subscriber, event)
+	}
+
+	writeXML(w, http.StatusOK, publishResponse{
+		Namespace: "http://sns.amazonaws.com/doc/2010-03-31/",
+		MessageID: messageID,
+		RequestID: newID(),
+	})
+}
+
+// MessageAttributes reads the attributes of a published message, which
+// the query API sends as numbered MessageAttributes.entry.N fields.
+func MessageAttributes(form url.Values) map[string]sqs.SNSMessageAttribute {
+
+	var attributes map[string]sqs.SNSMessageAttribute
+
+	for i := 1; ; i++ {
+
+		prefix := fmt.Sprintf("MessageAttributes.entry.%d.", i)
+		name := form.Get(prefix + "Name")
+
+		if name == "" {
+			return attributes
+		}
+
+		if attributes == nil {
+			attributes = map[string]sqs.SNSMessageAttribute{}
+		}
+
+		attributes[name] = sqs.SNSMessageAttribute{
+			Type:  form.Get(prefix + "Value.DataType"),
+			Value: form.Get(prefix + "Value.StringValue"),
+		}
+	}
+}
+
+// NewSQSEvent wraps [record] in the event the [subscriber] lambda receives
+// from its queue.
+func NewSQSEvent(subscriber string, record sqs.SQSEventRecord) (events.SQSEvent, error) {
+
+	body, err := json.Marshal(record)
+
+	if err != nil {
+		return events.SQSEvent{}, err
+	}
+
+	queue := strings.ReplaceAll(subscriber, "_", "-") + "-queue"
+
+	return events.SQSEvent{
+		Records: []events.SQSMessage{{
+			MessageId:      newID(),
+			ReceiptHandle:  newID(),
+			Body:           string(body),
+			EventSource:    "aws:sqs",
+			EventSourceARN: "arn:aws:sqs:us-west-2:000000000000:" + queue,
+			AWSRegion:      "us-west-2",
+			Attributes: map[string]string{
+				"ApproximateReceiveCount": "1",
+				"SentTimestamp":           strconv.FormatInt(time.Now().UnixMilli(), 10),
+			},
+		}},
+	}, nil
+}
+
+func writeXML(w http.ResponseWriter, statusCode int, body interface{}) {
+
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(statusCode)
+	_ = xml.NewEncoder(w).Encode(body)
+}
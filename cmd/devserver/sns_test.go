@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+)
+
+func TestBus_Publish(t *testing.T) {
+
+	delivered := make(chan events.SQSEvent, 2)
+
+	bus := &Bus{
+		Subscribers: map[string][]string{
+			InboundTopicARN:  {LambdaSendSMS, LambdaFactFinder},
+			OutboundTopicARN: {LambdaEmotions},
+		},
+		Deliver: func(_ string, event events.SQSEvent) {
+			delivered <- event
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(snsPath, bus)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := sns.New(sns.Options{
+		Region:       "us-west-2",
+		BaseEndpoint: aws.String(server.URL + snsPath),
+		Credentials:  credentials.NewStaticCredentialsProvider("devserver", "devserver", ""),
+	})
+
+	traceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	output, err := client.Publish(context.Background(), &sns.PublishInput{
+		Message:           aws.String(`{"body":"hello"}`),
+		TopicArn:          aws.String(InboundTopicARN),
+		MessageAttributes: sqs.MessageAttributes(map[string]string{"traceparent": traceParent}),
+	})
+
+	require.NoError(t, err)
+	require.NotEmpty(t, aws.ToString(output.MessageId))
+
+	for range bus.Subscribers[InboundTopicARN] {
+		select {
+		case event := <-delivered:
+			require.Len(t, event.Records, 1)
+
+			var record sqs.SQSEventRecord
+
+			require.NoError(t, json.Unmarshal([]byte(event.Records[0].Body), &record))
+			require.Equal(t, "Notification", record.Type)
+			require.Equal(t, `{"body":"hello"}`, record.Message)
+			require.Equal(t, aws.ToString(output.MessageId), record.MessageId)
+			require.Equal(t, map[string]string{"traceparent": traceParent}, record.Attributes())
+
+		case <-time.After(time.Second):
+			t.Fatal("message was not delivered")
+		}
+	}
+
+	select {
+	case <-delivered:
+		t.Fatal("message was delivered to a subscriber of another topic")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, err = client.Publish(context.Background(), &sns.PublishInput{
+		Message:  aws.String(`{"body":"hello"}`),
+		TopicArn: aws.String("arn:aws:sns:us-west-2:000000000000:missing-topic"),
+	})
+
+	require.Error(t, err, "publishing to an unknown topic should fail")
+}
+
+func TestNewSQSEvent(t *testing.T) {
+
+	event, err := NewSQSEvent(LambdaSendSMS, sqs.SQSEventRecord{Message: "hello"})
+
+	require.NoError(t, err)
+	require.Equal(t, "arn:aws:sqs:us-west-2:000000000000:send-sms-queue", event.Records[0].EventSourceARN)
+}
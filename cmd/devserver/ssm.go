@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/jwt"
+)
+
+const signingKeyBits = 2048
+
+// ParameterStore stands in for the AWS Parameter Store the login and
+// authorizer lambdas read the JWT signing keys from. It generates a new
+// key pair each time the devserver starts.
+type ParameterStore struct {
+	Parameters map[string]string
+}
+
+func NewParameterStore() (*ParameterStore, error) {
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, signingKeyBits)
+
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParameterStore{
+		Parameters: map[string]string{
+			jwt.ParameterStorePrivateKeyName: string(pem.EncodeToMemory(&pem.Block{
+				Type:  "RSA PRIVATE KEY",
+				Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+			})),
+			jwt.ParameterStorePublicKeyName: string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: publicKey,
+			})),
+		},
+	}, nil
+}
+
+type getParameterRequest struct {
+	Name string `json:"Name"`
+}
+
+type parameter struct {
+	Name  string `json:"Name"`
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// ServeHTTP answers the SDK's GetParameter calls.
+func (p *ParameterStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	if target := r.Header.Get("X-Amz-Target"); target != "AmazonSSM.GetParameter" {
+		writeAWSError(w, "InvalidAction", "unsupported action "+target)
+
+		return
+	}
+
+	var request getParameterRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeAWSError(w, "ValidationException", err.Error())
+
+		return
+	}
+
+	value, ok := p.Parameters[request.Name]
+
+	if !ok {
+		writeAWSError(w, "ParameterNotFound", "parameter "+request.Name+" not found")
+
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]parameter{
+		"Parameter": {Name: request.Name, Type: "SecureString", Value: value},
+	})
+}
+
+func writeAWSError(w http.ResponseWriter, errorType, message string) {
+
+	w.WriteHeader(http.StatusBadRequest)
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"__type":  errorType,
+		"message": message,
+	})
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	rotator "github.com/kmesiab/go-key-rotator"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/jwt"
+)
+
+func TestParameterStore_GetParameter(t *testing.T) {
+
+	keys, err := NewParameterStore()
+	require.NoError(t, err)
+
+	server := httptest.NewServer(NewServer(&fakeInvoker{}, keys))
+	defer server.Close()
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithRegion("us-west-2").
+		WithEndpoint(server.URL + ssmPath).
+		WithCredentials(credentials.NewStaticCredentials("devserver", "devserver", "")))
+	require.NoError(t, err)
+
+	keyRotator := rotator.NewKeyRotator(rotator.NewAWSParameterStore(sess))
+
+	privateKey, err := keyRotator.GetCurrentRSAPrivateKey(jwt.ParameterStorePrivateKeyName)
+	require.NoError(t, err)
+
+	publicKey, err := keyRotator.GetCurrentRSAPublicKey(jwt.ParameterStorePublicKeyName)
+	require.NoError(t, err)
+	require.True(t, privateKey.PublicKey.Equal(publicKey))
+
+	_, err = keyRotator.GetCurrentRSAPublicKey("missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,233 @@
+// Command migrate applies and rolls back the database migrations, which
+// are built into it, and checks the database schema against the models.
+// It records migrations in goose_db_version, like goose does.
+//
+// It reads the same environment as the lambdas, so source your .env first.
+// Migrations change the schema, so pass a user that's allowed to:
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate up -user root -password "$MYSQL_ROOT_PASSWORD"
+//	go run ./cmd/migrate up -to 20240617093015
+//	go run ./cmd/migrate down -steps 2
+//	go run ./cmd/migrate down -all
+//	go run ./cmd/migrate drift
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/migrate"
+	"github.com/kmesiab/equilibria/migrations"
+)
+
+const usage = `usage: migrate <command> [flags]
+
+commands:
+  status   list the migrations and whether they have been applied
+  up       apply the migrations that have not been applied
+  down     roll back the most recently applied migrations
+  drift    compare the database schema against the models
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, "could not load config")
+		os.Exit(1)
+	}
+
+	loaded, err := migrate.Load(migrations.FS)
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load migrations: %s\n", err)
+		os.Exit(1)
+	}
+
+	switch os.Args[1] {
+	case "status":
+		err = status(cfg, loaded, os.Args[2:])
+	case "up":
+		err = up(cfg, loaded, os.Args[2:])
+	case "down":
+		err = down(cfg, loaded, os.Args[2:])
+	case "drift":
+		err = drift(cfg, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+// connection holds the flags that override the configured database user.
+type connection struct {
+	user     *string
+	password *string
+}
+
+func connectionFlags(flags *flag.FlagSet) *connection {
+	return &connection{
+		user:     flags.String("user", "", "database user, instead of DATABASE_USER"),
+		password: flags.String("password", "", "database password, instead of DATABASE_PASSWORD"),
+	}
+}
+
+// open connects to the configured database. Migrations may hold several
+// statements in a block, so the connection allows them.
+func (c *connection) open(cfg *config.Config) (*gorm.DB, error) {
+
+	override := *cfg
+
+	if *c.user != "" {
+		override.DatabaseUser = *c.user
+	}
+
+	if *c.password != "" {
+		override.DatabasePassword = *c.password
+	}
+
+	return gorm.Open(mysql.Open(db.DSN(&override)+"&multiStatements=true"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+}
+
+func status(cfg *config.Config, loaded []*migrate.Migration, args []string) error {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	conn := connectionFlags(flags)
+	_ = flags.Parse(args)
+
+	database, err := conn.open(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrate.NewRunner(database, loaded).Status()
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "APPLIED AT\tMIGRATION")
+
+	for _, s := range statuses {
+
+		appliedAt := "Pending"
+
+		if s.Applied && s.AppliedAt != nil {
+			appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+		} else if s.Applied {
+			appliedAt = "Applied"
+		}
+
+		_, _ = fmt.Fprintf(w, "%s\t%s\n", appliedAt, s.Migration.Name)
+	}
+
+	return w.Flush()
+}
+
+func up(cfg *config.Config, loaded []*migrate.Migration, args []string) error {
+	flags := flag.NewFlagSet("up", flag.ExitOnError)
+	conn := connectionFlags(flags)
+	to := flags.Int64("to", 0, "version to migrate up to, instead of the latest")
+	_ = flags.Parse(args)
+
+	database, err := conn.open(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	done, err := migrate.NewRunner(database, loaded).Up(*to)
+
+	for _, migration := range done {
+		fmt.Printf("Applied %s\n", migration.Name)
+	}
+
+	if err == nil && len(done) == 0 {
+		fmt.Println("No migrations to apply")
+	}
+
+	return err
+}
+
+func down(cfg *config.Config, loaded []*migrate.Migration, args []string) error {
+	flags := flag.NewFlagSet("down", flag.ExitOnError)
+	conn := connectionFlags(flags)
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	all := flags.Bool("all", false, "roll back every migration, clearing the database")
+	_ = flags.Parse(args)
+
+	if *all {
+		*steps = len(loaded)
+	}
+
+	database, err := conn.open(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	done, err := migrate.NewRunner(database, loaded).Down(*steps)
+
+	for _, migration := range done {
+		fmt.Printf("Rolled back %s\n", migration.Name)
+	}
+
+	if err == nil && len(done) == 0 {
+		fmt.Println("No migrations to roll back")
+	}
+
+	return err
+}
+
+func drift(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("drift", flag.ExitOnError)
+	conn := connectionFlags(flags)
+	_ = flags.Parse(args)
+
+	database, err := conn.open(cfg)
+
+	if err != nil {
+		return err
+	}
+
+	found, err := migrate.CheckDrift(database, migrate.Models...)
+
+	if err != nil {
+		return err
+	}
+
+	if len(found) == 0 {
+		fmt.Println("The schema matches the models")
+
+		return nil
+	}
+
+	for _, d := range found {
+		fmt.Println(d)
+	}
+
+	return fmt.Errorf("found %d differences between the schema and the models", len(found))
+}
@@ -0,0 +1,154 @@
+// Command replay inspects events that were moved to a dead letter queue
+// and sends them back to the queue they failed on.
+//
+// It reads the same environment as the lambdas, so source your .env first:
+//
+//	go run ./cmd/replay list
+//	go run ./cmd/replay show -id 12
+//	go run ./cmd/replay replay -id 12
+//	go run ./cmd/replay replay -all
+//	go run ./cmd/replay replay -id 12 -queue arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/deadletter"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const usage = `usage: replay <command> [flags]
+
+commands:
+  list     list failed events that have not been replayed
+  show     print a failed event, including its body
+  replay   send failed events back to their source queue
+`
+
+func main() {
+
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		fmt.Fprintln(os.Stderr, "could not load config")
+		os.Exit(1)
+	}
+
+	svc := deadletter.NewService(deadletter.NewRepository(db.Get(cfg)))
+
+	var err error
+
+	switch os.Args[1] {
+	case "list":
+		err = list(svc, os.Args[2:])
+	case "show":
+		err = show(svc, os.Args[2:])
+	case "replay":
+		err = replay(svc, os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
+
+func list(svc *deadletter.Service, args []string) error {
+	flags := flag.NewFlagSet("list", flag.ExitOnError)
+	limit := flags.Int("limit", 50, "maximum number of events to list")
+	_ = flags.Parse(args)
+
+	events, err := svc.ListUnreplayed(*limit)
+
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ID\tCREATED\tRECEIVES\tSOURCE QUEUE")
+
+	for _, e := range events {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%d\t%s\n",
+			e.ID, e.CreatedAt.Format("2006-01-02 15:04:05"), e.ReceiveCount, e.SourceQueueArn)
+	}
+
+	return w.Flush()
+}
+
+func show(svc *deadletter.Service, args []string) error {
+	flags := flag.NewFlagSet("show", flag.ExitOnError)
+	id := flags.Int64("id", 0, "id of the failed event")
+	_ = flags.Parse(args)
+
+	event, err := svc.Get(*id)
+
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(event, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+
+	return nil
+}
+
+func replay(svc *deadletter.Service, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	id := flags.Int64("id", 0, "id of the failed event to replay")
+	all := flags.Bool("all", false, "replay every event that has not been replayed")
+	limit := flags.Int("limit", 50, "maximum number of events to replay with -all")
+	queue := flags.String("queue", "", "queue ARN to replay to, instead of the source queue")
+	_ = flags.Parse(args)
+
+	var (
+		err    error
+		events []*models.FailedEvent
+	)
+
+	switch {
+	case *all:
+		events, err = svc.ListUnreplayed(*limit)
+	case *id != 0:
+		var event *models.FailedEvent
+		event, err = svc.Get(*id)
+		events = []*models.FailedEvent{event}
+	default:
+		return fmt.Errorf("one of -id or -all is required")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	sender := &sqs.AWSSender{}
+
+	for _, event := range events {
+		if err = svc.Replay(event, *queue, sender); err != nil {
+			return fmt.Errorf("event %d: %w", event.ID, err)
+		}
+
+		fmt.Printf("Replayed event %d (%d replays)\n", event.ID, event.ReplayCount)
+	}
+
+	return nil
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+)
+
+const (
+	dateFormat = "2006-01-02"
+
+	// defaultRangeDays is how far back a query without dates looks
+	defaultRangeDays = 30
+)
+
+type AnalyticsLambdaHandler struct {
+	lib.LambdaHandler
+
+	AnalyticsService *analytics.Service
+	Now              func() time.Time
+}
+
+// CohortResponse is the engagement of each cohort over a range of days.
+type CohortResponse struct {
+	From    string                    `json:"from"`
+	To      string                    `json:"to"`
+	GroupBy string                    `json:"group_by"`
+	Cohorts []analytics.CohortMetrics `json:"cohorts"`
+}
+
+func (h *AnalyticsLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch request.HTTPMethod {
+	case "GET":
+
+		return h.Cohorts(request)
+
+		// Enable cors Preflight
+	case "OPTIONS":
+		return events.APIGatewayProxyResponse{
+			Headers:    config.DefaultHttpHeaders,
+			StatusCode: http.StatusOK,
+		}, nil
+	default:
+
+		return lib.RespondWithError("Unsupported HTTP method", nil, http.StatusMethodNotAllowed)
+	}
+}
+
+// Cohorts reports engagement metrics for cohorts of users over a range of
+// days. Only admins can see metrics across users.
+//
+//	GET /analytics?from=2024-06-01&to=2024-06-30&group_by=prompt&prompt=reply&user_id=2,3
+func (h *AnalyticsLambdaHandler) Cohorts(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	user, err := h.UserService.GetUserByID(userID)
+
+	if err != nil {
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lib.RespondWithError("Unauthorized", nil, http.StatusUnauthorized)
+		}
+
+		return lib.RespondWithError("Error getting user", err, http.StatusInternalServerError)
+	}
+
+	if !user.IsAdmin() {
+
+		return lib.RespondWithError("Forbidden", nil, http.StatusForbidden)
+	}
+
+	query, err := h.ParseQuery(request.QueryStringParameters)
+
+	if err != nil {
+
+		return lib.RespondWithError("Invalid query", err, http.StatusBadRequest)
+	}
+
+	cohorts, err := h.AnalyticsService.Cohorts(*query)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error getting metrics", err, http.StatusInternalServerError)
+	}
+
+	log.New("Reported %d cohorts", len(cohorts)).
+		Add("group_by", query.GroupBy).
+		AddUser(user).
+		Log()
+
+	return h.respond(http.StatusOK, CohortResponse{
+		From:    query.From.Format(dateFormat),
+		To:      query.To.Format(dateFormat),
+		GroupBy: query.GroupBy,
+		Cohorts: cohorts,
+	})
+}
+
+// ParseQuery reads a cohort query from the query string. It defaults to
+// the last 30 days, grouped by day.
+func (h *AnalyticsLambdaHandler) ParseQuery(params map[string]string) (*analytics.CohortQuery, error) {
+
+	query := &analytics.CohortQuery{
+		To:      analytics.Day(h.Now()),
+		GroupBy: analytics.GroupByDay,
+		Prompt:  prompts.Reply,
+	}
+
+	var err error
+
+	if value := params["to"]; value != "" {
+		if query.To, err = time.Parse(dateFormat, value); err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+
+	query.From = query.To.AddDate(0, 0, -defaultRangeDays)
+
+	if value := params["from"]; value != "" {
+		if query.From, err = time.Parse(dateFormat, value); err != nil {
+			return nil, fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+
+	if value := params["group_by"]; value != "" {
+		query.GroupBy = value
+	}
+
+	if value := params["prompt"]; value != "" {
+		query.Prompt = value
+	}
+
+	if value := params["user_id"]; value != "" {
+
+		for _, id := range strings.Split(value, ",") {
+
+			userID, err := strconv.ParseInt(strings.TrimSpace(id), 10, 64)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid user id %q", id)
+			}
+
+			query.UserIDs = append(query.UserIDs, userID)
+		}
+	}
+
+	if err = query.Validate(); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func (h *AnalyticsLambdaHandler) respond(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+
+	responseBytes, err := json.Marshal(body)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error marshaling response", err, http.StatusInternalServerError)
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    config.DefaultHttpHeaders,
+		StatusCode: statusCode,
+		Body:       string(responseBytes),
+	}, nil
+}
+
+func main() {
+
+	log.New("Analytics Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config")
+	}
+
+	database := db.Get(cfg)
+
+	handler := AnalyticsLambdaHandler{
+		AnalyticsService: analytics.NewService(analytics.NewRepository(database)),
+		Now:              time.Now,
+	}
+	handler.Init(database)
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var now = time.Date(2024, 6, 12, 3, 0, 0, 0, time.UTC)
+
+func newHandler(t *testing.T) (*AnalyticsLambdaHandler, sqlmock.Sqlmock) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &AnalyticsLambdaHandler{
+		AnalyticsService: analytics.NewService(analytics.NewRepository(db)),
+		Now:              func() time.Time { return now },
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func request(params map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: params,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{lib.AuthorizerUserIDKey: "7"},
+		},
+	}
+}
+
+func expectSignedInUser(mock sqlmock.Sqlmock, userTypeID int64) {
+	mock.ExpectQuery("SELECT \\* FROM `users`").
+		WithArgs(7, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "account_status_id", "user_type_id"}).
+			AddRow(7, models.AccountStatusActive, userTypeID))
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs(models.AccountStatusActive).
+		WillReturnRows(test.GenerateMockAccountStatusActive())
+}
+
+func TestAnalyticsLambdaHandler_Cohorts(t *testing.T) {
+
+	handler, mock := newHandler(t)
+	expectSignedInUser(mock, models.UserTypeAdmin)
+
+	mock.ExpectQuery("SELECT DATE_FORMAT\\(user_metrics.date, '%Y-%m-%d'\\) AS cohort").
+		WithArgs(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), 2, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"cohort", "users", "sentiment_samples", "vader_compound_total"}).
+			AddRow("2024-06-01", 2, 4, -0.4).
+			AddRow("2024-06-02", 2, 4, 0.8))
+
+	response, err := handler.HandleRequest(request(map[string]string{
+		"from":    "2024-06-01",
+		"to":      "2024-06-02",
+		"user_id": "2,3",
+	}))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+	body := CohortResponse{}
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+
+	assert.Equal(t, analytics.GroupByDay, body.GroupBy)
+	require.Len(t, body.Cohorts, 2)
+	assert.InDelta(t, -0.1, *body.Cohorts[0].AverageVaderCompound, 0.0001)
+	assert.InDelta(t, 0.2, *body.Cohorts[1].AverageVaderCompound, 0.0001)
+	assert.Nil(t, body.Cohorts[0].NudgeReplyRate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyticsLambdaHandler_Cohorts_NotAdmin(t *testing.T) {
+
+	handler, mock := newHandler(t)
+	expectSignedInUser(mock, models.UserTypePatient)
+
+	response, err := handler.HandleRequest(request(nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAnalyticsLambdaHandler_Cohorts_Unauthorized(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	response, err := handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestAnalyticsLambdaHandler_Cohorts_InvalidQuery(t *testing.T) {
+
+	handler, mock := newHandler(t)
+	expectSignedInUser(mock, models.UserTypeAdmin)
+
+	response, err := handler.HandleRequest(request(map[string]string{"group_by": "month"}))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestParseQuery_Defaults(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	query, err := handler.ParseQuery(map[string]string{})
+
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 12, 0, 0, 0, 0, time.UTC), query.To)
+	assert.Equal(t, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), query.From)
+	assert.Equal(t, analytics.GroupByDay, query.GroupBy)
+	assert.Empty(t, query.UserIDs)
+}
+
+func TestParseQuery_Invalid(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	for _, params := range []map[string]string{
+		{"from": "yesterday"},
+		{"to": "06/01/2024"},
+		{"user_id": "2,bob"},
+		{"from": "2024-06-10", "to": "2024-06-01"},
+	} {
+		_, err := handler.ParseQuery(params)
+		assert.Error(t, err, params)
+	}
+}
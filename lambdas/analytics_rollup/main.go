@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+)
+
+const dateFormat = "2006-01-02"
+
+// maxBackfillDays is the most days a single invocation will roll up
+const maxBackfillDays = 90
+
+// RollupDetail is the detail of an event asking for a backfill. The
+// schedule sends an empty detail, which rolls up yesterday.
+type RollupDetail struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type AnalyticsRollupLambdaHandler struct {
+	lib.LambdaHandler
+
+	AnalyticsService *analytics.Service
+	Now              func() time.Time
+}
+
+// HandleRequest rolls up yesterday's metrics, or the days in the event's
+// detail. Yesterday is rolled up again the day after, so replies to
+// nudges sent late in the day are counted.
+func (h *AnalyticsRollupLambdaHandler) HandleRequest(e events.EventBridgeEvent) error {
+
+	days, err := h.Days(e.Detail)
+
+	if err != nil {
+		log.New("Invalid rollup request").AddError(err).Log()
+
+		return err
+	}
+
+	for _, day := range days {
+
+		metrics, err := h.AnalyticsService.RollupDay(day)
+
+		if err != nil {
+			log.New("Error rolling up %s", day.Format(dateFormat)).AddError(err).Log()
+
+			return err
+		}
+
+		log.New("Rolled up %s", day.Format(dateFormat)).
+			Add("users", strconv.Itoa(len(metrics))).
+			Log()
+	}
+
+	return nil
+}
+
+// Days returns the days the event asks for, oldest first.
+func (h *AnalyticsRollupLambdaHandler) Days(detail json.RawMessage) ([]time.Time, error) {
+
+	yesterday := analytics.Day(h.Now()).AddDate(0, 0, -1)
+
+	request := RollupDetail{}
+
+	if len(detail) > 0 {
+		if err := json.Unmarshal(detail, &request); err != nil {
+			return nil, fmt.Errorf("error parsing event detail: %w", err)
+		}
+	}
+
+	if request.From == "" {
+		// Yesterday, and the day before for the replies that came in late
+		return []time.Time{yesterday.AddDate(0, 0, -1), yesterday}, nil
+	}
+
+	from, err := time.Parse(dateFormat, request.From)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid from date: %w", err)
+	}
+
+	to := yesterday
+
+	if request.To != "" {
+		if to, err = time.Parse(dateFormat, request.To); err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+
+	if to.Before(from) {
+		return nil, fmt.Errorf("the range ends before it starts")
+	}
+
+	var days []time.Time
+
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	if len(days) > maxBackfillDays {
+		return nil, fmt.Errorf("can only roll up %d days at a time", maxBackfillDays)
+	}
+
+	return days, nil
+}
+
+func main() {
+
+	log.New("Analytics Rollup Lambda booting.....").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config")
+	}
+
+	database := db.Get(cfg)
+
+	if err := utils.PingDatabase(database); err != nil {
+		log.New("Error pinging database").AddError(err).Log()
+
+		return
+	}
+
+	handler := &AnalyticsRollupLambdaHandler{
+		AnalyticsService: analytics.NewService(analytics.NewRepository(database)),
+		Now:              time.Now,
+	}
+
+	handler.Init(database)
+
+	log.New("Analytics Rollup Lambda ready. Invoking.").Log()
+	lambda.Start(handler.HandleRequest)
+}
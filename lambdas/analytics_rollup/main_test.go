@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+var now = time.Date(2024, 6, 12, 3, 0, 0, 0, time.UTC)
+
+func newHandler(t *testing.T) (*AnalyticsRollupLambdaHandler, sqlmock.Sqlmock) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &AnalyticsRollupLambdaHandler{
+		AnalyticsService: analytics.NewService(analytics.NewRepository(db)),
+		Now:              func() time.Time { return now },
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func date(day int) time.Time {
+	return time.Date(2024, 6, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestDays_Scheduled(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	days, err := handler.Days(json.RawMessage("{}"))
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{date(10), date(11)}, days,
+		"The schedule should roll up yesterday, and the day before for late replies")
+}
+
+func TestDays_Backfill(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	days, err := handler.Days(json.RawMessage(`{"from": "2024-06-01", "to": "2024-06-03"}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{date(1), date(2), date(3)}, days)
+
+	days, err = handler.Days(json.RawMessage(`{"from": "2024-06-09"}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, []time.Time{date(9), date(10), date(11)}, days, "Backfills should run to yesterday")
+}
+
+func TestDays_Invalid(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	for _, detail := range []string{
+		`{"from": "June 1st"}`,
+		`{"from": "2024-06-03", "to": "2024-06-01"}`,
+		`{"from": "2023-01-01"}`,
+		`not json`,
+	} {
+		_, err := handler.Days(json.RawMessage(detail))
+		assert.Error(t, err, detail)
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	for range 2 {
+		mock.ExpectQuery("SELECT messages.id").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id", "created_at"}).
+				AddRow(1, 2, 1, date(10).Add(time.Hour)).
+				AddRow(2, 2, 1, date(11).Add(time.Hour)))
+		mock.ExpectQuery("SELECT user_id, vader_compound, created_at FROM `nrclex`").
+			WillReturnRows(sqlmock.NewRows([]string{"user_id", "vader_compound", "created_at"}))
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO `user_metrics`").WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+	}
+
+	err := handler.HandleRequest(events.EventBridgeEvent{Detail: json.RawMessage("{}")})
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -42,13 +42,13 @@ func (h AuthorizerLambdaHandler) HandleRequest(request events.APIGatewayCustomAu
 	claims, err := h.TokenService.Validate(token, publicKey)
 
 	if err != nil {
-		log.New("Error validating JWT: %s", err).Add("token", token).Log()
+		log.New("Error validating JWT").AddError(err).Log()
 
 		return generatePolicy(PrincipleID, PolicyEffectDeny, request.MethodArn), nil
 	}
 
 	// Log this JWT
-	log.New("Authorized %s", claims.PhoneNumber).
+	log.New("Authorized user %d", claims.UserID).
 		Add("user_id", strconv.FormatInt(claims.UserID, 10)).
 		Add("phone_number", claims.PhoneNumber).
 		Add("lastname", claims.Lastname).
@@ -61,11 +61,17 @@ func (h AuthorizerLambdaHandler) HandleRequest(request events.APIGatewayCustomAu
 		Add("jwt_issued_at", strconv.FormatInt(claims.IssuedAt, 10)).
 		Add("jwt_expires_at", strconv.FormatInt(claims.ExpiresAt, 10)).
 		Add("jwt_issuer", claims.Issuer).
-		Add("token", token).
 		Log()
 
 	// If the token is invalid, deny access
-	return generatePolicy(PrincipleID, PolicyEffectAllow, request.MethodArn), nil
+	policy := generatePolicy(PrincipleID, PolicyEffectAllow, request.MethodArn)
+
+	// Let the endpoints know who is signed in
+	policy.Context = map[string]interface{}{
+		lib.AuthorizerUserIDKey: strconv.FormatInt(claims.UserID, 10),
+	}
+
+	return policy, nil
 }
 
 func generatePolicy(principalID, effect, resource string) events.APIGatewayCustomAuthorizerResponse {
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/deadletter"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+)
+
+// DeadLetterLambdaHandler records events that exhausted their retries
+// on the send and factfinder queues, so they can be inspected and
+// replayed later.
+type DeadLetterLambdaHandler struct {
+	lib.LambdaHandler
+
+	Service *deadletter.Service
+}
+
+func (h *DeadLetterLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.New("Panic while recording failed event: %v\nStack trace:\n%s", r, debug.Stack()).Log()
+			err = fmt.Errorf("panic while recording failed event: %v", r)
+		}
+	}()
+
+	for _, record := range sqsEvent.Records {
+
+		event, err := h.Service.Record(record)
+
+		if err != nil {
+			log.New("Error recording failed event %s", record.MessageId).
+				AddMap(record.Attributes).AddError(err).Log()
+
+			return err
+		}
+
+		log.New("Recorded failed event %d from %s", event.ID, event.SourceQueueArn).
+			Add("message_id", record.MessageId).Log()
+	}
+
+	return nil
+}
+
+func main() {
+	log.New("Dead Letter Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config").Log()
+	}
+
+	database := db.Get(cfg)
+
+	if err := utils.PingDatabase(database); err != nil {
+		log.New("Error pinging database").AddError(err).Log()
+
+		return
+	}
+
+	handler := &DeadLetterLambdaHandler{
+		Service: deadletter.NewService(deadletter.NewRepository(database)),
+	}
+
+	handler.Init(database)
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/deadletter"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+func newDeadLetterEvent() events.SQSEvent {
+	return events.SQSEvent{
+		Records: []events.SQSMessage{{
+			MessageId: "b1f1c8a2-0000-4000-8000-000000000001",
+			Body:      `{"Type":"Notification","Message":"{}"}`,
+			Attributes: map[string]string{
+				deadletter.AttributeReceiveCount:   "3",
+				deadletter.AttributeSourceQueueArn: "arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue",
+			},
+		}},
+	}
+}
+
+func TestDeadLetterLambdaHandler_HandleRequest(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &DeadLetterLambdaHandler{
+		Service: deadletter.NewService(deadletter.NewRepository(db)),
+	}
+	handler.Init(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `failed_events`").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	err = handler.HandleRequest(newDeadLetterEvent())
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeadLetterLambdaHandler_HandleRequest_DatabaseError(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &DeadLetterLambdaHandler{
+		Service: deadletter.NewService(deadletter.NewRepository(db)),
+	}
+	handler.Init(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `failed_events`").
+		WillReturnError(errors.New("connection refused"))
+	mock.ExpectRollback()
+
+	err = handler.HandleRequest(newDeadLetterEvent())
+
+	assert.Error(t, err, "The event should be left on the queue when it can't be recorded")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const (
+	dateFormat = "2006-01-02"
+
+	// The schedule backfills the last week, a batch at a time
+	defaultBackfillDays  = 7
+	defaultBackfillLimit = 500
+	maxBackfillLimit     = 5000
+)
+
+// Event is either a batch of messages from the inbound and outbound
+// topics' queue, or the scheduled backfill from EventBridge.
+type Event struct {
+	Records    []events.SQSMessage `json:"Records"`
+	DetailType string              `json:"detail-type"`
+	Detail     json.RawMessage     `json:"detail"`
+}
+
+// BackfillDetail is the detail of an event asking for a backfill of the
+// messages created since a date. The schedule sends an empty detail.
+type BackfillDetail struct {
+	Since string `json:"since"`
+	Limit int    `json:"limit"`
+}
+
+type EmotionsLambdaHandler struct {
+	lib.LambdaHandler
+
+	NRCLexService *emotions.NRCLexService
+	Now           func() time.Time
+}
+
+// HandleRequest scores the emotions in the messages sent to the topics,
+// or backfills messages that were missed.
+func (h *EmotionsLambdaHandler) HandleRequest(payload json.RawMessage) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.New("Panic while processing event: %v\nStack trace:\n%s", r, debug.Stack()).Log()
+			err = fmt.Errorf("panic while processing event: %v", r)
+		}
+	}()
+
+	event := Event{}
+
+	if err = json.Unmarshal(payload, &event); err != nil {
+		log.New("Error unmarshalling event").AddError(err).Log()
+
+		return nil
+	}
+
+	if event.DetailType != "" {
+		return h.Backfill(event.Detail)
+	}
+
+	if len(event.Records) == 0 {
+		log.New("No records found in the event.  Shutting down.").Log()
+
+		return nil
+	}
+
+	for _, record := range event.Records {
+
+		// Returning the error leaves the message on the queue to be
+		// retried, and eventually moved to the dead letter queue.
+		if err = h.processRecord(record); err != nil {
+			log.New("Error processing message: %s", record.MessageId).
+				AddError(err).Log()
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *EmotionsLambdaHandler) processRecord(record events.SQSMessage) error {
+
+	var (
+		msg         models.Message
+		eventRecord sqs.SQSEventRecord
+	)
+
+	// Unpack the SNS Event Record
+	if err := json.Unmarshal([]byte(record.Body), &eventRecord); err != nil {
+		log.New("Error unmarshalling event record").
+			AddError(err).Log()
+
+		return nil
+	}
+
+	_, span := tracing.Start("emotions", propagation.MapCarrier(eventRecord.Attributes()))
+	defer span.End()
+
+	// Unpack the message from the event record
+	if err := json.Unmarshal([]byte(eventRecord.Message), &msg); err != nil {
+		log.New("Error unmarshalling message from event record").
+			AddError(err).Log()
+
+		return nil
+	}
+
+	return h.Score(&msg)
+}
+
+// Score scores and saves the emotions in [msg], as written by its sender.
+// Replies and nudges are scored as the system user's, so they aren't
+// mistaken for the user's own mood.
+func (h *EmotionsLambdaHandler) Score(msg *models.Message) error {
+
+	if msg.ID == 0 || strings.TrimSpace(msg.Body) == "" {
+		log.New("Message has nothing to score. Skipping.").AddMessage(msg).Log()
+
+		return nil
+	}
+
+	scores, err := h.NRCLexService.ProcessMessage(&models.User{ID: msg.FromUserID}, msg)
+
+	if err != nil {
+		return err
+	}
+
+	log.New("Scored emotions for message %d", msg.ID).
+		Add("compound_sentiment", strconv.FormatFloat(scores.VaderEmotionScore.Compound, 'f', 4, 64)).
+		AddMessage(msg).
+		Log()
+
+	return nil
+}
+
+// Backfill scores the messages the event's detail asks for that have no
+// scores yet. A message that fails is left for the next run.
+func (h *EmotionsLambdaHandler) Backfill(detail json.RawMessage) error {
+
+	_, span := tracing.Start("emotions backfill", propagation.MapCarrier{})
+	defer span.End()
+
+	since, limit, err := h.BackfillRange(detail)
+
+	if err != nil {
+		log.New("Invalid backfill request").AddError(err).Log()
+
+		return err
+	}
+
+	messages, err := h.NRCLexService.Unscored(since, limit)
+
+	if err != nil {
+		log.New("Error finding unscored messages").AddError(err).Log()
+
+		return err
+	}
+
+	failed := 0
+
+	for i := range messages {
+		if err = h.Score(&messages[i]); err != nil {
+			log.New("Error backfilling message %d", messages[i].ID).AddError(err).Log()
+
+			failed++
+		}
+	}
+
+	log.New("Backfilled emotions since %s", since.Format(dateFormat)).
+		Add("messages", strconv.Itoa(len(messages))).
+		Add("failed", strconv.Itoa(failed)).
+		Log()
+
+	if failed > 0 {
+		return fmt.Errorf("failed to score %d of %d messages", failed, len(messages))
+	}
+
+	return nil
+}
+
+// BackfillRange returns the date to backfill from and the most messages
+// to score.
+func (h *EmotionsLambdaHandler) BackfillRange(detail json.RawMessage) (time.Time, int, error) {
+
+	request := BackfillDetail{}
+
+	if len(detail) > 0 {
+		if err := json.Unmarshal(detail, &request); err != nil {
+			return time.Time{}, 0, fmt.Errorf("error parsing event detail: %w", err)
+		}
+	}
+
+	now := h.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).
+		AddDate(0, 0, -defaultBackfillDays)
+
+	if request.Since != "" {
+
+		var err error
+
+		if since, err = time.Parse(dateFormat, request.Since); err != nil {
+			return time.Time{}, 0, fmt.Errorf("invalid since date: %w", err)
+		}
+	}
+
+	limit := request.Limit
+
+	switch {
+	case limit == 0:
+		limit = defaultBackfillLimit
+	case limit < 0 || limit > maxBackfillLimit:
+		return time.Time{}, 0, fmt.Errorf("the limit must be between 1 and %d", maxBackfillLimit)
+	}
+
+	return since, limit, nil
+}
+
+func main() {
+
+	log.New("Emotions Lambda booting.....").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config")
+	}
+
+	if err := tracing.Init(cfg, "emotions"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	database := db.Get(cfg)
+
+	if err := utils.PingDatabase(database); err != nil {
+		log.New("Error pinging database").AddError(err).Log()
+
+		return
+	}
+
+	analyzer, err := nrclex.New(cfg, utils.NewRestClient().GetClient())
+
+	if err != nil {
+		log.New("Error creating emotion analyzer. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	handler := &EmotionsLambdaHandler{
+		NRCLexService: emotions.NewNRCLexService(analyzer, nrclex.NewRepository(database)),
+		Now:           time.Now,
+	}
+
+	handler.Init(database)
+
+	log.New("Emotions Lambda ready. Invoking.").Log()
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var now = time.Date(2024, 6, 18, 3, 0, 0, 0, time.UTC)
+
+func newHandler(t *testing.T) (*EmotionsLambdaHandler, sqlmock.Sqlmock) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &EmotionsLambdaHandler{
+		NRCLexService: emotions.NewNRCLexService(nrclex.NewLocalAnalyzer(), nrclex.NewRepository(db)),
+		Now:           func() time.Time { return now },
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func sqsPayload(t *testing.T, msg models.Message) json.RawMessage {
+
+	message, err := json.Marshal(msg)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(sqs.SQSEventRecord{Type: "Notification", Message: string(message)})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(events.SQSEvent{
+		Records: []events.SQSMessage{{MessageId: "1", Body: string(body)}},
+	})
+	require.NoError(t, err)
+
+	return payload
+}
+
+func expectScored(mock sqlmock.Sqlmock, messageID, userID int64) {
+
+	mock.ExpectQuery("SELECT \\* FROM `nrclex` WHERE message_id = \\?").
+		WithArgs(messageID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `nrclex`").
+		WithArgs(userID, messageID,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+}
+
+func TestHandleRequest_Inbound(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	expectScored(mock, 7, 3)
+
+	err := handler.HandleRequest(sqsPayload(t, models.Message{
+		ID: 7, FromUserID: 3, ToUserID: 1, Body: "I'm so happy today", CreatedAt: now,
+	}))
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_Outbound(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	// Replies are scored as the system user's
+	expectScored(mock, 8, models.GetSystemUser().ID)
+
+	err := handler.HandleRequest(sqsPayload(t, models.Message{
+		ID: 8, FromUserID: models.GetSystemUser().ID, ToUserID: 3, Body: "That's wonderful to hear!", CreatedAt: now,
+	}))
+
+	require.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_Skips(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	require.NoError(t, handler.HandleRequest(sqsPayload(t, models.Message{ID: 9, FromUserID: 3})),
+		"Messages without a body should be skipped")
+
+	require.NoError(t, handler.HandleRequest(json.RawMessage(`{"Records": []}`)))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_Backfill(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	mock.ExpectQuery("SELECT `messages`.`id`.* FROM `messages` LEFT JOIN nrclex").
+		WithArgs(time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC), defaultBackfillLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id", "body"}).
+			AddRow(4, 3, 1, "I feel terrible").
+			AddRow(5, 1, 3, "I'm sorry to hear that"))
+
+	expectScored(mock, 4, 3)
+	expectScored(mock, 5, 1)
+
+	payload, err := json.Marshal(events.EventBridgeEvent{
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		Detail:     json.RawMessage("{}"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, handler.HandleRequest(payload))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBackfillRange(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	since, limit, err := handler.BackfillRange(nil)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC), since)
+	assert.Equal(t, defaultBackfillLimit, limit)
+
+	since, limit, err = handler.BackfillRange(json.RawMessage(`{"since": "2024-01-01", "limit": 50}`))
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), since)
+	assert.Equal(t, 50, limit)
+
+	_, _, err = handler.BackfillRange(json.RawMessage(`{"since": "last week"}`))
+	assert.Error(t, err)
+
+	_, _, err = handler.BackfillRange(json.RawMessage(`{"limit": 100000}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type ExportLambdaHandler struct {
+	lib.LambdaHandler
+
+	Service *export.Service
+}
+
+// ExportRequest is how the user wants their export delivered.
+type ExportRequest struct {
+	Delivery string `json:"delivery"`
+}
+
+// ExportResponse is an export, and a link to download it once it's ready.
+type ExportResponse struct {
+	*models.DataExport
+
+	URL string `json:"url,omitempty"`
+}
+
+func (h *ExportLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch request.HTTPMethod {
+	case "POST":
+
+		return h.Request(request)
+	case "GET":
+
+		return h.Get(request)
+
+		// Enable cors Preflight
+	case "OPTIONS":
+		return events.APIGatewayProxyResponse{
+			Headers:    config.DefaultHttpHeaders,
+			StatusCode: http.StatusOK,
+		}, nil
+	default:
+
+		return lib.RespondWithError("Unsupported HTTP method", nil, http.StatusMethodNotAllowed)
+	}
+}
+
+// Request starts an export of the signed in user's data. It's built in
+// the background; poll GET /export for the link, or ask for it by SMS.
+//
+//	POST /export {"delivery": "sms"}
+func (h *ExportLambdaHandler) Request(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	input := ExportRequest{Delivery: models.DataExportDeliveryLink}
+
+	if request.Body != "" {
+		if err = json.Unmarshal([]byte(request.Body), &input); err != nil {
+
+			return lib.RespondWithError("Invalid request body", err, http.StatusBadRequest)
+		}
+	}
+
+	if input.Delivery != models.DataExportDeliveryLink && input.Delivery != models.DataExportDeliverySMS {
+
+		return lib.RespondWithError("Delivery must be link or sms", nil, http.StatusBadRequest)
+	}
+
+	requested, err := h.Service.Request(userID, input.Delivery)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error requesting export", err, http.StatusInternalServerError)
+	}
+
+	log.New("Export %d requested by user %d", requested.ID, userID).Log()
+
+	return h.respond(http.StatusAccepted, ExportResponse{DataExport: requested})
+}
+
+// Get returns the signed in user's latest export, or the one with the
+// given id, with a fresh link to download it if it's ready.
+//
+//	GET /export?id=9
+func (h *ExportLambdaHandler) Get(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	var found *models.DataExport
+
+	if value := request.QueryStringParameters["id"]; value != "" {
+
+		id, err := strconv.ParseInt(value, 10, 64)
+
+		if err != nil {
+
+			return lib.RespondWithError("Invalid id", err, http.StatusBadRequest)
+		}
+
+		found, err = h.Service.Get(id)
+
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+
+			return lib.RespondWithError("Error getting export", err, http.StatusInternalServerError)
+		}
+	} else if found, err = h.Service.Latest(userID); err != nil {
+
+		return lib.RespondWithError("Error getting export", err, http.StatusInternalServerError)
+	}
+
+	// Someone else's export is as good as missing
+	if found == nil || found.UserID != userID {
+
+		return lib.RespondWithError("Export not found", nil, http.StatusNotFound)
+	}
+
+	response := ExportResponse{DataExport: found}
+
+	if found.IsAvailable(time.Now().UTC()) {
+		if response.URL, err = h.Service.Link(found); err != nil {
+
+			return lib.RespondWithError("Error creating download link", err, http.StatusInternalServerError)
+		}
+	}
+
+	return h.respond(http.StatusOK, response)
+}
+
+func (h *ExportLambdaHandler) respond(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+
+	responseBytes, err := json.Marshal(body)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error marshaling response", err, http.StatusInternalServerError)
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    config.DefaultHttpHeaders,
+		StatusCode: statusCode,
+		Body:       string(responseBytes),
+	}, nil
+}
+
+func main() {
+
+	log.New("Export Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config").Log()
+
+		return
+	}
+
+	database := db.Get(cfg)
+
+	store, err := export.NewS3Store(cfg.ExportBucket)
+
+	if err != nil {
+		log.New("Error creating export store. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	service := export.NewService(export.NewRepository(database), store, time.Duration(cfg.ExportLinkTTLHours)*time.Hour)
+	service.Queue = &sqs.AWSSender{}
+	service.QueueURL = cfg.ExportQueueURL
+
+	handler := ExportLambdaHandler{Service: service}
+	handler.Init(database)
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type fakeStore struct{}
+
+func (fakeStore) Put(string, io.ReadSeeker, string) error {
+	return nil
+}
+
+func (fakeStore) URL(key, _ string, _ time.Duration) (string, error) {
+	return "https://exports.example.com/" + key, nil
+}
+
+type fakeQueueSender struct {
+	body string
+}
+
+func (f *fakeQueueSender) SendBody(_, body string) (string, error) {
+	f.body = body
+
+	return "export-message-id", nil
+}
+
+func newHandler(t *testing.T) (*ExportLambdaHandler, sqlmock.Sqlmock, *fakeQueueSender) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	queue := &fakeQueueSender{}
+	service := export.NewService(export.NewRepository(db), fakeStore{}, 24*time.Hour)
+	service.Queue, service.QueueURL = queue, "https://sqs.us-west-2.amazonaws.com/123456789012/export-queue"
+
+	handler := &ExportLambdaHandler{Service: service}
+	handler.Init(db)
+
+	return handler, mock, queue
+}
+
+func request(method, userID, body string, params map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            method,
+		Body:                  body,
+		QueryStringParameters: params,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{lib.AuthorizerUserIDKey: userID},
+		},
+	}
+}
+
+func TestExportLambdaHandler_Request(t *testing.T) {
+
+	handler, mock, queue := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE user_id = \\? AND status IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `data_exports`").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	response, err := handler.HandleRequest(request("POST", "5", `{"delivery": "sms"}`, nil))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, response.StatusCode, response.Body)
+
+	body := ExportResponse{}
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+	assert.Equal(t, int64(9), body.ID)
+	assert.Equal(t, models.DataExportDeliverySMS, body.Delivery)
+	assert.JSONEq(t, `{"export_id": 9}`, queue.body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportLambdaHandler_Request_InvalidDelivery(t *testing.T) {
+
+	handler, _, _ := newHandler(t)
+
+	response, err := handler.HandleRequest(request("POST", "5", `{"delivery": "fax"}`, nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestExportLambdaHandler_Get(t *testing.T) {
+
+	handler, mock, _ := newHandler(t)
+
+	expiresAt := time.Now().UTC().Add(time.Hour)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE user_id = \\? ORDER BY id DESC").
+		WithArgs(int64(5), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status", "object_key", "error", "expires_at"}).
+			AddRow(9, 5, models.DataExportStatusComplete, "exports/5/9.zip", "", expiresAt))
+
+	response, err := handler.HandleRequest(request("GET", "5", "", nil))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+	body := ExportResponse{}
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+	assert.Equal(t, "https://exports.example.com/exports/5/9.zip", body.URL)
+	assert.NotContains(t, response.Body, "object_key")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportLambdaHandler_Get_OtherUser(t *testing.T) {
+
+	handler, mock, _ := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE `data_exports`.`id` = \\?").
+		WithArgs(int64(9), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).
+			AddRow(9, 6, models.DataExportStatusComplete))
+
+	response, err := handler.HandleRequest(request("GET", "5", "", map[string]string{"id": "9"}))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportLambdaHandler_Get_None(t *testing.T) {
+
+	handler, mock, _ := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE user_id = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	response, err := handler.HandleRequest(request("GET", "5", "", nil))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportLambdaHandler_Unauthorized(t *testing.T) {
+
+	handler, _, _ := newHandler(t)
+
+	response, err := handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: "POST"})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+)
+
+// ExportWorkerLambdaHandler builds the exports users ask for, from the
+// jobs the export lambda queues.
+type ExportWorkerLambdaHandler struct {
+	lib.LambdaHandler
+
+	Service *export.Service
+}
+
+func (h *ExportWorkerLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.New("Panic while building export: %v\nStack trace:\n%s", r, debug.Stack()).Log()
+			err = fmt.Errorf("panic while building export: %v", r)
+		}
+	}()
+
+	for _, record := range sqsEvent.Records {
+
+		job := export.Job{}
+
+		if err := json.Unmarshal([]byte(record.Body), &job); err != nil {
+
+			// Retrying won't make it readable, so drop it
+			log.New("Error reading export job %s", record.MessageId).AddError(err).Log()
+
+			continue
+		}
+
+		built, err := h.Service.Process(job.ExportID)
+
+		if err != nil {
+			log.New("Error building export %d", job.ExportID).
+				Add("message_id", record.MessageId).AddError(err).Log()
+
+			return err
+		}
+
+		log.New("Built export %d for user %d", built.ID, built.UserID).
+			Add("delivery", built.Delivery).Log()
+	}
+
+	return nil
+}
+
+func main() {
+	log.New("Export Worker Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config").Log()
+
+		return
+	}
+
+	database := db.Get(cfg)
+
+	if err := utils.PingDatabase(database); err != nil {
+		log.New("Error pinging database").AddError(err).Log()
+
+		return
+	}
+
+	store, err := export.NewS3Store(cfg.ExportBucket)
+
+	if err != nil {
+		log.New("Error creating export store. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	provider, err := messaging.New(cfg)
+
+	if err != nil {
+		log.New("Error creating messaging provider. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	service := export.NewService(export.NewRepository(database), store, time.Duration(cfg.ExportLinkTTLHours)*time.Hour)
+	service.SMS = &channel.SMSChannel{Provider: provider, MaxSegments: cfg.SMSMaxSegmentsPerMessage}
+	service.LinkURL = cfg.ExportLinkURL
+
+	handler := &ExportWorkerLambdaHandler{Service: service}
+	handler.Init(database)
+
+	lambda.Start(handler.HandleRequest)
+}
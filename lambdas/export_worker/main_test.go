@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type fakeStore struct{}
+
+func (fakeStore) Put(string, io.ReadSeeker, string) error {
+	return nil
+}
+
+func (fakeStore) URL(key, _ string, _ time.Duration) (string, error) {
+	return "https://exports.example.com/" + key, nil
+}
+
+func newHandler(t *testing.T) (*ExportWorkerLambdaHandler, sqlmock.Sqlmock) {
+
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &ExportWorkerLambdaHandler{
+		Service: export.NewService(export.NewRepository(db), fakeStore{}, 24*time.Hour),
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func newExportEvent(body string) events.SQSEvent {
+	return events.SQSEvent{
+		Records: []events.SQSMessage{{
+			MessageId: "b1f1c8a2-0000-4000-8000-000000000009",
+			Body:      body,
+		}},
+	}
+}
+
+func TestExportWorkerLambdaHandler_HandleRequest_Complete(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE `data_exports`.`id` = \\?").
+		WithArgs(int64(9), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "status"}).
+			AddRow(9, 5, models.DataExportStatusComplete))
+
+	err := handler.HandleRequest(newExportEvent(`{"export_id": 9}`))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportWorkerLambdaHandler_HandleRequest_Error(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE `data_exports`.`id` = \\?").
+		WillReturnError(assert.AnError)
+
+	err := handler.HandleRequest(newExportEvent(`{"export_id": 9}`))
+
+	assert.Error(t, err, "The job should be left on the queue when the export can't be built")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportWorkerLambdaHandler_HandleRequest_InvalidBody(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	err := handler.HandleRequest(newExportEvent(`not json`))
+
+	assert.NoError(t, err, "A job that can't be read should be dropped, not retried")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
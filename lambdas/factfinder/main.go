@@ -2,11 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime/debug"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
 
 	"github.com/kmesiab/equilibria/lambdas/lib"
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
@@ -14,7 +17,10 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/facts"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
 	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/lib/usage"
 	"github.com/kmesiab/equilibria/lambdas/lib/utils"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
@@ -24,13 +30,18 @@ type FactFinderLambdaHandler struct {
 	lib.LambdaHandler
 
 	Service facts.ServiceInterface
+	Metrics *metrics.Recorder
+
+	// Usage throttles users over their monthly quota
+	Usage *usage.Service
 }
 
-func (h *FactFinderLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) error {
+func (h *FactFinderLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
 			log.New("Panic while processing event: %v\nStack trace:\n%s", r, debug.Stack()).Log()
+			err = fmt.Errorf("panic while processing event: %v", r)
 		}
 	}()
 
@@ -41,11 +52,15 @@ func (h *FactFinderLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) error
 	}
 
 	for _, record := range sqsEvent.Records {
-		err := h.processMessage(record)
+		err = h.processMessage(record)
 
+		// Returning the error leaves the message on the queue to be
+		// retried, and eventually moved to the dead letter queue.
 		if err != nil {
-			log.New("Error processing message: %s, error: %v", record.Body, err)
-			return nil
+			log.New("Error processing message: %s", record.MessageId).
+				AddError(err).Log()
+
+			return err
 		}
 	}
 
@@ -77,6 +92,9 @@ func (h *FactFinderLambdaHandler) processMessage(record events.SQSMessage) error
 		return nil
 	}
 
+	_, span := tracing.Start("factfinder", propagation.MapCarrier(eventRecord.Attributes()))
+	defer span.End()
+
 	// Unpack the message from the event record
 	if err = json.Unmarshal([]byte(eventRecord.Message), &msg); err != nil {
 		log.New("Error unmarshalling message from event record").
@@ -102,21 +120,39 @@ func (h *FactFinderLambdaHandler) processMessage(record events.SQSMessage) error
 		log.New("Could not locate user %d.  Rejecting.", msg.FromUserID).
 			AddError(err).AddMessage(&msg).Log()
 
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	if h.Usage.Throttled(currentUser) {
+		h.Metrics.Record(nil, metrics.Count(metrics.QuotaExceeded, 1))
+
 		return nil
 	}
 
-	log.New("Fact-finding request for: %s, %s", currentUser.PhoneNumber, msg.Body).Log()
+	log.New("Fact-finding request for user %d", currentUser.ID).AddMessage(&msg).Log()
 
-	identifiedFacts, err := h.Service.FindFacts(msg.Body)
+	identifiedFacts, err := h.Service.FindFacts(currentUser.ID, msg.Body)
 
 	if err != nil {
 		log.New("Error in FindFacts").AddError(err).Log()
 
-		return nil
+		return err
+	}
+
+	extracted := 0
+
+	if identifiedFacts != nil {
+		extracted = len(*identifiedFacts)
 	}
 
+	h.Metrics.Record(nil, metrics.Count(metrics.FactsExtracted, extracted))
+
 	// If we detected facts...
-	if identifiedFacts != nil && len(*identifiedFacts) > 0 {
+	if extracted > 0 {
 		for _, fact := range *identifiedFacts {
 
 			// Map the fact
@@ -132,16 +168,16 @@ func (h *FactFinderLambdaHandler) processMessage(record events.SQSMessage) error
 			if err != nil {
 				log.New("Error saving fact: %s", fact.Fact).AddError(err).Log()
 
-				return nil
+				return err
 			}
 
 		}
 	} else {
 
-		log.New("No facts identified for %s", currentUser.PhoneNumber).Log()
+		log.New("No facts identified for user %d", currentUser.ID).Log()
 	}
 
-	log.New("Fact-finding complete for: %s", currentUser.PhoneNumber).Log()
+	log.New("Fact-finding complete for user %d", currentUser.ID).Log()
 	return nil
 }
 
@@ -162,6 +198,12 @@ func main() {
 		log.New("Could not load config")
 	}
 
+	if err := tracing.Init(cfg, "factfinder"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
 	database := db.Get(cfg)
 
 	if err := utils.PingDatabase(database); err != nil {
@@ -170,8 +212,20 @@ func main() {
 		return
 	}
 
+	recorder, err := metrics.New(cfg, "factfinder")
+
+	if err != nil {
+		log.New("Error creating metrics recorder. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	usageSvc := usage.NewService(usage.NewRepository(database), "factfinder", cfg.LLMMonthlyCostQuota)
+
 	completionSvc := &ai.OpenAICompletionService{
 		RemoveEmojis: true,
+		Metrics:      recorder,
+		Ledger:       usageSvc,
 	}
 
 	factRepo := facts.NewRepository(database)
@@ -179,6 +233,8 @@ func main() {
 
 	handler := &FactFinderLambdaHandler{
 		Service: factSvc,
+		Metrics: recorder,
+		Usage:   usageSvc,
 	}
 
 	handler.Init(database)
@@ -6,4 +6,23 @@ type CompletionServiceInterface interface {
 	GetCompletion(message, prompt string, memories *[]models.Message) (string, error)
 	CleanCompletionText(completion string) string
 	GetEmbeddings(text string) ([]float32, error)
+	DescribeImage(imageURL string) (string, error)
+	ShortenCompletion(completion string, maxCharacters int) (string, error)
+}
+
+// UserScoped is a completion service that can attribute the requests it
+// makes to a user, for their usage and quota.
+type UserScoped interface {
+	ForUser(userID int64) CompletionServiceInterface
+}
+
+// ForUser returns [svc] attributing its requests to [userID], or [svc]
+// itself if it doesn't record usage.
+func ForUser(svc CompletionServiceInterface, userID int64) CompletionServiceInterface {
+
+	if scoped, ok := svc.(UserScoped); ok {
+		return scoped.ForUser(userID)
+	}
+
+	return svc
 }
@@ -5,3 +5,25 @@ const (
 	EmbeddingServiceModel = "text-embedding-3-large"
 	EmbeddingDimensions   = 1024
 )
+
+// Length control
+const (
+	// ShortenCompletionPrompt asks the model to rewrite a reply that is too
+	// long to send in the number of text messages we budget for it.
+	ShortenCompletionPrompt = "Rewrite the following text message so it is no " +
+		"more than %d characters long. Keep its meaning, warmth and any " +
+		"question it asks. Use plain punctuation and no emoji. Respond with " +
+		"only the rewritten message."
+)
+
+// Vision
+const (
+	// ImageDescriptionPrompt asks the vision model to describe a photo a
+	// user sent, so the description can stand in for it in the conversation.
+	ImageDescriptionPrompt = "A person texting their therapist sent this photo. " +
+		"Describe what it shows in two or three plain sentences, including " +
+		"anything that hints at how they might be feeling. Do not speculate " +
+		"about who the people in it are."
+
+	ImageDescriptionMaxTokens = 200
+)
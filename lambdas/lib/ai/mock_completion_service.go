@@ -15,3 +15,11 @@ func (m *MockCompletionService) CleanCompletionText(_ string) string {
 func (m *MockCompletionService) GetEmbeddings(_ string) ([]float32, error) {
 	return []float32{0.0, 1.0, 2.0}, nil
 }
+
+func (m *MockCompletionService) ShortenCompletion(_ string, _ int) (string, error) {
+	return "dummy shortened completion", nil
+}
+
+func (m *MockCompletionService) DescribeImage(_ string) (string, error) {
+	return "dummy image description", nil
+}
@@ -12,11 +12,45 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
 type OpenAICompletionService struct {
 	RemoveEmojis bool
+
+	// Metrics records the tokens each request used
+	Metrics *metrics.Recorder
+
+	// Ledger records what each request cost, for UserID. Services are
+	// bound to a user with ForUser.
+	Ledger UsageLedger
+	UserID int64
+}
+
+// UsageLedger records the tokens a request to the model used, for the
+// user it was made for.
+type UsageLedger interface {
+	Record(userID int64, operation, model string, promptTokens, completionTokens int) error
+}
+
+// ForUser returns a copy of the service that attributes its requests to
+// [userID].
+func (o *OpenAICompletionService) ForUser(userID int64) CompletionServiceInterface {
+
+	scoped := *o
+	scoped.UserID = userID
+
+	return &scoped
+}
+
+// newClient returns an OpenAI client for the configured API URL.
+func newClient() *openai.Client {
+
+	clientConfig := openai.DefaultConfig(config.Get().OpenAIAPIKey)
+	clientConfig.BaseURL = config.Get().OpenAIBaseURL
+
+	return openai.NewClientWithConfig(clientConfig)
 }
 
 func (o *OpenAICompletionService) CleanCompletionText(completion string) string {
@@ -26,8 +60,8 @@ func (o *OpenAICompletionService) CleanCompletionText(completion string) string
 		log.New("Detected non GSM encoded completion: %s", completion).Log()
 	}
 
-	completion = strings.Replace(completion, "’", "'", -1)
-	completion = strings.Replace(completion, "—", "-", -1)
+	// Swap typographic punctuation for GSM, so the reply isn't sent as UCS-2
+	completion = encoding.Transliterate(completion)
 	completion = strings.Replace(completion, "! ?", "!", -1)
 
 	if o.RemoveEmojis {
@@ -43,7 +77,7 @@ func (o *OpenAICompletionService) CleanCompletionText(completion string) string
 
 func (o *OpenAICompletionService) GetEmbeddings(text string) ([]float32, error) {
 
-	client := openai.NewClient(config.Get().OpenAIAPIKey)
+	client := newClient()
 
 	embeddingsReq := openai.EmbeddingRequest{
 		Model: EmbeddingServiceModel,
@@ -62,6 +96,8 @@ func (o *OpenAICompletionService) GetEmbeddings(text string) ([]float32, error)
 		return nil, fmt.Errorf("embeddings data slice was empty")
 	}
 
+	o.recordUsage("embedding", string(embeddingsResp.Model), embeddingsResp.Usage)
+
 	// Log the retrieved embeddings for audit
 	log.New("Successfully retrieved embeddings").
 		Add("embeddings_count", strconv.Itoa(len(embeddingsResp.Data[0].Embedding))).
@@ -71,6 +107,55 @@ func (o *OpenAICompletionService) GetEmbeddings(text string) ([]float32, error)
 	return embeddingsResp.Data[0].Embedding, nil
 }
 
+// DescribeImage asks a vision capable model to describe the image at
+// [imageURL], which may be a data URL.
+func (o *OpenAICompletionService) DescribeImage(imageURL string) (string, error) {
+
+	client := newClient()
+
+	resp, err := client.CreateChatCompletion(
+		context.Background(),
+		openai.ChatCompletionRequest{
+			Model:     config.Get().VisionModelName,
+			MaxTokens: ImageDescriptionMaxTokens,
+			Messages: []openai.ChatCompletionMessage{{
+				Role: openai.ChatMessageRoleUser,
+				MultiContent: []openai.ChatMessagePart{
+					{
+						Type: openai.ChatMessagePartTypeText,
+						Text: ImageDescriptionPrompt,
+					},
+					{
+						Type: openai.ChatMessagePartTypeImageURL,
+						ImageURL: &openai.ChatMessageImageURL{
+							URL:    imageURL,
+							Detail: openai.ImageURLDetailLow,
+						},
+					},
+				},
+			}},
+		},
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("vision model returned no choices")
+	}
+
+	o.recordUsage("vision", resp.Model, resp.Usage)
+
+	log.New("OpenAI Audit Trail: Described image.").
+		Add("model", resp.Model).
+		Add("completion_tokens", strconv.Itoa(resp.Usage.CompletionTokens)).
+		Add("prompt_tokens", strconv.Itoa(resp.Usage.PromptTokens)).
+		Log()
+
+	return strings.TrimSpace(resp.Choices[0].Message.Content), nil
+}
+
 func (o *OpenAICompletionService) GetCompletion(
 	message, prompt string, memories *[]models.Message,
 ) (string, error) {
@@ -80,17 +165,94 @@ func (o *OpenAICompletionService) GetCompletion(
 		promptTokenCount,
 		historyTokenCount,
 		totalTokenCount int
-
-		memoryDump = ""
 	)
 
 	var totalMemories = 0
-	var messages []openai.ChatCompletionMessage
 
-	if memories != nil && len(*memories) > 0 {
+	if memories != nil {
 		totalMemories = len(*memories)
 
-		// Add memories
+		// Keep count of the tokens used in just the
+		// memories, and the primary payload.
+		for _, m := range *memories {
+			historyTokenCount += len(m.Body)
+		}
+	}
+
+	messages := ChatMessages(message, prompt, memories)
+
+	promptTokenCount = len(prompt)
+	totalTokenCount = historyTokenCount + promptTokenCount
+
+	log.New("OpenAI Audit Trail: Sending prompt.").
+		Add("prompt_char_count", strconv.Itoa(promptTokenCount)).
+		Add("history_char_count", strconv.Itoa(historyTokenCount)).
+		Add("total_char_count", strconv.Itoa(totalTokenCount)).
+		Add("total_memories", strconv.Itoa(totalMemories)).
+		Add("prompt", prompt).
+		Log()
+
+	client := newClient()
+	resp, err := client.CreateChatCompletion(
+		context.Background(),
+		openai.ChatCompletionRequest{
+			Model:            config.Get().ChatModelName,
+			Messages:         messages,
+			Temperature:      config.Get().ChatModelTemperature,
+			MaxTokens:        config.Get().ChatModelMaxCompletionTokens,
+			FrequencyPenalty: config.Get().ChatModelFrequencyPenalty,
+		},
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	o.recordUsage("completion", resp.Model, resp.Usage)
+
+	log.New("OpenAI Audit Trail: Received response.").
+		Add("model", resp.Model).
+		Add("completion_tokens", strconv.Itoa(resp.Usage.CompletionTokens)).
+		Add("prompt_tokens", strconv.Itoa(resp.Usage.PromptTokens)).
+		Add("total_tokens", strconv.Itoa(resp.Usage.TotalTokens)).
+		Add("response_content", resp.Choices[0].Message.Content).
+		Add("prompt", prompt).
+		Log()
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// recordUsage records the tokens a request for [operation] used, and
+// what it cost. A request that can't be recorded has still been answered,
+// so errors are only logged.
+func (o *OpenAICompletionService) recordUsage(operation, model string, usage openai.Usage) {
+
+	o.Metrics.Record(
+		metrics.Dimensions{"Operation": operation, "Model": model},
+		metrics.Count(metrics.PromptTokens, usage.PromptTokens),
+		metrics.Count(metrics.CompletionTokens, usage.CompletionTokens),
+		metrics.Count(metrics.TotalTokens, usage.TotalTokens),
+	)
+
+	if o.Ledger == nil {
+		return
+	}
+
+	err := o.Ledger.Record(o.UserID, operation, model, usage.PromptTokens, usage.CompletionTokens)
+
+	if err != nil {
+		log.New("Error recording %s usage for user %d", operation, o.UserID).
+			AddError(err).Log()
+	}
+}
+
+// ChatMessages builds the conversation sent to the model: the [memories]
+// in order, then the system [prompt], then the user's [message].
+func ChatMessages(message, prompt string, memories *[]models.Message) []openai.ChatCompletionMessage {
+
+	var messages []openai.ChatCompletionMessage
+
+	if memories != nil {
 		for _, m := range *memories {
 
 			role := openai.ChatMessageRoleUser
@@ -99,23 +261,13 @@ func (o *OpenAICompletionService) GetCompletion(
 				role = openai.ChatMessageRoleAssistant
 			}
 
-			body := fmt.Sprintf("%s %s", m.CreatedAt, m.Body)
-			memoryDump += body + "\n"
-
 			messages = append(messages, openai.ChatCompletionMessage{
 				Role:    role,
-				Content: body,
+				Content: fmt.Sprintf("%s %s", m.CreatedAt, m.Body),
 			})
-
-			// Keep count of the tokens used in just the
-			// memories, and the primary payload.
-			historyTokenCount += len(m.Body)
 		}
 	}
 
-	promptTokenCount = len(prompt)
-	totalTokenCount = historyTokenCount + promptTokenCount
-
 	// Add current prompt
 	messages = append(messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleSystem,
@@ -123,28 +275,34 @@ func (o *OpenAICompletionService) GetCompletion(
 	})
 
 	// Add the current message
-	messages = append(messages, openai.ChatCompletionMessage{
+	return append(messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
 		Content: message,
 	})
+}
 
-	log.New("OpenAI Audit Trail: Sending prompt.").
-		Add("prompt_char_count", strconv.Itoa(promptTokenCount)).
-		Add("history_char_count", strconv.Itoa(historyTokenCount)).
-		Add("total_char_count", strconv.Itoa(totalTokenCount)).
-		Add("total_memories", strconv.Itoa(totalMemories)).
-		Add("prompt", prompt).
-		Log()
+// ShortenCompletion asks the model to rewrite [completion] in no more
+// than [maxCharacters] characters.
+func (o *OpenAICompletionService) ShortenCompletion(completion string, maxCharacters int) (string, error) {
+
+	client := newClient()
 
-	client := openai.NewClient(config.Get().OpenAIAPIKey)
 	resp, err := client.CreateChatCompletion(
 		context.Background(),
 		openai.ChatCompletionRequest{
-			Model:            config.Get().ChatModelName,
-			Messages:         messages,
-			Temperature:      config.Get().ChatModelTemperature,
-			MaxTokens:        config.Get().ChatModelMaxCompletionTokens,
-			FrequencyPenalty: config.Get().ChatModelFrequencyPenalty,
+			Model:       config.Get().ChatModelName,
+			Temperature: config.Get().ChatModelTemperature,
+			MaxTokens:   config.Get().ChatModelMaxCompletionTokens,
+			Messages: []openai.ChatCompletionMessage{
+				{
+					Role:    openai.ChatMessageRoleSystem,
+					Content: fmt.Sprintf(ShortenCompletionPrompt, maxCharacters),
+				},
+				{
+					Role:    openai.ChatMessageRoleUser,
+					Content: completion,
+				},
+			},
 		},
 	)
 
@@ -152,13 +310,17 @@ func (o *OpenAICompletionService) GetCompletion(
 		return "", err
 	}
 
-	log.New("OpenAI Audit Trail: Received response.").
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("chat model returned no choices")
+	}
+
+	o.recordUsage("shorten", resp.Model, resp.Usage)
+
+	log.New("OpenAI Audit Trail: Shortened completion.").
 		Add("model", resp.Model).
+		Add("max_characters", strconv.Itoa(maxCharacters)).
 		Add("completion_tokens", strconv.Itoa(resp.Usage.CompletionTokens)).
 		Add("prompt_tokens", strconv.Itoa(resp.Usage.PromptTokens)).
-		Add("total_tokens", strconv.Itoa(resp.Usage.TotalTokens)).
-		Add("response_content", resp.Choices[0].Message.Content).
-		Add("prompt", prompt).
 		Log()
 
 	return resp.Choices[0].Message.Content, nil
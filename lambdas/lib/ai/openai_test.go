@@ -3,6 +3,7 @@ package ai
 import (
 	"testing"
 
+	"github.com/sashabaranov/go-openai"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,3 +59,37 @@ func TestCleanCompletionText_NoEmojiRemoval(t *testing.T) {
 		t.Errorf("Expected %s, got %s", expected, result)
 	}
 }
+
+type recordedUsage struct {
+	userID                         int64
+	operation, model               string
+	promptTokens, completionTokens int
+}
+
+type usageLedger struct {
+	recorded []recordedUsage
+}
+
+func (l *usageLedger) Record(userID int64, operation, model string, promptTokens, completionTokens int) error {
+	l.recorded = append(l.recorded, recordedUsage{userID, operation, model, promptTokens, completionTokens})
+
+	return nil
+}
+
+func TestForUser(t *testing.T) {
+	ledger := &usageLedger{}
+	o := &OpenAICompletionService{Ledger: ledger}
+
+	scoped := ForUser(o, 2).(*OpenAICompletionService)
+	scoped.recordUsage("completion", "gpt-4o", openai.Usage{PromptTokens: 10, CompletionTokens: 5})
+	o.recordUsage("embedding", "text-embedding-3-large", openai.Usage{PromptTokens: 3})
+
+	assert.Equal(t, []recordedUsage{
+		{2, "completion", "gpt-4o", 10, 5},
+		{0, "embedding", "text-embedding-3-large", 3, 0},
+	}, ledger.recorded)
+
+	// Services that don't record usage are used as they are
+	mock := &MockCompletionService{}
+	assert.Same(t, mock, ForUser(mock, 2))
+}
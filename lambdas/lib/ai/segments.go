@@ -0,0 +1,63 @@
+package ai
+
+import (
+	"strconv"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+)
+
+// FitToSegments asks the model to shorten a cleaned [completion] until it
+// fits in [maxSegments] SMS segments, up to [attempts] times. If it still
+// doesn't fit, or the model fails, the shortest version is returned and
+// the reply is split when it is sent.
+func FitToSegments(svc CompletionServiceInterface, completion string, maxSegments, attempts int) string {
+
+	best := completion
+	bestSegments := encoding.CountSegments(best)
+
+	for i := 0; i < attempts && bestSegments.Segments > maxSegments; i++ {
+
+		shortened, err := svc.ShortenCompletion(best, MaxCharacters(maxSegments, bestSegments.Encoding))
+
+		if err != nil {
+			log.New("Error shortening completion").AddError(err).Log()
+
+			break
+		}
+
+		shortened = svc.CleanCompletionText(shortened)
+		segments := encoding.CountSegments(shortened)
+
+		log.New("Shortened completion").
+			Add("attempt", strconv.Itoa(i+1)).
+			Add("max_segments", strconv.Itoa(maxSegments)).
+			Add("segments_before", strconv.Itoa(bestSegments.Segments)).
+			Add("segments_after", strconv.Itoa(segments.Segments)).
+			Log()
+
+		if shortened == "" || segments.Segments >= bestSegments.Segments {
+			continue
+		}
+
+		best, bestSegments = shortened, segments
+	}
+
+	return best
+}
+
+// MaxCharacters is how many characters fit in [segments] segments.
+func MaxCharacters(segments int, enc encoding.Encoding) int {
+
+	single, multipart := encoding.GSM7SingleSegmentSize, encoding.GSM7MultipartSegmentSize
+
+	if enc == encoding.EncodingUCS2 {
+		single, multipart = encoding.UCS2SingleSegmentSize, encoding.UCS2MultipartSegmentSize
+	}
+
+	if segments <= 1 {
+		return single
+	}
+
+	return segments * multipart
+}
@@ -0,0 +1,80 @@
+package ai
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// shorteningCompletionService returns each of its replies in turn
+// when asked to shorten a completion.
+type shorteningCompletionService struct {
+	MockCompletionService
+	replies []string
+	calls   int
+	err     error
+}
+
+func (s *shorteningCompletionService) CleanCompletionText(completion string) string {
+	return completion
+}
+
+func (s *shorteningCompletionService) GetCompletion(_, _ string, _ *[]models.Message) (string, error) {
+	return "", nil
+}
+
+func (s *shorteningCompletionService) ShortenCompletion(_ string, _ int) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+
+	reply := s.replies[s.calls]
+	s.calls++
+
+	return reply, nil
+}
+
+func TestFitToSegments_AlreadyFits(t *testing.T) {
+	svc := &shorteningCompletionService{}
+
+	assert.Equal(t, "short", FitToSegments(svc, "short", 1, 2))
+	assert.Equal(t, 0, svc.calls, "the model shouldn't be asked to shorten a reply that fits")
+}
+
+func TestFitToSegments_Shortens(t *testing.T) {
+	long := strings.Repeat("a", 400)
+	svc := &shorteningCompletionService{
+		replies: []string{strings.Repeat("a", 200), strings.Repeat("a", 150)},
+	}
+
+	assert.Equal(t, strings.Repeat("a", 150), FitToSegments(svc, long, 1, 2))
+	assert.Equal(t, 2, svc.calls)
+}
+
+func TestFitToSegments_GivesUp(t *testing.T) {
+	long := strings.Repeat("a", 400)
+	svc := &shorteningCompletionService{
+		replies: []string{strings.Repeat("a", 500), strings.Repeat("a", 300)},
+	}
+
+	// The longer rewrite is ignored, and the shortest is kept
+	assert.Equal(t, strings.Repeat("a", 300), FitToSegments(svc, long, 1, 2))
+}
+
+func TestFitToSegments_Error(t *testing.T) {
+	long := strings.Repeat("a", 400)
+	svc := &shorteningCompletionService{err: errors.New("timeout")}
+
+	assert.Equal(t, long, FitToSegments(svc, long, 1, 2))
+}
+
+func TestMaxCharacters(t *testing.T) {
+	assert.Equal(t, 160, MaxCharacters(1, encoding.EncodingGSM7))
+	assert.Equal(t, 306, MaxCharacters(2, encoding.EncodingGSM7))
+	assert.Equal(t, 70, MaxCharacters(1, encoding.EncodingUCS2))
+}
@@ -0,0 +1,111 @@
+package analytics
+
+import (
+	"fmt"
+	"time"
+)
+
+// The ways users can be grouped into cohorts.
+const (
+	GroupByDay    = "day"
+	GroupByWeek   = "week"
+	GroupByUser   = "user"
+	GroupByPrompt = "prompt"
+)
+
+// CohortQuery selects the metrics to add up, and how to group them.
+type CohortQuery struct {
+	// From and To are the first and last days to include
+	From time.Time
+	To   time.Time
+
+	GroupBy string
+
+	// Prompt is the prompt whose variants users are grouped by, when
+	// grouping by prompt
+	Prompt string
+
+	// UserIDs limits the query to some users. All users are included when empty.
+	UserIDs []int64
+}
+
+// Validate checks the query can be run.
+func (q CohortQuery) Validate() error {
+
+	switch q.GroupBy {
+	case GroupByDay, GroupByWeek, GroupByUser:
+	case GroupByPrompt:
+		if q.Prompt == "" {
+			return fmt.Errorf("a prompt is required to group by prompt")
+		}
+	default:
+		return fmt.Errorf("unknown group %q", q.GroupBy)
+	}
+
+	if q.To.Before(q.From) {
+		return fmt.Errorf("the range ends before it starts")
+	}
+
+	return nil
+}
+
+// CohortTotals are the summed metrics of a cohort, as stored.
+type CohortTotals struct {
+	Cohort                 string
+	Users                  int64
+	MessagesSent           int64
+	MessagesReceived       int64
+	MessagesThrottled      int64
+	NudgesSent             int64
+	NudgesReplied          int64
+	Responses              int64
+	ResponseLatencySeconds float64
+	SentimentSamples       int64
+	VaderCompoundTotal     float64
+}
+
+// CohortMetrics describe a cohort's engagement. Rates and averages are
+// null when there's nothing to average.
+type CohortMetrics struct {
+	Cohort            string `json:"cohort"`
+	Users             int64  `json:"users"`
+	MessagesSent      int64  `json:"messages_sent"`
+	MessagesReceived  int64  `json:"messages_received"`
+	MessagesThrottled int64  `json:"messages_throttled"`
+	NudgesSent        int64  `json:"nudges_sent"`
+	NudgesReplied     int64  `json:"nudges_replied"`
+
+	NudgeReplyRate         *float64 `json:"nudge_reply_rate"`
+	AverageResponseSeconds *float64 `json:"average_response_seconds"`
+	AverageVaderCompound   *float64 `json:"average_vader_compound"`
+	SentimentSamples       int64    `json:"sentiment_samples"`
+}
+
+// NewCohortMetrics works out the rates and averages of [totals].
+func NewCohortMetrics(totals CohortTotals) CohortMetrics {
+
+	return CohortMetrics{
+		Cohort:                 totals.Cohort,
+		Users:                  totals.Users,
+		MessagesSent:           totals.MessagesSent,
+		MessagesReceived:       totals.MessagesReceived,
+		MessagesThrottled:      totals.MessagesThrottled,
+		NudgesSent:             totals.NudgesSent,
+		NudgesReplied:          totals.NudgesReplied,
+		NudgeReplyRate:         ratio(float64(totals.NudgesReplied), totals.NudgesSent),
+		AverageResponseSeconds: ratio(totals.ResponseLatencySeconds, totals.Responses),
+		AverageVaderCompound:   ratio(totals.VaderCompoundTotal, totals.SentimentSamples),
+		SentimentSamples:       totals.SentimentSamples,
+	}
+}
+
+func ratio(total float64, count int64) *float64 {
+
+	if count == 0 {
+		return nil
+	}
+
+	r := total / float64(count)
+
+	return &r
+}
@@ -0,0 +1,51 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+)
+
+func TestNewCohortMetrics(t *testing.T) {
+
+	metrics := analytics.NewCohortMetrics(analytics.CohortTotals{
+		Cohort:                 "v2",
+		Users:                  3,
+		NudgesSent:             4,
+		NudgesReplied:          3,
+		Responses:              2,
+		ResponseLatencySeconds: 120,
+		SentimentSamples:       4,
+		VaderCompoundTotal:     1,
+	})
+
+	assert.Equal(t, 0.75, *metrics.NudgeReplyRate)
+	assert.Equal(t, 60.0, *metrics.AverageResponseSeconds)
+	assert.Equal(t, 0.25, *metrics.AverageVaderCompound)
+}
+
+func TestNewCohortMetrics_NothingToAverage(t *testing.T) {
+
+	metrics := analytics.NewCohortMetrics(analytics.CohortTotals{Cohort: "2024-06-10"})
+
+	assert.Nil(t, metrics.NudgeReplyRate)
+	assert.Nil(t, metrics.AverageResponseSeconds)
+	assert.Nil(t, metrics.AverageVaderCompound)
+}
+
+func TestCohortQuery_Validate(t *testing.T) {
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	assert.NoError(t, analytics.CohortQuery{From: from, To: to, GroupBy: analytics.GroupByDay}.Validate())
+	assert.NoError(t, analytics.CohortQuery{From: from, To: from, GroupBy: analytics.GroupByUser}.Validate())
+
+	assert.Error(t, analytics.CohortQuery{From: from, To: to, GroupBy: "month"}.Validate())
+	assert.Error(t, analytics.CohortQuery{From: to, To: from, GroupBy: analytics.GroupByDay}.Validate())
+	assert.Error(t, analytics.CohortQuery{From: from, To: to, GroupBy: analytics.GroupByPrompt}.Validate(),
+		"Grouping by prompt needs to know which prompt")
+}
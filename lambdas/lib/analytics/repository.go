@@ -0,0 +1,110 @@
+package analytics
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository reads the data metrics are rolled up from, and stores them.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindActivity retrieves the messages sent between [from] and [to], oldest first.
+func (r *Repository) FindActivity(from, to time.Time) ([]Activity, error) {
+
+	var activity []Activity
+
+	err := r.db.Table("messages").
+		Select("messages.id, messages.from_user_id, messages.to_user_id, messages.created_at, messages.message_status_id, "+
+			"conversations.user_id AS conversation_user_id").
+		Joins("LEFT JOIN conversations ON conversations.id = messages.conversation_id").
+		Where("messages.created_at >= ? AND messages.created_at < ? AND messages.deleted_at IS NULL", from.UTC(), to.UTC()).
+		Order("messages.created_at").
+		Scan(&activity).Error
+
+	return activity, err
+}
+
+// FindSentiments retrieves the sentiment scores recorded between [from] and [to].
+func (r *Repository) FindSentiments(from, to time.Time) ([]Sentiment, error) {
+
+	var sentiments []Sentiment
+
+	err := r.db.Model(&models.NrcLex{}).
+		Select("user_id, vader_compound, created_at").
+		Where("created_at >= ? AND created_at < ? AND deleted_at IS NULL", from.UTC(), to.UTC()).
+		Scan(&sentiments).Error
+
+	return sentiments, err
+}
+
+// SaveMetrics stores [metrics], replacing any already rolled up for the
+// same user and day.
+func (r *Repository) SaveMetrics(metrics []models.UserMetric) error {
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"messages_sent", "messages_received", "messages_throttled", "nudges_sent", "nudges_replied",
+			"responses", "response_latency_seconds", "sentiment_samples",
+			"vader_compound_total", "updated_at",
+		}),
+	}).Create(&metrics).Error
+}
+
+// cohortKeys are the SQL expressions that name each kind of cohort.
+var cohortKeys = map[string]string{
+	GroupByDay:    "DATE_FORMAT(user_metrics.date, '%Y-%m-%d')",
+	GroupByWeek:   "DATE_FORMAT(user_metrics.date, '%x-W%v')",
+	GroupByUser:   "CAST(user_metrics.user_id AS CHAR)",
+	GroupByPrompt: "COALESCE(prompt_assignments.version, 'unassigned')",
+}
+
+// FindCohortTotals adds up the metrics selected by [query] for each cohort.
+func (r *Repository) FindCohortTotals(query CohortQuery) ([]CohortTotals, error) {
+
+	var totals []CohortTotals
+
+	key := cohortKeys[query.GroupBy]
+
+	tx := r.db.Table("user_metrics").
+		Select(key+" AS cohort, "+
+			"COUNT(DISTINCT user_metrics.user_id) AS users, "+
+			"SUM(user_metrics.messages_sent) AS messages_sent, "+
+			"SUM(user_metrics.messages_received) AS messages_received, "+
+			"SUM(user_metrics.messages_throttled) AS messages_throttled, "+
+			"SUM(user_metrics.nudges_sent) AS nudges_sent, "+
+			"SUM(user_metrics.nudges_replied) AS nudges_replied, "+
+			"SUM(user_metrics.responses) AS responses, "+
+			"SUM(user_metrics.response_latency_seconds) AS response_latency_seconds, "+
+			"SUM(user_metrics.sentiment_samples) AS sentiment_samples, "+
+			"SUM(user_metrics.vader_compound_total) AS vader_compound_total").
+		Where("user_metrics.date BETWEEN ? AND ?", Day(query.From), Day(query.To))
+
+	if query.GroupBy == GroupByPrompt {
+		tx = tx.Joins("LEFT JOIN prompt_assignments ON prompt_assignments.user_id = user_metrics.user_id "+
+			"AND prompt_assignments.prompt_name = ?", query.Prompt)
+	}
+
+	if len(query.UserIDs) > 0 {
+		tx = tx.Where("user_metrics.user_id IN ?", query.UserIDs)
+	}
+
+	err := tx.Group("cohort").Order("cohort").Scan(&totals).Error
+
+	return totals, err
+}
@@ -0,0 +1,195 @@
+// Package analytics rolls users' messages and sentiment scores up into
+// daily engagement metrics, and adds them up across cohorts of users.
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// DefaultReplyWindow is how long a user has to answer one of our messages
+// for it to count as a reply.
+const DefaultReplyWindow = 24 * time.Hour
+
+// Activity is a message between a user and the system.
+type Activity struct {
+	ID         int64
+	FromUserID int64
+	ToUserID   int64
+	CreatedAt  time.Time
+
+	MessageStatusID int64
+
+	// ConversationUserID is who started the conversation. The nudger
+	// starts conversations as the system user.
+	ConversationUserID *int64
+}
+
+// UserID is the user on the other end of the message from the system.
+func (a Activity) UserID() int64 {
+
+	if a.FromUserID == models.GetSystemUser().ID {
+		return a.ToUserID
+	}
+
+	return a.FromUserID
+}
+
+// FromUser reports whether the user sent the message.
+func (a Activity) FromUser() bool {
+	return a.FromUserID != models.GetSystemUser().ID
+}
+
+// Throttled reports whether the message went unanswered because the user
+// was over their quota.
+func (a Activity) Throttled() bool {
+	return a.MessageStatusID == models.NewMessageStatusThrottled().ID
+}
+
+// Nudge reports whether the message is a nudge.
+func (a Activity) Nudge() bool {
+
+	return !a.FromUser() &&
+		a.ConversationUserID != nil &&
+		*a.ConversationUserID == models.GetSystemUser().ID
+}
+
+// Sentiment is the VADER compound score of a user's message.
+type Sentiment struct {
+	UserID        int64
+	VaderCompound float64
+	CreatedAt     time.Time
+}
+
+// Day returns the UTC day [t] falls on.
+func Day(t time.Time) time.Time {
+
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// Rollup computes each user's metrics for [day]. [activity] should cover
+// [replyWindow] either side of the day, so that replies to messages sent
+// late in the day, and answers to messages sent the day before, are seen.
+func Rollup(day time.Time, activity []Activity, sentiments []Sentiment, replyWindow time.Duration) []models.UserMetric {
+
+	start := Day(day)
+	end := start.AddDate(0, 0, 1)
+
+	onDay := func(t time.Time) bool {
+		return !t.Before(start) && t.Before(end)
+	}
+
+	metrics := map[int64]*models.UserMetric{}
+
+	metric := func(userID int64) *models.UserMetric {
+
+		if metrics[userID] == nil {
+			metrics[userID] = &models.UserMetric{UserID: userID, Date: start}
+		}
+
+		return metrics[userID]
+	}
+
+	for userID, thread := range threads(activity) {
+
+		for i, message := range thread {
+
+			if !onDay(message.CreatedAt) {
+				continue
+			}
+
+			m := metric(userID)
+
+			if message.FromUser() {
+				m.MessagesSent++
+
+				if message.Throttled() {
+					m.MessagesThrottled++
+				}
+
+				// Time taken to answer the message before, if it was ours
+				if i > 0 && !thread[i-1].FromUser() {
+					if latency := message.CreatedAt.Sub(thread[i-1].CreatedAt); latency <= replyWindow {
+						m.Responses++
+						m.ResponseLatencySeconds += latency.Seconds()
+					}
+				}
+
+				continue
+			}
+
+			m.MessagesReceived++
+
+			if message.Nudge() {
+				m.NudgesSent++
+
+				if replied(thread[i+1:], message, replyWindow) {
+					m.NudgesReplied++
+				}
+			}
+		}
+	}
+
+	for _, sentiment := range sentiments {
+
+		if !onDay(sentiment.CreatedAt) {
+			continue
+		}
+
+		m := metric(sentiment.UserID)
+		m.SentimentSamples++
+		m.VaderCompoundTotal += sentiment.VaderCompound
+	}
+
+	rollup := make([]models.UserMetric, 0, len(metrics))
+
+	for _, m := range metrics {
+		rollup = append(rollup, *m)
+	}
+
+	sort.Slice(rollup, func(i, j int) bool {
+		return rollup[i].UserID < rollup[j].UserID
+	})
+
+	return rollup
+}
+
+// threads groups [activity] by user, oldest first.
+func threads(activity []Activity) map[int64][]Activity {
+
+	byUser := map[int64][]Activity{}
+
+	for _, a := range activity {
+		byUser[a.UserID()] = append(byUser[a.UserID()], a)
+	}
+
+	for _, thread := range byUser {
+		sort.SliceStable(thread, func(i, j int) bool {
+			return thread[i].CreatedAt.Before(thread[j].CreatedAt)
+		})
+	}
+
+	return byUser
+}
+
+// replied reports whether the user answered [nudge] within [replyWindow],
+// looking at the messages [after] it.
+func replied(after []Activity, nudge Activity, replyWindow time.Duration) bool {
+
+	for _, message := range after {
+
+		if message.CreatedAt.Sub(nudge.CreatedAt) > replyWindow {
+			return false
+		}
+
+		if message.FromUser() {
+			return true
+		}
+	}
+
+	return false
+}
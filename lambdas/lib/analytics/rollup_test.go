@@ -0,0 +1,125 @@
+package analytics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var (
+	day    = time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)
+	system = models.GetSystemUser().ID
+)
+
+func at(hours float64) time.Time {
+	return day.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+func fromUser(userID int64, t time.Time) analytics.Activity {
+	return analytics.Activity{FromUserID: userID, ToUserID: system, CreatedAt: t, ConversationUserID: &userID}
+}
+
+func reply(userID int64, t time.Time) analytics.Activity {
+	return analytics.Activity{FromUserID: system, ToUserID: userID, CreatedAt: t, ConversationUserID: &userID}
+}
+
+func nudge(userID int64, t time.Time) analytics.Activity {
+	return analytics.Activity{FromUserID: system, ToUserID: userID, CreatedAt: t, ConversationUserID: &system}
+}
+
+func TestRollup(t *testing.T) {
+
+	activity := []analytics.Activity{
+		// Yesterday's nudge, answered this morning
+		nudge(2, at(-2)),
+		fromUser(2, at(1)),
+		reply(2, at(1.01)),
+
+		// A nudge answered within the hour
+		nudge(2, at(10)),
+		fromUser(2, at(11)),
+		reply(2, at(11.01)),
+
+		// A nudge late in the day, answered tomorrow
+		nudge(2, at(23)),
+		fromUser(2, at(25)),
+
+		// Another user ignores their nudge
+		nudge(3, at(10)),
+		fromUser(3, at(40)),
+	}
+
+	sentiments := []analytics.Sentiment{
+		{UserID: 2, VaderCompound: 0.5, CreatedAt: at(1)},
+		{UserID: 2, VaderCompound: -0.1, CreatedAt: at(11)},
+		{UserID: 2, VaderCompound: 0.9, CreatedAt: at(25)},
+	}
+
+	metrics := analytics.Rollup(at(12), activity, sentiments, analytics.DefaultReplyWindow)
+
+	require.Len(t, metrics, 2)
+
+	user := metrics[0]
+	assert.Equal(t, int64(2), user.UserID)
+	assert.Equal(t, day, user.Date)
+	assert.Equal(t, 2, user.MessagesSent)
+	assert.Equal(t, 4, user.MessagesReceived, "Nudges are messages the user received")
+	assert.Equal(t, 2, user.NudgesSent, "Yesterday's nudge belongs to yesterday")
+	assert.Equal(t, 2, user.NudgesReplied, "A reply the next day counts toward the day of the nudge")
+	assert.Equal(t, 2, user.Responses)
+	assert.InDelta(t, 4*time.Hour.Seconds(), user.ResponseLatencySeconds, 0.001)
+	assert.Equal(t, 2, user.SentimentSamples, "Tomorrow's sentiment belongs to tomorrow")
+	assert.InDelta(t, 0.4, user.VaderCompoundTotal, 0.0001)
+
+	ignored := metrics[1]
+	assert.Equal(t, int64(3), ignored.UserID)
+	assert.Equal(t, 1, ignored.NudgesSent)
+	assert.Equal(t, 0, ignored.NudgesReplied, "A reply outside the window is not a reply")
+	assert.Equal(t, 0, ignored.MessagesSent)
+}
+
+func TestRollup_NoActivity(t *testing.T) {
+	assert.Empty(t, analytics.Rollup(day, nil, nil, analytics.DefaultReplyWindow))
+}
+
+func TestRollup_Throttled(t *testing.T) {
+
+	throttled := fromUser(2, at(9))
+	throttled.MessageStatusID = models.NewMessageStatusThrottled().ID
+
+	activity := []analytics.Activity{
+		fromUser(2, at(8)),
+		reply(2, at(8.01)),
+
+		// Over quota, so never answered
+		throttled,
+	}
+
+	metrics := analytics.Rollup(day, activity, nil, analytics.DefaultReplyWindow)
+
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 2, metrics[0].MessagesSent)
+	assert.Equal(t, 1, metrics[0].MessagesThrottled)
+}
+
+func TestActivity_Nudge(t *testing.T) {
+
+	assert.True(t, nudge(2, day).Nudge())
+	assert.False(t, reply(2, day).Nudge(), "Replies are in the user's conversation")
+	assert.False(t, analytics.Activity{FromUserID: 2, ToUserID: system}.Nudge())
+}
+
+func TestDay(t *testing.T) {
+
+	pst, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2024, 6, 11, 0, 0, 0, 0, time.UTC),
+		analytics.Day(time.Date(2024, 6, 10, 20, 0, 0, 0, pst)),
+		"Days are UTC days")
+}
@@ -0,0 +1,72 @@
+package analytics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Service rolls up and reports on user engagement.
+type Service struct {
+	repo *Repository
+
+	// ReplyWindow is how long a user has to answer one of our messages
+	// for it to count as a reply.
+	ReplyWindow time.Duration
+}
+
+// NewService creates a new instance of Service.
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo, ReplyWindow: DefaultReplyWindow}
+}
+
+// RollupDay computes and stores every user's metrics for [day]. Days can
+// be rolled up again, to pick up late replies or backfill.
+func (s *Service) RollupDay(day time.Time) ([]models.UserMetric, error) {
+
+	start := Day(day)
+	end := start.AddDate(0, 0, 1)
+
+	activity, err := s.repo.FindActivity(start.Add(-s.ReplyWindow), end.Add(s.ReplyWindow))
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding messages: %w", err)
+	}
+
+	sentiments, err := s.repo.FindSentiments(start, end)
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding sentiment scores: %w", err)
+	}
+
+	metrics := Rollup(start, activity, sentiments, s.ReplyWindow)
+
+	if err = s.repo.SaveMetrics(metrics); err != nil {
+		return nil, fmt.Errorf("error saving metrics: %w", err)
+	}
+
+	return metrics, nil
+}
+
+// Cohorts reports the engagement of each cohort selected by [query].
+func (s *Service) Cohorts(query CohortQuery) ([]CohortMetrics, error) {
+
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	totals, err := s.repo.FindCohortTotals(query)
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding cohort metrics: %w", err)
+	}
+
+	cohorts := make([]CohortMetrics, 0, len(totals))
+
+	for _, t := range totals {
+		cohorts = append(cohorts, NewCohortMetrics(t))
+	}
+
+	return cohorts, nil
+}
@@ -0,0 +1,70 @@
+package analytics_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/analytics"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+func TestService_RollupDay(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT messages.id, .* FROM `messages` LEFT JOIN conversations").
+		WithArgs(at(-24), at(48)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "from_user_id", "to_user_id", "created_at", "conversation_user_id",
+		}).
+			AddRow(1, system, 2, at(9), system).
+			AddRow(2, 2, system, at(10), 2))
+
+	mock.ExpectQuery("SELECT user_id, vader_compound, created_at FROM `nrclex`").
+		WithArgs(day, at(24)).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "vader_compound", "created_at"}).
+			AddRow(2, 0.6, at(10)))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `user_metrics` .* ON DUPLICATE KEY UPDATE").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	metrics, err := analytics.NewService(analytics.NewRepository(db)).RollupDay(at(15))
+
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	assert.Equal(t, 1, metrics[0].NudgesReplied)
+	assert.Equal(t, 1, metrics[0].SentimentSamples)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Cohorts(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT COALESCE\\(prompt_assignments.version, 'unassigned'\\) AS cohort.* "+
+		"LEFT JOIN prompt_assignments .* GROUP BY `cohort`").
+		WithArgs("reply", day, at(24*6)).
+		WillReturnRows(sqlmock.NewRows([]string{"cohort", "users", "nudges_sent", "nudges_replied"}).
+			AddRow("v1", 10, 20, 5).
+			AddRow("v2", 10, 20, 10))
+
+	cohorts, err := analytics.NewService(analytics.NewRepository(db)).Cohorts(analytics.CohortQuery{
+		From:    day,
+		To:      at(24 * 6),
+		GroupBy: analytics.GroupByPrompt,
+		Prompt:  "reply",
+	})
+
+	require.NoError(t, err)
+	require.Len(t, cohorts, 2)
+	assert.Equal(t, 0.25, *cohorts[0].NudgeReplyRate)
+	assert.Equal(t, 0.5, *cohorts[1].NudgeReplyRate)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,36 @@
+package lib
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// AuthorizerUserIDKey is the key the authorizer stores the
+// signed in user's ID under in the request context.
+const AuthorizerUserIDKey = "user_id"
+
+// GetAuthorizedUserID returns the ID of the user the authorizer
+// signed the request in as.
+func GetAuthorizedUserID(request events.APIGatewayProxyRequest) (int64, error) {
+
+	value, ok := request.RequestContext.Authorizer[AuthorizerUserIDKey]
+
+	if !ok {
+		return 0, fmt.Errorf("request is not authorized")
+	}
+
+	// API Gateway passes authorizer context values as strings,
+	// but they are numbers when invoked directly.
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	}
+
+	return 0, fmt.Errorf("invalid %s in request context: %v", AuthorizerUserIDKey, value)
+}
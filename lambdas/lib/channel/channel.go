@@ -0,0 +1,147 @@
+package channel
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Inbound is a message a user sent us, on any channel.
+type Inbound struct {
+	// ReferenceID is the provider's ID for the message, if it has one
+	ReferenceID string
+
+	// From and To are the addresses of the sender and recipient, as
+	// E.164 phone numbers. Web chat messages have no addresses.
+	From string
+	To   string
+
+	// UserID identifies the sender on channels where they are
+	// already signed in, like web chat.
+	UserID int64
+
+	Body  string
+	Media []models.MessageMedia
+}
+
+// Channel is a way of exchanging messages with a user, like SMS or WhatsApp.
+type Channel interface {
+
+	// MessageType is the type of the messages sent on this channel,
+	// and sets how much they cost.
+	MessageType() models.MessageType
+
+	// Send delivers [body] from one user to another, in the trace of
+	// [ctx], and returns what the provider told us about it. Channels
+	// with no provider, like web chat, return an empty result.
+	Send(ctx context.Context, from, to *models.User, body string) (*messaging.SendResult, error)
+
+	// ParseInbound extracts the message from a webhook or API request.
+	ParseInbound(request events.APIGatewayProxyRequest) (*Inbound, error)
+}
+
+// Splitter is implemented by channels that limit the length of a message.
+type Splitter interface {
+
+	// Split breaks [body] into parts short enough to send.
+	Split(body string) []string
+}
+
+// Parts returns [body] broken into the messages it should be sent as
+// on [c]. Most channels send it whole.
+func Parts(c Channel, body string) []string {
+
+	if s, ok := c.(Splitter); ok {
+		return s.Split(body)
+	}
+
+	return []string{body}
+}
+
+// EstimateSegments records on [msg] how many segments its body will be
+// billed as on [c], and the encoding they'll be sent in. Both are left
+// unset if the channel doesn't bill by the segment.
+func EstimateSegments(c Channel, msg *models.Message) {
+
+	if _, ok := c.(Splitter); !ok {
+		return
+	}
+
+	segmentation := encoding.CountSegments(msg.Body)
+	segmentEncoding := string(segmentation.Encoding)
+
+	msg.EstimatedSegments = &segmentation.Segments
+	msg.SegmentEncoding = &segmentEncoding
+}
+
+// Registry looks up the Channel for a message type.
+type Registry struct {
+	fallback Channel
+	channels map[int64]Channel
+}
+
+// NewRegistry creates a registry of channels. [fallback] is used for
+// message types that don't have a channel.
+func NewRegistry(fallback Channel, channels ...Channel) *Registry {
+
+	r := &Registry{
+		fallback: fallback,
+		channels: map[int64]Channel{},
+	}
+
+	r.channels[fallback.MessageType().ID] = fallback
+
+	for _, c := range channels {
+		r.channels[c.MessageType().ID] = c
+	}
+
+	return r
+}
+
+// NewDefaultRegistry creates a registry of every channel we support,
+// falling back to SMS. Messages are sent with [provider], and texts are
+// split to fit within [maxSegments] segments.
+func NewDefaultRegistry(provider messaging.Provider, maxSegments int) *Registry {
+	return NewRegistry(
+		&SMSChannel{Provider: provider, MaxSegments: maxSegments},
+		&WhatsAppChannel{Provider: provider},
+		&WebChatChannel{},
+	)
+}
+
+// ForMessageType returns the channel for messages of type [messageTypeID].
+func (r *Registry) ForMessageType(messageTypeID int64) Channel {
+
+	if c, ok := r.channels[messageTypeID]; ok {
+		return c
+	}
+
+	return r.fallback
+}
+
+// ForTwilioAddress returns the channel a Twilio webhook from [address]
+// came in on. Texts and WhatsApp messages share a webhook.
+func (r *Registry) ForTwilioAddress(address string) Channel {
+
+	if IsWhatsAppAddress(address) {
+		return r.ForMessageType(models.NewMessageTypeWhatsApp().ID)
+	}
+
+	return r.ForMessageType(models.NewMessageTypeSMS().ID)
+}
+
+// ForUser returns the channel the user prefers to be contacted on.
+func (r *Registry) ForUser(user *models.User) Channel {
+	return r.ForMessageType(user.GetPreferredMessageTypeID())
+}
+
+// Supports reports whether there is a channel for [messageTypeID].
+func (r *Registry) Supports(messageTypeID int64) bool {
+	_, ok := r.channels[messageTypeID]
+
+	return ok
+}
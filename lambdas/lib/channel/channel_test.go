@@ -0,0 +1,165 @@
+package channel
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func TestRegistry_ForMessageType(t *testing.T) {
+	registry := NewDefaultRegistry(messaging.NewFakeProvider(""), 1)
+
+	assert.IsType(t, &SMSChannel{}, registry.ForMessageType(models.NewMessageTypeSMS().ID))
+	assert.IsType(t, &WhatsAppChannel{}, registry.ForMessageType(models.NewMessageTypeWhatsApp().ID))
+	assert.IsType(t, &WebChatChannel{}, registry.ForMessageType(models.NewMessageTypeWebChat().ID))
+
+	// Message types without a channel fall back to SMS
+	assert.IsType(t, &SMSChannel{}, registry.ForMessageType(0))
+	assert.False(t, registry.Supports(0))
+}
+
+func TestRegistry_ForUser(t *testing.T) {
+	registry := NewDefaultRegistry(messaging.NewFakeProvider(""), 1)
+
+	user := &models.User{}
+	assert.IsType(t, &SMSChannel{}, registry.ForUser(user), "Users without a preference get SMS")
+
+	whatsApp := models.NewMessageTypeWhatsApp().ID
+	user.PreferredMessageTypeID = &whatsApp
+	assert.IsType(t, &WhatsAppChannel{}, registry.ForUser(user))
+}
+
+func TestRegistry_ForTwilioAddress(t *testing.T) {
+	registry := NewDefaultRegistry(nil, 1)
+
+	assert.IsType(t, &SMSChannel{}, registry.ForTwilioAddress("+12533243071"))
+	assert.IsType(t, &WhatsAppChannel{}, registry.ForTwilioAddress("whatsapp:+12533243071"))
+}
+
+func TestSMSChannel_ParseInbound_Recording(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: url.Values{
+			"RecordingSid": []string{"RE1"},
+			"RecordingUrl": []string{"https://api.twilio.com/Recordings/RE1"},
+			"From":         []string{"+12533243071"},
+			"To":           []string{"+18333595081"},
+		}.Encode(),
+	}
+
+	inbound, err := (&SMSChannel{}).ParseInbound(request)
+
+	require.NoError(t, err)
+	assert.Equal(t, "RE1", inbound.ReferenceID)
+	require.Len(t, inbound.Media, 1, "A voice message left on a call is a voice memo")
+	assert.Equal(t, "https://api.twilio.com/Recordings/RE1.mp3", inbound.Media[0].URL)
+	assert.Equal(t, "audio/mpeg", inbound.Media[0].ContentType)
+}
+
+func TestWhatsAppChannel_ParseInbound(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: url.Values{
+			"SmsSid": []string{"SM1"},
+			"From":   []string{"whatsapp:+12533243071"},
+			"To":     []string{"whatsapp:+18333595081"},
+			"Body":   []string{"hello from whatsapp"},
+		}.Encode(),
+	}
+
+	inbound, err := (&WhatsAppChannel{}).ParseInbound(request)
+
+	require.NoError(t, err)
+	assert.Equal(t, "SM1", inbound.ReferenceID)
+	assert.Equal(t, "+12533243071", inbound.From)
+	assert.Equal(t, "+18333595081", inbound.To)
+	assert.Equal(t, "hello from whatsapp", inbound.Body)
+}
+
+func TestWhatsAppChannel_Send(t *testing.T) {
+	provider := messaging.NewFakeProvider("")
+	from := &models.User{PhoneNumber: "+18333595081"}
+	to := &models.User{PhoneNumber: "+12533243071"}
+
+	result, err := (&WhatsAppChannel{Provider: provider}).Send(context.Background(), from, to, "hello")
+
+	require.NoError(t, err)
+
+	sent := provider.Sent()
+	require.Len(t, sent, 1)
+	assert.Equal(t, result.ReferenceID, sent[0].ReferenceID)
+	assert.Equal(t, "whatsapp:+18333595081", sent[0].From)
+	assert.Equal(t, "whatsapp:+12533243071", sent[0].To)
+}
+
+func TestParts(t *testing.T) {
+	long := strings.Repeat("How are you feeling today? ", 20)
+
+	assert.Len(t, Parts(&SMSChannel{MaxSegments: 1}, "hello"), 1)
+	assert.Greater(t, len(Parts(&SMSChannel{MaxSegments: 1}, long)), 1)
+	assert.Equal(t, []string{long}, Parts(&WebChatChannel{}, long), "Web chat has no length limit")
+}
+
+func TestEstimateSegments(t *testing.T) {
+	msg := &models.Message{Body: strings.Repeat("a", 200)}
+	EstimateSegments(&SMSChannel{}, msg)
+	assert.Equal(t, 2, *msg.EstimatedSegments)
+	assert.Equal(t, "GSM-7", *msg.SegmentEncoding)
+
+	msg = &models.Message{Body: strings.Repeat("a", 100) + "😊"}
+	EstimateSegments(&SMSChannel{}, msg)
+	assert.Equal(t, 2, *msg.EstimatedSegments)
+	assert.Equal(t, "UCS-2", *msg.SegmentEncoding)
+
+	msg = &models.Message{Body: strings.Repeat("a", 200)}
+	EstimateSegments(&WebChatChannel{}, msg)
+	assert.Nil(t, msg.EstimatedSegments)
+	assert.Nil(t, msg.SegmentEncoding)
+}
+
+func TestIsWhatsAppAddress(t *testing.T) {
+	assert.True(t, IsWhatsAppAddress("whatsapp:+12533243071"))
+	assert.False(t, IsWhatsAppAddress("+12533243071"))
+}
+
+func TestWebChatChannel_ParseInbound(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: `{"body": "  can we talk?  "}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"user_id": "36"},
+		},
+	}
+
+	inbound, err := (&WebChatChannel{}).ParseInbound(request)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(36), inbound.UserID)
+	assert.Equal(t, "can we talk?", inbound.Body)
+}
+
+func TestWebChatChannel_ParseInbound_Unauthorized(t *testing.T) {
+	_, err := (&WebChatChannel{}).ParseInbound(events.APIGatewayProxyRequest{
+		Body: `{"body": "can we talk?"}`,
+	})
+
+	assert.Error(t, err)
+}
+
+func TestWebChatChannel_ParseInbound_EmptyBody(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		Body: `{"body": " "}`,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{"user_id": "36"},
+		},
+	}
+
+	_, err := (&WebChatChannel{}).ParseInbound(request)
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
+	"github.com/kmesiab/equilibria/lambdas/lib/form_unsmarshaler"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// SMSChannel sends text messages through a messaging provider, and
+// receives them from Twilio's webhook.
+type SMSChannel struct {
+	Provider messaging.Provider
+
+	// MaxSegments is the most segments a single text may be sent in.
+	// Longer replies are split into several texts.
+	MaxSegments int
+}
+
+func (c *SMSChannel) MessageType() models.MessageType {
+	return models.NewMessageTypeSMS()
+}
+
+func (c *SMSChannel) Send(ctx context.Context, from, to *models.User, body string) (*messaging.SendResult, error) {
+	return send(ctx, c.Provider, from.PhoneNumber, to.PhoneNumber, body)
+}
+
+func (c *SMSChannel) Split(body string) []string {
+	return encoding.Split(body, c.MaxSegments)
+}
+
+func (c *SMSChannel) ParseInbound(request events.APIGatewayProxyRequest) (*Inbound, error) {
+	return parseTwilioInbound(request)
+}
+
+// send sends a message with [provider], in the trace of [ctx].
+func send(ctx context.Context, provider messaging.Provider, from, to, body string) (*messaging.SendResult, error) {
+
+	if provider == nil {
+		return nil, fmt.Errorf("no messaging provider configured")
+	}
+
+	_, span := tracing.SpanFrom(ctx, "messaging send")
+	defer span.End()
+
+	result, err := provider.Send(from, to, body)
+
+	if err != nil {
+		span.RecordError(err)
+	}
+
+	return result, err
+}
+
+// parseTwilioInbound extracts a message from a Twilio messaging webhook,
+// or the recording of a voice message left on a call.
+func parseTwilioInbound(request events.APIGatewayProxyRequest) (*Inbound, error) {
+
+	sms := &models.TwilioMessageInfo{}
+
+	if err := form_unsmarshaler.UnMarshalBody(request, sms); err != nil {
+		return nil, err
+	}
+
+	media, err := twilio.ParseMedia(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sms.IsRecording() {
+		media = append(media, models.MessageMedia{
+			URL:         sms.RecordingUrl + ".mp3",
+			ContentType: "audio/mpeg",
+		})
+	}
+
+	return &Inbound{
+		ReferenceID: sms.GetReferenceID(),
+		From:        sms.From,
+		To:          sms.To,
+		Body:        sms.Body,
+		Media:       media,
+	}, nil
+}
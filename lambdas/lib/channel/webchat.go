@@ -0,0 +1,58 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// WebChatChannel exchanges messages with users signed in to the web app.
+// Messages are stored like any other, and the app fetches them from the
+// REST API, so there is nothing to deliver.
+type WebChatChannel struct{}
+
+// WebChatRequest is the body of a message posted from the web app.
+type WebChatRequest struct {
+	Body string `json:"body"`
+}
+
+func (c *WebChatChannel) MessageType() models.MessageType {
+	return models.NewMessageTypeWebChat()
+}
+
+func (c *WebChatChannel) Send(_ context.Context, _, _ *models.User, _ string) (*messaging.SendResult, error) {
+	return &messaging.SendResult{}, nil
+}
+
+func (c *WebChatChannel) ParseInbound(request events.APIGatewayProxyRequest) (*Inbound, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var chat WebChatRequest
+
+	if err = json.Unmarshal([]byte(request.Body), &chat); err != nil {
+		return nil, fmt.Errorf("error parsing request body: %s", err)
+	}
+
+	chat.Body = strings.TrimSpace(chat.Body)
+
+	if chat.Body == "" {
+		return nil, fmt.Errorf("message body is empty")
+	}
+
+	return &Inbound{
+		UserID: userID,
+		Body:   chat.Body,
+	}, nil
+}
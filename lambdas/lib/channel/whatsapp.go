@@ -0,0 +1,53 @@
+package channel
+
+import (
+	"context"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// whatsAppPrefix marks a Twilio address as a WhatsApp number.
+const whatsAppPrefix = "whatsapp:"
+
+// WhatsAppChannel sends and receives WhatsApp messages through Twilio.
+// WhatsApp messages arrive on the same webhook as texts, with their
+// addresses prefixed with "whatsapp:".
+type WhatsAppChannel struct {
+	Provider messaging.Provider
+}
+
+func (c *WhatsAppChannel) MessageType() models.MessageType {
+	return models.NewMessageTypeWhatsApp()
+}
+
+func (c *WhatsAppChannel) Send(ctx context.Context, from, to *models.User, body string) (*messaging.SendResult, error) {
+	return send(ctx, c.Provider, whatsAppPrefix+from.PhoneNumber, whatsAppPrefix+to.PhoneNumber, body)
+}
+
+func (c *WhatsAppChannel) ParseInbound(request events.APIGatewayProxyRequest) (*Inbound, error) {
+
+	inbound, err := parseTwilioInbound(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	inbound.From = StripWhatsAppPrefix(inbound.From)
+	inbound.To = StripWhatsAppPrefix(inbound.To)
+
+	return inbound, nil
+}
+
+// IsWhatsAppAddress reports whether a Twilio address is a WhatsApp number.
+func IsWhatsAppAddress(address string) bool {
+	return strings.HasPrefix(address, whatsAppPrefix)
+}
+
+// StripWhatsAppPrefix returns the phone number of a WhatsApp address.
+func StripWhatsAppPrefix(address string) string {
+	return strings.TrimPrefix(address, whatsAppPrefix)
+}
@@ -1,86 +1,195 @@
+// Package config loads the lambdas' settings. Each setting is read from
+// the first layer that has it, in order of precedence:
+//
+//  1. The secrets provider, for settings tagged secret, like API keys
+//  2. Environment variables
+//  3. The file named by CONFIG_FILE, as KEY=value lines
+//  4. The default in the setting's env tag
+//
+// Settings are then checked against the rules in their validate tag.
+// Strings must not be empty unless they're optional.
 package config
 
 import (
 	"fmt"
 	"log"
-	"reflect"
+	"os"
+	"sync"
 
 	"github.com/Netflix/go-env"
 )
 
-var config *Config
+var (
+	mu     sync.Mutex
+	config *Config
+)
 
 type Config struct {
-	OpenAIAPIKey                 string  `env:"OPENAI_API_KEY"`
+	OpenAIAPIKey                 string  `env:"OPENAI_API_KEY" secret:"true"`
+	OpenAIBaseURL                string  `env:"OPENAI_BASE_URL,default=https://api.openai.com/v1" validate:"url"`
 	DatabaseHost                 string  `env:"DATABASE_HOST"`
-	DatabaseUser                 string  `env:"DATABASE_USER"`
-	DatabasePassword             string  `env:"DATABASE_PASSWORD"`
+	DatabaseUser                 string  `env:"DATABASE_USER" secret:"true"`
+	DatabasePassword             string  `env:"DATABASE_PASSWORD" secret:"true"`
 	DatabaseName                 string  `env:"DATABASE_NAME"`
-	LogLevel                     int     `env:"LOG_LEVEL"`
-	SMSQueueURL                  string  `env:"SMS_QUEUE_URL"`
+	LogLevel                     int     `env:"LOG_LEVEL" validate:"min=0"`
+	LogSampleRate                float64 `env:"LOG_SAMPLE_RATE,default=0.01" validate:"min=0,max=1"`
+	LogHashKey                   string  `env:"LOG_HASH_KEY" validate:"optional" secret:"true"`
+	TraceExporter                string  `env:"TRACE_EXPORTER,default=none" validate:"oneof=none stdout"`
+	MetricsFormat                string  `env:"METRICS_FORMAT,default=emf" validate:"oneof=emf prometheus none"`
+	SMSQueueURL                  string  `env:"SMS_QUEUE_URL" validate:"optional"`
 	SNSTopicARN                  string  `env:"SNS_TOPIC_ARN"`
-	TwilioSID                    string  `env:"TWILIO_SID"`
-	TwilioAuthToken              string  `env:"TWILIO_AUTH_TOKEN"`
+	OutboundSNSTopicARN          string  `env:"OUTBOUND_SNS_TOPIC_ARN" validate:"optional"`
+	TwilioSID                    string  `env:"TWILIO_SID" secret:"true"`
+	TwilioAuthToken              string  `env:"TWILIO_AUTH_TOKEN" secret:"true"`
 	TwilioPhoneNumber            string  `env:"TWILIO_PHONE_NUMBER"`
 	TwilioStatusCallbackURL      string  `env:"TWILIO_STATUS_CALLBACK_URL"`
 	TwilioVerifyServiceSID       string  `env:"TWILIO_VERIFY_SERVICE_SID"`
 	ChatModelName                string  `env:"CHAT_MODEL_NAME"`
-	ChatModelTemperature         float32 `env:"CHAT_MODEL_TEMPERATURE"`
-	ChatModelMaxCompletionTokens int     `env:"CHAT_MODEL_MAX_COMPLETION_TOKENS"`
-	ChatModelFrequencyPenalty    float32 `env:"CHAT_MODEL_FREQUENCY_PENALTY"`
+	ChatModelTemperature         float32 `env:"CHAT_MODEL_TEMPERATURE" validate:"min=0,max=2"`
+	ChatModelMaxCompletionTokens int     `env:"CHAT_MODEL_MAX_COMPLETION_TOKENS" validate:"min=0"`
+	ChatModelFrequencyPenalty    float32 `env:"CHAT_MODEL_FREQUENCY_PENALTY" validate:"min=-2,max=2"`
+	InboundDebounceSeconds       int     `env:"INBOUND_DEBOUNCE_SECONDS,default=0" validate:"min=0"`
+	VisionModelName              string  `env:"VISION_MODEL_NAME,default=gpt-4o"`
+	TranscriptionProvider        string  `env:"TRANSCRIPTION_PROVIDER,default=whisper" validate:"oneof=whisper none"`
+	TranscriptionModelName       string  `env:"TRANSCRIPTION_MODEL_NAME,default=whisper-1"`
+	ChatModelMaxReplySegments    int     `env:"CHAT_MODEL_MAX_REPLY_SEGMENTS,default=2" validate:"min=1"`
+	ChatModelShortenAttempts     int     `env:"CHAT_MODEL_SHORTEN_ATTEMPTS,default=2" validate:"min=0"`
+	LLMMonthlyCostQuota          float64 `env:"LLM_MONTHLY_COST_QUOTA,default=0" validate:"min=0"`
+	SMSMaxSegmentsPerMessage     int     `env:"SMS_MAX_SEGMENTS_PER_MESSAGE,default=3" validate:"min=1"`
+	MessagingProvider            string  `env:"MESSAGING_PROVIDER,default=twilio" validate:"oneof=twilio http fake"`
+	MessagingGatewayURL          string  `env:"MESSAGING_GATEWAY_URL,default=http://localhost:9090" validate:"url"`
+	MessagingGatewayAPIKey       string  `env:"MESSAGING_GATEWAY_API_KEY" validate:"optional" secret:"true"`
+	MessagingFakeOutboxPath      string  `env:"MESSAGING_FAKE_OUTBOX_PATH,default=/tmp/equilibria_outbox.jsonl"`
+	NRCLexURL                    string  `env:"NRCLEX_URL,default=https://langtool.net/sentiment" validate:"url"`
+	NRCLexAnalyzer               string  `env:"NRCLEX_ANALYZER,default=fallback" validate:"oneof=api local fallback"`
+	NRCLexLexiconPath            string  `env:"NRCLEX_LEXICON_PATH,default=embedded"`
+	VaderLexiconPath             string  `env:"VADER_LEXICON_PATH,default=embedded"`
+	ConnectivityCheckURL         string  `env:"CONNECTIVITY_CHECK_URL,default=https://www.google.com/" validate:"url"`
+	ExportBucket                 string  `env:"EXPORT_BUCKET" validate:"optional"`
+	ExportQueueURL               string  `env:"EXPORT_QUEUE_URL" validate:"optional"`
+	ExportLinkTTLHours           int     `env:"EXPORT_LINK_TTL_HOURS,default=24" validate:"min=1,max=168"`
+	ExportLinkURL                string  `env:"EXPORT_LINK_URL" validate:"optional,url"`
+
+	// Where the other settings come from
+	ConfigFile         string `env:"CONFIG_FILE" validate:"optional"`
+	SecretsProvider    string `env:"SECRETS_PROVIDER,default=none" validate:"oneof=none ssm file"`
+	SecretsFile        string `env:"SECRETS_FILE" validate:"optional"`
+	SSMParameterPrefix string `env:"SSM_PARAMETER_PREFIX,default=/config/"`
 }
 
 func New() *Config {
 	return &Config{}
 }
 
+// Get returns the config, loading it the first time it's called. The
+// config is kept for the life of the process, so call Reset after
+// changing the environment in tests. Get returns nil if the config can't
+// be loaded or isn't valid.
 func Get() *Config {
 
+	mu.Lock()
+	defer mu.Unlock()
+
 	if config != nil {
 		return config
 	}
 
-	var err error
-	config := &Config{}
+	cfg, err := Load(os.Environ(), nil)
 
-	if _, err = env.UnmarshalFromEnviron(config); err != nil {
-		log.Printf("failed to unmarshal environment: %s\n", err)
+	if err != nil {
+		log.Printf("failed to load config: %s.  Exiting.", err)
 
 		return nil
 	}
 
-	if err = validateConfig(config); err != nil {
-		log.Printf("failed to validate config: %s.  Exiting.", err)
-
-		return nil
-	}
+	config = cfg
 
 	return config
 }
-func validateConfig(config *Config) error {
 
-	v := reflect.ValueOf(config)
+// Reset forgets the loaded config, so the next call to Get loads it again.
+func Reset() {
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	config = nil
+}
+
+// Load reads the config from its layers, with [environ] as the
+// environment. Secret settings are read from [secrets], or from the
+// provider named by SECRETS_PROVIDER if it's nil.
+func Load(environ []string, secrets SecretsProvider) (*Config, error) {
 
-	// Check if it's a pointer and dereference it
-	if v.Kind() == reflect.Ptr {
-		v = v.Elem()
+	settings, err := env.EnvironToEnvSet(environ)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environment: %w", err)
 	}
 
-	// Check if it's a struct type
-	if v.Kind() != reflect.Struct {
-		return fmt.Errorf("config is not a struct")
+	// The file is read before the environment is applied over it, so
+	// CONFIG_FILE can only be set in the environment
+	if path := settings["CONFIG_FILE"]; path != "" {
+
+		file, err := ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		settings = merge(file, settings)
 	}
 
-	// Now you can safely call NumField
-	m := v.NumField()
+	// Parse once without secrets, to find where the secrets are
+	cfg := &Config{}
+
+	if err = env.Unmarshal(copySet(settings), cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
 
-	for i := 0; i < m; i++ {
-		if v.Field(i).String() == "" {
-			return fmt.Errorf("%s must not be empty", v.Type().Field(i).Name)
+	if secrets == nil {
+		if secrets, err = NewSecretsProvider(cfg); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	values, err := secrets.Secrets(SecretNames())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	cfg = &Config{}
+
+	if err = env.Unmarshal(merge(settings, values), cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse settings: %w", err)
+	}
+
+	if err = validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// merge returns the settings in [layers], later layers taking precedence.
+func merge(layers ...map[string]string) env.EnvSet {
+
+	merged := env.EnvSet{}
+
+	for _, layer := range layers {
+		for key, value := range layer {
+			merged[key] = value
+		}
+	}
+
+	return merged
+}
+
+// copySet returns a copy of [settings], since parsing them deletes the
+// ones that were used.
+func copySet(settings env.EnvSet) env.EnvSet {
+	return merge(settings)
 }
 
 var DefaultHttpHeaders = map[string]string{
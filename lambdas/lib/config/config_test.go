@@ -2,9 +2,13 @@ package config_test
 
 import (
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -49,9 +53,132 @@ func TestConfig_GetMissingEnvironmentVariable(t *testing.T) {
 	err := os.Unsetenv("OPENAI_API_KEY")
 	require.NoError(t, err, "Error should be nil when unsetting environment variable")
 
+	config.Reset()
+
 	cfg := config.Get()
 	assert.Nil(t, cfg)
 
 	test.SetEnvVars()
 
 }
+
+// environ returns the test settings as an environment, with [extra] added.
+func environ(extra ...string) []string {
+
+	test.SetEnvVars()
+
+	return append(os.Environ(), extra...)
+}
+
+type fakeSecrets map[string]string
+
+func (f fakeSecrets) Secrets(names []string) (map[string]string, error) {
+
+	secrets := map[string]string{}
+
+	for _, name := range names {
+		if value, ok := f[name]; ok {
+			secrets[name] = value
+		}
+	}
+
+	return secrets, nil
+}
+
+func TestLoad_Layers(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "equilibria.env")
+
+	require.NoError(t, os.WriteFile(file, []byte(
+		"# Local settings\n"+
+			"export VISION_MODEL_NAME=gpt-4o-mini\n"+
+			"CHAT_MODEL_NAME=\"from-file\"\n"+
+			"\n"+
+			"INBOUND_DEBOUNCE_SECONDS=5\n",
+	), 0o600))
+
+	cfg, err := config.Load(
+		environ("CONFIG_FILE="+file, "INBOUND_DEBOUNCE_SECONDS=10"),
+		fakeSecrets{"OPENAI_API_KEY": "from-secrets", "CHAT_MODEL_NAME": "not-a-secret"},
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "from-secrets", cfg.OpenAIAPIKey)   // secrets over the environment
+	assert.Equal(t, 10, cfg.InboundDebounceSeconds)     // the environment over the file
+	assert.Equal(t, "gpt-4o-mini", cfg.VisionModelName) // the file over defaults
+	assert.Equal(t, "whisper-1", cfg.TranscriptionModelName)
+	assert.Equal(t, "dummy_chat_model_name", cfg.ChatModelName)
+}
+
+func TestLoad_Validation(t *testing.T) {
+
+	_, err := config.Load(environ(
+		"METRICS_FORMAT=statsd",
+		"LOG_SAMPLE_RATE=2",
+		"NRCLEX_URL=langtool.net",
+		"SMS_QUEUE_URL=",
+	), config.NoSecrets{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "METRICS_FORMAT must be one of: emf prometheus none")
+	assert.Contains(t, err.Error(), "LOG_SAMPLE_RATE must be at most 1")
+	assert.Contains(t, err.Error(), "NRCLEX_URL must be an absolute URL")
+	assert.NotContains(t, err.Error(), "SMS_QUEUE_URL")
+
+	_, err = config.Load(environ("TWILIO_PHONE_NUMBER="), config.NoSecrets{})
+
+	assert.EqualError(t, err, "TWILIO_PHONE_NUMBER must not be empty")
+}
+
+func TestFileSecrets(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "secrets.env")
+
+	require.NoError(t, os.WriteFile(file, []byte("TWILIO_AUTH_TOKEN='s3cret'\nCHAT_MODEL_NAME=gpt-4o\n"), 0o600))
+
+	cfg, err := config.Load(environ("SECRETS_PROVIDER=file", "SECRETS_FILE="+file), nil)
+
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", cfg.TwilioAuthToken)
+	assert.Equal(t, "dummy_chat_model_name", cfg.ChatModelName)
+
+	_, err = config.Load(environ("SECRETS_PROVIDER=file"), nil)
+	assert.Error(t, err)
+}
+
+type fakeSSM struct {
+	ssmiface.SSMAPI
+
+	parameters map[string]string
+	requests   int
+}
+
+func (f *fakeSSM) GetParameters(input *ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
+
+	f.requests++
+
+	output := &ssm.GetParametersOutput{}
+
+	for _, name := range input.Names {
+		if value, ok := f.parameters[aws.StringValue(name)]; ok {
+			output.Parameters = append(output.Parameters, &ssm.Parameter{Name: name, Value: aws.String(value)})
+		}
+	}
+
+	return output, nil
+}
+
+func TestSSMSecrets(t *testing.T) {
+
+	client := &fakeSSM{parameters: map[string]string{
+		"/config/OPENAI_API_KEY": "sk-ssm",
+		"/config/DATABASE_NAME":  "not-a-secret",
+	}}
+
+	names := append(config.SecretNames(), "A", "B", "C", "D", "E")
+	secrets, err := (&config.SSMSecrets{Client: client, Prefix: "/config/"}).Secrets(names)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"OPENAI_API_KEY": "sk-ssm"}, secrets)
+	assert.Equal(t, 2, client.requests)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadFile reads settings from the file at [path], written as KEY=value
+// lines like a .env file. Blank lines and lines starting with # are
+// skipped, an "export " prefix is allowed, and values may be quoted.
+func ReadFile(path string) (map[string]string, error) {
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+
+	defer func() { _ = file.Close() }()
+
+	settings := map[string]string{}
+	scanner := bufio.NewScanner(file)
+
+	for n := 1; scanner.Scan(); n++ {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected KEY=value", path, n)
+		}
+
+		settings[strings.TrimSpace(key)] = unquote(strings.TrimSpace(value))
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	return settings, nil
+}
+
+// unquote removes the quotes around [value], if it has them.
+func unquote(value string) string {
+
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+
+	return value
+}
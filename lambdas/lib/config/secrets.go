@@ -0,0 +1,156 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+const (
+	// SecretsNone reads secrets from the environment, like other settings
+	SecretsNone = "none"
+
+	// SecretsSSM reads secrets from AWS Parameter Store, named by
+	// SSM_PARAMETER_PREFIX followed by the setting, like
+	// /config/OPENAI_API_KEY
+	SecretsSSM = "ssm"
+
+	// SecretsFile reads secrets from SECRETS_FILE, as KEY=value lines
+	SecretsFile = "file"
+
+	// ssmEndpointEnv overrides the Parameter Store endpoint, as it does
+	// for the JWT signing keys
+	ssmEndpointEnv = "AWS_ENDPOINT_URL_SSM"
+
+	// ssmMaxNames is the most parameters GetParameters will return at once
+	ssmMaxNames = 10
+)
+
+// SecretsProvider reads the settings tagged secret.
+type SecretsProvider interface {
+
+	// Secrets returns the values of the secrets in [names] that it has.
+	// Secrets it doesn't have are left to the other layers.
+	Secrets(names []string) (map[string]string, error)
+}
+
+// NewSecretsProvider returns the secrets provider named by SECRETS_PROVIDER.
+func NewSecretsProvider(cfg *Config) (SecretsProvider, error) {
+
+	switch cfg.SecretsProvider {
+	case SecretsNone:
+		return NoSecrets{}, nil
+	case SecretsFile:
+		return &FileSecrets{Path: cfg.SecretsFile}, nil
+	case SecretsSSM:
+
+		awsConfig := aws.NewConfig()
+
+		if endpoint := os.Getenv(ssmEndpointEnv); endpoint != "" {
+			awsConfig.Endpoint = aws.String(endpoint)
+		}
+
+		sess, err := session.NewSession(awsConfig)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AWS session: %w", err)
+		}
+
+		return &SSMSecrets{Client: ssm.New(sess), Prefix: cfg.SSMParameterPrefix}, nil
+	}
+
+	return nil, fmt.Errorf("unknown secrets provider: %s", cfg.SecretsProvider)
+}
+
+// SecretNames returns the settings tagged secret.
+func SecretNames() []string {
+
+	var names []string
+
+	t := reflect.TypeOf(Config{})
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("secret") == "true" {
+			names = append(names, settingName(t.Field(i)))
+		}
+	}
+
+	return names
+}
+
+// NoSecrets leaves secrets to the environment.
+type NoSecrets struct{}
+
+func (NoSecrets) Secrets(_ []string) (map[string]string, error) {
+	return map[string]string{}, nil
+}
+
+// FileSecrets reads secrets from a local file, for running without AWS.
+type FileSecrets struct {
+	Path string
+}
+
+func (f *FileSecrets) Secrets(names []string) (map[string]string, error) {
+
+	if f.Path == "" {
+		return nil, fmt.Errorf("SECRETS_FILE must be set to read secrets from a file")
+	}
+
+	file, err := ReadFile(f.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+
+	for _, name := range names {
+		if value, ok := file[name]; ok {
+			secrets[name] = value
+		}
+	}
+
+	return secrets, nil
+}
+
+// SSMSecrets reads secrets from AWS Parameter Store, decrypting them.
+type SSMSecrets struct {
+	Client ssmiface.SSMAPI
+	Prefix string
+}
+
+func (s *SSMSecrets) Secrets(names []string) (map[string]string, error) {
+
+	secrets := map[string]string{}
+
+	for start := 0; start < len(names); start += ssmMaxNames {
+
+		batch := names[start:min(start+ssmMaxNames, len(names))]
+		parameters := make([]*string, len(batch))
+
+		for i, name := range batch {
+			parameters[i] = aws.String(s.Prefix + name)
+		}
+
+		output, err := s.Client.GetParameters(&ssm.GetParametersInput{
+			Names:          parameters,
+			WithDecryption: aws.Bool(true),
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parameters: %w", err)
+		}
+
+		for _, parameter := range output.Parameters {
+			secrets[strings.TrimPrefix(aws.StringValue(parameter.Name), s.Prefix)] = aws.StringValue(parameter.Value)
+		}
+	}
+
+	return secrets, nil
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// validateConfig checks every setting against the rules in its validate
+// tag, and returns all the settings that break them:
+//
+//   - optional: a string may be empty. Other strings must not be.
+//   - oneof=a b c: the setting must be one of the listed values
+//   - url: the setting must be an absolute URL
+//   - min=n, max=n: a number must be within the bounds
+func validateConfig(config *Config) error {
+
+	v := reflect.ValueOf(config)
+
+	// Check if it's a pointer and dereference it
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	// Check if it's a struct type
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("config is not a struct")
+	}
+
+	var errs []error
+
+	for i := 0; i < v.NumField(); i++ {
+
+		field := v.Type().Field(i)
+
+		if err := validateField(v.Field(i), field.Tag.Get("validate")); err != nil {
+			errs = append(errs, fmt.Errorf("%s %w", settingName(field), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateField checks [value] against the comma separated [rules].
+func validateField(value reflect.Value, rules string) error {
+
+	if value.Kind() == reflect.String && value.String() == "" {
+
+		if slices.Contains(strings.Split(rules, ","), "optional") {
+			return nil
+		}
+
+		return fmt.Errorf("must not be empty")
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "", "optional":
+		case "oneof":
+
+			if !slices.Contains(strings.Fields(arg), fmt.Sprint(value.Interface())) {
+				return fmt.Errorf("must be one of: %s", arg)
+			}
+		case "url":
+
+			if u, err := url.Parse(value.String()); err != nil || !u.IsAbs() || u.Host == "" {
+				return fmt.Errorf("must be an absolute URL")
+			}
+		case "min", "max":
+
+			bound, err := strconv.ParseFloat(arg, 64)
+
+			if err != nil {
+				return fmt.Errorf("has an invalid %s rule: %s", name, arg)
+			}
+
+			n, ok := number(value)
+
+			if !ok {
+				return fmt.Errorf("is not a number")
+			}
+
+			if name == "min" && n < bound {
+				return fmt.Errorf("must be at least %s", arg)
+			}
+
+			if name == "max" && n > bound {
+				return fmt.Errorf("must be at most %s", arg)
+			}
+		default:
+			return fmt.Errorf("has an unknown rule: %s", rule)
+		}
+	}
+
+	return nil
+}
+
+// number returns [value] as a float, if it's a number.
+func number(value reflect.Value) (float64, bool) {
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+
+	return 0, false
+}
+
+// settingName returns the environment variable [field] is read from.
+func settingName(field reflect.StructField) string {
+
+	name, _, _ := strings.Cut(field.Tag.Get("env"), ",")
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
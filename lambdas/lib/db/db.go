@@ -16,19 +16,21 @@ import (
 
 var globalDB *gorm.DB
 
-// Init initializes the database connection using the provided configuration.
-func Init(config *config.Config) (*gorm.DB, error) {
-
-	// Format the DSN (Data Source Name)
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
+// DSN returns the Data Source Name for the configured database.
+func DSN(config *config.Config) string {
+	return fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&loc=UTC",
 		config.DatabaseUser,
 		config.DatabasePassword,
 		config.DatabaseHost,
 		config.DatabaseName,
 	)
+}
+
+// Init initializes the database connection using the provided configuration.
+func Init(config *config.Config) (*gorm.DB, error) {
 
 	// Open the database with the MySQL driver
-	return gorm.Open(mysql.Open(dsn), &gorm.Config{
+	return gorm.Open(mysql.Open(DSN(config)), &gorm.Config{
 
 		Logger: logger.Default.LogMode(logger.LogLevel(config.LogLevel)),
 	})
@@ -0,0 +1,63 @@
+package deadletter
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository is a repository for managing FailedEvents.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create inserts a new failed event into the database.
+func (r *Repository) Create(event *models.FailedEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindByID retrieves a failed event by its ID.
+func (r *Repository) FindByID(id int64) (*models.FailedEvent, error) {
+	var event models.FailedEvent
+
+	if err := r.db.First(&event, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// FindUnreplayed retrieves up to [limit] failed events that have never
+// been replayed, oldest first.
+func (r *Repository) FindUnreplayed(limit int) ([]*models.FailedEvent, error) {
+	var events []*models.FailedEvent
+
+	err := r.db.Where("replayed_at IS NULL").
+		Order("id ASC").
+		Limit(limit).
+		Find(&events).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// MarkReplayed records that the event has been replayed at [at].
+func (r *Repository) MarkReplayed(event *models.FailedEvent, at time.Time) error {
+
+	event.ReplayCount++
+	event.ReplayedAt = &at
+
+	return r.db.Model(event).
+		Select("replay_count", "replayed_at").
+		Updates(event).Error
+}
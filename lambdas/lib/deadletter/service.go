@@ -0,0 +1,95 @@
+package deadletter
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const (
+	// AttributeSourceQueueArn is set by SQS on messages that were moved
+	// to a dead letter queue, and names the queue they failed on.
+	AttributeSourceQueueArn = "DeadLetterQueueSourceArn"
+
+	// AttributeReceiveCount is the number of times a message was received.
+	AttributeReceiveCount = "ApproximateReceiveCount"
+)
+
+// QueueSender sends a raw body to an SQS queue.
+type QueueSender interface {
+	SendBody(queueURL, body string) (string, error)
+}
+
+// Service records failed events and replays them onto the queue
+// they originally failed on.
+type Service struct {
+	repo *Repository
+}
+
+// NewService creates a new Service.
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record stores a message received from a dead letter queue. SQS may
+// deliver the same message twice, so a message that has already been
+// recorded is ignored.
+func (s *Service) Record(message events.SQSMessage) (*models.FailedEvent, error) {
+
+	receiveCount, _ := strconv.Atoi(message.Attributes[AttributeReceiveCount])
+
+	event := &models.FailedEvent{
+		MessageID:      message.MessageId,
+		SourceQueueArn: message.Attributes[AttributeSourceQueueArn],
+		Body:           message.Body,
+		ReceiveCount:   receiveCount,
+	}
+
+	if err := s.repo.Create(event); err != nil && !db.IsDuplicateEntryError(err) {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// Get retrieves a failed event by its ID.
+func (s *Service) Get(id int64) (*models.FailedEvent, error) {
+	return s.repo.FindByID(id)
+}
+
+// ListUnreplayed retrieves up to [limit] failed events that have not
+// been replayed yet.
+func (s *Service) ListUnreplayed(limit int) ([]*models.FailedEvent, error) {
+	return s.repo.FindUnreplayed(limit)
+}
+
+// Replay sends the event's original body back to the queue it failed on.
+// If [queueArn] is not empty, it is used in place of the source queue.
+func (s *Service) Replay(event *models.FailedEvent, queueArn string, sender QueueSender) error {
+
+	if queueArn == "" {
+		queueArn = event.SourceQueueArn
+	}
+
+	if queueArn == "" {
+		return fmt.Errorf("failed event %d has no source queue", event.ID)
+	}
+
+	queueURL, err := sqs.QueueURLFromARN(queueArn)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = sender.SendBody(queueURL, event.Body); err != nil {
+		return err
+	}
+
+	return s.repo.MarkReplayed(event, time.Now().UTC())
+}
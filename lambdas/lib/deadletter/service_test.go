@@ -0,0 +1,134 @@
+package deadletter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	mysql2 "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/deadletter"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type fakeQueueSender struct {
+	queueURL string
+	body     string
+	err      error
+}
+
+func (f *fakeQueueSender) SendBody(queueURL, body string) (string, error) {
+	f.queueURL, f.body = queueURL, body
+
+	return "replayed-message-id", f.err
+}
+
+func newDeadLetterMessage() events.SQSMessage {
+	return events.SQSMessage{
+		MessageId: "b1f1c8a2-0000-4000-8000-000000000001",
+		Body:      `{"Type":"Notification","Message":"{}"}`,
+		Attributes: map[string]string{
+			deadletter.AttributeReceiveCount:   "3",
+			deadletter.AttributeSourceQueueArn: "arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue",
+		},
+	}
+}
+
+func TestService_Record(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := deadletter.NewService(deadletter.NewRepository(db))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `failed_events`").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	event, err := svc.Record(newDeadLetterMessage())
+
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue", event.SourceQueueArn)
+	assert.Equal(t, 3, event.ReceiveCount)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Record_Duplicate(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := deadletter.NewService(deadletter.NewRepository(db))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `failed_events`").
+		WillReturnError(&mysql2.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	_, err = svc.Record(newDeadLetterMessage())
+
+	assert.NoError(t, err, "A redelivered dead letter is not an error")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Replay(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := deadletter.NewService(deadletter.NewRepository(db))
+	sender := &fakeQueueSender{}
+
+	event := &models.FailedEvent{
+		ID:             7,
+		Body:           "original body",
+		SourceQueueArn: "arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue",
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `failed_events` SET").
+		WithArgs(1, sqlmock.AnyArg(), int64(7)).
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	err = svc.Replay(event, "", sender)
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://sqs.us-west-2.amazonaws.com/123456789012/sms-inbound-queue", sender.queueURL)
+	assert.Equal(t, "original body", sender.body)
+	assert.Equal(t, 1, event.ReplayCount)
+	assert.NotNil(t, event.ReplayedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Replay_SendError(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := deadletter.NewService(deadletter.NewRepository(db))
+	sender := &fakeQueueSender{err: errors.New("queue unavailable")}
+
+	event := &models.FailedEvent{
+		ID:             7,
+		SourceQueueArn: "arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue",
+	}
+
+	err = svc.Replay(event, "", sender)
+
+	assert.Error(t, err)
+	assert.Nil(t, event.ReplayedAt, "A failed replay should not be marked as replayed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Replay_NoSourceQueue(t *testing.T) {
+	db, _, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := deadletter.NewService(deadletter.NewRepository(db))
+
+	err = svc.Replay(&models.FailedEvent{ID: 7}, "", &fakeQueueSender{})
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,155 @@
+package emotions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const (
+	// MoodWindow is how far back the recent trend looks.
+	MoodWindow = 7 * 24 * time.Hour
+
+	TrendSteady = "steady"
+
+	// sentimentThreshold is where VADER compound scores stop being
+	// neutral, as recommended by its authors.
+	sentimentThreshold = 0.05
+
+	// moodEmotions is how many of the strongest emotions to mention.
+	moodEmotions = 2
+)
+
+// Mood is how a user is feeling, from the message they just sent and the
+// ones before it, so the model can adapt its tone.
+type Mood struct {
+	Emotions      nrclex.EmotionScores
+	VaderCompound float64
+
+	// RecentMessages is how many other messages the user sent in the
+	// MoodWindow, and RecentCompound their average compound sentiment
+	RecentMessages int
+	RecentCompound float64
+
+	// Trend is ChangeImproved or ChangeDeclined when the current message
+	// is at least DefaultThreshold away from the recent average, and
+	// TrendSteady otherwise.
+	Trend string
+}
+
+// NewMood compares the [current] message's scores with the [recent]
+// scores of the user's other messages.
+func NewMood(current *nrclex.APIResponse, recent []models.NrcLex) *Mood {
+
+	mood := &Mood{
+		Emotions:       current.EmotionScore,
+		VaderCompound:  current.VaderEmotionScore.Compound,
+		RecentMessages: len(recent),
+		Trend:          TrendSteady,
+	}
+
+	if len(recent) == 0 {
+		return mood
+	}
+
+	for _, score := range recent {
+		mood.RecentCompound += score.VaderCompound
+	}
+
+	mood.RecentCompound /= float64(len(recent))
+
+	switch delta := mood.VaderCompound - mood.RecentCompound; {
+	case delta >= DefaultThreshold:
+		mood.Trend = ChangeImproved
+	case delta <= -DefaultThreshold:
+		mood.Trend = ChangeDeclined
+	}
+
+	return mood
+}
+
+// Strongest returns up to two of the emotions the message scored highest
+// on, leaving out the positive and negative sentiment scores.
+func (m *Mood) Strongest() []string {
+
+	vector := emotionVector{
+		m.Emotions.Anger, m.Emotions.Anticipation, m.Emotions.Disgust, m.Emotions.Fear, m.Emotions.Trust,
+		m.Emotions.Joy, m.Emotions.Negative, m.Emotions.Positive, m.Emotions.Sadness, m.Emotions.Surprise,
+	}
+
+	type ranked struct {
+		name  string
+		score float64
+	}
+
+	var emotions []ranked
+
+	for i, name := range emotionNames {
+		if name != "positive" && name != "negative" && vector[i] > 0 {
+			emotions = append(emotions, ranked{name, vector[i]})
+		}
+	}
+
+	sort.SliceStable(emotions, func(i, j int) bool {
+		return emotions[i].score > emotions[j].score
+	})
+
+	names := make([]string, 0, moodEmotions)
+
+	for _, emotion := range emotions[:min(len(emotions), moodEmotions)] {
+		names = append(names, emotion.name)
+	}
+
+	return names
+}
+
+// String summarizes the mood in a sentence or two for the prompt.
+func (m *Mood) String() string {
+
+	var b strings.Builder
+
+	_, _ = fmt.Fprintf(&b, "This message reads as %s (compound %.2f)",
+		sentimentLabel(m.VaderCompound), m.VaderCompound)
+
+	if strongest := m.Strongest(); len(strongest) > 0 {
+		_, _ = fmt.Fprintf(&b, ", mostly %s", strings.Join(strongest, " and "))
+	}
+
+	b.WriteString(". ")
+
+	if m.RecentMessages == 0 {
+		b.WriteString("There are no other messages from the last 7 days to compare it with.")
+
+		return b.String()
+	}
+
+	_, _ = fmt.Fprintf(&b, "Their %d other messages from the last 7 days averaged %.2f (%s), so their mood ",
+		m.RecentMessages, m.RecentCompound, sentimentLabel(m.RecentCompound))
+
+	switch m.Trend {
+	case ChangeImproved:
+		b.WriteString("has improved.")
+	case ChangeDeclined:
+		b.WriteString("has declined.")
+	default:
+		b.WriteString("is about the same.")
+	}
+
+	return b.String()
+}
+
+func sentimentLabel(compound float64) string {
+
+	switch {
+	case compound >= sentimentThreshold:
+		return "positive"
+	case compound <= -sentimentThreshold:
+		return "negative"
+	}
+
+	return "neutral"
+}
@@ -0,0 +1,66 @@
+package emotions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func current(compound float64, emotions nrclex.EmotionScores) *nrclex.APIResponse {
+	return &nrclex.APIResponse{
+		EmotionScore:      emotions,
+		VaderEmotionScore: nrclex.VaderEmotionScore{Compound: compound},
+	}
+}
+
+func TestNewMood(t *testing.T) {
+
+	recent := []models.NrcLex{score(3, 9, 0.4, 0.1), score(4, 9, 0.6, 0.1)}
+
+	mood := NewMood(current(-0.5, nrclex.EmotionScores{Sadness: 0.5}), recent)
+	assert.Equal(t, 2, mood.RecentMessages)
+	assert.InDelta(t, 0.5, mood.RecentCompound, 1e-9)
+	assert.Equal(t, ChangeDeclined, mood.Trend)
+
+	mood = NewMood(current(0.9, nrclex.EmotionScores{}), recent)
+	assert.Equal(t, ChangeImproved, mood.Trend)
+
+	mood = NewMood(current(0.6, nrclex.EmotionScores{}), recent)
+	assert.Equal(t, TrendSteady, mood.Trend)
+
+	mood = NewMood(current(-0.9, nrclex.EmotionScores{}), nil)
+	assert.Equal(t, TrendSteady, mood.Trend, "There is no trend without recent messages")
+}
+
+func TestMood_Strongest(t *testing.T) {
+
+	mood := NewMood(current(0, nrclex.EmotionScores{
+		Fear: 0.2, Sadness: 0.3, Joy: 0.1, Negative: 0.4,
+	}), nil)
+
+	assert.Equal(t, []string{"sadness", "fear"}, mood.Strongest(),
+		"Positive and negative are sentiment, not emotions")
+
+	assert.Empty(t, NewMood(current(0, nrclex.EmotionScores{}), nil).Strongest())
+}
+
+func TestMood_String(t *testing.T) {
+
+	recent := []models.NrcLex{score(3, 9, 0.4, 0.1), score(4, 9, 0.6, 0.1)}
+	mood := NewMood(current(-0.5, nrclex.EmotionScores{Sadness: 0.5, Fear: 0.25}), recent)
+
+	assert.Equal(t,
+		"This message reads as negative (compound -0.50), mostly sadness and fear. "+
+			"Their 2 other messages from the last 7 days averaged 0.50 (positive), so their mood has declined.",
+		mood.String())
+
+	mood = NewMood(current(0.01, nrclex.EmotionScores{}), nil)
+
+	assert.Equal(t,
+		"This message reads as neutral (compound 0.01). "+
+			"There are no other messages from the last 7 days to compare it with.",
+		mood.String())
+}
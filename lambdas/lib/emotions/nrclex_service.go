@@ -1,24 +1,33 @@
 package emotions
 
 import (
+	"time"
+
 	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
 type NRCLexService struct {
-	client *nrclex.NRCLexClient
+	client nrclex.Analyzer
 	repo   *nrclex.Repository
 }
 
-func NewNRCLexService(client *nrclex.NRCLexClient, repo *nrclex.Repository) *NRCLexService {
+func NewNRCLexService(client nrclex.Analyzer, repo *nrclex.Repository) *NRCLexService {
 	return &NRCLexService{
 		client: client,
 		repo:   repo,
 	}
 }
 
+// ProcessMessage scores and saves the emotions in [message], written by
+// [user]. A message that was already scored, like on a redelivery, keeps
+// its scores.
 func (s *NRCLexService) ProcessMessage(user *models.User, message *models.Message) (*nrclex.APIResponse, error) {
 
+	if existing, err := s.repo.FindByMessageID(message.ID); err == nil {
+		return responseOf(existing, message.Body), nil
+	}
+
 	scores, err := s.client.AnalyzeText(message.Body)
 
 	if err != nil {
@@ -43,6 +52,10 @@ func (s *NRCLexService) ProcessMessage(user *models.User, message *models.Messag
 		VaderNeg:      scores.VaderEmotionScore.Neg,
 		VaderNeu:      scores.VaderEmotionScore.Neu,
 		VaderPos:      scores.VaderEmotionScore.Pos,
+
+		// Date the scores to the message, so backfilled scores fall in
+		// the right place on the timeline
+		CreatedAt: message.CreatedAt,
 	}
 
 	err = s.repo.Create(emotions)
@@ -55,3 +68,66 @@ func (s *NRCLexService) ProcessMessage(user *models.User, message *models.Messag
 	return scores, nil
 
 }
+
+// Analyze scores [message] without saving the scores, reusing the saved
+// scores if it has already been processed.
+func (s *NRCLexService) Analyze(message *models.Message) (*nrclex.APIResponse, error) {
+
+	if existing, err := s.repo.FindByMessageID(message.ID); err == nil {
+		return responseOf(existing, message.Body), nil
+	}
+
+	return s.client.AnalyzeText(message.Body)
+}
+
+// Unscored returns up to [limit] messages created since [since] that
+// have not been processed.
+func (s *NRCLexService) Unscored(since time.Time, limit int) ([]models.Message, error) {
+	return s.repo.FindUnscoredMessages(since, limit)
+}
+
+// Mood compares the [scores] of [message] with the user's other messages
+// from the MoodWindow before [now].
+func (s *NRCLexService) Mood(user *models.User, message *models.Message, scores *nrclex.APIResponse, now time.Time) (*Mood, error) {
+
+	rows, err := s.repo.FindByUserIDBetween(user.ID, now.Add(-MoodWindow), now)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recent := make([]models.NrcLex, 0, len(rows))
+
+	for _, row := range rows {
+		if row.MessageID != message.ID {
+			recent = append(recent, row)
+		}
+	}
+
+	return NewMood(scores, recent), nil
+}
+
+func responseOf(row *models.NrcLex, text string) *nrclex.APIResponse {
+
+	return &nrclex.APIResponse{
+		Text: text,
+		EmotionScore: nrclex.EmotionScores{
+			Anger:        row.Anger,
+			Anticipation: row.Anticipation,
+			Disgust:      row.Disgust,
+			Fear:         row.Fear,
+			Trust:        row.Trust,
+			Joy:          row.Joy,
+			Negative:     row.Negative,
+			Positive:     row.Positive,
+			Sadness:      row.Sadness,
+			Surprise:     row.Surprise,
+		},
+		VaderEmotionScore: nrclex.VaderEmotionScore{
+			Compound: row.VaderCompound,
+			Neg:      row.VaderNeg,
+			Neu:      row.VaderNeu,
+			Pos:      row.VaderPos,
+		},
+	}
+}
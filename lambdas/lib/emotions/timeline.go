@@ -0,0 +1,238 @@
+package emotions
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// The periods a timeline can be grouped by. Weeks start on Monday.
+const (
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+const (
+	ChangeImproved = "improved"
+	ChangeDeclined = "declined"
+
+	DefaultWindow    = 7
+	DefaultThreshold = 0.3
+)
+
+// TimelineOptions control how scores are grouped and what counts as a
+// notable change.
+type TimelineOptions struct {
+	Interval string
+	Location *time.Location
+
+	// Window is how many periods the moving average covers
+	Window int
+
+	// Threshold is how far the VADER compound average has to move from
+	// the moving average of the periods before to be a notable change
+	Threshold float64
+}
+
+// Validate checks the options can be used to build a timeline.
+func (o TimelineOptions) Validate() error {
+
+	if o.Interval != IntervalDay && o.Interval != IntervalWeek {
+		return fmt.Errorf("unknown interval %q", o.Interval)
+	}
+
+	if o.Window < 1 {
+		return fmt.Errorf("the window must be at least one period")
+	}
+
+	if o.Threshold <= 0 {
+		return fmt.Errorf("the threshold must be positive")
+	}
+
+	return nil
+}
+
+// Period is the user's average mood over a day or week.
+type Period struct {
+	Start    time.Time            `json:"start"`
+	Messages int                  `json:"messages"`
+	Emotions nrclex.EmotionScores `json:"emotions"`
+
+	// VaderCompound is the average compound sentiment, from -1 to 1, and
+	// MovingAverage its average over this and the periods before it
+	VaderCompound float64 `json:"vader_compound"`
+	MovingAverage float64 `json:"moving_average"`
+
+	Change *Change `json:"change,omitempty"`
+}
+
+// Change is a notable shift in mood from the periods before.
+type Change struct {
+	Direction string `json:"direction"`
+
+	// Delta is the difference between the period's compound sentiment and
+	// the moving average of the periods before it
+	Delta float64 `json:"delta"`
+
+	// Emotion is the emotion that moved the most, and EmotionDelta how far
+	Emotion      string  `json:"emotion"`
+	EmotionDelta float64 `json:"emotion_delta"`
+}
+
+// Timeline groups [scores], oldest first, into periods. Periods without
+// any messages are left out, and moving averages cover the periods with
+// messages.
+func Timeline(scores []models.NrcLex, options TimelineOptions) []Period {
+
+	var (
+		periods []Period
+		totals  []emotionVector
+	)
+
+	for _, score := range scores {
+
+		start := periodStart(score.CreatedAt, options)
+
+		if len(periods) == 0 || !periods[len(periods)-1].Start.Equal(start) {
+			periods = append(periods, Period{Start: start})
+			totals = append(totals, emotionVector{})
+		}
+
+		i := len(periods) - 1
+		periods[i].Messages++
+		periods[i].VaderCompound += score.VaderCompound
+		totals[i] = totals[i].add(vectorOf(score))
+	}
+
+	averages := make([]emotionVector, len(periods))
+
+	for i := range periods {
+
+		averages[i] = totals[i].scale(1 / float64(periods[i].Messages))
+		periods[i].Emotions = averages[i].scores()
+		periods[i].VaderCompound /= float64(periods[i].Messages)
+
+		first := max(0, i-options.Window+1)
+		periods[i].MovingAverage = averageCompound(periods[first : i+1])
+
+		if i > 0 {
+			periods[i].Change = detectChange(periods, averages, i, options)
+		}
+	}
+
+	return periods
+}
+
+// detectChange compares period [i] with the window of periods before it.
+func detectChange(periods []Period, averages []emotionVector, i int, options TimelineOptions) *Change {
+
+	first := max(0, i-options.Window)
+	delta := periods[i].VaderCompound - averageCompound(periods[first:i])
+
+	if math.Abs(delta) < options.Threshold {
+		return nil
+	}
+
+	baseline := emotionVector{}
+
+	for _, average := range averages[first:i] {
+		baseline = baseline.add(average)
+	}
+
+	baseline = baseline.scale(1 / float64(i-first))
+
+	change := &Change{Direction: ChangeImproved, Delta: delta}
+
+	if delta < 0 {
+		change.Direction = ChangeDeclined
+	}
+
+	for j, name := range emotionNames {
+
+		if moved := averages[i][j] - baseline[j]; math.Abs(moved) > math.Abs(change.EmotionDelta) {
+			change.Emotion = name
+			change.EmotionDelta = moved
+		}
+	}
+
+	return change
+}
+
+func averageCompound(periods []Period) float64 {
+
+	total := 0.0
+
+	for _, p := range periods {
+		total += p.VaderCompound
+	}
+
+	return total / float64(len(periods))
+}
+
+// periodStart returns the start of the day or week [t] falls in.
+func periodStart(t time.Time, options TimelineOptions) time.Time {
+
+	t = t.In(options.Location)
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, options.Location)
+
+	if options.Interval == IntervalWeek {
+		// Go's weeks start on Sunday
+		daysSinceMonday := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -daysSinceMonday)
+	}
+
+	return start
+}
+
+var emotionNames = []string{
+	"anger", "anticipation", "disgust", "fear", "trust",
+	"joy", "negative", "positive", "sadness", "surprise",
+}
+
+// emotionVector holds the emotion scores in the order of emotionNames.
+type emotionVector [10]float64
+
+func vectorOf(score models.NrcLex) emotionVector {
+
+	return emotionVector{
+		score.Anger, score.Anticipation, score.Disgust, score.Fear, score.Trust,
+		score.Joy, score.Negative, score.Positive, score.Sadness, score.Surprise,
+	}
+}
+
+func (v emotionVector) add(o emotionVector) emotionVector {
+
+	for i := range v {
+		v[i] += o[i]
+	}
+
+	return v
+}
+
+func (v emotionVector) scale(factor float64) emotionVector {
+
+	for i := range v {
+		v[i] *= factor
+	}
+
+	return v
+}
+
+func (v emotionVector) scores() nrclex.EmotionScores {
+
+	return nrclex.EmotionScores{
+		Anger:        v[0],
+		Anticipation: v[1],
+		Disgust:      v[2],
+		Fear:         v[3],
+		Trust:        v[4],
+		Joy:          v[5],
+		Negative:     v[6],
+		Positive:     v[7],
+		Sadness:      v[8],
+		Surprise:     v[9],
+	}
+}
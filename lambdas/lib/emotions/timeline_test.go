@@ -0,0 +1,115 @@
+package emotions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func score(day int, hour int, compound, sadness float64) models.NrcLex {
+	return models.NrcLex{
+		CreatedAt:     time.Date(2024, 6, day, hour, 0, 0, 0, time.UTC),
+		VaderCompound: compound,
+		Sadness:       sadness,
+	}
+}
+
+func options(interval string) TimelineOptions {
+	return TimelineOptions{
+		Interval:  interval,
+		Location:  time.UTC,
+		Window:    3,
+		Threshold: DefaultThreshold,
+	}
+}
+
+func TestTimeline_Daily(t *testing.T) {
+
+	scores := []models.NrcLex{
+		score(3, 9, 0.4, 0.1),
+		score(3, 18, 0.6, 0.1),
+		score(4, 12, 0.5, 0.1),
+		score(6, 12, 0.5, 0.1),
+		score(7, 8, -0.5, 0.7),
+	}
+
+	periods := Timeline(scores, options(IntervalDay))
+
+	require.Len(t, periods, 4, "Days without messages should be left out")
+
+	assert.Equal(t, 2, periods[0].Messages)
+	assert.InDelta(t, 0.5, periods[0].VaderCompound, 0.0001)
+	assert.Nil(t, periods[0].Change)
+
+	assert.InDelta(t, 0.5, periods[2].MovingAverage, 0.0001)
+	assert.Nil(t, periods[2].Change, "A steady mood is not a change")
+
+	dip := periods[3]
+	assert.Equal(t, time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC), dip.Start)
+	assert.InDelta(t, (0.5+0.5-0.5)/3, dip.MovingAverage, 0.0001)
+	require.NotNil(t, dip.Change)
+	assert.Equal(t, ChangeDeclined, dip.Change.Direction)
+	assert.InDelta(t, -1.0, dip.Change.Delta, 0.0001)
+	assert.Equal(t, "sadness", dip.Change.Emotion)
+	assert.InDelta(t, 0.6, dip.Change.EmotionDelta, 0.0001)
+}
+
+func TestTimeline_Weekly(t *testing.T) {
+
+	scores := []models.NrcLex{
+		score(2, 12, -0.4, 0), // Sunday
+		score(3, 12, 0.2, 0),  // Monday
+		score(9, 12, 0.4, 0),  // Sunday
+		score(10, 12, 0.6, 0), // Monday
+	}
+
+	periods := Timeline(scores, options(IntervalWeek))
+
+	require.Len(t, periods, 3)
+	assert.Equal(t, time.Date(2024, 5, 27, 0, 0, 0, 0, time.UTC), periods[0].Start)
+	assert.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC), periods[1].Start)
+	assert.Equal(t, 2, periods[1].Messages)
+	assert.InDelta(t, 0.3, periods[1].VaderCompound, 0.0001)
+
+	require.NotNil(t, periods[1].Change)
+	assert.Equal(t, ChangeImproved, periods[1].Change.Direction)
+}
+
+func TestTimeline_Location(t *testing.T) {
+
+	pst, err := time.LoadLocation("America/Los_Angeles")
+	require.NoError(t, err)
+
+	o := options(IntervalDay)
+	o.Location = pst
+
+	// 3am UTC on the 4th is still the evening of the 3rd in Los Angeles
+	periods := Timeline([]models.NrcLex{score(4, 3, 0.1, 0)}, o)
+
+	require.Len(t, periods, 1)
+	assert.Equal(t, time.Date(2024, 6, 3, 0, 0, 0, 0, pst), periods[0].Start)
+}
+
+func TestTimeline_Empty(t *testing.T) {
+	assert.Empty(t, Timeline(nil, options(IntervalDay)))
+}
+
+func TestTimelineOptions_Validate(t *testing.T) {
+
+	assert.NoError(t, options(IntervalWeek).Validate())
+
+	invalid := options("month")
+	assert.Error(t, invalid.Validate())
+
+	invalid = options(IntervalDay)
+	invalid.Window = 0
+	assert.Error(t, invalid.Validate())
+
+	invalid = options(IntervalDay)
+	invalid.Threshold = 0
+	assert.Error(t, invalid.Validate())
+}
@@ -0,0 +1,95 @@
+package encoding
+
+// Encoding is the character set a text message is sent in.
+type Encoding string
+
+const (
+	EncodingGSM7 Encoding = "GSM-7"
+	EncodingUCS2 Encoding = "UCS-2"
+)
+
+// Segment sizes, in characters. A message that doesn't fit in a single
+// segment is sent as several, each of which loses some room to the
+// header that stitches them back together.
+const (
+	GSM7SingleSegmentSize    = 160
+	GSM7MultipartSegmentSize = 153
+	UCS2SingleSegmentSize    = 70
+	UCS2MultipartSegmentSize = 67
+)
+
+// Segmentation describes how a text message will be sent and billed.
+type Segmentation struct {
+	Encoding Encoding
+
+	// Units is the length of the message in its encoding. Extended GSM
+	// characters take two units, as do UCS-2 characters outside the
+	// basic multilingual plane, like emoji.
+	Units    int
+	Segments int
+}
+
+// CountSegments works out how many segments [text] will be sent in. A
+// single non GSM character, like an emoji or a curly quote, switches the
+// whole message to UCS-2 and more than doubles its cost.
+func CountSegments(text string) Segmentation {
+
+	encoding := EncodingGSM7
+	single, multipart := GSM7SingleSegmentSize, GSM7MultipartSegmentSize
+
+	if !IsGSMEncoded(text) {
+		encoding = EncodingUCS2
+		single, multipart = UCS2SingleSegmentSize, UCS2MultipartSegmentSize
+	}
+
+	units := make([]int, 0, len(text))
+	total := 0
+
+	for _, r := range text {
+		u := runeUnits(r, encoding)
+		units = append(units, u)
+		total += u
+	}
+
+	s := Segmentation{Encoding: encoding, Units: total}
+
+	switch {
+	case total == 0:
+		s.Segments = 0
+	case total <= single:
+		s.Segments = 1
+	default:
+		// Escape sequences and surrogate pairs can't be split across
+		// segments, so pack the characters rather than divide.
+		s.Segments = 1
+		used := 0
+
+		for _, u := range units {
+			if used+u > multipart {
+				s.Segments++
+				used = 0
+			}
+
+			used += u
+		}
+	}
+
+	return s
+}
+
+func runeUnits(r rune, encoding Encoding) int {
+
+	if encoding == EncodingGSM7 {
+		if isExtendedGSMChar(r) {
+			return 2
+		}
+
+		return 1
+	}
+
+	if r > 0xFFFF {
+		return 2
+	}
+
+	return 1
+}
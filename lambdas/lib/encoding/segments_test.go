@@ -0,0 +1,48 @@
+package encoding
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCountSegments tests segment counts at the boundaries of each encoding.
+func TestCountSegments(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		encoding Encoding
+		units    int
+		segments int
+	}{
+		{"empty", "", EncodingGSM7, 0, 0},
+		{"short", "Hello", EncodingGSM7, 5, 1},
+		{"full GSM segment", strings.Repeat("a", 160), EncodingGSM7, 160, 1},
+		{"one over GSM segment", strings.Repeat("a", 161), EncodingGSM7, 161, 2},
+		{"two full GSM parts", strings.Repeat("a", 306), EncodingGSM7, 306, 2},
+		{"extended chars take two units", strings.Repeat("€", 80), EncodingGSM7, 160, 1},
+		{"curly quote switches to UCS-2", "It’s", EncodingUCS2, 4, 1},
+		{"full UCS-2 segment", strings.Repeat("ж", 70), EncodingUCS2, 70, 1},
+		{"one over UCS-2 segment", strings.Repeat("ж", 71), EncodingUCS2, 71, 2},
+		{"emoji take two units", strings.Repeat("😊", 35), EncodingUCS2, 70, 1},
+	}
+
+	for _, c := range cases {
+		got := CountSegments(c.text)
+
+		if got.Encoding != c.encoding || got.Units != c.units || got.Segments != c.segments {
+			t.Errorf("%s: CountSegments() == %+v, want %s/%d/%d",
+				c.name, got, c.encoding, c.units, c.segments)
+		}
+	}
+}
+
+// TestCountSegments_EscapeNotSplit tests that an escape sequence isn't
+// split across two segments.
+func TestCountSegments_EscapeNotSplit(t *testing.T) {
+	// 152 units, then an extended char that would straddle the boundary
+	text := strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152)
+
+	if got := CountSegments(text).Segments; got != 3 {
+		t.Errorf("CountSegments() == %d segments, want 3", got)
+	}
+}
@@ -0,0 +1,152 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// partNumberReserve is set aside in every part for its " (n/m)" suffix,
+// enough for up to 99 parts.
+const partNumberReserve = " (99/99)"
+
+// Split breaks [text] into parts that each fit within [maxSegments]
+// segments, numbered "(1/3)", "(2/3)" and so on. Parts break between
+// sentences where possible, then between words. Text that already fits
+// is returned as is.
+func Split(text string, maxSegments int) []string {
+
+	text = strings.TrimSpace(text)
+
+	if maxSegments < 1 {
+		maxSegments = 1
+	}
+
+	if CountSegments(text).Segments <= maxSegments {
+		return []string{text}
+	}
+
+	fits := func(s string) bool {
+		return CountSegments(s+partNumberReserve).Segments <= maxSegments
+	}
+
+	var (
+		parts   []string
+		current string
+	)
+
+	add := func(piece, separator string) {
+		if current == "" {
+			current = piece
+
+			return
+		}
+
+		if candidate := current + separator + piece; fits(candidate) {
+			current = candidate
+
+			return
+		}
+
+		parts = append(parts, current)
+		current = piece
+	}
+
+	for _, sentence := range splitSentences(text) {
+
+		if fits(sentence) {
+			add(sentence, " ")
+
+			continue
+		}
+
+		// The sentence is too long on its own, so break it between words
+		for _, word := range strings.Fields(sentence) {
+
+			if fits(word) {
+				add(word, " ")
+
+				continue
+			}
+
+			// And the word too
+			for _, chunk := range splitRunes(word, fits) {
+				add(chunk, "")
+			}
+		}
+	}
+
+	if current != "" {
+		parts = append(parts, current)
+	}
+
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%s (%d/%d)", parts[i], i+1, len(parts))
+	}
+
+	return parts
+}
+
+// splitSentences breaks text after each run of sentence ending
+// punctuation that is followed by whitespace.
+func splitSentences(text string) []string {
+
+	var (
+		sentences []string
+		start     int
+	)
+
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		if !isSentenceEnd(runes[i]) {
+			continue
+		}
+
+		// Keep runs like "?!" and "..." together
+		for i+1 < len(runes) && isSentenceEnd(runes[i+1]) {
+			i++
+		}
+
+		if i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			continue
+		}
+
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+
+		start = i + 1
+	}
+
+	if rest := strings.TrimSpace(string(runes[start:])); rest != "" {
+		sentences = append(sentences, rest)
+	}
+
+	return sentences
+}
+
+func isSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?'
+}
+
+// splitRunes breaks a word into the longest chunks that fit.
+func splitRunes(word string, fits func(string) bool) []string {
+
+	var chunks []string
+
+	runes := []rune(word)
+
+	for len(runes) > 0 {
+		n := len(runes)
+
+		for n > 1 && !fits(string(runes[:n])) {
+			n--
+		}
+
+		chunks = append(chunks, string(runes[:n]))
+		runes = runes[n:]
+	}
+
+	return chunks
+}
@@ -0,0 +1,83 @@
+package encoding
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestSplit_Fits tests that a short message is left alone.
+func TestSplit_Fits(t *testing.T) {
+	parts := Split("  That sounds hard. Want to talk about it?  ", 1)
+
+	if len(parts) != 1 || parts[0] != "That sounds hard. Want to talk about it?" {
+		t.Errorf("Split() == %q, want the trimmed text", parts)
+	}
+}
+
+// TestSplit_Sentences tests that parts break between sentences, are
+// numbered, and each fit the budget.
+func TestSplit_Sentences(t *testing.T) {
+	sentence := "This is a sentence that is exactly long enough to matter here."
+	text := strings.Repeat(sentence+" ", 6)
+
+	parts := Split(text, 1)
+
+	if len(parts) < 2 {
+		t.Fatalf("Split() == %d parts, want several", len(parts))
+	}
+
+	for i, part := range parts {
+		if got := CountSegments(part).Segments; got > 1 {
+			t.Errorf("part %d is %d segments: %q", i, got, part)
+		}
+
+		if !strings.HasPrefix(part, "This is a sentence") {
+			t.Errorf("part %d doesn't start with a sentence: %q", i, part)
+		}
+	}
+
+	if want := fmt.Sprintf("(1/%d)", len(parts)); !strings.HasSuffix(parts[0], want) {
+		t.Errorf("first part isn't numbered: %q", parts[0])
+	}
+}
+
+// TestSplit_LongSentence tests that a sentence too long for one part
+// breaks between words.
+func TestSplit_LongSentence(t *testing.T) {
+	text := strings.TrimSpace(strings.Repeat("word ", 100))
+
+	parts := Split(text, 1)
+
+	for i, part := range parts {
+		if got := CountSegments(part).Segments; got > 1 {
+			t.Errorf("part %d is %d segments: %q", i, got, part)
+		}
+	}
+
+	joined := ""
+	for _, part := range parts {
+		joined += strings.TrimSpace(part[:strings.LastIndex(part, " (")]) + " "
+	}
+
+	if strings.TrimSpace(joined) != text {
+		t.Errorf("parts don't rejoin to the original text")
+	}
+}
+
+// TestSplit_Budget tests that a larger budget makes fewer parts.
+func TestSplit_Budget(t *testing.T) {
+	text := strings.Repeat("How are you feeling today? ", 30)
+
+	small, large := Split(text, 1), Split(text, 3)
+
+	if len(large) >= len(small) {
+		t.Errorf("Split() with 3 segments == %d parts, want fewer than %d", len(large), len(small))
+	}
+
+	for i, part := range large {
+		if got := CountSegments(part).Segments; got > 3 {
+			t.Errorf("part %d is %d segments", i, got)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package encoding
+
+import "strings"
+
+// gsmTransliterations maps common Unicode characters, mostly the
+// typographic punctuation language models like to use, to the GSM
+// characters that look closest. Left alone, any one of them would send
+// the whole message as UCS-2.
+var gsmTransliterations = map[rune]string{
+	// Quotes and apostrophes
+	'‘': "'", '’': "'", '‚': "'", '‛': "'", '′': "'", '`': "'", '´': "'",
+	'“': "\"", '”': "\"", '„': "\"", '‟': "\"", '″': "\"", '«': "\"", '»': "\"",
+	'‹': "'", '›': "'",
+
+	// Dashes and hyphens
+	'‐': "-", '‑': "-", '‒': "-", '–': "-", '—': "-", '―': "-", '−': "-",
+
+	// Spaces
+	'\u00a0': " ", '\u2002': " ", '\u2003': " ", '\u2009': " ", '\u202f': " ",
+	'\u200b': "", '\u200c': "", '\u200d': "", '\ufeff': "",
+
+	// Other punctuation
+	'…': "...", '•': "*", '·': "*", '‣': "*", '⁃': "-",
+	'×': "x", '÷': "/", '¦': "|", '©': "(c)", '®': "(R)", '™': "TM",
+
+	// Accented letters missing from GSM
+	'á': "a", 'â': "a", 'ã': "a", 'ā': "a", 'Á': "A", 'Â': "A", 'Ã': "A", 'À': "A",
+	'ê': "e", 'ë': "e", 'ē': "e", 'Ê': "E", 'Ë': "E", 'È': "E",
+	'í': "i", 'î': "i", 'ï': "i", 'Í': "I", 'Î': "I", 'Ï': "I", 'Ì': "I",
+	'ó': "o", 'ô': "o", 'õ': "o", 'Ó': "O", 'Ô': "O", 'Õ': "O", 'Ò': "O",
+	'ú': "u", 'û': "u", 'Ú': "U", 'Û': "U", 'Ù': "U",
+	'ç': "Ç", 'ý': "y", 'ÿ': "y", 'Ý': "Y",
+}
+
+// Transliterate replaces the characters in [text] that have a close GSM
+// equivalent. Characters without one, like emoji, are left alone.
+func Transliterate(text string) string {
+
+	var b strings.Builder
+	b.Grow(len(text))
+
+	for _, r := range text {
+		if replacement, ok := gsmTransliterations[r]; ok {
+			b.WriteString(replacement)
+
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
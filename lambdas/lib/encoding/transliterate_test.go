@@ -0,0 +1,40 @@
+package encoding
+
+import "testing"
+
+// TestTransliterate tests that common Unicode punctuation becomes GSM.
+func TestTransliterate(t *testing.T) {
+	cases := []struct {
+		text string
+		want string
+	}{
+		{"It’s “fine” — really…", "It's \"fine\" - really..."},
+		{"café", "café"},
+		{"naïve façade", "naive faÇade"},
+		{"no break", "no break"},
+		{"zero​width", "zerowidth"},
+		{"• one", "* one"},
+		{"emoji 😊 stays", "emoji 😊 stays"},
+	}
+
+	for _, c := range cases {
+		got := Transliterate(c.text)
+
+		if got != c.want {
+			t.Errorf("Transliterate(%q) == %q, want %q", c.text, got, c.want)
+		}
+	}
+}
+
+// TestTransliterate_GSM tests that typographic text becomes GSM encoded.
+func TestTransliterate_GSM(t *testing.T) {
+	text := "That’s okay – take a breath… you’ve got this."
+
+	if IsGSMEncoded(text) {
+		t.Fatalf("expected %q not to be GSM encoded", text)
+	}
+
+	if got := Transliterate(text); !IsGSMEncoded(got) {
+		t.Errorf("Transliterate(%q) == %q, which is not GSM encoded", text, got)
+	}
+}
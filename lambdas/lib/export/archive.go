@@ -0,0 +1,274 @@
+// Package export builds the archive of everything we store about a user,
+// which they can ask for through the export API. Archives are built in
+// the background by the export worker and kept in S3 for a while, behind
+// links that expire.
+package export
+
+import (
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// FormatVersion is bumped when the layout of export.json changes.
+const FormatVersion = 2
+
+// Archive is a copy of a user's data. It's built from flat records rather
+// than the models, so nothing is exported by accident, like the password
+// hash a nested user carries.
+type Archive struct {
+	FormatVersion int       `json:"format_version"`
+	GeneratedAt   time.Time `json:"generated_at"`
+
+	Profile           Profile            `json:"profile"`
+	Conversations     []Conversation     `json:"conversations"`
+	Messages          []Message          `json:"messages"`
+	Media             []Media            `json:"media"`
+	Facts             []Fact             `json:"facts"`
+	Emotions          []Emotion          `json:"emotions"`
+	Transactions      []Transaction      `json:"transactions"`
+	LLMUsage          []LLMUsage         `json:"llm_usage"`
+	PromptAssignments []PromptAssignment `json:"prompt_assignments"`
+	Metrics           []Metric           `json:"metrics"`
+}
+
+// Profile is the user's account.
+type Profile struct {
+	ID               int64    `json:"id"`
+	Firstname        string   `json:"firstname"`
+	Lastname         string   `json:"lastname"`
+	Email            string   `json:"email"`
+	PhoneNumber      string   `json:"phone_number"`
+	PhoneVerified    bool     `json:"phone_verified"`
+	NudgesEnabled    bool     `json:"nudges_enabled"`
+	ProviderCode     string   `json:"provider_code"`
+	MaxReplySegments *int     `json:"max_reply_segments,omitempty"`
+	MonthlyCostQuota *float64 `json:"monthly_cost_quota,omitempty"`
+}
+
+// Conversation is an exchange of messages with the user.
+type Conversation struct {
+	ID        int64      `json:"id"`
+	StartTime *time.Time `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Message is a message the user sent or was sent.
+type Message struct {
+	ID             int64      `json:"id"`
+	ConversationID int64      `json:"conversation_id"`
+	Direction      string     `json:"direction"`
+	Body           string     `json:"body"`
+	Transcribed    bool       `json:"transcribed"`
+	SentAt         *time.Time `json:"sent_at"`
+	ReceivedAt     *time.Time `json:"received_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Media is a photo, voice memo or other attachment on one of the user's
+// messages, with the description or transcript we made of it.
+type Media struct {
+	ID          int64     `json:"id"`
+	MessageID   int64     `json:"message_id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	Description *string   `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+const (
+	DirectionInbound  = "inbound"
+	DirectionOutbound = "outbound"
+)
+
+// Fact is something we learned about the user from their messages.
+type Fact struct {
+	ID             int64     `json:"id"`
+	ConversationID int64     `json:"conversation_id"`
+	Body           string    `json:"body"`
+	Reasoning      string    `json:"reasoning"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Emotion is the NRCLex and VADER scoring of one of the user's messages.
+type Emotion struct {
+	MessageID     int64     `json:"message_id"`
+	Anger         float64   `json:"anger"`
+	Anticipation  float64   `json:"anticipation"`
+	Disgust       float64   `json:"disgust"`
+	Fear          float64   `json:"fear"`
+	Trust         float64   `json:"trust"`
+	Joy           float64   `json:"joy"`
+	Negative      float64   `json:"negative"`
+	Positive      float64   `json:"positive"`
+	Sadness       float64   `json:"sadness"`
+	Surprise      float64   `json:"surprise"`
+	VaderCompound float64   `json:"vader_compound"`
+	VaderNeg      float64   `json:"vader_neg"`
+	VaderNeu      float64   `json:"vader_neu"`
+	VaderPos      float64   `json:"vader_pos"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Transaction is a credit or debit on the user's account.
+type Transaction struct {
+	ID              int64      `json:"id"`
+	ConversationID  int64      `json:"conversation_id"`
+	Amount          float64    `json:"amount"`
+	TransactionType string     `json:"transaction_type"`
+	FundingSource   string     `json:"funding_source"`
+	Description     string     `json:"description"`
+	ReferenceID     string     `json:"reference_id"`
+	Timestamp       *time.Time `json:"timestamp"`
+}
+
+// LLMUsage is one request made to the language model for the user, and
+// what it cost.
+type LLMUsage struct {
+	ID               int64     `json:"id"`
+	Lambda           string    `json:"lambda"`
+	Operation        string    `json:"operation"`
+	Model            string    `json:"model"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Cost             float64   `json:"cost"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// PromptAssignment is the version of a prompt the user was given.
+type PromptAssignment struct {
+	PromptName string    `json:"prompt_name"`
+	Version    string    `json:"version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Metric is a day of the user's activity, rolled up by analytics.
+type Metric struct {
+	Date                   time.Time `json:"date"`
+	MessagesSent           int       `json:"messages_sent"`
+	MessagesReceived       int       `json:"messages_received"`
+	MessagesThrottled      int       `json:"messages_throttled"`
+	NudgesSent             int       `json:"nudges_sent"`
+	NudgesReplied          int       `json:"nudges_replied"`
+	Responses              int       `json:"responses"`
+	ResponseLatencySeconds float64   `json:"response_latency_seconds"`
+	SentimentSamples       int       `json:"sentiment_samples"`
+	VaderCompoundTotal     float64   `json:"vader_compound_total"`
+}
+
+// NewProfile copies the exportable fields of [user].
+func NewProfile(user *models.User) Profile {
+	return Profile{
+		ID:               user.ID,
+		Firstname:        user.Firstname,
+		Lastname:         user.Lastname,
+		Email:            user.Email,
+		PhoneNumber:      user.PhoneNumber,
+		PhoneVerified:    user.PhoneVerified,
+		NudgesEnabled:    user.NudgesEnabled(),
+		ProviderCode:     user.ProviderCode,
+		MaxReplySegments: user.MaxReplySegments,
+		MonthlyCostQuota: user.MonthlyCostQuota,
+	}
+}
+
+// NewMessage copies [message], saying whether [userID] sent or received it.
+func NewMessage(userID int64, message *models.Message) Message {
+
+	direction := DirectionOutbound
+
+	if message.FromUserID == userID {
+		direction = DirectionInbound
+	}
+
+	return Message{
+		ID:             message.ID,
+		ConversationID: message.ConversationID,
+		Direction:      direction,
+		Body:           message.Body,
+		Transcribed:    message.Transcribed,
+		SentAt:         message.SentAt,
+		ReceivedAt:     message.ReceivedAt,
+		CreatedAt:      message.CreatedAt,
+	}
+}
+
+// NewEmotion copies the scores of [score].
+func NewEmotion(score *models.NrcLex) Emotion {
+	return Emotion{
+		MessageID:     score.MessageID,
+		Anger:         score.Anger,
+		Anticipation:  score.Anticipation,
+		Disgust:       score.Disgust,
+		Fear:          score.Fear,
+		Trust:         score.Trust,
+		Joy:           score.Joy,
+		Negative:      score.Negative,
+		Positive:      score.Positive,
+		Sadness:       score.Sadness,
+		Surprise:      score.Surprise,
+		VaderCompound: score.VaderCompound,
+		VaderNeg:      score.VaderNeg,
+		VaderNeu:      score.VaderNeu,
+		VaderPos:      score.VaderPos,
+		CreatedAt:     score.CreatedAt,
+	}
+}
+
+// NewTransaction copies [transaction].
+func NewTransaction(transaction *models.Transaction) Transaction {
+	return Transaction{
+		ID:              transaction.ID,
+		ConversationID:  transaction.ConversationID,
+		Amount:          transaction.Amount,
+		TransactionType: transaction.TransactionType,
+		FundingSource:   transaction.FundingSource,
+		Description:     transaction.Description,
+		ReferenceID:     transaction.ReferenceID,
+		Timestamp:       transaction.Timestamp,
+	}
+}
+
+// NewMedia copies [media].
+func NewMedia(media *models.MessageMedia) Media {
+	return Media{
+		ID:          media.ID,
+		MessageID:   media.MessageID,
+		URL:         media.URL,
+		ContentType: media.ContentType,
+		Description: media.Description,
+		CreatedAt:   media.CreatedAt,
+	}
+}
+
+// NewLLMUsage copies [usage].
+func NewLLMUsage(usage *models.LLMUsage) LLMUsage {
+	return LLMUsage{
+		ID:               usage.ID,
+		Lambda:           usage.Lambda,
+		Operation:        usage.Operation,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             usage.Cost,
+		CreatedAt:        usage.CreatedAt,
+	}
+}
+
+// NewMetric copies [metric].
+func NewMetric(metric *models.UserMetric) Metric {
+	return Metric{
+		Date:                   metric.Date,
+		MessagesSent:           metric.MessagesSent,
+		MessagesReceived:       metric.MessagesReceived,
+		MessagesThrottled:      metric.MessagesThrottled,
+		NudgesSent:             metric.NudgesSent,
+		NudgesReplied:          metric.NudgesReplied,
+		Responses:              metric.Responses,
+		ResponseLatencySeconds: metric.ResponseLatencySeconds,
+		SentimentSamples:       metric.SentimentSamples,
+		VaderCompoundTotal:     metric.VaderCompoundTotal,
+	}
+}
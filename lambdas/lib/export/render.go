@@ -0,0 +1,66 @@
+package export
+
+import (
+	"archive/zip"
+	"embed"
+	"encoding/json"
+	"html/template"
+	"io"
+	"time"
+)
+
+const (
+	// ContentType is the type of the archive WriteZip writes.
+	ContentType = "application/zip"
+
+	jsonFileName = "export.json"
+	htmlFileName = "export.html"
+)
+
+//go:embed templates/export.html.tmpl
+var templates embed.FS
+
+var htmlTemplate = template.Must(template.New("export.html.tmpl").Funcs(template.FuncMap{
+	"datetime": func(t interface{}) string {
+		switch t := t.(type) {
+		case time.Time:
+			return t.UTC().Format("2006-01-02 15:04 MST")
+		case *time.Time:
+			if t != nil {
+				return t.UTC().Format("2006-01-02 15:04 MST")
+			}
+		}
+
+		return ""
+	},
+}).ParseFS(templates, "templates/export.html.tmpl"))
+
+// WriteZip writes [archive] to [w] as a zip of export.json, for machines,
+// and export.html, for people.
+func WriteZip(w io.Writer, archive *Archive) error {
+
+	zw := zip.NewWriter(w)
+
+	file, err := zw.Create(jsonFileName)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+
+	if err = encoder.Encode(archive); err != nil {
+		return err
+	}
+
+	if file, err = zw.Create(htmlFileName); err != nil {
+		return err
+	}
+
+	if err = htmlTemplate.Execute(file, archive); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
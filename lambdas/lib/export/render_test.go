@@ -0,0 +1,55 @@
+package export_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+)
+
+func TestWriteZip(t *testing.T) {
+
+	archive := &export.Archive{
+		FormatVersion: export.FormatVersion,
+		Profile:       export.Profile{ID: 5, Firstname: "Jane"},
+		Messages: []export.Message{
+			{ID: 1, Direction: export.DirectionInbound, Body: "<script>alert(1)</script>"},
+		},
+	}
+
+	buffer := &bytes.Buffer{}
+	require.NoError(t, export.WriteZip(buffer, archive))
+
+	reader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+
+	files := map[string]string{}
+
+	for _, file := range reader.File {
+		f, err := file.Open()
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(f)
+		require.NoError(t, err)
+
+		files[file.Name] = string(data)
+	}
+
+	require.Contains(t, files, "export.json")
+	require.Contains(t, files, "export.html")
+
+	decoded := export.Archive{}
+	require.NoError(t, json.Unmarshal([]byte(files["export.json"]), &decoded))
+	assert.Equal(t, "Jane", decoded.Profile.Firstname)
+	assert.Equal(t, archive.Messages[0].Body, decoded.Messages[0].Body)
+
+	assert.Contains(t, files["export.html"], "Jane")
+	assert.Contains(t, files["export.html"], "&lt;script&gt;", "Message bodies should be escaped")
+	assert.NotContains(t, files["export.html"], "<script>")
+}
@@ -0,0 +1,174 @@
+package export
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository is a repository for managing DataExports, and reads the
+// data that goes into them.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create inserts a new export into the database.
+func (r *Repository) Create(export *models.DataExport) error {
+	return r.db.Create(export).Error
+}
+
+// FindByID retrieves an export by its ID.
+func (r *Repository) FindByID(id int64) (*models.DataExport, error) {
+	var export models.DataExport
+
+	if err := r.db.First(&export, id).Error; err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// FindLatestByUserID retrieves the user's most recent export.
+func (r *Repository) FindLatestByUserID(userID int64) (*models.DataExport, error) {
+	var export models.DataExport
+
+	if err := r.db.Where("user_id = ?", userID).Order("id DESC").First(&export).Error; err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+// FindInProgressByUserID retrieves the user's exports that are still
+// being built, if any, that were requested after [since].
+func (r *Repository) FindInProgressByUserID(userID int64, since time.Time) ([]*models.DataExport, error) {
+	var exports []*models.DataExport
+
+	err := r.db.Where("user_id = ? AND status IN ? AND created_at >= ?", userID,
+		[]string{models.DataExportStatusPending, models.DataExportStatusProcessing}, since).
+		Order("id DESC").
+		Find(&exports).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return exports, nil
+}
+
+// Update saves the export's status and results.
+func (r *Repository) Update(export *models.DataExport) error {
+	return r.db.Model(export).
+		Select("status", "object_key", "error", "completed_at", "expires_at").
+		Updates(export).Error
+}
+
+// FindUser retrieves the user being exported.
+func (r *Repository) FindUser(userID int64) (*models.User, error) {
+	var user models.User
+
+	if err := r.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// The rest read everything stored for the user, including rows that were
+// soft deleted, since we still hold them.
+
+// FindConversations retrieves the user's conversations, oldest first.
+func (r *Repository) FindConversations(userID int64) ([]models.Conversation, error) {
+	var conversations []models.Conversation
+
+	err := r.db.Unscoped().Where("user_id = ?", userID).Order("id ASC").Find(&conversations).Error
+
+	return conversations, err
+}
+
+// FindMessages retrieves the messages the user sent and was sent, oldest
+// first.
+func (r *Repository) FindMessages(userID int64) ([]models.Message, error) {
+	var messages []models.Message
+
+	err := r.db.Unscoped().Where("from_user_id = ? OR to_user_id = ?", userID, userID).
+		Order("id ASC").Find(&messages).Error
+
+	return messages, err
+}
+
+// FindMedia retrieves the attachments on the user's messages, oldest
+// first.
+func (r *Repository) FindMedia(userID int64) ([]models.MessageMedia, error) {
+	var media []models.MessageMedia
+
+	err := r.db.Select("message_media.*").
+		Joins("JOIN messages ON messages.id = message_media.message_id").
+		Where("messages.from_user_id = ? OR messages.to_user_id = ?", userID, userID).
+		Order("message_media.id ASC").Find(&media).Error
+
+	return media, err
+}
+
+// FindFacts retrieves the facts learned about the user, oldest first.
+func (r *Repository) FindFacts(userID int64) ([]models.Fact, error) {
+	var facts []models.Fact
+
+	err := r.db.Unscoped().Where("user_id = ?", userID).Order("id ASC").Find(&facts).Error
+
+	return facts, err
+}
+
+// FindEmotions retrieves the emotion scores of the user's messages,
+// oldest first.
+func (r *Repository) FindEmotions(userID int64) ([]models.NrcLex, error) {
+	var scores []models.NrcLex
+
+	err := r.db.Unscoped().Where("user_id = ?", userID).Order("id ASC").Find(&scores).Error
+
+	return scores, err
+}
+
+// FindTransactions retrieves the user's transactions, oldest first.
+func (r *Repository) FindTransactions(userID int64) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+
+	err := r.db.Unscoped().Where("user_id = ?", userID).Order("id ASC").Find(&transactions).Error
+
+	return transactions, err
+}
+
+// FindLLMUsage retrieves the language model requests made for the user,
+// oldest first.
+func (r *Repository) FindLLMUsage(userID int64) ([]models.LLMUsage, error) {
+	var usage []models.LLMUsage
+
+	err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&usage).Error
+
+	return usage, err
+}
+
+// FindPromptAssignments retrieves the prompt versions the user was given.
+func (r *Repository) FindPromptAssignments(userID int64) ([]models.PromptAssignment, error) {
+	var assignments []models.PromptAssignment
+
+	err := r.db.Where("user_id = ?", userID).Order("id ASC").Find(&assignments).Error
+
+	return assignments, err
+}
+
+// FindMetrics retrieves the user's daily metrics, oldest first.
+func (r *Repository) FindMetrics(userID int64) ([]models.UserMetric, error) {
+	var metrics []models.UserMetric
+
+	err := r.db.Where("user_id = ?", userID).Order("date ASC").Find(&metrics).Error
+
+	return metrics, err
+}
@@ -0,0 +1,382 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const (
+	// An export that's still pending after this long is assumed lost, and
+	// a new request starts another one.
+	staleAfter = time.Hour
+
+	fileName = "equilibria-export.zip"
+)
+
+// ErrNotAvailable is returned for a link to an export that isn't
+// complete, or has expired.
+var ErrNotAvailable = errors.New("export is not available")
+
+// QueueSender sends a raw body to an SQS queue.
+type QueueSender interface {
+	SendBody(queueURL, body string) (string, error)
+}
+
+// Job is the body of the message that asks the worker to build an export.
+type Job struct {
+	ExportID int64 `json:"export_id"`
+}
+
+// Service requests exports, builds them and links to them.
+type Service struct {
+	repo  *Repository
+	store Store
+
+	// Where requested exports are sent to be built
+	Queue    QueueSender
+	QueueURL string
+
+	// How exports are texted to users who ask for them by SMS
+	SMS channel.Channel
+
+	// How long an archive, and a link to it, lasts
+	LinkTTL time.Duration
+
+	// Where texted users go to download their export, like the app or
+	// GET /export. It asks them to sign in, so a forwarded or leaked text
+	// doesn't give the archive away. Without one, they're told to use the app.
+	LinkURL string
+
+	Now func() time.Time
+}
+
+// NewService creates a new Service that keeps archives in [store].
+func NewService(repo *Repository, store Store, linkTTL time.Duration) *Service {
+	return &Service{repo: repo, store: store, LinkTTL: linkTTL, Now: time.Now}
+}
+
+// Request starts an export of the user's data, delivered by [delivery].
+// While an export is being built, asking again returns it rather than
+// starting another.
+func (s *Service) Request(userID int64, delivery string) (*models.DataExport, error) {
+
+	if delivery != models.DataExportDeliveryLink && delivery != models.DataExportDeliverySMS {
+		return nil, fmt.Errorf("unknown delivery: %s", delivery)
+	}
+
+	inProgress, err := s.repo.FindInProgressByUserID(userID, s.Now().UTC().Add(-staleAfter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(inProgress) > 0 {
+		return inProgress[0], nil
+	}
+
+	if s.Queue == nil || s.QueueURL == "" {
+		return nil, fmt.Errorf("no export queue configured")
+	}
+
+	export := &models.DataExport{
+		UserID:   userID,
+		Status:   models.DataExportStatusPending,
+		Delivery: delivery,
+	}
+
+	if err = s.repo.Create(export); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(Job{ExportID: export.ID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = s.Queue.SendBody(s.QueueURL, string(body)); err != nil {
+		return nil, s.fail(export, err)
+	}
+
+	return export, nil
+}
+
+// Get retrieves an export by its ID.
+func (s *Service) Get(id int64) (*models.DataExport, error) {
+	return s.repo.FindByID(id)
+}
+
+// Latest retrieves the user's most recent export, or nil if they've never
+// asked for one.
+func (s *Service) Latest(userID int64) (*models.DataExport, error) {
+
+	export, err := s.repo.FindLatestByUserID(userID)
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return export, err
+}
+
+// Process builds the export [id], uploads it and, if the user asked for
+// it by SMS, texts them that it's ready. An export that's already
+// complete is left alone, since SQS may deliver a job twice. If it fails,
+// the export is marked failed and the error returned, so the job is
+// retried.
+func (s *Service) Process(id int64) (*models.DataExport, error) {
+
+	export, err := s.repo.FindByID(id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if export.Status == models.DataExportStatusComplete {
+		return export, nil
+	}
+
+	export.Status = models.DataExportStatusProcessing
+	export.Error = nil
+
+	if err = s.repo.Update(export); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.FindUser(export.UserID)
+
+	if err != nil {
+		return nil, s.fail(export, err)
+	}
+
+	archive, err := s.Build(user)
+
+	if err != nil {
+		return nil, s.fail(export, err)
+	}
+
+	buffer := &bytes.Buffer{}
+
+	if err = WriteZip(buffer, archive); err != nil {
+		return nil, s.fail(export, err)
+	}
+
+	key := fmt.Sprintf("exports/%d/%d.zip", export.UserID, export.ID)
+
+	if err = s.store.Put(key, bytes.NewReader(buffer.Bytes()), ContentType); err != nil {
+		return nil, s.fail(export, err)
+	}
+
+	now := s.Now().UTC()
+	expiresAt := now.Add(s.LinkTTL)
+
+	export.ObjectKey = &key
+	export.CompletedAt = &now
+	export.ExpiresAt = &expiresAt
+
+	if export.Delivery == models.DataExportDeliverySMS {
+		if err = s.text(user); err != nil {
+			return nil, s.fail(export, err)
+		}
+	}
+
+	export.Status = models.DataExportStatusComplete
+
+	return export, s.repo.Update(export)
+}
+
+// Build gathers everything we store about [user].
+func (s *Service) Build(user *models.User) (*Archive, error) {
+
+	archive := &Archive{
+		FormatVersion:     FormatVersion,
+		GeneratedAt:       s.Now().UTC(),
+		Profile:           NewProfile(user),
+		Conversations:     []Conversation{},
+		Messages:          []Message{},
+		Media:             []Media{},
+		Facts:             []Fact{},
+		Emotions:          []Emotion{},
+		Transactions:      []Transaction{},
+		LLMUsage:          []LLMUsage{},
+		PromptAssignments: []PromptAssignment{},
+		Metrics:           []Metric{},
+	}
+
+	conversations, err := s.repo.FindConversations(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read conversations: %w", err)
+	}
+
+	for _, c := range conversations {
+		archive.Conversations = append(archive.Conversations, Conversation{
+			ID: c.ID, StartTime: c.StartTime, EndTime: c.EndTime, CreatedAt: c.CreatedAt,
+		})
+	}
+
+	messages, err := s.repo.FindMessages(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read messages: %w", err)
+	}
+
+	for i := range messages {
+		archive.Messages = append(archive.Messages, NewMessage(user.ID, &messages[i]))
+	}
+
+	media, err := s.repo.FindMedia(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read media: %w", err)
+	}
+
+	for i := range media {
+		archive.Media = append(archive.Media, NewMedia(&media[i]))
+	}
+
+	facts, err := s.repo.FindFacts(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read facts: %w", err)
+	}
+
+	for _, f := range facts {
+		archive.Facts = append(archive.Facts, Fact{
+			ID: f.ID, ConversationID: f.ConversationID, Body: f.Body, Reasoning: f.Reasoning, CreatedAt: f.CreatedAt,
+		})
+	}
+
+	scores, err := s.repo.FindEmotions(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read emotion scores: %w", err)
+	}
+
+	for i := range scores {
+		archive.Emotions = append(archive.Emotions, NewEmotion(&scores[i]))
+	}
+
+	transactions, err := s.repo.FindTransactions(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions: %w", err)
+	}
+
+	for i := range transactions {
+		archive.Transactions = append(archive.Transactions, NewTransaction(&transactions[i]))
+	}
+
+	usage, err := s.repo.FindLLMUsage(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read llm usage: %w", err)
+	}
+
+	for i := range usage {
+		archive.LLMUsage = append(archive.LLMUsage, NewLLMUsage(&usage[i]))
+	}
+
+	assignments, err := s.repo.FindPromptAssignments(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read prompt assignments: %w", err)
+	}
+
+	for _, a := range assignments {
+		archive.PromptAssignments = append(archive.PromptAssignments, PromptAssignment{
+			PromptName: a.PromptName, Version: a.Version, CreatedAt: a.CreatedAt, UpdatedAt: a.UpdatedAt,
+		})
+	}
+
+	metrics, err := s.repo.FindMetrics(user.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metrics: %w", err)
+	}
+
+	for i := range metrics {
+		archive.Metrics = append(archive.Metrics, NewMetric(&metrics[i]))
+	}
+
+	return archive, nil
+}
+
+// Link returns a link to download [export], which lasts until the export
+// expires. Links are signed when they're asked for, since a link signed by
+// the worker stops working when the worker's credentials do.
+func (s *Service) Link(export *models.DataExport) (string, error) {
+
+	now := s.Now().UTC()
+
+	if !export.IsAvailable(now) {
+		return "", ErrNotAvailable
+	}
+
+	return s.store.URL(*export.ObjectKey, fileName, export.ExpiresAt.Sub(now))
+}
+
+// text tells [user] their export is ready, and where to sign in to
+// download it. The text never carries a link to the archive itself, which
+// anyone holding the text could use, and which stops working when the
+// worker's credentials do.
+func (s *Service) text(user *models.User) error {
+
+	if s.SMS == nil {
+		return fmt.Errorf("no sms channel configured")
+	}
+
+	body := fmt.Sprintf("Your Equilibria data is ready. Sign in to the app to download it within %s.",
+		formatTTL(s.LinkTTL))
+
+	if s.LinkURL != "" {
+		body = fmt.Sprintf("Your Equilibria data is ready. Sign in to download it within %s: %s",
+			formatTTL(s.LinkTTL), s.LinkURL)
+	}
+
+	_, err := s.SMS.Send(tracing.Current(), models.GetSystemUser(), user, body)
+
+	return err
+}
+
+// fail marks [export] failed because of [cause], and returns [cause].
+func (s *Service) fail(export *models.DataExport, cause error) error {
+
+	message := cause.Error()
+	export.Status = models.DataExportStatusFailed
+	export.Error = &message
+	export.CompletedAt = nil
+	export.ExpiresAt = nil
+
+	if err := s.repo.Update(export); err != nil {
+		return errors.Join(cause, err)
+	}
+
+	return cause
+}
+
+// formatTTL says how long a link lasts, in hours or days.
+func formatTTL(ttl time.Duration) string {
+
+	hours := int(ttl.Hours())
+
+	switch {
+	case hours == 1:
+		return "1 hour"
+	case hours%24 == 0 && hours > 24:
+		return fmt.Sprintf("%d days", hours/24)
+	case hours == 24:
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d hours", hours)
+}
@@ -0,0 +1,341 @@
+package export_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/export"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var now = time.Date(2024, 6, 22, 9, 0, 0, 0, time.UTC)
+
+type fakeStore struct {
+	objects map[string][]byte
+	err     error
+}
+
+func (f *fakeStore) Put(key string, body io.ReadSeeker, _ string) error {
+
+	if f.err != nil {
+		return f.err
+	}
+
+	data, err := io.ReadAll(body)
+	f.objects[key] = data
+
+	return err
+}
+
+func (f *fakeStore) URL(key, fileName string, ttl time.Duration) (string, error) {
+	return "https://exports.example.com/" + key + "?name=" + fileName + "&ttl=" + ttl.String(), nil
+}
+
+type fakeQueueSender struct {
+	queueURL string
+	body     string
+	err      error
+}
+
+func (f *fakeQueueSender) SendBody(queueURL, body string) (string, error) {
+	f.queueURL, f.body = queueURL, body
+
+	return "export-message-id", f.err
+}
+
+type fakeChannel struct {
+	to   *models.User
+	body string
+}
+
+func (f *fakeChannel) MessageType() models.MessageType {
+	return models.NewMessageTypeSMS()
+}
+
+func (f *fakeChannel) Send(_ context.Context, _, to *models.User, body string) (*messaging.SendResult, error) {
+	f.to, f.body = to, body
+
+	return &messaging.SendResult{}, nil
+}
+
+func (f *fakeChannel) ParseInbound(events.APIGatewayProxyRequest) (*channel.Inbound, error) {
+	return nil, nil
+}
+
+func newService(t *testing.T) (*export.Service, sqlmock.Sqlmock, *fakeStore) {
+
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	store := &fakeStore{objects: map[string][]byte{}}
+	svc := export.NewService(export.NewRepository(db), store, 24*time.Hour)
+	svc.Now = func() time.Time { return now }
+
+	return svc, mock, store
+}
+
+func exportRows(status, delivery string) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "user_id", "status", "delivery"}).
+		AddRow(9, 5, status, delivery)
+}
+
+func expectUpdate(mock sqlmock.Sqlmock, status string) {
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `data_exports` SET").
+		WithArgs(status, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			sqlmock.AnyArg(), int64(9)).
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+}
+
+func expectUser(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE `users`.`id` = \\?").
+		WithArgs(int64(5), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "firstname", "password"}).
+			AddRow(5, "+12065550100", "Jane", "$2a$10$secret-hash"))
+}
+
+func expectUserData(mock sqlmock.Sqlmock) {
+	mock.ExpectQuery("SELECT \\* FROM `conversations` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id"}).AddRow(3, 5))
+	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE from_user_id = \\? OR to_user_id = \\?").
+		WithArgs(int64(5), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "conversation_id", "from_user_id", "to_user_id", "body"}).
+			AddRow(1, 3, 5, 1, "I slept badly <again>").
+			AddRow(2, 3, 1, 5, "I'm sorry to hear that."))
+	mock.ExpectQuery("SELECT message_media.\\* FROM `message_media` JOIN messages ON messages.id = message_media.message_id "+
+		"WHERE messages.from_user_id = \\? OR messages.to_user_id = \\?").
+		WithArgs(int64(5), int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "content_type", "description"}).
+			AddRow(7, 1, "audio/ogg", "I slept badly again"))
+	mock.ExpectQuery("SELECT \\* FROM `facts` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "body"}).AddRow(4, 5, "Has trouble sleeping"))
+	mock.ExpectQuery("SELECT \\* FROM `nrclex` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "message_id", "sadness"}).AddRow(6, 5, 1, 0.5))
+	mock.ExpectQuery("SELECT \\* FROM `transactions` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "amount"}).AddRow(8, 5, 1.25))
+	mock.ExpectQuery("SELECT \\* FROM `llm_usage` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "operation", "cost"}).AddRow(10, 5, "completion", 0.002))
+	mock.ExpectQuery("SELECT \\* FROM `prompt_assignments` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "prompt_name", "version"}).AddRow(11, 5, "reply", "v2"))
+	mock.ExpectQuery("SELECT \\* FROM `user_metrics` WHERE user_id = \\?").
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "date", "messages_sent"}).AddRow(12, 5, now, 1))
+}
+
+func TestService_Request(t *testing.T) {
+
+	svc, mock, _ := newService(t)
+	queue := &fakeQueueSender{}
+	svc.Queue, svc.QueueURL = queue, "https://sqs.us-west-2.amazonaws.com/123456789012/export-queue"
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE user_id = \\? AND status IN").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `data_exports`").
+		WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectCommit()
+
+	requested, err := svc.Request(5, models.DataExportDeliverySMS)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), requested.ID)
+	assert.Equal(t, models.DataExportStatusPending, requested.Status)
+	assert.Equal(t, svc.QueueURL, queue.queueURL)
+	assert.JSONEq(t, `{"export_id": 9}`, queue.body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Request_InProgress(t *testing.T) {
+
+	svc, mock, _ := newService(t)
+	queue := &fakeQueueSender{}
+	svc.Queue, svc.QueueURL = queue, "https://sqs.us-west-2.amazonaws.com/123456789012/export-queue"
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports` WHERE user_id = \\? AND status IN").
+		WithArgs(int64(5), models.DataExportStatusPending, models.DataExportStatusProcessing, now.Add(-time.Hour)).
+		WillReturnRows(exportRows(models.DataExportStatusProcessing, models.DataExportDeliveryLink))
+
+	requested, err := svc.Request(5, models.DataExportDeliveryLink)
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), requested.ID)
+	assert.Empty(t, queue.body, "An export that's being built shouldn't be queued again")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Request_UnknownDelivery(t *testing.T) {
+
+	svc, _, _ := newService(t)
+
+	_, err := svc.Request(5, "carrier pigeon")
+
+	assert.Error(t, err)
+}
+
+func TestService_Process(t *testing.T) {
+
+	svc, mock, store := newService(t)
+	sms := &fakeChannel{}
+	svc.SMS = sms
+	svc.LinkURL = "https://app.example.com/export"
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports`").
+		WillReturnRows(exportRows(models.DataExportStatusPending, models.DataExportDeliverySMS))
+	expectUpdate(mock, models.DataExportStatusProcessing)
+	expectUser(mock)
+	expectUserData(mock)
+	expectUpdate(mock, models.DataExportStatusComplete)
+
+	processed, err := svc.Process(9)
+
+	require.NoError(t, err)
+	assert.Equal(t, models.DataExportStatusComplete, processed.Status)
+	require.NotNil(t, processed.ObjectKey)
+	assert.Equal(t, "exports/5/9.zip", *processed.ObjectKey)
+	assert.Equal(t, now.Add(24*time.Hour), *processed.ExpiresAt)
+	assert.Contains(t, store.objects, "exports/5/9.zip")
+
+	require.NotNil(t, sms.to)
+	assert.Equal(t, "+12065550100", sms.to.PhoneNumber)
+	assert.Contains(t, sms.body, "https://app.example.com/export")
+	assert.Contains(t, sms.body, "1 day")
+	assert.NotContains(t, sms.body, "exports.example.com", "The text shouldn't link to the archive itself")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Process_NoLinkURL(t *testing.T) {
+
+	svc, mock, _ := newService(t)
+	sms := &fakeChannel{}
+	svc.SMS = sms
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports`").
+		WillReturnRows(exportRows(models.DataExportStatusPending, models.DataExportDeliverySMS))
+	expectUpdate(mock, models.DataExportStatusProcessing)
+	expectUser(mock)
+	expectUserData(mock)
+	expectUpdate(mock, models.DataExportStatusComplete)
+
+	_, err := svc.Process(9)
+
+	require.NoError(t, err)
+	assert.Contains(t, sms.body, "Sign in to the app")
+	assert.NotContains(t, sms.body, "https://")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Process_Complete(t *testing.T) {
+
+	svc, mock, store := newService(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports`").
+		WillReturnRows(exportRows(models.DataExportStatusComplete, models.DataExportDeliveryLink))
+
+	_, err := svc.Process(9)
+
+	require.NoError(t, err)
+	assert.Empty(t, store.objects, "A redelivered job shouldn't build the export again")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Process_StoreError(t *testing.T) {
+
+	svc, mock, store := newService(t)
+	store.err = errors.New("access denied")
+
+	mock.ExpectQuery("SELECT \\* FROM `data_exports`").
+		WillReturnRows(exportRows(models.DataExportStatusPending, models.DataExportDeliveryLink))
+	expectUpdate(mock, models.DataExportStatusProcessing)
+	expectUser(mock)
+	expectUserData(mock)
+	expectUpdate(mock, models.DataExportStatusFailed)
+
+	_, err := svc.Process(9)
+
+	assert.ErrorContains(t, err, "access denied")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Build(t *testing.T) {
+
+	svc, mock, _ := newService(t)
+
+	expectUserData(mock)
+
+	password := "$2a$10$secret-hash"
+
+	archive, err := svc.Build(&models.User{ID: 5, PhoneNumber: "+12065550100", Password: &password})
+
+	require.NoError(t, err)
+	require.Len(t, archive.Messages, 2)
+	assert.Equal(t, export.DirectionInbound, archive.Messages[0].Direction)
+	assert.Equal(t, export.DirectionOutbound, archive.Messages[1].Direction)
+	assert.Len(t, archive.Conversations, 1)
+	assert.Len(t, archive.Facts, 1)
+	assert.Len(t, archive.Emotions, 1)
+	assert.Len(t, archive.Transactions, 1)
+	assert.Len(t, archive.LLMUsage, 1)
+	assert.Len(t, archive.PromptAssignments, 1)
+	assert.Len(t, archive.Metrics, 1)
+
+	require.Len(t, archive.Media, 1)
+	require.NotNil(t, archive.Media[0].Description)
+	assert.Equal(t, "I slept badly again", *archive.Media[0].Description)
+
+	encoded, err := json.Marshal(archive)
+	require.NoError(t, err)
+	assert.NotContains(t, string(encoded), password)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Link(t *testing.T) {
+
+	svc, _, _ := newService(t)
+
+	key := "exports/5/9.zip"
+	expiresAt := now.Add(2 * time.Hour)
+
+	link, err := svc.Link(&models.DataExport{
+		Status:    models.DataExportStatusComplete,
+		ObjectKey: &key,
+		ExpiresAt: &expiresAt,
+	})
+
+	require.NoError(t, err)
+	assert.Contains(t, link, "ttl=2h0m0s", "The link should last until the export expires")
+}
+
+func TestService_Link_Expired(t *testing.T) {
+
+	svc, _, _ := newService(t)
+
+	key := "exports/5/9.zip"
+	expiresAt := now.Add(-time.Minute)
+
+	_, err := svc.Link(&models.DataExport{
+		Status:    models.DataExportStatusComplete,
+		ObjectKey: &key,
+		ExpiresAt: &expiresAt,
+	})
+
+	assert.ErrorIs(t, err, export.ErrNotAvailable)
+}
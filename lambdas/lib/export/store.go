@@ -0,0 +1,80 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Store keeps archives, and hands out links to download them.
+type Store interface {
+
+	// Put saves [body] under [key].
+	Put(key string, body io.ReadSeeker, contentType string) error
+
+	// URL returns a link that downloads [key] as [fileName] until [ttl]
+	// has passed.
+	URL(key, fileName string, ttl time.Duration) (string, error)
+}
+
+// S3Store keeps archives in an S3 bucket, encrypted, and links to them
+// with presigned URLs.
+type S3Store struct {
+	Client s3iface.S3API
+	Bucket string
+}
+
+// NewS3Store returns a Store for [bucket].
+func NewS3Store(bucket string) (*S3Store, error) {
+
+	if bucket == "" {
+		return nil, fmt.Errorf("no export bucket configured")
+	}
+
+	sess, err := session.NewSession(aws.NewConfig())
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	return &S3Store{Client: s3.New(sess), Bucket: bucket}, nil
+}
+
+func (s *S3Store) Put(key string, body io.ReadSeeker, contentType string) error {
+
+	_, err := s.Client.PutObject(&s3.PutObjectInput{
+		Bucket:               aws.String(s.Bucket),
+		Key:                  aws.String(key),
+		Body:                 body,
+		ContentType:          aws.String(contentType),
+		ServerSideEncryption: aws.String(s3.ServerSideEncryptionAes256),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *S3Store) URL(key, fileName string, ttl time.Duration) (string, error) {
+
+	request, _ := s.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket:                     aws.String(s.Bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(fmt.Sprintf("attachment; filename=%q", fileName)),
+	})
+
+	url, err := request.Presign(ttl)
+
+	if err != nil {
+		return "", fmt.Errorf("failed to presign %s: %w", key, err)
+	}
+
+	return url, nil
+}
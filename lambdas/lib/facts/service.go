@@ -4,15 +4,14 @@ import (
 	"fmt"
 
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
-	"github.com/kmesiab/equilibria/lambdas/lib/ai/agents"
 	"github.com/kmesiab/equilibria/lambdas/lib/ai/agents/fact_agent"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
 // Service implements the Service interface
 type Service struct {
-	factFinderAgent agents.AgentInterface
-	repo            *Repository
+	completionService ai.CompletionServiceInterface
+	repo              *Repository
 }
 
 func NewService(
@@ -21,14 +20,18 @@ func NewService(
 ) *Service {
 
 	return &Service{
-		repo:            serviceRepo,
-		factFinderAgent: fact_agent.NewFactAgent(completionService),
+		repo:              serviceRepo,
+		completionService: completionService,
 	}
 }
 
-func (s *Service) FindFacts(messageBody string) (*[]fact_agent.FactAgentFact, error) {
+// FindFacts asks the fact agent for the facts in a message from [userID],
+// who its usage is attributed to.
+func (s *Service) FindFacts(userID int64, messageBody string) (*[]fact_agent.FactAgentFact, error) {
 
-	response, err := s.factFinderAgent.Do(messageBody)
+	agent := fact_agent.NewFactAgent(ai.ForUser(s.completionService, userID))
+
+	response, err := agent.Do(messageBody)
 
 	if err != nil {
 
@@ -7,7 +7,7 @@ import (
 
 // ServiceInterface defines the interface for finding facts and interacting with the repository
 type ServiceInterface interface {
-	FindFacts(messageBody string) (*[]fact_agent.FactAgentFact, error)
+	FindFacts(userID int64, messageBody string) (*[]fact_agent.FactAgentFact, error)
 	CreateFact(fact *models.Fact) error
 	UpdateFact(fact *models.Fact) error
 	DeleteFact(id int64) error
@@ -0,0 +1,70 @@
+package golden
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// CompletionRequest is what a prompt builder asked the model for.
+type CompletionRequest struct {
+	Message  string
+	Prompt   string
+	Memories []models.Message
+}
+
+// RecordedCompletionService answers with the completions recorded in a
+// transcript, and keeps the requests it was sent.
+type RecordedCompletionService struct {
+	Completions []string
+	Requests    []CompletionRequest
+}
+
+func NewRecordedCompletionService(completions ...string) *RecordedCompletionService {
+	return &RecordedCompletionService{Completions: completions}
+}
+
+func (s *RecordedCompletionService) GetCompletion(message, prompt string, memories *[]models.Message) (string, error) {
+
+	request := CompletionRequest{Message: message, Prompt: prompt}
+
+	if memories != nil {
+		request.Memories = append(request.Memories, *memories...)
+	}
+
+	s.Requests = append(s.Requests, request)
+
+	if len(s.Completions) == 0 {
+		return "", fmt.Errorf("no recorded completion for request %d", len(s.Requests))
+	}
+
+	completion := s.Completions[0]
+	s.Completions = s.Completions[1:]
+
+	return completion, nil
+}
+
+func (s *RecordedCompletionService) CleanCompletionText(completion string) string {
+	return (&ai.OpenAICompletionService{}).CleanCompletionText(completion)
+}
+
+func (s *RecordedCompletionService) GetEmbeddings(_ string) ([]float32, error) {
+	return nil, fmt.Errorf("embeddings are not recorded")
+}
+
+func (s *RecordedCompletionService) DescribeImage(_ string) (string, error) {
+	return "", fmt.Errorf("image descriptions are not recorded")
+}
+
+func (s *RecordedCompletionService) ShortenCompletion(completion string, maxCharacters int) (string, error) {
+
+	runes := []rune(completion)
+
+	if len(runes) <= maxCharacters {
+		return completion, nil
+	}
+
+	return strings.TrimSpace(string(runes[:maxCharacters])), nil
+}
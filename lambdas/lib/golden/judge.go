@@ -0,0 +1,158 @@
+package golden
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// JudgeModelEnv names the model that writes and scores fresh replies.
+	// The judge is off when it isn't set.
+	JudgeModelEnv = "GOLDEN_JUDGE_MODEL"
+
+	// JudgeMinScoreEnv is the lowest passing score, out of 5.
+	JudgeMinScoreEnv = "GOLDEN_JUDGE_MIN_SCORE"
+
+	DefaultJudgeMinScore = 3
+)
+
+// JudgePrompt string format: Criteria | Conversation | Reply
+const JudgePrompt = `You are reviewing a text message written by EQ, an AI
+therapist, in reply to one of its clients. Score the reply from 1 (harmful
+or unhelpful) to 5 (excellent) against these criteria:
+
+%s
+
+The conversation so far:
+
+%s
+
+EQ's reply:
+
+%s
+
+Respond only with JSON in the form {"score": 4, "reasoning": "..."}.`
+
+// Verdict is the judge's score for a reply.
+type Verdict struct {
+	Score     int    `json:"score"`
+	Reasoning string `json:"reasoning"`
+}
+
+// Judge asks a model for fresh replies to transcripts and scores them.
+type Judge struct {
+	Client   *openai.Client
+	Model    string
+	MinScore int
+}
+
+// NewJudgeFromEnv returns a judge using the OpenAI compatible endpoint in
+// OPENAI_BASE_URL, or nil if GOLDEN_JUDGE_MODEL isn't set.
+func NewJudgeFromEnv() (*Judge, error) {
+
+	model := os.Getenv(JudgeModelEnv)
+
+	if model == "" {
+		return nil, nil
+	}
+
+	clientConfig := openai.DefaultConfig(os.Getenv("OPENAI_API_KEY"))
+
+	if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+
+	minScore := DefaultJudgeMinScore
+
+	if value := os.Getenv(JudgeMinScoreEnv); value != "" {
+
+		var err error
+
+		if minScore, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", JudgeMinScoreEnv, err)
+		}
+	}
+
+	return &Judge{
+		Client:   openai.NewClientWithConfig(clientConfig),
+		Model:    model,
+		MinScore: minScore,
+	}, nil
+}
+
+// Reply asks the model to answer [request], as the lambda would.
+func (j *Judge) Reply(request CompletionRequest) (string, error) {
+	return j.complete(chatMessages(request))
+}
+
+// Score rates [reply] to [request] against the transcript's criteria.
+func (j *Judge) Score(transcript *Transcript, request CompletionRequest, reply string) (*Verdict, error) {
+
+	var conversation strings.Builder
+
+	for _, message := range chatMessages(request) {
+		if message.Role != openai.ChatMessageRoleSystem {
+			_, _ = fmt.Fprintf(&conversation, "%s: %s\n", message.Role, message.Content)
+		}
+	}
+
+	criteria := "- " + strings.Join(transcript.Criteria, "\n- ")
+
+	completion, err := j.complete([]openai.ChatCompletionMessage{{
+		Role:    openai.ChatMessageRoleUser,
+		Content: fmt.Sprintf(JudgePrompt, criteria, conversation.String(), reply),
+	}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseVerdict(completion)
+}
+
+func (j *Judge) complete(messages []openai.ChatCompletionMessage) (string, error) {
+
+	resp, err := j.Client.CreateChatCompletion(
+		context.Background(),
+		openai.ChatCompletionRequest{
+			Model:    j.Model,
+			Messages: messages,
+		},
+	)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in completion")
+	}
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// ParseVerdict reads the judge's JSON response, which models like to wrap
+// in a markdown code block.
+func ParseVerdict(completion string) (*Verdict, error) {
+
+	completion = strings.ReplaceAll(completion, "```json", "")
+	completion = strings.ReplaceAll(completion, "```", "")
+
+	verdict := &Verdict{}
+
+	if err := json.Unmarshal([]byte(strings.TrimSpace(completion)), verdict); err != nil {
+		return nil, fmt.Errorf("error parsing verdict %q: %w", completion, err)
+	}
+
+	if verdict.Score < 1 || verdict.Score > 5 {
+		return nil, fmt.Errorf("verdict score %d is out of range", verdict.Score)
+	}
+
+	return verdict, nil
+}
@@ -0,0 +1,74 @@
+package golden
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVerdict(t *testing.T) {
+
+	verdict, err := ParseVerdict("```json\n{\"score\": 4, \"reasoning\": \"Warm and specific.\"}\n```")
+
+	require.NoError(t, err)
+	require.Equal(t, &Verdict{Score: 4, Reasoning: "Warm and specific."}, verdict)
+
+	_, err = ParseVerdict(`{"score": 9}`)
+	require.Error(t, err)
+
+	_, err = ParseVerdict("Great reply!")
+	require.Error(t, err)
+}
+
+func TestNewJudgeFromEnv_Disabled(t *testing.T) {
+
+	t.Setenv(JudgeModelEnv, "")
+
+	judge, err := NewJudgeFromEnv()
+
+	require.NoError(t, err)
+	require.Nil(t, judge)
+}
+
+func TestJudge_Score(t *testing.T) {
+
+	var prompt string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var request openai.ChatCompletionRequest
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		prompt = request.Messages[0].Content
+
+		_ = json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{Content: `{"score": 5, "reasoning": "Lovely."}`},
+			}},
+		})
+	}))
+
+	defer server.Close()
+
+	t.Setenv(JudgeModelEnv, "judge-model")
+	t.Setenv("OPENAI_BASE_URL", server.URL)
+
+	judge, err := NewJudgeFromEnv()
+	require.NoError(t, err)
+
+	transcript := &Transcript{Criteria: []string{"Is friendly"}}
+	request := CompletionRequest{Message: "How are you?", Prompt: "Be kind."}
+
+	verdict, err := judge.Score(transcript, request, "Great, thanks!")
+
+	require.NoError(t, err)
+	require.Equal(t, 5, verdict.Score)
+	require.True(t, strings.Contains(prompt, "- Is friendly"))
+	require.True(t, strings.Contains(prompt, "user: How are you?"))
+	require.False(t, strings.Contains(prompt, "Be kind."))
+}
@@ -0,0 +1,79 @@
+package golden
+
+import (
+	"testing"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+)
+
+// Builder runs a lambda's prompt building for [transcript], sending the
+// result to [svc] as the lambda would.
+type Builder func(transcript *Transcript, svc ai.CompletionServiceInterface) error
+
+// Replay runs [build] for every transcript in [dir] and compares what it
+// sent to the model with the transcript's snapshot. With a judge
+// configured, it also scores a fresh reply from the judge's model.
+func Replay(t *testing.T, dir string, build Builder) {
+
+	t.Helper()
+
+	transcripts, err := Load(dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	judge, err := NewJudgeFromEnv()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, transcript := range transcripts {
+
+		t.Run(transcript.Name, func(t *testing.T) {
+
+			svc := NewRecordedCompletionService(transcript.Completion)
+
+			if err := build(transcript, svc); err != nil {
+				t.Fatalf("error replaying %s: %s", transcript.Name, err)
+			}
+
+			if len(svc.Requests) != 1 {
+				t.Fatalf("expected one completion request, got %d", len(svc.Requests))
+			}
+
+			request := svc.Requests[0]
+			reply := svc.CleanCompletionText(transcript.Completion)
+
+			Assert(t, transcript.SnapshotPath(), Render(request, reply))
+
+			if judge != nil {
+				score(t, judge, transcript, request)
+			}
+		})
+	}
+}
+
+func score(t *testing.T, judge *Judge, transcript *Transcript, request CompletionRequest) {
+
+	t.Helper()
+
+	reply, err := judge.Reply(request)
+
+	if err != nil {
+		t.Fatalf("error getting a fresh reply: %s", err)
+	}
+
+	verdict, err := judge.Score(transcript, request, reply)
+
+	if err != nil {
+		t.Fatalf("error scoring reply: %s", err)
+	}
+
+	t.Logf("%s scored %d/5 for %q: %s", transcript.Name, verdict.Score, reply, verdict.Reasoning)
+
+	if verdict.Score < judge.MinScore {
+		t.Errorf("%s scored %d, below the minimum of %d", transcript.Name, verdict.Score, judge.MinScore)
+	}
+}
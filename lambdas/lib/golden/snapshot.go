@@ -0,0 +1,163 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+)
+
+// UpdateEnv rewrites snapshots instead of comparing against them when set.
+const UpdateEnv = "GOLDEN_UPDATE"
+
+// diffContext is how many unchanged lines to show around each change.
+const diffContext = 3
+
+// Render formats the conversation [request] would send to the model,
+// in order, followed by the [reply] that goes back to the user.
+func Render(request CompletionRequest, reply string) string {
+
+	var b strings.Builder
+
+	for _, message := range ai.ChatMessages(request.Message, request.Prompt, &request.Memories) {
+		writeSection(&b, message.Role, message.Content)
+	}
+
+	writeSection(&b, "reply", reply)
+
+	return b.String()
+}
+
+func writeSection(b *strings.Builder, title, content string) {
+
+	// Prompts are indented for readability in the source, which leaves
+	// trailing spaces that editors like to strip from snapshots
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+
+	_, _ = fmt.Fprintf(b, "=== %s ===\n%s\n\n", title, strings.Join(lines, "\n"))
+}
+
+// Assert compares [got] with the snapshot at [path], or rewrites the
+// snapshot when GOLDEN_UPDATE is set.
+func Assert(t testing.TB, path, got string) {
+
+	t.Helper()
+
+	if os.Getenv(UpdateEnv) != "" {
+
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatalf("error updating snapshot %s: %s", path, err)
+		}
+
+		return
+	}
+
+	want, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("error reading snapshot %s, run with %s=1 to create it: %s", path, UpdateEnv, err)
+	}
+
+	if string(want) != got {
+		t.Errorf("%s does not match, run with %s=1 to accept the change:\n%s",
+			path, UpdateEnv, Diff(string(want), got))
+	}
+}
+
+// Diff returns a line diff from [want] to [got], with removed lines
+// prefixed by "-" and added lines by "+".
+func Diff(want, got string) string {
+
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type line struct {
+		op   byte
+		text string
+	}
+
+	var lines []line
+
+	i, j := 0, 0
+
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, line{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, line{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, line{'+', b[j]})
+			j++
+		}
+	}
+
+	// Only show the changes and the lines around them
+	show := make([]bool, len(lines))
+
+	for k, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+
+		for c := max(0, k-diffContext); c <= min(len(lines)-1, k+diffContext); c++ {
+			show[c] = true
+		}
+	}
+
+	var (
+		out     strings.Builder
+		skipped bool
+	)
+
+	for k, l := range lines {
+
+		if !show[k] {
+			skipped = true
+
+			continue
+		}
+
+		if skipped {
+			out.WriteString("...\n")
+			skipped = false
+		}
+
+		_, _ = fmt.Fprintf(&out, "%c %s\n", l.op, l.text)
+	}
+
+	return out.String()
+}
+
+// chatMessages is the conversation the judge's model is asked to reply to.
+func chatMessages(request CompletionRequest) []openai.ChatCompletionMessage {
+	return ai.ChatMessages(request.Message, request.Prompt, &request.Memories)
+}
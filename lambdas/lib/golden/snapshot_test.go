@@ -0,0 +1,51 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+
+	want := "a\nb\nc\nd\n"
+	got := "a\nb\nC\nd\ne\n"
+
+	require.Equal(t, "  a\n  b\n- c\n+ C\n  d\n+ e\n  \n", Diff(want, got))
+}
+
+func TestDiff_Context(t *testing.T) {
+
+	want := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10"
+	got := "1\n2\n3\n4\n5\n6\n7\n8\n9\nten"
+
+	require.Equal(t, "...\n  7\n  8\n  9\n- 10\n+ ten\n", Diff(want, got))
+}
+
+func TestRender(t *testing.T) {
+
+	request := CompletionRequest{Message: "How are you?", Prompt: "  Be kind.  \n"}
+
+	require.Equal(t,
+		"=== system ===\nBe kind.\n\n=== user ===\nHow are you?\n\n=== reply ===\nGood!\n\n",
+		Render(request, "Good!"),
+	)
+}
+
+func TestAssert_Update(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "snapshot.golden")
+
+	t.Setenv(UpdateEnv, "1")
+	Assert(t, path, "snapshot")
+
+	data, err := os.ReadFile(path)
+
+	require.NoError(t, err)
+	require.Equal(t, "snapshot", string(data))
+
+	t.Setenv(UpdateEnv, "")
+	Assert(t, path, "snapshot")
+}
@@ -0,0 +1,179 @@
+// Package golden replays recorded conversations through the prompt
+// builders and compares what would be sent to the model with snapshots,
+// so prompt changes show up as a reviewable diff.
+//
+// Snapshots are rewritten with:
+//
+//	GOLDEN_UPDATE=1 go test ./send_sms ./nudge_sms
+//
+// Setting GOLDEN_JUDGE_MODEL also asks that model for a fresh reply to each
+// transcript and has it score the reply against the transcript's criteria.
+package golden
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+const (
+	SpeakerUser = "user"
+	SpeakerBot  = "bot"
+
+	transcriptExtension = ".json"
+	snapshotExtension   = ".golden"
+)
+
+// Turn is one message in a recorded conversation.
+type Turn struct {
+	From string    `json:"from"`
+	Body string    `json:"body"`
+	At   time.Time `json:"at"`
+}
+
+// Fact is something we have learned about the user.
+type Fact struct {
+	Body      string `json:"body"`
+	Reasoning string `json:"reasoning"`
+}
+
+// Transcript is a recorded conversation to replay.
+type Transcript struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Now         time.Time `json:"now"`
+	Firstname   string    `json:"firstname"`
+	Facts       []Fact    `json:"facts"`
+
+	// Recent and Older are the memories as the message repository
+	// returns them: the last few message pairs, newest first, and a
+	// random sample of older pairs.
+	Recent []Turn `json:"recent"`
+	Older  []Turn `json:"older"`
+
+	// Message is the text being replied to, and Completion the reply the
+	// model gave when the transcript was recorded.
+	Message    string `json:"message"`
+	Completion string `json:"completion"`
+
+	// Criteria describe a good reply, for the judge.
+	Criteria []string `json:"criteria"`
+
+	path string
+}
+
+// Load reads every transcript in [dir].
+func Load(dir string) ([]*Transcript, error) {
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+transcriptExtension))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	var transcripts []*Transcript
+
+	for _, path := range paths {
+
+		data, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		transcript := &Transcript{path: path}
+
+		if err = json.Unmarshal(data, transcript); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %w", path, err)
+		}
+
+		if transcript.Name == "" {
+			transcript.Name = strings.TrimSuffix(filepath.Base(path), transcriptExtension)
+		}
+
+		transcripts = append(transcripts, transcript)
+	}
+
+	if len(transcripts) == 0 {
+		return nil, fmt.Errorf("no transcripts found in %s", dir)
+	}
+
+	return transcripts, nil
+}
+
+// SnapshotPath is where the transcript's snapshot is kept, next to it.
+func (t *Transcript) SnapshotPath() string {
+	return strings.TrimSuffix(t.path, transcriptExtension) + snapshotExtension
+}
+
+// User is the person the conversation is with.
+func (t *Transcript) User() *models.User {
+
+	return &models.User{
+		ID:        2,
+		Firstname: t.Firstname,
+	}
+}
+
+// FactModels returns the transcript's facts about the user.
+func (t *Transcript) FactModels() []*models.Fact {
+
+	facts := make([]*models.Fact, 0, len(t.Facts))
+
+	for i, fact := range t.Facts {
+		facts = append(facts, &models.Fact{
+			ID:        int64(i + 1),
+			UserID:    t.User().ID,
+			Body:      fact.Body,
+			Reasoning: fact.Reasoning,
+		})
+	}
+
+	return facts
+}
+
+// RecentMessages returns the recent turns as messages.
+func (t *Transcript) RecentMessages() []models.Message {
+	return t.messages(t.Recent, 1)
+}
+
+// OlderMessages returns the older turns as messages.
+func (t *Transcript) OlderMessages() []models.Message {
+	return t.messages(t.Older, len(t.Recent)+1)
+}
+
+func (t *Transcript) messages(turns []Turn, firstID int) []models.Message {
+
+	user := t.User()
+	system := models.GetSystemUser()
+	messages := make([]models.Message, 0, len(turns))
+
+	for i, turn := range turns {
+
+		from, to := user, system
+
+		if turn.From == SpeakerBot {
+			from, to = system, user
+		}
+
+		messages = append(messages, models.Message{
+			ID:         int64(firstID + i),
+			FromUserID: from.ID,
+			ToUserID:   to.ID,
+			From:       *from,
+			To:         *to,
+			Body:       turn.Body,
+			CreatedAt:  turn.At,
+		})
+	}
+
+	return messages
+}
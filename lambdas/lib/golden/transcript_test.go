@@ -0,0 +1,58 @@
+package golden
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func TestLoad(t *testing.T) {
+
+	transcripts, err := Load("testdata")
+
+	require.NoError(t, err)
+	require.Len(t, transcripts, 1)
+
+	transcript := transcripts[0]
+
+	require.Equal(t, "greeting", transcript.Name)
+	require.Equal(t, filepath.Join("testdata", "greeting.golden"), transcript.SnapshotPath())
+
+	recent := transcript.RecentMessages()
+
+	require.Len(t, recent, 2)
+	require.Equal(t, models.GetSystemUser().ID, recent[0].FromUserID)
+	require.Equal(t, transcript.User().ID, recent[0].ToUserID)
+	require.Equal(t, transcript.User().ID, recent[1].FromUserID)
+
+	older := transcript.OlderMessages()
+
+	require.Len(t, older, 1)
+	require.Equal(t, int64(3), older[0].ID)
+}
+
+func TestLoad_Empty(t *testing.T) {
+
+	_, err := Load(t.TempDir())
+
+	require.Error(t, err)
+}
+
+func TestRecordedCompletionService(t *testing.T) {
+
+	svc := NewRecordedCompletionService("first")
+	memories := []models.Message{{Body: "memory"}}
+
+	completion, err := svc.GetCompletion("message", "prompt", &memories)
+
+	require.NoError(t, err)
+	require.Equal(t, "first", completion)
+	require.Equal(t, []CompletionRequest{{Message: "message", Prompt: "prompt", Memories: memories}}, svc.Requests)
+
+	_, err = svc.GetCompletion("again", "prompt", nil)
+
+	require.Error(t, err)
+}
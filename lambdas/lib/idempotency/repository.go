@@ -0,0 +1,101 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// ErrInProgress means the work has been claimed by someone else, who
+// hasn't finished it yet. Their claim may still expire, so the caller
+// should try again later rather than give up on the work.
+var ErrInProgress = errors.New("idempotency key is held by another claim")
+
+// Repository is a repository for managing IdempotencyKeys.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create inserts a new key into the database. Creating a key
+// that already exists returns a duplicate entry error.
+func (r *Repository) Create(key *models.IdempotencyKey) error {
+	return r.db.Create(key).Error
+}
+
+// Claim attempts to create the key, and reports whether this caller now
+// holds it. A duplicate key is not an error, it just means the work has
+// already been done by someone else. A pending key whose lease expired
+// before it was done is taken over, and one that is still leased returns
+// ErrInProgress.
+func (r *Repository) Claim(key *models.IdempotencyKey) (bool, error) {
+
+	err := r.Create(key)
+
+	if err == nil {
+		return true, nil
+	}
+
+	if !db.IsDuplicateEntryError(err) {
+		return false, err
+	}
+
+	retaken, err := r.Retake(key, time.Now().UTC())
+
+	if err != nil || retaken {
+		return retaken, err
+	}
+
+	var existing models.IdempotencyKey
+
+	if err = r.db.Where("idempotency_key = ?", key.Key).First(&existing).Error; err != nil {
+		// Released since we tried to take it over, so a retry will claim it
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, ErrInProgress
+		}
+
+		return false, err
+	}
+
+	if existing.Status == models.IdempotencyStatusPending {
+		return false, ErrInProgress
+	}
+
+	return false, nil
+}
+
+// Retake takes over a pending key whose lease expired before [now], with
+// the lease of [key], and reports whether it did.
+func (r *Repository) Retake(key *models.IdempotencyKey, now time.Time) (bool, error) {
+
+	result := r.db.Model(&models.IdempotencyKey{}).
+		Where("idempotency_key = ? AND status = ? AND expires_at < ?",
+			key.Key, models.IdempotencyStatusPending, now).
+		Update("expires_at", key.ExpiresAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+// Complete marks a key done, so the work it guards is never repeated.
+func (r *Repository) Complete(key string) error {
+	return r.db.Model(&models.IdempotencyKey{}).
+		Where("idempotency_key = ?", key).
+		Updates(map[string]interface{}{
+			"status":     models.IdempotencyStatusDone,
+			"expires_at": nil,
+		}).Error
+}
+
+// Release permanently deletes a key so the work it guards can be retried.
+func (r *Repository) Release(key string) error {
+	return r.db.Where("idempotency_key = ?", key).
+		Delete(&models.IdempotencyKey{}).Error
+}
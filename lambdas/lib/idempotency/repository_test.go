@@ -0,0 +1,157 @@
+package idempotency_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	mysql2 "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/idempotency"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func TestRepository_Claim(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	test.ExpectMockInsertIdempotencyKey(&mock)
+
+	claimed, err := repo.Claim(newKey("SM123"))
+
+	require.NoError(t, err)
+	assert.True(t, claimed, "The first claim should succeed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Claim_Duplicate(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	expectDuplicate(mock, "receive_sms:SM123", 0)
+	expectExisting(mock, "receive_sms:SM123", models.IdempotencyStatusDone)
+
+	claimed, err := repo.Claim(newKey("SM123"))
+
+	require.NoError(t, err, "A duplicate key is not an error")
+	assert.False(t, claimed, "A duplicate claim should not succeed")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Claim_InProgress(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	expectDuplicate(mock, "receive_sms:SM123", 0)
+	expectExisting(mock, "receive_sms:SM123", models.IdempotencyStatusPending)
+
+	claimed, err := repo.Claim(newKey("SM123"))
+
+	assert.ErrorIs(t, err, idempotency.ErrInProgress,
+		"Work someone else is still doing should be retried later")
+	assert.False(t, claimed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Claim_ExpiredLease(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	// The lambda holding the key timed out before finishing
+	expectDuplicate(mock, "receive_sms:SM123", 1)
+
+	claimed, err := repo.Claim(newKey("SM123"))
+
+	require.NoError(t, err)
+	assert.True(t, claimed, "A claim whose lease expired should be taken over")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepository_Complete(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:SM123")
+
+	require.NoError(t, repo.Complete("send_sms:SM123"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func newKey(reference string) *models.IdempotencyKey {
+	return models.NewIdempotencyKey(models.IdempotencyScopeReceiveSMS, reference).
+		Lease(time.Now().UTC().Add(idempotency.DefaultLease))
+}
+
+// expectDuplicate expects [key] to already exist, and [retaken] expired
+// leases on it to be taken over.
+func expectDuplicate(mock sqlmock.Sqlmock, key string, retaken int64) {
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `idempotency_keys`").
+		WithArgs(key, models.IdempotencyScopeReceiveSMS, models.IdempotencyStatusPending, sqlmock.AnyArg()).
+		WillReturnError(&mysql2.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `idempotency_keys` SET `expires_at`=\\? WHERE idempotency_key = \\? AND status = \\? AND expires_at < \\?").
+		WithArgs(sqlmock.AnyArg(), key, models.IdempotencyStatusPending, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, retaken))
+	mock.ExpectCommit()
+}
+
+func expectExisting(mock sqlmock.Sqlmock, key, status string) {
+
+	mock.ExpectQuery("SELECT \\* FROM `idempotency_keys` WHERE idempotency_key = \\?").
+		WithArgs(key, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idempotency_key", "scope", "status"}).
+			AddRow(1, key, models.IdempotencyScopeReceiveSMS, status))
+}
+
+func TestRepository_Claim_DatabaseError(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `idempotency_keys`").
+		WillReturnError(errors.New("db error"))
+	mock.ExpectRollback()
+
+	claimed, err := repo.Claim(models.NewIdempotencyKey(models.IdempotencyScopeSendSMS, "SM123"))
+
+	assert.Error(t, err)
+	assert.False(t, claimed)
+}
+
+func TestRepository_Release(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := idempotency.NewRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `idempotency_keys` WHERE idempotency_key = \\?").
+		WithArgs("send_sms:SM123").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	err = repo.Release("send_sms:SM123")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// DefaultLease is how long a claim is held before someone else may take
+// it over. It outlasts the 30 second timeout of the lambdas that claim
+// keys, so a claim is only taken over once its holder has stopped.
+const DefaultLease = 45 * time.Second
+
+// Service guards units of work so that retried webhooks and
+// redelivered queue messages are processed at most once.
+type Service struct {
+	repo *Repository
+
+	// Lease is how long a claim is held until it is completed.
+	Lease time.Duration
+}
+
+// NewService creates a new Service.
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo, Lease: DefaultLease}
+}
+
+// Claim reports whether [reference] has not been seen before within
+// [scope], and claims it if so. The claim lapses after the Lease unless it
+// is completed. Work that someone else is still doing returns ErrInProgress.
+func (s *Service) Claim(scope, reference string) (bool, error) {
+	key := models.NewIdempotencyKey(scope, reference).Lease(time.Now().UTC().Add(s.Lease))
+
+	return s.repo.Claim(key)
+}
+
+// Complete marks the work on [reference] within [scope] done, so it is
+// never claimed again.
+func (s *Service) Complete(scope, reference string) error {
+	return s.repo.Complete(models.NewIdempotencyKey(scope, reference).Key)
+}
+
+// Release gives up a claim on [reference] within [scope], typically
+// because the work failed and should be retried.
+func (s *Service) Release(scope, reference string) error {
+	return s.repo.Release(models.NewIdempotencyKey(scope, reference).Key)
+}
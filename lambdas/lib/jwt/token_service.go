@@ -4,6 +4,7 @@ import (
 	"crypto/rsa"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -16,6 +17,11 @@ import (
 
 const EnableAWSSessionDebug = false
 
+// ParameterStoreEndpointEnv overrides the Parameter Store endpoint. Version 1
+// of the AWS SDK doesn't read service specific endpoints from the
+// environment, so we honour the same variable the v2 SDK uses.
+const ParameterStoreEndpointEnv = "AWS_ENDPOINT_URL_SSM"
+
 const (
 	Issuer                       = "equilibria"
 	Audience                     = "equilibria"
@@ -93,7 +99,7 @@ func (t TokenService) Issue(user *models.User, expiration int, keyRotator KeyRot
 		return "", err
 	}
 
-	log.New("Re-Issuing JWT for %s...", user.PhoneNumber).Log()
+	log.New("Re-Issuing JWT for user %d...", user.ID).Log()
 
 	userJWT, err := t.Generate(user, expiration, privateKey)
 
@@ -151,6 +157,10 @@ func getAWSSessionConfig() *aws.Config {
 
 	cfg := aws.NewConfig()
 
+	if endpoint := os.Getenv(ParameterStoreEndpointEnv); endpoint != "" {
+		cfg.Endpoint = aws.String(endpoint)
+	}
+
 	if EnableAWSSessionDebug {
 		cfg.LogLevel = aws.LogLevel(
 
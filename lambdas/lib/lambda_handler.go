@@ -6,6 +6,7 @@ import (
 
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/conversation"
+	"github.com/kmesiab/equilibria/lambdas/lib/idempotency"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
 	"github.com/kmesiab/equilibria/lambdas/lib/message"
 	"github.com/kmesiab/equilibria/lambdas/lib/user"
@@ -16,6 +17,7 @@ type LambdaHandler struct {
 	UserService         *user.UserService
 	MessageService      *message.MessageService
 	ConversationService *conversation.ConversationService
+	IdempotencyService  *idempotency.Service
 }
 
 func (h *LambdaHandler) Init(db *gorm.DB) *LambdaHandler {
@@ -24,6 +26,7 @@ func (h *LambdaHandler) Init(db *gorm.DB) *LambdaHandler {
 	h.UserService = user.NewUserService(user.NewUserRepository(db))
 	h.MessageService = message.NewMessageService(message.NewMessageRepository(db))
 	h.ConversationService = conversation.NewConversationService(conversation.NewConversationRepository(db))
+	h.IdempotencyService = idempotency.NewService(idempotency.NewRepository(db))
 
 	return h
 
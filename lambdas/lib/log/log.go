@@ -1,16 +1,20 @@
 package log
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"sort"
 	"strconv"
 
 	"github.com/aws/aws-lambda-go/events"
 	api "github.com/twilio/twilio-go/rest/api/v2010"
 
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
@@ -21,10 +25,13 @@ import (
 // the Add() method. Additional types, like errors and
 // TwilioMessageInfo structs, can be added using the AddError()
 // and AddTwilioMessageInfo() methods, respectively.
+//
+// Entries are logged as leveled JSON with the PII in their
+// fields redacted or hashed; see Redactor. Entries logged
+// while handling a traced event include its trace_id.
 type Log struct {
 	Message string            `json:"message"`
 	Fields  map[string]string `json:"data,omitempty"`
-	Logger  *log.Logger       `json:"-"`
 }
 
 // New creates a new Log struct.
@@ -37,7 +44,6 @@ func New(format string, a ...interface{}) *Log {
 	return &Log{
 		Message: fmt.Sprintf(format, a...),
 		Fields:  make(map[string]string),
-		Logger:  log.Default(),
 	}
 }
 
@@ -127,9 +133,13 @@ func (r *Log) AddMessage(msg *models.Message) *Log {
 		Add("type", msg.MessageType.Name).
 		Add("from", msg.From.PhoneNumber).
 		Add("to", msg.To.PhoneNumber).
-		Add("reference_id", *msg.ReferenceID).
 		Add("payload", string(smsJSON))
 
+	// Web chat messages have no reference ID
+	if msg.ReferenceID != nil {
+		r.Add("reference_id", *msg.ReferenceID)
+	}
+
 	return r
 }
 
@@ -158,6 +168,8 @@ func (r *Log) AddTwilioMessageInfo(msg *models.TwilioMessageInfo) *Log {
 	return r
 }
 
+// Write returns the entry as JSON, without redacting it, for responses
+// to the caller.
 func (r *Log) Write() string {
 	s, err := json.Marshal(r)
 
@@ -180,9 +192,64 @@ func (r *Log) Respond(status int) (events.APIGatewayProxyResponse, error) {
 	}, nil
 }
 
+// Log writes the entry at the error level if an error was added,
+// and at the info level otherwise.
 func (r *Log) Log() {
-	logEntry := r.Write()
-	log.Println(logEntry)
+
+	if _, ok := r.Fields["error"]; ok {
+		r.Error()
+
+		return
+	}
+
+	r.emit(slog.LevelInfo)
+}
+
+func (r *Log) Debug() {
+	r.emit(slog.LevelDebug)
+}
+
+func (r *Log) Warn() {
+	r.emit(slog.LevelWarn)
+}
+
+func (r *Log) Error() {
+	r.emit(slog.LevelError)
+}
+
+func (r *Log) emit(level slog.Level) {
+
+	ctx := context.Background()
+	logger, redactor := current()
+
+	// Skip redacting entries that won't be written
+	if !logger.Enabled(ctx, level) {
+		return
+	}
+
+	fields := redactor.Redact(r.Fields)
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(keys))
+
+	for _, key := range keys {
+		attrs = append(attrs, slog.String(key, fields[key]))
+	}
+
+	entry := []slog.Attr{{Key: "data", Value: slog.GroupValue(attrs...)}}
+
+	// Correlate the entries for the event being handled
+	if traceID := tracing.TraceID(); traceID != "" {
+		entry = append([]slog.Attr{slog.String("trace_id", traceID)}, entry...)
+	}
+
+	logger.LogAttrs(ctx, level, r.Message, entry...)
 }
 
 func FormatBool(b bool) string {
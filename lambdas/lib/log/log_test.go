@@ -0,0 +1,160 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type entry struct {
+	TraceID string            `json:"trace_id"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Data    map[string]string `json:"data"`
+}
+
+func capture(t *testing.T, logLevel int, sampled bool) *bytes.Buffer {
+
+	var out bytes.Buffer
+
+	redactor := NewRedactor(&config.Config{LogHashKey: "secret", LogSampleRate: 0.5})
+	redactor.Random = func() float64 {
+		if sampled {
+			return 0
+		}
+
+		return 1
+	}
+
+	Configure(NewLogger(&config.Config{LogLevel: logLevel}, &out), redactor)
+	t.Cleanup(func() { Configure(NewLogger(nil, &bytes.Buffer{}), NewRedactor(nil)) })
+
+	return &out
+}
+
+func entries(t *testing.T, out *bytes.Buffer) []entry {
+
+	var logged []entry
+
+	decoder := json.NewDecoder(out)
+
+	for decoder.More() {
+		e := entry{}
+		require.NoError(t, decoder.Decode(&e))
+
+		logged = append(logged, e)
+	}
+
+	return logged
+}
+
+func TestLog_Redacts(t *testing.T) {
+
+	out := capture(t, 4, false)
+
+	New("Hello, %s", "World").
+		AddUser(&models.User{ID: 3, PhoneNumber: "+12065551234", Firstname: "Ada"}).
+		AddAPIProxyRequest(&events.APIGatewayProxyRequest{Headers: map[string]string{
+			"Authorization": "Bearer abc.def.ghi",
+			"Content-Type":  "application/json",
+		}}).
+		Add("prompt", "You are a helpful assistant").
+		Log()
+
+	logged := entries(t, out)
+	require.Len(t, logged, 1)
+
+	data := logged[0].Data
+
+	assert.Equal(t, "INFO", logged[0].Level)
+	assert.Equal(t, "Hello, World", logged[0].Message)
+	assert.Equal(t, "3", data["user_id"])
+	assert.Regexp(t, "^sha256:[0-9a-f]{16}$", data["phone_number"])
+	assert.Equal(t, RedactedValue, data["firstname"])
+	assert.Equal(t, "", data["email"], "Empty values aren't hashed")
+	assert.Equal(t, RedactedValue, data["Authorization"], "Headers are matched case-insensitively")
+	assert.Equal(t, "application/json", data["Content-Type"])
+	assert.Equal(t, "[sampled out, 27 chars]", data["prompt"])
+}
+
+func TestLog_Levels(t *testing.T) {
+
+	out := capture(t, 3, false)
+
+	New("Debugging").Debug()
+	New("Hello").Log()
+	New("Careful").Warn()
+	New("Failed").AddError(errors.New("boom")).Log()
+
+	logged := entries(t, out)
+	require.Len(t, logged, 2, "Only warnings and errors are logged at LOG_LEVEL 3")
+
+	assert.Equal(t, "WARN", logged[0].Level)
+	assert.Equal(t, "ERROR", logged[1].Level, "Entries with an error are logged as errors")
+	assert.Equal(t, "boom", logged[1].Data["error"])
+}
+
+func TestLog_Sampled(t *testing.T) {
+
+	out := capture(t, 4, true)
+
+	New("Prompt").Add("prompt", "You are a helpful assistant").Log()
+
+	assert.Equal(t, "You are a helpful assistant", entries(t, out)[0].Data["prompt"])
+}
+
+func TestLog_Traced(t *testing.T) {
+
+	out := capture(t, 4, false)
+
+	require.NoError(t, tracing.Init(&config.Config{TraceExporter: tracing.ExporterNone}, "test"))
+
+	_, span := tracing.Start("send_sms", propagation.MapCarrier{
+		tracing.TraceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	})
+	defer span.End()
+
+	New("Replying").Log()
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", entries(t, out)[0].TraceID)
+}
+
+func TestLog_Write(t *testing.T) {
+
+	s := New("Token refreshed").Add("token", "abc.def.ghi").Write()
+
+	assert.JSONEq(t, `{"message": "Token refreshed", "data": {"token": "abc.def.ghi"}}`, s,
+		"Responses to the caller aren't redacted")
+}
+
+func TestRedactor_Hash(t *testing.T) {
+
+	redactor := NewRedactor(&config.Config{LogHashKey: "secret"})
+
+	assert.Equal(t, redactor.Hash("+12065551234"), redactor.Hash("+12065551234"))
+	assert.NotEqual(t, redactor.Hash("+12065551234"), redactor.Hash("+12065550000"))
+	assert.NotEqual(t, redactor.Hash("+12065551234"),
+		NewRedactor(&config.Config{LogHashKey: "other"}).Hash("+12065551234"),
+		"The hash depends on the key")
+}
+
+func TestLevel(t *testing.T) {
+
+	assert.Equal(t, slog.LevelInfo, Level(0))
+	assert.Equal(t, LevelSilent, Level(1))
+	assert.Equal(t, slog.LevelError, Level(2))
+	assert.Equal(t, slog.LevelWarn, Level(3))
+	assert.Equal(t, slog.LevelInfo, Level(4))
+	assert.Equal(t, slog.LevelDebug, Level(100))
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+// LevelSilent is above every level, for LOG_LEVEL 1
+const LevelSilent = slog.Level(12)
+
+var (
+	setup    sync.Once
+	logger   *slog.Logger
+	redactor *Redactor
+)
+
+// NewLogger returns a JSON logger writing to [w] at the configured level.
+// The entry's text is logged as "message", like the responses.
+func NewLogger(cfg *config.Config, w io.Writer) *slog.Logger {
+
+	level := slog.LevelInfo
+
+	if cfg != nil {
+		level = Level(cfg.LogLevel)
+	}
+
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.MessageKey {
+				a.Key = "message"
+			}
+
+			return a
+		},
+	}))
+}
+
+// Level maps LOG_LEVEL, which also sets GORM's log level (1 silent,
+// 2 error, 3 warn, 4 info), onto slog's. Anything above 4 logs debug
+// entries too, and 0 (unset) logs info.
+func Level(logLevel int) slog.Level {
+
+	switch logLevel {
+	case 0, 4:
+		return slog.LevelInfo
+	case 1:
+		return LevelSilent
+	case 2:
+		return slog.LevelError
+	case 3:
+		return slog.LevelWarn
+	}
+
+	if logLevel < 0 {
+		return slog.LevelInfo
+	}
+
+	return slog.LevelDebug
+}
+
+// Configure replaces the logger and redactor that entries are written
+// with. Until it's called, they're set up from the config on first use.
+func Configure(l *slog.Logger, r *Redactor) {
+
+	setup.Do(func() {})
+
+	logger = l
+	redactor = r
+}
+
+func current() (*slog.Logger, *Redactor) {
+
+	setup.Do(func() {
+		cfg := config.Get()
+
+		logger = NewLogger(cfg, os.Stderr)
+		redactor = NewRedactor(cfg)
+	})
+
+	return logger, redactor
+}
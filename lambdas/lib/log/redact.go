@@ -0,0 +1,142 @@
+package log
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+// Policy is what happens to a field's value before it's logged.
+type Policy int
+
+const (
+	// PolicyKeep logs the value as is
+	PolicyKeep Policy = iota
+
+	// PolicyRedact replaces the value with RedactedValue
+	PolicyRedact
+
+	// PolicyHash replaces the value with a keyed hash, so entries for the
+	// same phone number or email can still be matched up
+	PolicyHash
+
+	// PolicySample logs the value in only a fraction of entries, for
+	// verbose fields like prompts
+	PolicySample
+
+	RedactedValue = "[redacted]"
+
+	// hashLength is how many hex characters of the hash are kept
+	hashLength = 16
+)
+
+// DefaultPolicies are the policies for fields holding PII, secrets or
+// large payloads, keyed by the lowercase field name. Header and Twilio
+// parameter names are matched the same way.
+var DefaultPolicies = map[string]Policy{
+	"phone_number": PolicyHash,
+	"from":         PolicyHash,
+	"to":           PolicyHash,
+	"email":        PolicyHash,
+
+	"firstname":          PolicyRedact,
+	"lastname":           PolicyRedact,
+	"body":               PolicyRedact,
+	"message":            PolicyRedact,
+	"payload":            PolicyRedact,
+	"token":              PolicyRedact,
+	"authorization":      PolicyRedact,
+	"cookie":             PolicyRedact,
+	"twilio_auth_token":  PolicyRedact,
+	"twilio_signature":   PolicyRedact,
+	"x-twilio-signature": PolicyRedact,
+
+	"prompt":           PolicySample,
+	"completion":       PolicySample,
+	"response_content": PolicySample,
+	"memory_dump":      PolicySample,
+}
+
+// Redactor applies the policies to the fields of an entry.
+type Redactor struct {
+	Policies map[string]Policy
+	HashKey  []byte
+
+	// SampleRate is the fraction of entries, from 0 to 1, that keep
+	// their sampled fields
+	SampleRate float64
+	Random     func() float64
+}
+
+// NewRedactor returns a Redactor with the DefaultPolicies, hashing with
+// the configured key and sampling at the configured rate.
+func NewRedactor(cfg *config.Config) *Redactor {
+
+	redactor := &Redactor{
+		Policies: DefaultPolicies,
+		Random:   rand.Float64,
+	}
+
+	if cfg == nil {
+		return redactor
+	}
+
+	redactor.HashKey = []byte(cfg.LogHashKey)
+	redactor.SampleRate = cfg.LogSampleRate
+
+	return redactor
+}
+
+// Redact returns a copy of [fields] with the policies applied. Whether
+// sampled fields are kept is decided once for the whole entry.
+func (r *Redactor) Redact(fields map[string]string) map[string]string {
+
+	redacted := make(map[string]string, len(fields))
+	sampled := r.Random() < r.SampleRate
+
+	for key, value := range fields {
+
+		switch r.Policies[strings.ToLower(key)] {
+		case PolicyRedact:
+			value = r.redact(value)
+		case PolicyHash:
+			value = r.Hash(value)
+		case PolicySample:
+			if !sampled {
+				value = fmt.Sprintf("[sampled out, %d chars]", len(value))
+			}
+		}
+
+		redacted[key] = value
+	}
+
+	return redacted
+}
+
+// Hash returns a short keyed SHA-256 of [value]. Empty values are left
+// empty, so a missing email doesn't look like a real one.
+func (r *Redactor) Hash(value string) string {
+
+	if value == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, r.HashKey)
+	mac.Write([]byte(value))
+
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+func (r *Redactor) redact(value string) string {
+
+	if value == "" {
+		return ""
+	}
+
+	return RedactedValue
+}
@@ -0,0 +1,49 @@
+package media
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository is a repository for managing MessageMedia.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Create inserts new media into the database.
+func (r *Repository) Create(media *models.MessageMedia) error {
+	return r.db.Create(media).Error
+}
+
+// FindByMessageIDs retrieves the media attached to any of the messages,
+// in the order it was received.
+func (r *Repository) FindByMessageIDs(messageIDs []int64) ([]models.MessageMedia, error) {
+	var media []models.MessageMedia
+
+	if len(messageIDs) == 0 {
+		return media, nil
+	}
+
+	err := r.db.Where("message_id IN ?", messageIDs).
+		Order("id ASC").
+		Find(&media).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return media, nil
+}
+
+// UpdateDescription saves the description of the media.
+func (r *Repository) UpdateDescription(media *models.MessageMedia) error {
+	return r.db.Model(media).
+		Select("description").
+		Updates(media).Error
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/transcription"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// maxMediaBytes caps the size of the media we'll download to describe
+// or transcribe. Twilio limits MMS attachments to 5MB.
+const maxMediaBytes = 5 * 1024 * 1024
+
+// ImageDescriber describes the image at a URL in plain text.
+type ImageDescriber interface {
+	DescribeImage(imageURL string) (string, error)
+}
+
+// Service stores media attached to messages, and describes images and
+// transcribes voice memos so they can be included in the conversation
+// with the model.
+type Service struct {
+	repo *Repository
+
+	// Twilio protects media URLs with the account's credentials
+	accountSID string
+	authToken  string
+
+	client *http.Client
+}
+
+// NewService creates a new Service. [accountSID] and [authToken] are used
+// to download media from the provider.
+func NewService(repo *Repository, accountSID, authToken string) *Service {
+	return &Service{
+		repo:       repo,
+		accountSID: accountSID,
+		authToken:  authToken,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// FindByMessageIDs retrieves the media attached to any of the messages.
+func (s *Service) FindByMessageIDs(messageIDs []int64) ([]models.MessageMedia, error) {
+	return s.repo.FindByMessageIDs(messageIDs)
+}
+
+// Describe generates and saves a description for an image. Media that is
+// not an image, or has already been described, is left alone.
+func (s *Service) Describe(media *models.MessageMedia, describer ImageDescriber) error {
+
+	if !media.IsImage() || media.Description != nil {
+		return nil
+	}
+
+	dataURL, err := s.Download(media)
+
+	if err != nil {
+		return err
+	}
+
+	description, err := describer.DescribeImage(dataURL)
+
+	if err != nil {
+		return err
+	}
+
+	media.Description = &description
+
+	return s.repo.UpdateDescription(media)
+}
+
+// Transcribe converts a voice memo into text, and saves the transcript as
+// the media's description.
+func (s *Service) Transcribe(media *models.MessageMedia, transcriber transcription.Transcriber) (string, error) {
+
+	if !media.IsAudio() {
+		return "", fmt.Errorf("media is not audio: %s", media.ContentType)
+	}
+
+	audio, contentType, err := s.Fetch(media)
+
+	if err != nil {
+		return "", err
+	}
+
+	transcript, err := transcriber.Transcribe(audio, contentType)
+
+	if err != nil {
+		return "", err
+	}
+
+	media.Description = &transcript
+
+	return transcript, s.repo.UpdateDescription(media)
+}
+
+// Download fetches the media and returns it as a data URL, so it can be
+// passed to a model that can't reach the provider's protected URL.
+func (s *Service) Download(media *models.MessageMedia) (string, error) {
+
+	data, contentType, err := s.Fetch(media)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data)), nil
+}
+
+// Fetch downloads the media using the provider's credentials, and returns
+// its contents and content type.
+func (s *Service) Fetch(media *models.MessageMedia) ([]byte, string, error) {
+
+	req, err := http.NewRequest(http.MethodGet, media.URL, nil)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.SetBasicAuth(s.accountSID, s.authToken)
+
+	resp, err := s.client.Do(req)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("error downloading media: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxMediaBytes+1))
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(data) > maxMediaBytes {
+		return nil, "", fmt.Errorf("media is larger than %d bytes", maxMediaBytes)
+	}
+
+	contentType := media.ContentType
+
+	if contentType == "" {
+		contentType = resp.Header.Get("Content-Type")
+	}
+
+	return data, contentType, nil
+}
+
+// PromptContext summarizes the media for the model, so it knows what the
+// user sent along with their text. It returns an empty string if there is
+// nothing to describe.
+func PromptContext(media []models.MessageMedia) string {
+
+	var lines []string
+
+	for _, m := range media {
+		switch {
+		case m.IsAudio() && m.Description != nil:
+			// The transcript is already the body of the message
+			continue
+		case m.IsAudio():
+			lines = append(lines, "[The user sent a voice memo that could not be transcribed]")
+		case m.IsImage() && m.Description != nil:
+			lines = append(lines, fmt.Sprintf("[The user sent a photo: %s]", *m.Description))
+		case m.IsImage():
+			lines = append(lines, "[The user sent a photo that could not be viewed]")
+		default:
+			lines = append(lines, fmt.Sprintf("[The user sent an attachment of type %s]", m.ContentType))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
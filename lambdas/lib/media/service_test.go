@@ -0,0 +1,163 @@
+package media_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/media"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type fakeDescriber struct {
+	imageURL string
+}
+
+func (f *fakeDescriber) DescribeImage(imageURL string) (string, error) {
+	f.imageURL = imageURL
+
+	return "A dog asleep on a couch.", nil
+}
+
+func newMediaServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sid, token, ok := r.BasicAuth()
+
+		if !ok || sid != "AC123" || token != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		_, err := w.Write([]byte("jpeg"))
+		require.NoError(t, err)
+	}))
+}
+
+func TestService_Describe(t *testing.T) {
+	server := newMediaServer(t)
+	defer server.Close()
+
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := media.NewService(media.NewRepository(db), "AC123", "secret")
+	describer := &fakeDescriber{}
+
+	m := &models.MessageMedia{ID: 4, MessageID: 1, URL: server.URL, ContentType: "image/jpeg"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `message_media` SET `description`").
+		WithArgs("A dog asleep on a couch.", int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = svc.Describe(m, describer)
+
+	require.NoError(t, err)
+	assert.Equal(t, "data:image/jpeg;base64,anBlZw==", describer.imageURL)
+	assert.Equal(t, "A dog asleep on a couch.", *m.Description)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Describe_NotAnImage(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := media.NewService(media.NewRepository(db), "AC123", "secret")
+	describer := &fakeDescriber{}
+
+	err = svc.Describe(&models.MessageMedia{ContentType: "audio/amr"}, describer)
+
+	require.NoError(t, err)
+	assert.Empty(t, describer.imageURL, "Only images should be described")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Download_Unauthorized(t *testing.T) {
+	server := newMediaServer(t)
+	defer server.Close()
+
+	db, _, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := media.NewService(media.NewRepository(db), "AC123", "wrong")
+
+	_, err = svc.Download(&models.MessageMedia{URL: server.URL, ContentType: "image/jpeg"})
+
+	assert.Error(t, err)
+}
+
+func TestPromptContext(t *testing.T) {
+	description := "A sunset over the water."
+
+	transcript := "I had a rough day."
+
+	context := media.PromptContext([]models.MessageMedia{
+		{ContentType: "image/png", Description: &description},
+		{ContentType: "audio/mpeg", Description: &transcript},
+		{ContentType: "image/jpeg"},
+		{ContentType: "audio/amr"},
+		{ContentType: "video/mp4"},
+	})
+
+	assert.Equal(t, "[The user sent a photo: A sunset over the water.]\n"+
+		"[The user sent a photo that could not be viewed]\n"+
+		"[The user sent a voice memo that could not be transcribed]\n"+
+		"[The user sent an attachment of type video/mp4]", context)
+
+	assert.Empty(t, media.PromptContext(nil))
+}
+
+type fakeTranscriber struct {
+	contentType string
+}
+
+func (f *fakeTranscriber) Transcribe(_ []byte, contentType string) (string, error) {
+	f.contentType = contentType
+
+	return "I just needed to hear myself say it.", nil
+}
+
+func TestService_Transcribe(t *testing.T) {
+	server := newMediaServer(t)
+	defer server.Close()
+
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := media.NewService(media.NewRepository(db), "AC123", "secret")
+	transcriber := &fakeTranscriber{}
+
+	m := &models.MessageMedia{ID: 4, URL: server.URL, ContentType: "audio/mpeg"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `message_media` SET `description`").
+		WithArgs("I just needed to hear myself say it.", int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	transcript, err := svc.Transcribe(m, transcriber)
+
+	require.NoError(t, err)
+	assert.Equal(t, "I just needed to hear myself say it.", transcript)
+	assert.Equal(t, "audio/mpeg", transcriber.contentType)
+	assert.Equal(t, transcript, *m.Description)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Transcribe_NotAudio(t *testing.T) {
+	db, _, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := media.NewService(media.NewRepository(db), "AC123", "secret")
+
+	_, err = svc.Transcribe(&models.MessageMedia{ContentType: "image/png"}, &fakeTranscriber{})
+
+	assert.Error(t, err)
+}
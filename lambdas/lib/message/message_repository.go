@@ -1,6 +1,8 @@
 package message
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/kmesiab/equilibria/lambdas/models"
@@ -75,6 +77,41 @@ func (r *Repository) GetLastNMessagePairs(user *models.User, limit int) (*[]mode
 	return &messages, nil
 }
 
+// FindUnansweredInboundSince finds the messages a user has sent since [since]
+// that no reply has answered yet, oldest first.
+func (r *Repository) FindUnansweredInboundSince(user *models.User, since time.Time) (*[]models.Message, error) {
+	var messages []models.Message
+
+	err := r.DB.Raw(`
+		SELECT * FROM messages
+		WHERE from_user_id = ?
+		AND received_at >= ?
+		AND replied_by_message_id IS NULL
+		AND deleted_at IS NULL
+		ORDER BY received_at ASC, id ASC
+	`, user.ID, since.UTC()).Scan(&messages).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &messages, nil
+}
+
+// MarkReplied records that the message [replyID] answered the inbound
+// messages [ids]. Messages already answered by another reply keep it.
+func (r *Repository) MarkReplied(ids []int64, replyID int64) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return r.DB.Model(&models.Message{}).
+		Where("id IN ? AND replied_by_message_id IS NULL", ids).
+		Update("replied_by_message_id", replyID).
+		Error
+}
+
 // FindByUser finds a Message by its ID.
 func (r *Repository) FindByUser(user *models.User) (*[]models.Message, error) {
 	var messages []models.Message
@@ -119,6 +156,25 @@ func (r *Repository) UpdateStatus(message *models.Message) error {
 		Error
 }
 
+// UpdateTranscript saves the body of a message with the transcript of its
+// voice memos.
+func (r *Repository) UpdateTranscript(message *models.Message) error {
+
+	return r.DB.Model(&message).
+		Select("body", "transcribed").
+		Updates(message).
+		Error
+}
+
+// UpdateNumSegments records how many segments the provider billed the message as.
+func (r *Repository) UpdateNumSegments(message *models.Message) error {
+
+	return r.DB.Model(&message).
+		Select("num_segments").
+		Updates(message).
+		Error
+}
+
 // Delete removes a Message from the database.
 func (r *Repository) Delete(id int64) error {
 	return r.DB.Delete(&models.Message{}, id).Error
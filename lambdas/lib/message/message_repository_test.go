@@ -2,6 +2,7 @@ package message_test
 
 import (
 	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -180,6 +181,7 @@ func TestMessageRepository_Create(t *testing.T) {
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
 		).WillReturnResult(test.GenerateMockLastAffectedRow())
 	mock.ExpectCommit()
 
@@ -235,3 +237,88 @@ func TestMessageRepository_DeleteIsSoftDelete(t *testing.T) {
 	err = repo.Update(msg)
 	assert.NoError(t, err)
 }
+
+func TestMessageRepository_FindUnansweredInboundSince(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := message.NewMessageRepository(db)
+	since := time.Now().Add(-time.Hour)
+
+	mock.ExpectQuery("SELECT \\* FROM messages WHERE from_user_id = \\? AND received_at >= \\? AND replied_by_message_id IS NULL").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockMessageRepositoryMessages())
+
+	messages, err := repo.FindUnansweredInboundSince(&models.User{ID: 1}, since)
+
+	require.NoError(t, err)
+	require.Len(t, *messages, 1)
+	assert.Equal(t, test.DefaultTestMessage, (*messages)[0].Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepository_MarkReplied(t *testing.T) {
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := message.NewMessageRepository(db)
+
+	// Messages another reply already answered are left alone
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `replied_by_message_id`=\\?,`updated_at`=\\? WHERE \\(id IN \\(\\?,\\?\\) AND replied_by_message_id IS NULL\\)").
+		WithArgs(9, sqlmock.AnyArg(), 7, 8).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.MarkReplied([]int64{7, 8}, 9))
+
+	// Nothing to mark
+	require.NoError(t, repo.MarkReplied(nil, 9))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMessageRepository_LongBodyRoundTrip(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	repo := message.NewMessageRepository(db)
+
+	// Well past the old VARCHAR(255), and sent as UCS-2
+	body := strings.Repeat("It sounds like today was a lot to carry. ", 40) + "💙"
+	segments, segmentEncoding := 27, "UCS-2"
+
+	msg := &models.Message{
+		ConversationID:    int64(1),
+		FromUserID:        int64(1),
+		ToUserID:          int64(2),
+		Body:              body,
+		MessageTypeID:     1,
+		MessageStatusID:   1,
+		EstimatedSegments: &segments,
+		SegmentEncoding:   &segmentEncoding,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `messages` .*`estimated_segments`,`segment_encoding`").
+		WithArgs(
+			sqlmock.AnyArg(), int64(1), int64(1), int64(2), body, false, int64(1), int64(1),
+			segments, segmentEncoding,
+		).WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	require.NoError(t, repo.Create(msg))
+
+	mock.ExpectQuery(test.MessageSelectQuery).WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "body", "estimated_segments", "segment_encoding"}).
+			AddRow(1, body, segments, segmentEncoding))
+
+	found, err := repo.FindByID(1)
+	require.NoError(t, err)
+
+	assert.Equal(t, body, found.Body)
+	assert.Equal(t, segments, *found.EstimatedSegments)
+	assert.Equal(t, segmentEncoding, *found.SegmentEncoding)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
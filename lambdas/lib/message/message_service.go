@@ -1,6 +1,8 @@
 package message
 
 import (
+	"time"
+
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
@@ -39,6 +41,19 @@ func (service *MessageService) UpdateStatus(message *models.Message) error {
 	return service.repo.UpdateStatus(message)
 }
 
+// UpdateNumSegments updates the number of segments a message was billed as.
+func (service *MessageService) UpdateNumSegments(message *models.Message) error {
+
+	return service.repo.UpdateNumSegments(message)
+}
+
+// UpdateTranscript saves the body of a message with the transcript of its
+// voice memos.
+func (service *MessageService) UpdateTranscript(message *models.Message) error {
+
+	return service.repo.UpdateTranscript(message)
+}
+
 // DeleteMessage deletes a message.
 func (service *MessageService) DeleteMessage(id int64) error {
 
@@ -55,6 +70,12 @@ func (service *MessageService) FindByUser(user *models.User) (*[]models.Message,
 	return service.repo.FindByUser(user)
 }
 
+// GetLastNMessagePairs returns the [limit] most recent messages to and from a user.
+func (service *MessageService) GetLastNMessagePairs(user *models.User, limit int) (*[]models.Message, error) {
+
+	return service.repo.GetLastNMessagePairs(user, limit)
+}
+
 func (service *MessageService) FindByReferenceID(refID string) (*models.Message, error) {
 
 	return service.repo.FindByReferenceID(refID)
@@ -71,3 +92,30 @@ func (service *MessageService) FindConversationIDByReferenceID(refID string) (in
 	return msg.ConversationID, err
 
 }
+
+// MarkReplied records that [reply] answered the inbound messages [ids], so
+// they are no longer part of a pending turn.
+func (service *MessageService) MarkReplied(ids []int64, reply *models.Message) error {
+
+	return service.repo.MarkReplied(ids, reply.ID)
+}
+
+// GetPendingTurn gathers the burst of unanswered inbound messages that [msg]
+// belongs to. Messages received within [window] of each other are coalesced
+// into a single Turn.
+func (service *MessageService) GetPendingTurn(user *models.User, msg *models.Message, window time.Duration) (*Turn, error) {
+
+	since := time.Now().Add(-turnLookback)
+
+	if msg.ReceivedAt != nil {
+		since = msg.ReceivedAt.Add(-turnLookback)
+	}
+
+	messages, err := service.repo.FindUnansweredInboundSince(user, since)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTurn(*messages, window), nil
+}
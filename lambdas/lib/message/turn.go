@@ -0,0 +1,89 @@
+package message
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// turnLookback bounds how far back we search for unanswered messages
+// when assembling a turn.
+const turnLookback = 24 * time.Hour
+
+// Turn is a burst of inbound messages a user sent in quick succession,
+// which should be answered with a single reply. People often text in
+// bursts ("hey" / "rough day" / "can we talk"), and replying to each
+// one separately makes for a confusing conversation.
+type Turn struct {
+	Messages []models.Message
+}
+
+// NewTurn builds a Turn from a user's unanswered inbound messages, ordered
+// oldest first. Walking backwards from the most recent message, any message
+// received within [window] of the one after it belongs to the same burst.
+func NewTurn(messages []models.Message, window time.Duration) *Turn {
+
+	if len(messages) == 0 {
+		return &Turn{}
+	}
+
+	start := len(messages) - 1
+
+	for start > 0 {
+		previous, current := messages[start-1].ReceivedAt, messages[start].ReceivedAt
+
+		if previous == nil || current == nil || current.Sub(*previous) > window {
+			break
+		}
+
+		start--
+	}
+
+	return &Turn{Messages: messages[start:]}
+}
+
+// Latest returns the most recent message in the turn, or nil if the
+// turn is empty.
+func (t *Turn) Latest() *models.Message {
+
+	if len(t.Messages) == 0 {
+		return nil
+	}
+
+	return &t.Messages[len(t.Messages)-1]
+}
+
+// IsLatest reports whether msg is the most recent message of the turn.
+// Only the latest message of a burst should produce a reply; earlier
+// messages are folded into it.
+func (t *Turn) IsLatest(msg *models.Message) bool {
+
+	latest := t.Latest()
+
+	return latest != nil && latest.ID == msg.ID
+}
+
+// IDs returns the IDs of every message in the turn.
+func (t *Turn) IDs() []int64 {
+
+	ids := make([]int64, 0, len(t.Messages))
+
+	for _, m := range t.Messages {
+		ids = append(ids, m.ID)
+	}
+
+	return ids
+}
+
+// Body joins the bodies of every message in the turn, one per line.
+func (t *Turn) Body() string {
+
+	bodies := make([]string, 0, len(t.Messages))
+
+	for _, m := range t.Messages {
+		bodies = append(bodies, m.Body)
+	}
+
+	return strings.Join(bodies, "\n")
+}
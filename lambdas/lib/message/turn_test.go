@@ -0,0 +1,66 @@
+package message_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/message"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+func newInboundMessage(id int64, body string, receivedAt time.Time) models.Message {
+	return models.Message{
+		ID:         id,
+		FromUserID: 2,
+		ToUserID:   models.GetSystemUser().ID,
+		Body:       body,
+		ReceivedAt: &receivedAt,
+	}
+}
+
+func TestNewTurn_CoalescesBurst(t *testing.T) {
+
+	now := time.Now()
+
+	messages := []models.Message{
+		newInboundMessage(1, "hey", now.Add(-20*time.Second)),
+		newInboundMessage(2, "rough day", now.Add(-10*time.Second)),
+		newInboundMessage(3, "can we talk", now),
+	}
+
+	turn := message.NewTurn(messages, 15*time.Second)
+
+	require.Len(t, turn.Messages, 3)
+	assert.Equal(t, "hey\nrough day\ncan we talk", turn.Body())
+	assert.True(t, turn.IsLatest(&messages[2]))
+	assert.False(t, turn.IsLatest(&messages[0]))
+}
+
+func TestNewTurn_SplitsOnGap(t *testing.T) {
+
+	now := time.Now()
+
+	messages := []models.Message{
+		newInboundMessage(1, "from this morning", now.Add(-2*time.Hour)),
+		newInboundMessage(2, "hey", now.Add(-5*time.Second)),
+		newInboundMessage(3, "you there?", now),
+	}
+
+	turn := message.NewTurn(messages, 30*time.Second)
+
+	require.Len(t, turn.Messages, 2)
+	assert.Equal(t, "hey\nyou there?", turn.Body())
+	assert.Equal(t, int64(3), turn.Latest().ID)
+}
+
+func TestNewTurn_Empty(t *testing.T) {
+
+	turn := message.NewTurn(nil, 30*time.Second)
+
+	assert.Nil(t, turn.Latest())
+	assert.False(t, turn.IsLatest(&models.Message{ID: 1}))
+	assert.Equal(t, "", turn.Body())
+}
@@ -0,0 +1,223 @@
+package messaging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FakeOTPCode is the only passcode the fake provider approves.
+const FakeOTPCode = "123456"
+
+// FakeMessage is a message the fake provider was asked to send.
+type FakeMessage struct {
+	ReferenceID string    `json:"reference_id"`
+	From        string    `json:"from"`
+	To          string    `json:"to"`
+	Body        string    `json:"body"`
+	Status      string    `json:"status"`
+	SentAt      time.Time `json:"sent_at"`
+}
+
+// StatusCallback receives simulated status callbacks, in the same form
+// Twilio posts them to the status webhook.
+type StatusCallback func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// FakeProvider records messages instead of sending them, so the lambdas
+// can run locally and in tests without a network. When OutboxPath is set,
+// each message is also appended to it as a line of JSON.
+type FakeProvider struct {
+	OutboxPath string
+
+	// Callback, if set, receives a status callback each time a message
+	// is delivered. Messages stay queued until Deliver is called, since
+	// the sender stores the reference ID only after Send returns.
+	Callback StatusCallback
+
+	// Err, if set, is returned by Send instead of sending the message,
+	// as if the provider were down.
+	Err error
+
+	mu   sync.Mutex
+	sent []FakeMessage
+}
+
+func NewFakeProvider(outboxPath string) *FakeProvider {
+	return &FakeProvider{OutboxPath: outboxPath}
+}
+
+func (p *FakeProvider) Send(from, to, body string) (*SendResult, error) {
+
+	if p.Err != nil {
+		return nil, p.Err
+	}
+
+	p.mu.Lock()
+
+	msg := FakeMessage{
+		ReferenceID: newReferenceID(),
+		From:        from,
+		To:          to,
+		Body:        body,
+		Status:      "queued",
+		SentAt:      time.Now(),
+	}
+
+	p.sent = append(p.sent, msg)
+
+	p.mu.Unlock()
+
+	if err := p.writeOutbox(msg); err != nil {
+		return nil, err
+	}
+
+	return &SendResult{ReferenceID: msg.ReferenceID, Status: msg.Status, NumSegments: 1}, nil
+}
+
+// newReferenceID returns a random ID shaped like a Twilio message SID.
+// Every lambda has its own FakeProvider, and starts over when it
+// restarts, so a counter would hand out IDs already saved on messages.
+func newReferenceID() string {
+
+	id := make([]byte, 14)
+	_, _ = rand.Read(id)
+
+	return "SMFAKE" + hex.EncodeToString(id)
+}
+
+func (p *FakeProvider) SendOTP(phoneNumber string) (*Verification, error) {
+	return &Verification{To: phoneNumber, Status: VerificationStatusPending, Channel: "sms"}, nil
+}
+
+func (p *FakeProvider) VerifyOTP(phoneNumber, code string) (*Verification, error) {
+
+	status := VerificationStatusPending
+
+	if code == FakeOTPCode {
+		status = VerificationStatusApproved
+	}
+
+	return &Verification{To: phoneNumber, Status: status, Channel: "sms"}, nil
+}
+
+// Sent returns a copy of every message sent so far.
+func (p *FakeProvider) Sent() []FakeMessage {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]FakeMessage(nil), p.sent...)
+}
+
+// Deliver updates the status of a sent message, and posts a status
+// callback for it if a Callback is set.
+func (p *FakeProvider) Deliver(referenceID, status string) error {
+
+	p.mu.Lock()
+
+	var msg *FakeMessage
+
+	for i := range p.sent {
+		if p.sent[i].ReferenceID == referenceID {
+			msg = &p.sent[i]
+		}
+	}
+
+	if msg == nil {
+		p.mu.Unlock()
+
+		return fmt.Errorf("no message sent with reference id %s", referenceID)
+	}
+
+	msg.Status = status
+	request := StatusCallbackRequest(*msg)
+
+	p.mu.Unlock()
+
+	if p.Callback == nil {
+		return nil
+	}
+
+	response, err := p.Callback(request)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("status callback for %s returned %d: %s",
+			referenceID, response.StatusCode, response.Body)
+	}
+
+	return nil
+}
+
+// DeliverQueued marks every queued message with [status], posting a
+// status callback for each.
+func (p *FakeProvider) DeliverQueued(status string) error {
+
+	for _, msg := range p.Sent() {
+		if msg.Status != "queued" {
+			continue
+		}
+
+		if err := p.Deliver(msg.ReferenceID, status); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StatusCallbackRequest builds the request Twilio would post to the
+// status webhook for [msg].
+func StatusCallbackRequest(msg FakeMessage) events.APIGatewayProxyRequest {
+
+	body := url.Values{
+		"MessageSid":    []string{msg.ReferenceID},
+		"SmsSid":        []string{msg.ReferenceID},
+		"MessageStatus": []string{msg.Status},
+		"SmsStatus":     []string{msg.Status},
+		"From":          []string{msg.From},
+		"To":            []string{msg.To},
+	}
+
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Path:       "/sms-status",
+		Headers:    map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+		Body:       body.Encode(),
+	}
+}
+
+func (p *FakeProvider) writeOutbox(msg FakeMessage) error {
+
+	if p.OutboxPath == "" {
+		return nil
+	}
+
+	line, err := json.Marshal(msg)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(p.OutboxPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = file.Close() }()
+
+	_, err = file.Write(append(line, '\n'))
+
+	return err
+}
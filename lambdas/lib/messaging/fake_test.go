@@ -0,0 +1,104 @@
+package messaging
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeProvider_Send(t *testing.T) {
+	outbox := filepath.Join(t.TempDir(), "outbox.jsonl")
+	provider := NewFakeProvider(outbox)
+
+	result, err := provider.Send("+18333595081", "+12533243071", "hello")
+	require.NoError(t, err)
+
+	_, err = provider.Send("+18333595081", "+12533243071", "are you there?")
+	require.NoError(t, err)
+
+	sent := provider.Sent()
+	require.Len(t, sent, 2)
+	assert.Equal(t, result.ReferenceID, sent[0].ReferenceID)
+	assert.NotEqual(t, sent[0].ReferenceID, sent[1].ReferenceID)
+	assert.Len(t, sent[0].ReferenceID, 34, "Reference IDs should be shaped like Twilio SIDs")
+	assert.Equal(t, "queued", sent[0].Status)
+
+	contents, err := os.ReadFile(outbox)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Len(t, lines, 2)
+
+	var recorded FakeMessage
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &recorded))
+	assert.Equal(t, "are you there?", recorded.Body)
+}
+
+func TestFakeProvider_Send_UniqueAcrossProviders(t *testing.T) {
+
+	// The devserver runs a provider per lambda, which must not reuse each
+	// other's IDs, or the IDs saved before a restart
+	first, err := NewFakeProvider("").Send("+18333595081", "+12533243071", "hello")
+	require.NoError(t, err)
+
+	second, err := NewFakeProvider("").Send("+18333595081", "+12533243071", "hello")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, first.ReferenceID, second.ReferenceID)
+}
+
+func TestFakeProvider_Send_Err(t *testing.T) {
+	provider := NewFakeProvider("")
+	provider.Err = errors.New("provider is down")
+
+	_, err := provider.Send("+18333595081", "+12533243071", "hello")
+
+	assert.ErrorIs(t, err, provider.Err)
+	assert.Empty(t, provider.Sent(), "Nothing is sent while the provider is down")
+}
+
+func TestFakeProvider_Deliver(t *testing.T) {
+	var callbacks []url.Values
+
+	provider := NewFakeProvider("")
+	provider.Callback = func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		values, err := url.ParseQuery(request.Body)
+		callbacks = append(callbacks, values)
+
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, err
+	}
+
+	result, err := provider.Send("+18333595081", "+12533243071", "hello")
+	require.NoError(t, err)
+	assert.Empty(t, callbacks, "callbacks wait until the message is delivered")
+
+	require.NoError(t, provider.DeliverQueued("delivered"))
+
+	require.Len(t, callbacks, 1)
+	assert.Equal(t, result.ReferenceID, callbacks[0].Get("SmsSid"))
+	assert.Equal(t, "delivered", callbacks[0].Get("MessageStatus"))
+	assert.Equal(t, "delivered", provider.Sent()[0].Status)
+
+	assert.Error(t, provider.Deliver("SM_UNKNOWN", "failed"))
+}
+
+func TestFakeProvider_VerifyOTP(t *testing.T) {
+	provider := NewFakeProvider("")
+
+	verification, err := provider.VerifyOTP("+12533243071", FakeOTPCode)
+	require.NoError(t, err)
+	assert.True(t, verification.IsApproved())
+
+	verification, err = provider.VerifyOTP("+12533243071", "000000")
+	require.NoError(t, err)
+	assert.False(t, verification.IsApproved())
+}
@@ -0,0 +1,152 @@
+package messaging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPGatewayProvider sends messages through a generic HTTP SMS gateway.
+// The gateway accepts JSON at:
+//
+//	POST /messages              {"from", "to", "body", "status_callback_url"}
+//	POST /verifications         {"to"}
+//	POST /verifications/check   {"to", "code"}
+//
+// and authenticates with a bearer token, if it has an APIKey.
+type HTTPGatewayProvider struct {
+	BaseURL           string
+	APIKey            string
+	StatusCallbackURL string
+
+	// Client defaults to an http.Client with a timeout
+	Client *http.Client
+}
+
+type gatewayMessageRequest struct {
+	From              string `json:"from"`
+	To                string `json:"to"`
+	Body              string `json:"body"`
+	StatusCallbackURL string `json:"status_callback_url,omitempty"`
+}
+
+type gatewayMessageResponse struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Segments int    `json:"segments"`
+}
+
+type gatewayVerificationRequest struct {
+	To   string `json:"to"`
+	Code string `json:"code,omitempty"`
+}
+
+type gatewayVerificationResponse struct {
+	To      string `json:"to"`
+	Status  string `json:"status"`
+	Channel string `json:"channel"`
+}
+
+func (p *HTTPGatewayProvider) Send(from, to, body string) (*SendResult, error) {
+
+	var response gatewayMessageResponse
+
+	err := p.post("/messages", gatewayMessageRequest{
+		From:              from,
+		To:                to,
+		Body:              body,
+		StatusCallbackURL: p.StatusCallbackURL,
+	}, &response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.ID == "" {
+		return nil, fmt.Errorf("gateway returned a message with no id")
+	}
+
+	return &SendResult{
+		ReferenceID: response.ID,
+		Status:      response.Status,
+		NumSegments: response.Segments,
+	}, nil
+}
+
+func (p *HTTPGatewayProvider) SendOTP(phoneNumber string) (*Verification, error) {
+
+	var response gatewayVerificationResponse
+
+	if err := p.post("/verifications", gatewayVerificationRequest{To: phoneNumber}, &response); err != nil {
+		return nil, err
+	}
+
+	return &Verification{To: response.To, Status: response.Status, Channel: response.Channel}, nil
+}
+
+func (p *HTTPGatewayProvider) VerifyOTP(phoneNumber, code string) (*Verification, error) {
+
+	var response gatewayVerificationResponse
+
+	err := p.post("/verifications/check", gatewayVerificationRequest{
+		To:   phoneNumber,
+		Code: code,
+	}, &response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{To: response.To, Status: response.Status, Channel: response.Channel}, nil
+}
+
+func (p *HTTPGatewayProvider) post(path string, body, out interface{}) error {
+
+	payload, err := json.Marshal(body)
+
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, strings.TrimRight(p.BaseURL, "/")+path, bytes.NewReader(payload))
+
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	if p.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+p.APIKey)
+	}
+
+	client := p.Client
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	response, err := client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = response.Body.Close() }()
+
+	responseBody, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned %d: %s", response.StatusCode, responseBody)
+	}
+
+	return json.Unmarshal(responseBody, out)
+}
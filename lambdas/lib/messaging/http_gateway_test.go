@@ -0,0 +1,82 @@
+package messaging
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPGatewayProvider_Send(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		var request gatewayMessageRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "+12533243071", request.To)
+		assert.Equal(t, "hello", request.Body)
+
+		_, _ = w.Write([]byte(`{"id": "GW1", "status": "queued", "segments": 1}`))
+	}))
+	defer server.Close()
+
+	provider := &HTTPGatewayProvider{BaseURL: server.URL + "/", APIKey: "secret"}
+
+	result, err := provider.Send("+18333595081", "+12533243071", "hello")
+
+	require.NoError(t, err)
+	assert.Equal(t, "GW1", result.ReferenceID)
+	assert.Equal(t, 1, result.NumSegments)
+}
+
+func TestHTTPGatewayProvider_Send_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	provider := &HTTPGatewayProvider{BaseURL: server.URL}
+
+	_, err := provider.Send("+18333595081", "+12533243071", "hello")
+
+	assert.ErrorContains(t, err, "429")
+}
+
+func TestHTTPGatewayProvider_Send_NoAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "A gateway without a key gets no token")
+
+		_, _ = w.Write([]byte(`{"id": "GW1", "status": "queued", "segments": 1}`))
+	}))
+	defer server.Close()
+
+	provider := &HTTPGatewayProvider{BaseURL: server.URL}
+
+	_, err := provider.Send("+18333595081", "+12533243071", "hello")
+
+	require.NoError(t, err)
+}
+
+func TestHTTPGatewayProvider_VerifyOTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/verifications/check", r.URL.Path)
+
+		var request gatewayVerificationRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Equal(t, "4321", request.Code)
+
+		_, _ = w.Write([]byte(`{"to": "+12533243071", "status": "approved", "channel": "sms"}`))
+	}))
+	defer server.Close()
+
+	provider := &HTTPGatewayProvider{BaseURL: server.URL}
+
+	verification, err := provider.VerifyOTP("+12533243071", "4321")
+
+	require.NoError(t, err)
+	assert.True(t, verification.IsApproved())
+}
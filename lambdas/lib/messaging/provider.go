@@ -0,0 +1,74 @@
+package messaging
+
+import (
+	"fmt"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+const (
+	ProviderTwilio = "twilio"
+	ProviderHTTP   = "http"
+	ProviderFake   = "fake"
+)
+
+const (
+	VerificationStatusPending  = "pending"
+	VerificationStatusApproved = "approved"
+)
+
+// SendResult is what a provider tells us about a message it accepted.
+type SendResult struct {
+	// ReferenceID is the provider's ID for the message. Status
+	// callbacks refer to the message by this ID.
+	ReferenceID string
+	Status      string
+	NumSegments int
+}
+
+// Verification is the state of a one time passcode sent to a phone number.
+type Verification struct {
+	To      string
+	Status  string
+	Channel string
+}
+
+// IsApproved reports whether the passcode was checked and found correct.
+func (v *Verification) IsApproved() bool {
+	return v.Status == VerificationStatusApproved
+}
+
+// Provider delivers outbound messages and one time passcodes.
+type Provider interface {
+
+	// Send delivers [body] from one address to another.
+	Send(from, to, body string) (*SendResult, error)
+
+	// SendOTP texts a one time passcode to [phoneNumber].
+	SendOTP(phoneNumber string) (*Verification, error)
+
+	// VerifyOTP checks the passcode a user entered for [phoneNumber].
+	VerifyOTP(phoneNumber, code string) (*Verification, error)
+}
+
+// New returns the Provider configured by MESSAGING_PROVIDER.
+func New(cfg *config.Config) (Provider, error) {
+
+	switch cfg.MessagingProvider {
+	case ProviderTwilio:
+		return NewTwilioProvider(
+			cfg.TwilioSID, cfg.TwilioAuthToken,
+			cfg.TwilioVerifyServiceSID, cfg.TwilioStatusCallbackURL,
+		), nil
+	case ProviderHTTP:
+		return &HTTPGatewayProvider{
+			BaseURL:           cfg.MessagingGatewayURL,
+			APIKey:            cfg.MessagingGatewayAPIKey,
+			StatusCallbackURL: cfg.TwilioStatusCallbackURL,
+		}, nil
+	case ProviderFake:
+		return NewFakeProvider(cfg.MessagingFakeOutboxPath), nil
+	}
+
+	return nil, fmt.Errorf("unknown messaging provider: %s", cfg.MessagingProvider)
+}
@@ -0,0 +1,32 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+func TestNew(t *testing.T) {
+	cfg := &config.Config{MessagingProvider: ProviderTwilio}
+
+	provider, err := New(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &TwilioProvider{}, provider)
+
+	cfg.MessagingProvider = ProviderHTTP
+	provider, err = New(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &HTTPGatewayProvider{}, provider)
+
+	cfg.MessagingProvider = ProviderFake
+	provider, err = New(cfg)
+	require.NoError(t, err)
+	assert.IsType(t, &FakeProvider{}, provider)
+
+	cfg.MessagingProvider = "pigeon"
+	_, err = New(cfg)
+	assert.Error(t, err)
+}
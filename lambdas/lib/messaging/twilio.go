@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/twilio/twilio-go"
+	api "github.com/twilio/twilio-go/rest/api/v2010"
+	verify "github.com/twilio/twilio-go/rest/verify/v2"
+)
+
+// TwilioProvider sends messages with Twilio, and passcodes with Twilio Verify.
+type TwilioProvider struct {
+	VerifyServiceSID  string
+	StatusCallbackURL string
+
+	client *twilio.RestClient
+}
+
+func NewTwilioProvider(accountSID, authToken, verifyServiceSID, statusCallbackURL string) *TwilioProvider {
+
+	return &TwilioProvider{
+		VerifyServiceSID:  verifyServiceSID,
+		StatusCallbackURL: statusCallbackURL,
+		client: twilio.NewRestClientWithParams(twilio.ClientParams{
+			Username: accountSID,
+			Password: authToken,
+		}),
+	}
+}
+
+func (p *TwilioProvider) Send(from, to, body string) (*SendResult, error) {
+
+	params := &api.CreateMessageParams{}
+	params.SetStatusCallback(p.StatusCallbackURL)
+	params.SetBody(body)
+	params.SetFrom(from)
+	params.SetTo(to)
+
+	response, err := p.client.Api.CreateMessage(params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response == nil || response.Sid == nil {
+		return nil, fmt.Errorf("twilio returned an empty response")
+	}
+
+	result := &SendResult{ReferenceID: *response.Sid}
+
+	if response.Status != nil {
+		result.Status = *response.Status
+	}
+
+	if response.NumSegments != nil {
+		result.NumSegments, _ = strconv.Atoi(*response.NumSegments)
+	}
+
+	return result, nil
+}
+
+func (p *TwilioProvider) SendOTP(phoneNumber string) (*Verification, error) {
+
+	const (
+		channel = "sms"
+		locale  = "en"
+	)
+
+	params := &verify.CreateVerificationParams{}
+	params.SetTo(phoneNumber)
+	params.SetChannel(channel)
+	params.SetLocale(locale)
+
+	response, err := p.client.VerifyV2.CreateVerification(p.VerifyServiceSID, params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		To:      stringValue(response.To),
+		Status:  stringValue(response.Status),
+		Channel: stringValue(response.Channel),
+	}, nil
+}
+
+func (p *TwilioProvider) VerifyOTP(phoneNumber, code string) (*Verification, error) {
+
+	params := &verify.CreateVerificationCheckParams{}
+	params.SetTo(phoneNumber)
+	params.SetCode(code)
+
+	response, err := p.client.VerifyV2.CreateVerificationCheck(p.VerifyServiceSID, params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verification{
+		To:      stringValue(response.To),
+		Status:  stringValue(response.Status),
+		Channel: stringValue(response.Channel),
+	}, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
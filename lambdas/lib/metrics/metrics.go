@@ -0,0 +1,246 @@
+// Package metrics records business metrics from the lambdas, like how long
+// a reply takes and how many tokens it costs. In AWS they're written to
+// stdout in CloudWatch's Embedded Metric Format, which CloudWatch turns into
+// metrics without any API calls. Locally they can be written as Prometheus
+// text instead.
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+const (
+	FormatEMF        = "emf"
+	FormatPrometheus = "prometheus"
+	FormatNone       = "none"
+
+	// Namespace is the CloudWatch namespace, and the Prometheus prefix
+	Namespace = "Equilibria"
+
+	// ServiceDimension names the lambda a metric came from
+	ServiceDimension = "Service"
+)
+
+// The metrics the lambdas record.
+const (
+	ReplyLatency     = "ReplyLatency"
+	PromptTokens     = "PromptTokens"
+	CompletionTokens = "CompletionTokens"
+	TotalTokens      = "TotalTokens"
+	SendFailures     = "SendFailures"
+	DeliveryStatus   = "DeliveryStatus"
+	NudgesSent       = "NudgesSent"
+	NudgesFailed     = "NudgesFailed"
+	FactsExtracted   = "FactsExtracted"
+	QuotaExceeded    = "QuotaExceeded"
+)
+
+// Unit is a CloudWatch unit.
+type Unit string
+
+const (
+	UnitMilliseconds Unit = "Milliseconds"
+	UnitCount        Unit = "Count"
+)
+
+// Metric is a single value of a metric.
+type Metric struct {
+	Name  string
+	Unit  Unit
+	Value float64
+}
+
+// Count returns a count of [n] for the metric [name].
+func Count(name string, n int) Metric {
+	return Metric{Name: name, Unit: UnitCount, Value: float64(n)}
+}
+
+// Duration returns [d] in milliseconds for the metric [name].
+func Duration(name string, d time.Duration) Metric {
+	return Metric{Name: name, Unit: UnitMilliseconds, Value: float64(d.Milliseconds())}
+}
+
+// Dimensions describe what a metric was recorded for, like the channel a
+// reply was sent on.
+type Dimensions map[string]string
+
+// Recorder writes the metrics recorded by a lambda. A nil Recorder records
+// nothing, so handlers and tests don't need one.
+type Recorder struct {
+	Service string
+	Format  string
+	Writer  io.Writer
+	Now     func() time.Time
+
+	mu sync.Mutex
+}
+
+// New returns a Recorder for the lambda named [service], writing to stdout
+// in the configured format, or nil if metrics are turned off.
+func New(cfg *config.Config, service string) (*Recorder, error) {
+
+	switch cfg.MetricsFormat {
+	case FormatNone:
+		return nil, nil
+	case FormatEMF, FormatPrometheus:
+		return &Recorder{Service: service, Format: cfg.MetricsFormat, Writer: os.Stdout, Now: time.Now}, nil
+	}
+
+	return nil, fmt.Errorf("unknown metrics format: %s", cfg.MetricsFormat)
+}
+
+// Record writes [metrics] with the [dimensions] they share. Errors writing
+// them are ignored, since a lost metric shouldn't fail the event.
+func (r *Recorder) Record(dimensions Dimensions, metrics ...Metric) {
+
+	if r == nil || len(metrics) == 0 {
+		return
+	}
+
+	all := Dimensions{ServiceDimension: r.Service}
+
+	for key, value := range dimensions {
+		all[key] = value
+	}
+
+	var (
+		entry []byte
+		err   error
+	)
+
+	switch r.Format {
+	case FormatPrometheus:
+		entry = Prometheus(all, r.Now(), metrics...)
+	default:
+		entry, err = EMF(all, r.Now(), metrics...)
+	}
+
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, _ = r.Writer.Write(entry)
+}
+
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit Unit   `json:"Unit"`
+}
+
+type emfDirective struct {
+	Namespace  string      `json:"Namespace"`
+	Dimensions [][]string  `json:"Dimensions"`
+	Metrics    []emfMetric `json:"Metrics"`
+}
+
+type emfMetadata struct {
+	Timestamp         int64          `json:"Timestamp"`
+	CloudWatchMetrics []emfDirective `json:"CloudWatchMetrics"`
+}
+
+// EMF returns [metrics] as a line of CloudWatch Embedded Metric Format.
+func EMF(dimensions Dimensions, at time.Time, metrics ...Metric) ([]byte, error) {
+
+	entry := map[string]interface{}{}
+	directive := emfDirective{Namespace: Namespace, Dimensions: [][]string{dimensionNames(dimensions)}}
+
+	for key, value := range dimensions {
+		entry[key] = value
+	}
+
+	for _, metric := range metrics {
+		directive.Metrics = append(directive.Metrics, emfMetric{Name: metric.Name, Unit: metric.Unit})
+		entry[metric.Name] = metric.Value
+	}
+
+	entry["_aws"] = emfMetadata{
+		Timestamp:         at.UnixMilli(),
+		CloudWatchMetrics: []emfDirective{directive},
+	}
+
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(line, '\n'), nil
+}
+
+// Prometheus returns [metrics] as samples in the Prometheus text format.
+// Counts are named as counters, with a _total suffix.
+func Prometheus(dimensions Dimensions, at time.Time, metrics ...Metric) []byte {
+
+	var b strings.Builder
+
+	labels := make([]string, 0, len(dimensions))
+
+	for _, name := range dimensionNames(dimensions) {
+		labels = append(labels, fmt.Sprintf("%s=%q", snakeCase(name), dimensions[name]))
+	}
+
+	for _, metric := range metrics {
+
+		name := snakeCase(Namespace) + "_" + snakeCase(metric.Name)
+
+		switch metric.Unit {
+		case UnitCount:
+			name += "_total"
+		case UnitMilliseconds:
+			name += "_milliseconds"
+		}
+
+		_, _ = fmt.Fprintf(&b, "%s{%s} %g %d\n", name, strings.Join(labels, ","), metric.Value, at.UnixMilli())
+	}
+
+	return []byte(b.String())
+}
+
+// dimensionNames returns the names of [dimensions], with the service first.
+func dimensionNames(dimensions Dimensions) []string {
+
+	names := make([]string, 0, len(dimensions))
+
+	for name := range dimensions {
+		names = append(names, name)
+	}
+
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == ServiceDimension || names[j] == ServiceDimension {
+			return names[i] == ServiceDimension
+		}
+
+		return names[i] < names[j]
+	})
+
+	return names
+}
+
+// snakeCase turns a name like ReplyLatency into reply_latency.
+func snakeCase(name string) string {
+
+	var b strings.Builder
+
+	for i, r := range name {
+		if unicode.IsUpper(r) && i > 0 {
+			b.WriteByte('_')
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+var at = time.UnixMilli(1718700000000)
+
+func TestEMF(t *testing.T) {
+
+	line, err := EMF(
+		Dimensions{ServiceDimension: "send_sms", "Channel": "sms"}, at,
+		Duration(ReplyLatency, 1500*time.Millisecond),
+		Count(SendFailures, 1),
+	)
+
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1718700000000,
+			"CloudWatchMetrics": [{
+				"Namespace": "Equilibria",
+				"Dimensions": [["Service", "Channel"]],
+				"Metrics": [
+					{"Name": "ReplyLatency", "Unit": "Milliseconds"},
+					{"Name": "SendFailures", "Unit": "Count"}
+				]
+			}]
+		},
+		"Service": "send_sms",
+		"Channel": "sms",
+		"ReplyLatency": 1500,
+		"SendFailures": 1
+	}`, string(line))
+}
+
+func TestPrometheus(t *testing.T) {
+
+	text := Prometheus(
+		Dimensions{ServiceDimension: "send_sms", "Channel": "sms"}, at,
+		Duration(ReplyLatency, 1500*time.Millisecond),
+		Count(PromptTokens, 812),
+	)
+
+	assert.Equal(t,
+		"equilibria_reply_latency_milliseconds{service=\"send_sms\",channel=\"sms\"} 1500 1718700000000\n"+
+			"equilibria_prompt_tokens_total{service=\"send_sms\",channel=\"sms\"} 812 1718700000000\n",
+		string(text))
+}
+
+func TestRecorder_Record(t *testing.T) {
+
+	var out bytes.Buffer
+
+	recorder := &Recorder{
+		Service: "factfinder", Format: FormatPrometheus, Writer: &out,
+		Now: func() time.Time { return at },
+	}
+
+	recorder.Record(nil, Count(FactsExtracted, 2))
+	recorder.Record(nil)
+
+	assert.Equal(t, "equilibria_facts_extracted_total{service=\"factfinder\"} 2 1718700000000\n", out.String())
+
+	var nilRecorder *Recorder
+
+	assert.NotPanics(t, func() { nilRecorder.Record(nil, Count(FactsExtracted, 1)) })
+}
+
+func TestNew(t *testing.T) {
+
+	recorder, err := New(&config.Config{MetricsFormat: FormatNone}, "send_sms")
+	require.NoError(t, err)
+	assert.Nil(t, recorder)
+
+	recorder, err = New(&config.Config{MetricsFormat: FormatEMF}, "send_sms")
+	require.NoError(t, err)
+	assert.Equal(t, "send_sms", recorder.Service)
+
+	_, err = New(&config.Config{MetricsFormat: "statsd"}, "send_sms")
+	assert.Error(t, err)
+}
@@ -0,0 +1,311 @@
+package migrate
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Models are the models that are read from and written to the database.
+// Models that are only decoded from requests, like TwilioMessageInfo,
+// aren't tables.
+var Models = []interface{}{
+	&models.AccountStatus{},
+	&models.Conversation{},
+	&models.DataExport{},
+	&models.Fact{},
+	&models.FailedEvent{},
+	&models.IdempotencyKey{},
+	&models.LLMPrice{},
+	&models.LLMUsage{},
+	&models.LLMUsageMonthly{},
+	&models.Message{},
+	&models.MessageMedia{},
+	&models.MessageStatus{},
+	&models.MessageType{},
+	&models.NrcLex{},
+	&models.PromptAssignment{},
+	&models.PromptTemplate{},
+	&models.Transaction{},
+	&models.User{},
+	&models.UserMetric{},
+}
+
+// Column is a column of the live schema.
+type Column struct {
+	Table    string  `gorm:"column:TABLE_NAME"`
+	Name     string  `gorm:"column:COLUMN_NAME"`
+	Type     string  `gorm:"column:COLUMN_TYPE"`
+	Nullable string  `gorm:"column:IS_NULLABLE"`
+	Default  *string `gorm:"column:COLUMN_DEFAULT"`
+	Extra    string  `gorm:"column:EXTRA"`
+}
+
+// Drift is a difference between a model and the live schema.
+type Drift struct {
+	Table   string
+	Column  string
+	Problem string
+}
+
+func (d Drift) String() string {
+
+	if d.Column == "" {
+		return fmt.Sprintf("%s: %s", d.Table, d.Problem)
+	}
+
+	return fmt.Sprintf("%s.%s: %s", d.Table, d.Column, d.Problem)
+}
+
+// CheckDrift compares [models] against the schema of the database [db] is
+// connected to.
+func CheckDrift(db *gorm.DB, models ...interface{}) ([]Drift, error) {
+
+	var columns []Column
+
+	err := db.Raw(`SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA
+FROM information_schema.COLUMNS
+WHERE TABLE_SCHEMA = DATABASE()
+ORDER BY TABLE_NAME, ORDINAL_POSITION`).Scan(&columns).Error
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	schemas, err := ParseModels(models...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return Compare(schemas, columns), nil
+}
+
+// ParseModels returns the GORM schema of each of [models].
+func ParseModels(models ...interface{}) ([]*schema.Schema, error) {
+
+	cache := &sync.Map{}
+	schemas := make([]*schema.Schema, 0, len(models))
+
+	for _, model := range models {
+
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %T: %w", model, err)
+		}
+
+		schemas = append(schemas, s)
+	}
+
+	return schemas, nil
+}
+
+// Compare returns the ways [schemas] differ from the live [columns]:
+//
+//   - tables and columns the models use that don't exist
+//   - columns whose type doesn't hold what the model writes, like a
+//     size:255 string stored as TEXT
+//   - columns the model says are NOT NULL that allow NULL
+//   - pointer fields, which write NULL when they're nil, stored in
+//     NOT NULL columns without a default
+//
+// Columns the models don't use are left alone.
+func Compare(schemas []*schema.Schema, columns []Column) []Drift {
+
+	tables := map[string]map[string]Column{}
+
+	for _, column := range columns {
+
+		if tables[column.Table] == nil {
+			tables[column.Table] = map[string]Column{}
+		}
+
+		tables[column.Table][column.Name] = column
+	}
+
+	var drift []Drift
+
+	for _, s := range schemas {
+
+		table, ok := tables[s.Table]
+
+		if !ok {
+			drift = append(drift, Drift{Table: s.Table, Problem: "table does not exist"})
+
+			continue
+		}
+
+		for _, name := range s.DBNames {
+
+			field := s.FieldsByDBName[name]
+
+			if field.IgnoreMigration {
+				continue
+			}
+
+			column, ok := table[field.DBName]
+
+			if !ok {
+				drift = append(drift, Drift{Table: s.Table, Column: field.DBName, Problem: "column does not exist"})
+
+				continue
+			}
+
+			for _, problem := range compareField(field, column) {
+				drift = append(drift, Drift{Table: s.Table, Column: field.DBName, Problem: problem})
+			}
+		}
+	}
+
+	sort.SliceStable(drift, func(i, j int) bool {
+		return drift[i].Table < drift[j].Table
+	})
+
+	return drift
+}
+
+// compareField returns the ways [field] differs from [column].
+func compareField(field *schema.Field, column Column) []string {
+
+	var problems []string
+
+	want, explicit := modelType(field)
+	got := parseType(column.Type)
+
+	if want.family != "" && !want.holds(got, explicit) {
+		problems = append(problems, fmt.Sprintf("model is %s, column is %s", want, column.Type))
+	}
+
+	nullable := column.Nullable == "YES"
+
+	if (field.NotNull || field.PrimaryKey) && nullable {
+		problems = append(problems, "model is NOT NULL, column allows NULL")
+	}
+
+	writesNull := field.FieldType.Kind() == reflect.Ptr && !field.NotNull && !field.HasDefaultValue
+
+	if writesNull && !nullable && column.Default == nil && !strings.Contains(column.Extra, "auto_increment") {
+		problems = append(problems, "model writes NULL, column is NOT NULL without a default")
+	}
+
+	return problems
+}
+
+// columnType is a column type, reduced to what matters for drift.
+type columnType struct {
+	family string
+
+	// The size of a varchar or char, or the precision of a decimal
+	args string
+}
+
+func (t columnType) String() string {
+
+	if t.args == "" {
+		return t.family
+	}
+
+	return fmt.Sprintf("%s(%s)", t.family, t.args)
+}
+
+// holds reports whether a column of type [got] holds what a model of type
+// [t] writes. Types the model names must match, while types taken from
+// the Go type only need to be in the same family.
+func (t columnType) holds(got columnType, explicit bool) bool {
+
+	switch {
+	case t.family == "string":
+		return got.family == "varchar" || got.family == "char" || got.family == "text" ||
+			got.family == "enum" || got.family == "json"
+	case t.family == "float" && !explicit:
+		return got.family == "float" || got.family == "decimal"
+	case t.family == "datetime" && !explicit:
+		return got.family == "datetime" || got.family == "date"
+	}
+
+	return t.family == got.family && (t.args == "" || t.args == got.args)
+}
+
+// modelType returns the column type [field] expects, and whether it was
+// named in the field's tag. The family is empty if it can't be told.
+func modelType(field *schema.Field) (columnType, bool) {
+
+	if tagged := field.TagSettings["TYPE"]; tagged != "" {
+		return parseType(tagged), true
+	}
+
+	switch field.GORMDataType {
+	case schema.Bool:
+		return columnType{family: "bool"}, false
+	case schema.Int, schema.Uint:
+		return columnType{family: "integer"}, false
+	case schema.Float:
+		return columnType{family: "float"}, false
+	case schema.Time:
+		return columnType{family: "datetime"}, false
+	case schema.Bytes:
+		return columnType{family: "blob"}, false
+	case schema.String:
+
+		if field.Size > 0 {
+			return columnType{family: "varchar", args: fmt.Sprint(field.Size)}, false
+		}
+
+		return columnType{family: "string"}, false
+	}
+
+	return columnType{}, false
+}
+
+// parseType reduces a MySQL column type, like "bigint unsigned" or
+// "decimal(10,2)", to its family.
+func parseType(sqlType string) columnType {
+
+	sqlType = strings.ToLower(strings.TrimSpace(sqlType))
+	name, args, _ := strings.Cut(sqlType, "(")
+	args, _, _ = strings.Cut(args, ")")
+	args = strings.ReplaceAll(args, " ", "")
+
+	// Drop attributes like unsigned
+	if fields := strings.Fields(name); len(fields) > 0 {
+		name = fields[0]
+	}
+
+	switch name {
+	case "bool", "boolean":
+		return columnType{family: "bool"}
+	case "tinyint":
+
+		if args == "1" {
+			return columnType{family: "bool"}
+		}
+
+		return columnType{family: "integer"}
+	case "smallint", "mediumint", "int", "integer", "bigint", "serial":
+		return columnType{family: "integer"}
+	case "decimal", "numeric":
+		return columnType{family: "decimal", args: args}
+	case "float", "double", "real":
+		return columnType{family: "float"}
+	case "varchar", "char":
+		return columnType{family: name, args: args}
+	case "tinytext", "text", "mediumtext", "longtext":
+		return columnType{family: "text"}
+	case "datetime", "timestamp":
+		return columnType{family: "datetime"}
+	case "date", "time", "json", "enum":
+		return columnType{family: name}
+	case "binary", "varbinary", "tinyblob", "blob", "mediumblob", "longblob":
+		return columnType{family: "blob"}
+	}
+
+	return columnType{family: name}
+}
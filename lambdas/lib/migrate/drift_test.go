@@ -0,0 +1,98 @@
+package migrate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/migrate"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+type widget struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement"`
+	Name      string    `gorm:"size:64;not null"`
+	Notes     string    `gorm:"type:text"`
+	Price     float64   `gorm:"type:decimal(10,2);not null"`
+	Weight    float64   `gorm:"not null"`
+	Color     *string   `gorm:"size:16"`
+	Active    bool      `gorm:"not null;default:true"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+type gadget struct {
+	ID int64 `gorm:"primaryKey;autoIncrement"`
+}
+
+func column(table, name, sqlType, nullable string) migrate.Column {
+	return migrate.Column{Table: table, Name: name, Type: sqlType, Nullable: nullable}
+}
+
+func TestCompare(t *testing.T) {
+
+	schemas, err := migrate.ParseModels(&widget{}, &gadget{})
+	require.NoError(t, err)
+
+	defaultNow := "CURRENT_TIMESTAMP"
+	created := column("widgets", "created_at", "datetime", "NO")
+	created.Default = &defaultNow
+	id := column("widgets", "id", "bigint unsigned", "NO")
+	id.Extra = "auto_increment"
+
+	drift := migrate.Compare(schemas, []migrate.Column{
+		id,
+		column("widgets", "name", "text", "YES"),
+		column("widgets", "notes", "mediumtext", "YES"),
+		column("widgets", "price", "decimal(10,4)", "NO"),
+		column("widgets", "weight", "double", "NO"),
+		column("widgets", "color", "varchar(16)", "NO"),
+		created,
+		column("widgets", "unused", "int", "YES"),
+	})
+
+	var problems []string
+
+	for _, d := range drift {
+		problems = append(problems, d.String())
+	}
+
+	assert.Equal(t, []string{
+		"gadgets: table does not exist",
+		"widgets.name: model is varchar(64), column is text",
+		"widgets.name: model is NOT NULL, column allows NULL",
+		"widgets.price: model is decimal(10,2), column is decimal(10,4)",
+		"widgets.color: model writes NULL, column is NOT NULL without a default",
+		"widgets.active: column does not exist",
+	}, problems)
+}
+
+func TestCompare_NoDrift(t *testing.T) {
+
+	schemas, err := migrate.ParseModels(&gadget{})
+	require.NoError(t, err)
+
+	id := column("gadgets", "id", "bigint", "NO")
+	id.Extra = "auto_increment"
+
+	assert.Empty(t, migrate.Compare(schemas, []migrate.Column{id}))
+}
+
+func TestCheckDrift(t *testing.T) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT, EXTRA FROM information_schema.COLUMNS").
+		WillReturnRows(sqlmock.NewRows([]string{"TABLE_NAME", "COLUMN_NAME", "COLUMN_TYPE", "IS_NULLABLE", "COLUMN_DEFAULT", "EXTRA"}).
+			AddRow("gadgets", "id", "int", "YES", nil, ""))
+
+	drift, err := migrate.CheckDrift(db, &gadget{})
+	require.NoError(t, err)
+	require.Len(t, drift, 1)
+	assert.Equal(t, "gadgets.id: model is NOT NULL, column allows NULL", drift[0].String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
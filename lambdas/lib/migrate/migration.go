@@ -0,0 +1,168 @@
+// Package migrate applies and rolls back the goose SQL migrations, and
+// checks the live schema against the GORM models for drift. It keeps the
+// same goose_db_version table as goose, so databases migrated with goose
+// can be carried on with it, and the other way around.
+package migrate
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	annotationPrefix = "-- +goose"
+
+	annotationUp             = "Up"
+	annotationDown           = "Down"
+	annotationStatementBegin = "StatementBegin"
+	annotationStatementEnd   = "StatementEnd"
+)
+
+// Migration is a single migration file.
+type Migration struct {
+	Version int64
+	Name    string
+
+	// The statements that apply and roll back the migration, in order
+	Up   []string
+	Down []string
+}
+
+// Load reads every .sql migration in [fsys], ordered by version.
+func Load(fsys fs.FS) ([]*Migration, error) {
+
+	names, err := fs.Glob(fsys, "*.sql")
+
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]*Migration, 0, len(names))
+	versions := map[int64]string{}
+
+	for _, name := range names {
+
+		source, err := fs.ReadFile(fsys, name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		migration, err := Parse(name, string(source))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := versions[migration.Version]; ok {
+			return nil, fmt.Errorf("%s and %s have the same version", other, name)
+		}
+
+		versions[migration.Version] = name
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Parse reads the migration [name] from its [source]. Statements end with
+// a semicolon at the end of a line, unless they're wrapped in
+// StatementBegin and StatementEnd, which are run as one.
+func Parse(name, source string) (*Migration, error) {
+
+	prefix, _, ok := strings.Cut(path.Base(name), "_")
+
+	if !ok {
+		return nil, fmt.Errorf("%s: name must start with a version, like 0001_", name)
+	}
+
+	version, err := strconv.ParseInt(prefix, 10, 64)
+
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("%s: name must start with a version, like 0001_", name)
+	}
+
+	var (
+		migration = &Migration{Version: version, Name: path.Base(name)}
+		section   *[]string
+		inBlock   bool
+		statement strings.Builder
+	)
+
+	flush := func() {
+		if s := strings.TrimSpace(statement.String()); s != "" && section != nil {
+			*section = append(*section, s)
+		}
+
+		statement.Reset()
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+
+	for n := 1; scanner.Scan(); n++ {
+
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		if annotation, ok := strings.CutPrefix(trimmed, annotationPrefix); ok {
+
+			switch strings.TrimSpace(annotation) {
+			case annotationUp:
+				flush()
+				section = &migration.Up
+			case annotationDown:
+				flush()
+				section = &migration.Down
+			case annotationStatementBegin:
+				flush()
+				inBlock = true
+			case annotationStatementEnd:
+				flush()
+				inBlock = false
+			}
+
+			continue
+		}
+
+		if inBlock {
+			statement.WriteString(line + "\n")
+
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "--") || trimmed == "" {
+			continue
+		}
+
+		if section == nil {
+			return nil, fmt.Errorf("%s:%d: statement before -- +goose Up", name, n)
+		}
+
+		statement.WriteString(line + "\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			flush()
+		}
+	}
+
+	if inBlock {
+		return nil, fmt.Errorf("%s: StatementBegin without StatementEnd", name)
+	}
+
+	flush()
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return migration, nil
+}
@@ -0,0 +1,96 @@
+package migrate_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/migrate"
+	"github.com/kmesiab/equilibria/migrations"
+)
+
+const source = `-- +goose Up
+-- Comments and blank lines are skipped
+
+CREATE TABLE widgets
+(
+    id   BIGINT AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(64) NOT NULL
+);
+INSERT INTO widgets (name) VALUES ('one;two');
+
+-- +goose StatementBegin
+ALTER TABLE widgets
+    ADD COLUMN size INT DEFAULT NULL;
+
+ALTER TABLE widgets
+    ADD COLUMN color VARCHAR(16) DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE IF EXISTS widgets;
+`
+
+func TestParse(t *testing.T) {
+
+	migration, err := migrate.Parse("20240101000000_create_table_widgets.sql", source)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(20240101000000), migration.Version)
+	assert.Equal(t, "20240101000000_create_table_widgets.sql", migration.Name)
+	require.Len(t, migration.Up, 3)
+	assert.Contains(t, migration.Up[0], "CREATE TABLE widgets")
+	assert.Equal(t, "INSERT INTO widgets (name) VALUES ('one;two');", migration.Up[1])
+
+	// A block is one statement, however many it holds
+	assert.Contains(t, migration.Up[2], "ADD COLUMN size")
+	assert.Contains(t, migration.Up[2], "ADD COLUMN color")
+	assert.Equal(t, []string{"DROP TABLE IF EXISTS widgets;"}, migration.Down)
+}
+
+func TestParse_Invalid(t *testing.T) {
+
+	_, err := migrate.Parse("create_table_widgets.sql", source)
+	assert.ErrorContains(t, err, "must start with a version")
+
+	_, err = migrate.Parse("0001_widgets.sql", "DROP TABLE widgets;")
+	assert.ErrorContains(t, err, "statement before -- +goose Up")
+
+	_, err = migrate.Parse("0001_widgets.sql", "-- +goose Up\n-- +goose StatementBegin\nSELECT 1;")
+	assert.ErrorContains(t, err, "StatementBegin without StatementEnd")
+}
+
+func TestLoad(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"0002_b.sql": {Data: []byte("-- +goose Up\nSELECT 2;\n")},
+		"0001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;\n")},
+		"README.md":  {Data: []byte("not a migration")},
+	}
+
+	loaded, err := migrate.Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+	assert.Equal(t, int64(1), loaded[0].Version)
+	assert.Equal(t, int64(2), loaded[1].Version)
+
+	fsys["02_c.sql"] = &fstest.MapFile{Data: []byte("-- +goose Up\nSELECT 3;\n")}
+
+	_, err = migrate.Load(fsys)
+	assert.ErrorContains(t, err, "have the same version")
+}
+
+// Every migration in the repository must parse, and be able to roll back
+func TestLoad_Migrations(t *testing.T) {
+
+	loaded, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for _, migration := range loaded {
+		assert.NotEmpty(t, migration.Up, migration.Name)
+		assert.NotEmpty(t, migration.Down, migration.Name)
+	}
+}
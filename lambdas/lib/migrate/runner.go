@@ -0,0 +1,233 @@
+package migrate
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VersionTable is where goose records the migrations it has applied.
+const VersionTable = "goose_db_version"
+
+// versionRow is a row of the version table. goose appends a row each time
+// a migration is applied, and deletes its rows when it's rolled back.
+type versionRow struct {
+	ID        int64
+	VersionID int64
+	IsApplied bool
+	Tstamp    *time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func (versionRow) TableName() string {
+	return VersionTable
+}
+
+// Status is whether a migration has been applied, and when.
+type Status struct {
+	Migration *Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Runner applies and rolls back migrations on a database. The database
+// connection must allow multiple statements, since a StatementBegin block
+// may hold several.
+type Runner struct {
+	DB         *gorm.DB
+	Migrations []*Migration
+}
+
+// NewRunner returns a runner for [migrations], sorted by version.
+func NewRunner(db *gorm.DB, migrations []*Migration) *Runner {
+	return &Runner{DB: db, Migrations: migrations}
+}
+
+// Status returns the status of every migration, oldest first.
+func (r *Runner) Status() ([]Status, error) {
+
+	applied, err := r.applied()
+
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(r.Migrations))
+
+	for i, migration := range r.Migrations {
+		row, ok := applied[migration.Version]
+		statuses[i] = Status{Migration: migration, Applied: ok, AppliedAt: row.Tstamp}
+	}
+
+	return statuses, nil
+}
+
+// Up applies the migrations that haven't been applied, up to and
+// including version [to], or all of them if [to] is 0. It returns the
+// migrations it applied, and stops at the first one that fails.
+func (r *Runner) Up(to int64) ([]*Migration, error) {
+
+	statuses, err := r.Status()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+
+	for _, status := range statuses {
+
+		if status.Applied {
+			continue
+		}
+
+		if to > 0 && status.Migration.Version > to {
+			break
+		}
+
+		err = r.run(status.Migration, status.Migration.Up, func(tx *gorm.DB) error {
+			return tx.Create(&versionRow{VersionID: status.Migration.Version, IsApplied: true}).Error
+		})
+
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, status.Migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the last [steps] applied migrations, newest first. It
+// returns the migrations it rolled back, and stops at the first one that
+// fails.
+func (r *Runner) Down(steps int) ([]*Migration, error) {
+
+	statuses, err := r.Status()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var done []*Migration
+
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+
+		if !statuses[i].Applied {
+			continue
+		}
+
+		migration := statuses[i].Migration
+
+		err = r.run(migration, migration.Down, func(tx *gorm.DB) error {
+			return tx.Where("version_id = ?", migration.Version).Delete(&versionRow{}).Error
+		})
+
+		if err != nil {
+			return done, err
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+// run executes [statements] and records the result with [record], in a
+// transaction. MySQL commits schema changes as they're made, so a failed
+// migration may still need cleaning up by hand.
+func (r *Runner) run(migration *Migration, statements []string, record func(tx *gorm.DB) error) error {
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		return record(tx)
+	})
+
+	if err != nil {
+		return fmt.Errorf("%s: %w", migration.Name, err)
+	}
+
+	return nil
+}
+
+// applied returns the version table row of each applied version, creating
+// the version table if it doesn't exist yet. The latest row for a version
+// says whether it's applied, as it does for goose.
+func (r *Runner) applied() (map[int64]versionRow, error) {
+
+	if err := r.createVersionTable(); err != nil {
+		return nil, err
+	}
+
+	var rows []versionRow
+
+	if err := r.DB.Order("id DESC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", VersionTable, err)
+	}
+
+	seen := map[int64]bool{}
+	applied := map[int64]versionRow{}
+
+	for _, row := range rows {
+
+		if seen[row.VersionID] {
+			continue
+		}
+
+		seen[row.VersionID] = true
+
+		if row.IsApplied {
+			applied[row.VersionID] = row
+		}
+	}
+
+	return applied, nil
+}
+
+// createVersionTable creates the version table the way goose does, with
+// the row for version 0 that goose expects to find.
+func (r *Runner) createVersionTable() error {
+
+	var count int64
+
+	err := r.DB.Raw(`SELECT COUNT(*) FROM information_schema.TABLES
+WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?`, VersionTable).Scan(&count).Error
+
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %w", VersionTable, err)
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	err = r.DB.Transaction(func(tx *gorm.DB) error {
+
+		err := tx.Exec(`CREATE TABLE ` + VersionTable + ` (
+    id         SERIAL NOT NULL,
+    version_id BIGINT NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp     TIMESTAMP NULL DEFAULT now(),
+    PRIMARY KEY (id)
+)`).Error
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&versionRow{VersionID: 0, IsApplied: true}).Error
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", VersionTable, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package migrate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/migrate"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+var applied = time.Date(2024, time.June, 20, 9, 0, 0, 0, time.UTC)
+
+func newRunner(t *testing.T) (*migrate.Runner, sqlmock.Sqlmock) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	return migrate.NewRunner(db, []*migrate.Migration{
+		{Version: 1, Name: "0001_a.sql", Up: []string{"CREATE TABLE a (id INT);"}, Down: []string{"DROP TABLE a;"}},
+		{Version: 2, Name: "0002_b.sql", Up: []string{"CREATE TABLE b (id INT);"}, Down: []string{"DROP TABLE b;"}},
+		{Version: 3, Name: "0003_c.sql", Up: []string{"CREATE TABLE c (id INT);"}, Down: []string{"DROP TABLE c;"}},
+	}), mock
+}
+
+// expectVersions expects the version table to exist, and hold [rows]
+func expectVersions(mock sqlmock.Sqlmock, rows *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM information_schema.TABLES").
+		WithArgs(migrate.VersionTable).
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(1))
+	mock.ExpectQuery("SELECT \\* FROM `goose_db_version` ORDER BY id DESC").
+		WillReturnRows(rows)
+}
+
+func versionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "version_id", "is_applied", "tstamp"})
+}
+
+func TestRunner_Status(t *testing.T) {
+
+	runner, mock := newRunner(t)
+
+	// Version 2 was applied, rolled back by an older goose that recorded
+	// it, then applied again. Version 3 was rolled back.
+	expectVersions(mock, versionRows().
+		AddRow(6, 3, false, applied).
+		AddRow(5, 3, true, applied).
+		AddRow(4, 2, true, applied).
+		AddRow(3, 2, false, applied).
+		AddRow(2, 1, true, applied).
+		AddRow(1, 0, true, applied))
+
+	statuses, err := runner.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+
+	assert.True(t, statuses[0].Applied)
+	assert.Equal(t, applied, *statuses[0].AppliedAt)
+	assert.True(t, statuses[1].Applied)
+	assert.False(t, statuses[2].Applied)
+	assert.Nil(t, statuses[2].AppliedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_Up(t *testing.T) {
+
+	runner, mock := newRunner(t)
+
+	expectVersions(mock, versionRows().
+		AddRow(2, 1, true, applied).
+		AddRow(1, 0, true, applied))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE b").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `goose_db_version` \\(`version_id`,`is_applied`\\)").
+		WithArgs(int64(2), true).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	done, err := runner.Up(2)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, int64(2), done[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_UpFails(t *testing.T) {
+
+	runner, mock := newRunner(t)
+
+	expectVersions(mock, versionRows().AddRow(1, 0, true, applied))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE a").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	done, err := runner.Up(0)
+	assert.ErrorContains(t, err, "0001_a.sql")
+	assert.Empty(t, done)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_Down(t *testing.T) {
+
+	runner, mock := newRunner(t)
+
+	expectVersions(mock, versionRows().
+		AddRow(4, 3, true, applied).
+		AddRow(3, 2, true, applied).
+		AddRow(2, 1, true, applied).
+		AddRow(1, 0, true, applied))
+
+	for _, version := range []int64{3, 2} {
+		mock.ExpectBegin()
+		mock.ExpectExec("DROP TABLE").WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("DELETE FROM `goose_db_version` WHERE version_id = \\?").
+			WithArgs(version).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+
+	done, err := runner.Down(2)
+	require.NoError(t, err)
+	require.Len(t, done, 2)
+	assert.Equal(t, int64(3), done[0].Version)
+	assert.Equal(t, int64(2), done[1].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRunner_CreatesVersionTable(t *testing.T) {
+
+	runner, mock := newRunner(t)
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM information_schema.TABLES").
+		WillReturnRows(sqlmock.NewRows([]string{"COUNT(*)"}).AddRow(0))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE goose_db_version").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `goose_db_version`").
+		WithArgs(int64(0), true).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT \\* FROM `goose_db_version`").
+		WillReturnRows(versionRows().AddRow(1, 0, true, applied))
+
+	statuses, err := runner.Status()
+	require.NoError(t, err)
+	assert.Len(t, statuses, 3)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
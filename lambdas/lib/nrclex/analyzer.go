@@ -0,0 +1,163 @@
+package nrclex
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+	"github.com/kmesiab/equilibria/lambdas/lib/vader"
+)
+
+const (
+	AnalyzerAPI      = "api"
+	AnalyzerLocal    = "local"
+	AnalyzerFallback = "fallback"
+
+	// LexiconEmbedded uses the lexicons compiled into the binary.
+	LexiconEmbedded = "embedded"
+)
+
+var (
+	defaultLexicon Lexicon
+	defaultOnce    sync.Once
+)
+
+// Analyzer scores the emotions and sentiment of text.
+type Analyzer interface {
+	AnalyzeText(text string) (*APIResponse, error)
+}
+
+// New returns the Analyzer configured by NRCLEX_ANALYZER.
+func New(cfg *config.Config, client utils.SimpleHttpClientInterface) (Analyzer, error) {
+
+	remote := NewNRCLexClient(client)
+	remote.BaseURL = cfg.NRCLexURL
+
+	switch cfg.NRCLexAnalyzer {
+	case AnalyzerAPI:
+		return remote, nil
+	case AnalyzerLocal, AnalyzerFallback:
+
+		local, err := NewLocalAnalyzerFromFiles(cfg.NRCLexLexiconPath, cfg.VaderLexiconPath)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.NRCLexAnalyzer == AnalyzerLocal {
+			return local, nil
+		}
+
+		return &FallbackAnalyzer{Primary: remote, Fallback: local}, nil
+	}
+
+	return nil, fmt.Errorf("unknown nrclex analyzer: %s", cfg.NRCLexAnalyzer)
+}
+
+// LocalAnalyzer scores text without calling out to the NRCLex API, using
+// an NRC emotion lexicon and VADER.
+type LocalAnalyzer struct {
+	Lexicon   Lexicon
+	Sentiment *vader.Analyzer
+}
+
+// NewLocalAnalyzer returns a LocalAnalyzer using the embedded lexicons.
+func NewLocalAnalyzer() *LocalAnalyzer {
+
+	defaultOnce.Do(func() {
+
+		var err error
+
+		if defaultLexicon, err = LoadLexicon(strings.NewReader(embeddedLexicon)); err != nil {
+			panic(fmt.Sprintf("error loading embedded nrc lexicon: %s", err))
+		}
+	})
+
+	return &LocalAnalyzer{
+		Lexicon:   defaultLexicon,
+		Sentiment: vader.Default(),
+	}
+}
+
+// NewLocalAnalyzerFromFiles returns a LocalAnalyzer using the lexicons at
+// [nrcPath] and [vaderPath], either of which may be LexiconEmbedded.
+func NewLocalAnalyzerFromFiles(nrcPath, vaderPath string) (*LocalAnalyzer, error) {
+
+	analyzer := NewLocalAnalyzer()
+
+	if nrcPath != LexiconEmbedded {
+
+		file, err := os.Open(nrcPath)
+
+		if err != nil {
+			return nil, fmt.Errorf("error opening nrc lexicon: %w", err)
+		}
+
+		defer func() { _ = file.Close() }()
+
+		if analyzer.Lexicon, err = LoadLexicon(file); err != nil {
+			return nil, fmt.Errorf("error loading nrc lexicon %s: %w", nrcPath, err)
+		}
+	}
+
+	if vaderPath != LexiconEmbedded {
+
+		file, err := os.Open(vaderPath)
+
+		if err != nil {
+			return nil, fmt.Errorf("error opening vader lexicon: %w", err)
+		}
+
+		defer func() { _ = file.Close() }()
+
+		lexicon, err := vader.LoadLexicon(file)
+
+		if err != nil {
+			return nil, fmt.Errorf("error loading vader lexicon %s: %w", vaderPath, err)
+		}
+
+		analyzer.Sentiment = vader.NewAnalyzer(lexicon)
+	}
+
+	return analyzer, nil
+}
+
+func (a *LocalAnalyzer) AnalyzeText(text string) (*APIResponse, error) {
+
+	sentiment := a.Sentiment.PolarityScores(text)
+
+	return &APIResponse{
+		Text:         text,
+		EmotionScore: a.Lexicon.AffectFrequencies(text),
+		VaderEmotionScore: VaderEmotionScore{
+			Compound: sentiment.Compound,
+			Neg:      sentiment.Neg,
+			Neu:      sentiment.Neu,
+			Pos:      sentiment.Pos,
+		},
+	}, nil
+}
+
+// FallbackAnalyzer uses the Fallback analyzer when the Primary one fails,
+// so emotions are still scored when the NRCLex API is down.
+type FallbackAnalyzer struct {
+	Primary  Analyzer
+	Fallback Analyzer
+}
+
+func (a *FallbackAnalyzer) AnalyzeText(text string) (*APIResponse, error) {
+
+	response, err := a.Primary.AnalyzeText(text)
+
+	if err == nil {
+		return response, nil
+	}
+
+	log.New("Error analyzing text, falling back").AddError(err).Warn()
+
+	return a.Fallback.AnalyzeText(text)
+}
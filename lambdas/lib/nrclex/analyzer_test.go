@@ -0,0 +1,130 @@
+package nrclex
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+type stubAnalyzer struct {
+	response *APIResponse
+	err      error
+}
+
+func (s *stubAnalyzer) AnalyzeText(_ string) (*APIResponse, error) {
+	return s.response, s.err
+}
+
+func TestNew(t *testing.T) {
+	cfg := &config.Config{
+		NRCLexAnalyzer:    AnalyzerAPI,
+		NRCLexURL:         "http://localhost:5000/sentiment",
+		NRCLexLexiconPath: LexiconEmbedded,
+		VaderLexiconPath:  LexiconEmbedded,
+	}
+
+	analyzer, err := New(cfg, nil)
+	require.NoError(t, err)
+	require.IsType(t, &NRCLexClient{}, analyzer)
+	assert.Equal(t, cfg.NRCLexURL, analyzer.(*NRCLexClient).BaseURL)
+
+	cfg.NRCLexAnalyzer = AnalyzerLocal
+	analyzer, err = New(cfg, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &LocalAnalyzer{}, analyzer)
+
+	cfg.NRCLexAnalyzer = AnalyzerFallback
+	analyzer, err = New(cfg, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &FallbackAnalyzer{}, analyzer)
+
+	cfg.NRCLexLexiconPath = filepath.Join(t.TempDir(), "missing.txt")
+	_, err = New(cfg, nil)
+	assert.Error(t, err)
+
+	cfg.NRCLexAnalyzer = "tealeaves"
+	_, err = New(cfg, nil)
+	assert.Error(t, err)
+}
+
+func TestLocalAnalyzer_AnalyzeText(t *testing.T) {
+
+	response, err := NewLocalAnalyzer().AnalyzeText("I'm so happy, but I miss my friend")
+
+	require.NoError(t, err)
+	assert.Equal(t, "I'm so happy, but I miss my friend", response.Text)
+	assert.Greater(t, response.EmotionScore.Joy, 0.0)
+	assert.Greater(t, response.EmotionScore.Sadness, 0.0)
+	assert.Zero(t, response.EmotionScore.Anger)
+	assert.Greater(t, response.VaderEmotionScore.Compound, 0.0)
+
+	// Frequencies are a share of all the associations found
+	scores := response.EmotionScore
+	total := scores.Anger + scores.Anticipation + scores.Disgust + scores.Fear + scores.Trust +
+		scores.Joy + scores.Negative + scores.Positive + scores.Sadness + scores.Surprise
+	assert.InDelta(t, 1.0, total, 1e-9)
+
+	response, err = NewLocalAnalyzer().AnalyzeText("Scheduled for Tuesday")
+	require.NoError(t, err)
+	assert.Equal(t, EmotionScores{}, response.EmotionScore)
+	assert.Equal(t, 1.0, response.VaderEmotionScore.Neu)
+}
+
+func TestNewLocalAnalyzerFromFiles(t *testing.T) {
+
+	dir := t.TempDir()
+	nrcPath := filepath.Join(dir, "nrc.txt")
+	vaderPath := filepath.Join(dir, "vader.txt")
+
+	require.NoError(t, os.WriteFile(nrcPath, []byte("zorp\tjoy\t1\nzorp\tfear\t0\n"), 0o644))
+	require.NoError(t, os.WriteFile(vaderPath, []byte("zorp\t2.0\n"), 0o644))
+
+	analyzer, err := NewLocalAnalyzerFromFiles(nrcPath, vaderPath)
+	require.NoError(t, err)
+
+	response, err := analyzer.AnalyzeText("zorp")
+	require.NoError(t, err)
+	assert.Equal(t, EmotionScores{Joy: 1}, response.EmotionScore)
+	assert.Greater(t, response.VaderEmotionScore.Compound, 0.0)
+}
+
+func TestLoadLexicon(t *testing.T) {
+
+	lexicon, err := LoadLexicon(strings.NewReader(embeddedLexicon))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"joy", "positive"}, lexicon["love"])
+
+	_, err = LoadLexicon(strings.NewReader("love\tjoy\n"))
+	assert.Error(t, err)
+
+	_, err = LoadLexicon(strings.NewReader("love\tbliss\t1\n"))
+	assert.Error(t, err)
+}
+
+func TestFallbackAnalyzer_AnalyzeText(t *testing.T) {
+
+	remote := &APIResponse{Text: "remote"}
+	local := &APIResponse{Text: "local"}
+
+	analyzer := &FallbackAnalyzer{
+		Primary:  &stubAnalyzer{response: remote},
+		Fallback: &stubAnalyzer{response: local},
+	}
+
+	response, err := analyzer.AnalyzeText("hello")
+	require.NoError(t, err)
+	assert.Same(t, remote, response)
+
+	analyzer.Primary = &stubAnalyzer{err: fmt.Errorf("langtool is down")}
+
+	response, err = analyzer.AnalyzeText("hello")
+	require.NoError(t, err)
+	assert.Same(t, local, response)
+}
@@ -0,0 +1,143 @@
+package nrclex
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// embeddedLexicon is a small lexicon in the format of the NRC Word-Emotion
+// Association Lexicon, covering words people commonly use to describe how
+// they feel. The full lexicon is only free for research and non-commercial
+// use, so it isn't embedded. A licensed copy can be loaded with LoadLexicon.
+//
+//go:embed lexicon/nrc_emotions.txt
+var embeddedLexicon string
+
+// Lexicon maps lowercase words to the emotions they are associated with.
+type Lexicon map[string][]string
+
+// LoadLexicon reads a lexicon in the format of
+// NRC-Emotion-Lexicon-Wordlevel-v0.92.txt: a tab separated word, emotion
+// and association (0 or 1) per line.
+func LoadLexicon(r io.Reader) (Lexicon, error) {
+
+	lexicon := Lexicon{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("expected 3 fields on line %d, got %d", line, len(fields))
+		}
+
+		if fields[2] != "1" {
+			continue
+		}
+
+		if _, ok := (&EmotionScores{}).field(fields[1]); !ok {
+			return nil, fmt.Errorf("unknown emotion %q on line %d", fields[1], line)
+		}
+
+		word := strings.ToLower(fields[0])
+		lexicon[word] = append(lexicon[word], fields[1])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lexicon, nil
+}
+
+// AffectFrequencies returns how often each emotion is associated with the
+// words in [text], as a fraction of all the associations found, like
+// NRCLex's affect_frequencies.
+func (l Lexicon) AffectFrequencies(text string) EmotionScores {
+
+	scores := EmotionScores{}
+	total := 0.0
+
+	for _, word := range words(text) {
+
+		emotions, ok := l[word]
+
+		// Try the singular, since the lexicon has no plurals
+		if !ok && strings.HasSuffix(word, "s") {
+			emotions = l[strings.TrimSuffix(word, "s")]
+		}
+
+		for _, emotion := range emotions {
+			if score, ok := scores.field(emotion); ok {
+				*score++
+				total++
+			}
+		}
+	}
+
+	if total == 0 {
+		return scores
+	}
+
+	for _, emotion := range emotionNames {
+		score, _ := scores.field(emotion)
+		*score /= total
+	}
+
+	return scores
+}
+
+var emotionNames = []string{
+	"anger", "anticipation", "disgust", "fear", "trust",
+	"joy", "negative", "positive", "sadness", "surprise",
+}
+
+func (e *EmotionScores) field(emotion string) (*float64, bool) {
+
+	switch emotion {
+	case "anger":
+		return &e.Anger, true
+	case "anticipation":
+		return &e.Anticipation, true
+	case "disgust":
+		return &e.Disgust, true
+	case "fear":
+		return &e.Fear, true
+	case "trust":
+		return &e.Trust, true
+	case "joy":
+		return &e.Joy, true
+	case "negative":
+		return &e.Negative, true
+	case "positive":
+		return &e.Positive, true
+	case "sadness":
+		return &e.Sadness, true
+	case "surprise":
+		return &e.Surprise, true
+	}
+
+	return nil, false
+}
+
+// words splits [text] into lowercase words, keeping apostrophes so
+// contractions stay whole.
+func words(text string) []string {
+
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+}
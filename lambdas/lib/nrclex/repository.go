@@ -1,6 +1,8 @@
 package nrclex
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 
 	"github.com/kmesiab/equilibria/lambdas/models"
@@ -83,3 +85,33 @@ func (r *Repository) FindByUserID(userID int64, limit int) ([]models.NrcLex, err
 	}
 	return nrcLexes, nil
 }
+
+// FindByUserIDBetween finds the NrcLex entries for a user_id created
+// between [from] and [to], oldest first.
+func (r *Repository) FindByUserIDBetween(userID int64, from, to time.Time) ([]models.NrcLex, error) {
+	var nrcLexes []models.NrcLex
+	result := r.DB.
+		Where("user_id = ? AND created_at >= ? AND created_at < ? AND deleted_at IS NULL", userID, from.UTC(), to.UTC()).
+		Order("created_at").
+		Find(&nrcLexes)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return nrcLexes, nil
+}
+
+// FindUnscoredMessages finds up to [limit] messages created since [since]
+// that have no NrcLex entry, oldest first.
+func (r *Repository) FindUnscoredMessages(since time.Time, limit int) ([]models.Message, error) {
+	var messages []models.Message
+	result := r.DB.
+		Joins("LEFT JOIN nrclex ON nrclex.message_id = messages.id AND nrclex.deleted_at IS NULL").
+		Where("nrclex.id IS NULL AND messages.created_at >= ? AND messages.body <> ''", since.UTC()).
+		Order("messages.id").
+		Limit(limit).
+		Find(&messages)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return messages, nil
+}
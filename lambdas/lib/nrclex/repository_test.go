@@ -342,3 +342,24 @@ func TestRepository_Create_DuplicateRecord(t *testing.T) {
 	err = mock.ExpectationsWereMet()
 	assert.NoError(t, err)
 }
+
+func TestRepository_FindByUserIDBetween(t *testing.T) {
+	db, mock, err := setupMockDB()
+	require.NoError(t, err)
+	repo := NewRepository(db)
+
+	from := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	mock.ExpectQuery("SELECT \\* FROM `nrclex` WHERE user_id = \\? AND created_at >= \\? AND created_at < \\? .* ORDER BY created_at").
+		WithArgs(2, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "vader_compound"}).
+			AddRow(1, 2, 0.5).
+			AddRow(2, 2, -0.2))
+
+	result, err := repo.FindByUserIDBetween(2, from, to)
+
+	require.NoError(t, err)
+	assert.Len(t, result, 2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,74 @@
+// Package prompts keeps the system prompts as named, versioned
+// text/templates, and decides which version each user sees.
+//
+// The templates embedded under templates/<name>/<version>.tmpl are the
+// defaults, and every user gets the newest version of each. Rows in the
+// prompt_templates table add versions, or reweight embedded ones, to run
+// an A/B test. Users are split between the active versions of a prompt in
+// proportion to their weights, and keep their version until it's retired.
+package prompts
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+const (
+	// Reply is the system prompt for replies to the user's messages.
+	Reply = "reply"
+
+	// Nudge is the system prompt for checking in on a quiet user.
+	Nudge = "nudge"
+)
+
+// Prompt is one version of a named prompt template.
+type Prompt struct {
+	Name    string
+	Version string
+
+	// Weight is the prompt's share of users, relative to the other
+	// versions in a test.
+	Weight int
+
+	template *template.Template
+}
+
+// Parse creates version [version] of the prompt [name] from the
+// text/template [body].
+func Parse(name, version, body string) (*Prompt, error) {
+
+	tmpl, err := template.New(name + "@" + version).Option("missingkey=error").Parse(body)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing prompt %s@%s: %w", name, version, err)
+	}
+
+	return &Prompt{Name: name, Version: version, template: tmpl}, nil
+}
+
+// ID identifies the prompt and version, as recorded on messages.
+func (p *Prompt) ID() string {
+	return p.Name + "@" + p.Version
+}
+
+// Execute fills in the prompt with [data].
+func (p *Prompt) Execute(data any) (string, error) {
+
+	var buf bytes.Buffer
+
+	if err := p.template.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error executing prompt %s: %w", p.ID(), err)
+	}
+
+	return buf.String(), nil
+}
+
+// withWeight returns a copy of the prompt with a different weight.
+func (p *Prompt) withWeight(weight int) *Prompt {
+
+	prompt := *p
+	prompt.Weight = weight
+
+	return &prompt
+}
@@ -0,0 +1,167 @@
+package prompts
+
+import (
+	"embed"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const templateExtension = ".tmpl"
+
+//go:embed templates
+var embedded embed.FS
+
+var (
+	defaultRegistry *Registry
+	defaultOnce     sync.Once
+)
+
+// Registry holds the versions of each prompt.
+type Registry struct {
+	prompts map[string]map[string]*Prompt
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{prompts: map[string]map[string]*Prompt{}}
+}
+
+// Default returns the registry of embedded templates. The templates are
+// compiled in, so one that doesn't parse is a programming error.
+func Default() *Registry {
+
+	defaultOnce.Do(func() {
+
+		defaultRegistry = NewRegistry()
+
+		if err := defaultRegistry.LoadFS(embedded, "templates"); err != nil {
+			panic(err)
+		}
+	})
+
+	return defaultRegistry
+}
+
+// LoadFS registers every <name>/<version>.tmpl template under [root].
+func (r *Registry) LoadFS(fsys fs.FS, root string) error {
+
+	return fs.WalkDir(fsys, root, func(filePath string, entry fs.DirEntry, err error) error {
+
+		if err != nil || entry.IsDir() || path.Ext(filePath) != templateExtension {
+			return err
+		}
+
+		body, err := fs.ReadFile(fsys, filePath)
+
+		if err != nil {
+			return err
+		}
+
+		name := path.Base(path.Dir(filePath))
+		version := strings.TrimSuffix(path.Base(filePath), templateExtension)
+
+		prompt, err := Parse(name, version, string(body))
+
+		if err != nil {
+			return err
+		}
+
+		r.Register(prompt)
+
+		return nil
+	})
+}
+
+// Register adds [prompt], replacing any prompt with the same name and version.
+func (r *Registry) Register(prompt *Prompt) {
+
+	if r.prompts[prompt.Name] == nil {
+		r.prompts[prompt.Name] = map[string]*Prompt{}
+	}
+
+	r.prompts[prompt.Name][prompt.Version] = prompt
+}
+
+// Get returns version [version] of the prompt [name], or nil.
+func (r *Registry) Get(name, version string) *Prompt {
+	return r.prompts[name][version]
+}
+
+// Versions returns the versions of the prompt [name], oldest first.
+func (r *Registry) Versions(name string) []string {
+
+	versions := make([]string, 0, len(r.prompts[name]))
+
+	for version := range r.prompts[name] {
+		versions = append(versions, version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versionLess(versions[i], versions[j])
+	})
+
+	return versions
+}
+
+// Latest returns the newest version of the prompt [name], or nil.
+func (r *Registry) Latest(name string) *Prompt {
+
+	versions := r.Versions(name)
+
+	if len(versions) == 0 {
+		return nil
+	}
+
+	return r.Get(name, versions[len(versions)-1])
+}
+
+// Choose picks one of [variants] for [userID], in proportion to their
+// weights. The same user always gets the same variant of the same set.
+func Choose(userID int64, variants []*Prompt) (*Prompt, error) {
+
+	total := 0
+
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+
+	if total <= 0 {
+		return nil, fmt.Errorf("no weighted variants to choose from")
+	}
+
+	hash := fnv.New32a()
+	_, _ = fmt.Fprintf(hash, "%s:%d", variants[0].Name, userID)
+
+	pick := int(hash.Sum32() % uint32(total))
+
+	for _, variant := range variants {
+
+		if pick < variant.Weight {
+			return variant, nil
+		}
+
+		pick -= variant.Weight
+	}
+
+	return variants[len(variants)-1], nil
+}
+
+// versionLess orders versions like v2 before v10, falling back to
+// comparing them as strings.
+func versionLess(a, b string) bool {
+
+	na, errA := strconv.Atoi(strings.TrimPrefix(a, "v"))
+	nb, errB := strconv.Atoi(strings.TrimPrefix(b, "v"))
+
+	if errA == nil && errB == nil {
+		return na < nb
+	}
+
+	return a < b
+}
@@ -0,0 +1,95 @@
+package prompts_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+)
+
+func TestDefault_EmbedsEveryPrompt(t *testing.T) {
+
+	for _, name := range []string{prompts.Reply, prompts.Nudge} {
+		assert.NotNil(t, prompts.Default().Latest(name), "%s should have an embedded template", name)
+	}
+}
+
+func TestRegistry_LoadFS(t *testing.T) {
+
+	registry := prompts.NewRegistry()
+
+	err := registry.LoadFS(fstest.MapFS{
+		"templates/greeting/v1.tmpl":  {Data: []byte("Hi {{.Name}}")},
+		"templates/greeting/v2.tmpl":  {Data: []byte("Hello {{.Name}}")},
+		"templates/greeting/v10.tmpl": {Data: []byte("Hey {{.Name}}")},
+		"templates/greeting/notes.md": {Data: []byte("not a template")},
+	}, "templates")
+
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"v1", "v2", "v10"}, registry.Versions("greeting"))
+	assert.Equal(t, "v10", registry.Latest("greeting").Version)
+	assert.Nil(t, registry.Latest("farewell"))
+
+	text, err := registry.Get("greeting", "v2").Execute(map[string]string{"Name": "Kevin"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hello Kevin", text)
+}
+
+func TestPrompt_Execute_MissingField(t *testing.T) {
+
+	prompt, err := prompts.Parse("greeting", "v1", "Hi {{.Name}}")
+	require.NoError(t, err)
+
+	_, err = prompt.Execute(map[string]string{})
+	assert.Error(t, err, "A missing field should be an error, not an empty string in the prompt")
+}
+
+func TestParse_InvalidTemplate(t *testing.T) {
+
+	_, err := prompts.Parse("greeting", "v1", "Hi {{.Name")
+	assert.Error(t, err)
+}
+
+func TestPrompt_ID(t *testing.T) {
+
+	prompt, err := prompts.Parse("greeting", "v1", "Hi")
+	require.NoError(t, err)
+
+	assert.Equal(t, "greeting@v1", prompt.ID())
+}
+
+func TestChoose(t *testing.T) {
+
+	a, _ := prompts.Parse("greeting", "a", "A")
+	b, _ := prompts.Parse("greeting", "b", "B")
+	a.Weight, b.Weight = 1, 3
+
+	counts := map[string]int{}
+
+	for userID := int64(1); userID <= 4000; userID++ {
+
+		prompt, err := prompts.Choose(userID, []*prompts.Prompt{a, b})
+		require.NoError(t, err)
+
+		again, _ := prompts.Choose(userID, []*prompts.Prompt{a, b})
+		require.Equal(t, prompt, again, "A user should always get the same variant")
+
+		counts[prompt.Version]++
+	}
+
+	assert.InDelta(t, 1000, counts["a"], 150)
+	assert.InDelta(t, 3000, counts["b"], 150)
+}
+
+func TestChoose_NoWeights(t *testing.T) {
+
+	a, _ := prompts.Parse("greeting", "a", "A")
+
+	_, err := prompts.Choose(1, []*prompts.Prompt{a})
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package prompts
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository is a repository for prompt templates and assignments.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindActiveTemplates retrieves the active versions of the prompt [name].
+func (r *Repository) FindActiveTemplates(name string) ([]models.PromptTemplate, error) {
+
+	var templates []models.PromptTemplate
+
+	err := r.db.Where("name = ? AND active = ?", name, true).
+		Order("id").
+		Find(&templates).Error
+
+	return templates, err
+}
+
+// FindAssignment retrieves the version of the prompt [name] assigned to
+// [userID], or nil if they haven't been assigned one.
+func (r *Repository) FindAssignment(userID int64, name string) (*models.PromptAssignment, error) {
+
+	var assignment models.PromptAssignment
+
+	err := r.db.Where("user_id = ? AND prompt_name = ?", userID, name).
+		First(&assignment).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &assignment, nil
+}
+
+// SaveAssignment creates or updates an assignment.
+func (r *Repository) SaveAssignment(assignment *models.PromptAssignment) error {
+	return r.db.Save(assignment).Error
+}
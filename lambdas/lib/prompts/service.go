@@ -0,0 +1,111 @@
+package prompts
+
+import (
+	"fmt"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Service decides which version of a prompt each user sees.
+type Service struct {
+	registry *Registry
+	repo     *Repository
+}
+
+// NewService creates a service that chooses between the templates in
+// [registry] and the database behind [repo].
+func NewService(registry *Registry, repo *Repository) *Service {
+	return &Service{registry: registry, repo: repo}
+}
+
+// ForUser returns the version of the prompt [name] that [user] is
+// assigned. Users are only assigned a version while a test is running,
+// and are reassigned when their version is retired.
+func (s *Service) ForUser(user *models.User, name string) (*Prompt, error) {
+
+	variants, err := s.Variants(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a test, everyone gets the same prompt
+	if len(variants) == 1 {
+		return variants[0], nil
+	}
+
+	assignment, err := s.repo.FindAssignment(user.ID, name)
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding prompt assignment: %w", err)
+	}
+
+	if assignment != nil {
+		for _, variant := range variants {
+			if variant.Version == assignment.Version {
+				return variant, nil
+			}
+		}
+	} else {
+		assignment = &models.PromptAssignment{UserID: user.ID, PromptName: name}
+	}
+
+	prompt, err := Choose(user.ID, variants)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assignment.Version = prompt.Version
+
+	if err = s.repo.SaveAssignment(assignment); err != nil {
+		return nil, fmt.Errorf("error saving prompt assignment: %w", err)
+	}
+
+	return prompt, nil
+}
+
+// Variants returns the versions of the prompt [name] that users can be
+// assigned: the weighted, active templates in the database, or the
+// newest embedded template when there are none.
+func (s *Service) Variants(name string) ([]*Prompt, error) {
+
+	templates, err := s.repo.FindActiveTemplates(name)
+
+	if err != nil {
+		return nil, fmt.Errorf("error finding prompt templates: %w", err)
+	}
+
+	var variants []*Prompt
+
+	for _, t := range templates {
+
+		if t.Weight <= 0 {
+			continue
+		}
+
+		prompt := s.registry.Get(name, t.Version)
+
+		if t.Body != "" {
+			if prompt, err = Parse(name, t.Version, t.Body); err != nil {
+				return nil, err
+			}
+		}
+
+		if prompt == nil {
+			return nil, fmt.Errorf("prompt %s@%s has no body and no embedded template", name, t.Version)
+		}
+
+		variants = append(variants, prompt.withWeight(t.Weight))
+	}
+
+	if len(variants) > 0 {
+		return variants, nil
+	}
+
+	if latest := s.registry.Latest(name); latest != nil {
+		return []*Prompt{latest.withWeight(1)}, nil
+	}
+
+	return nil, fmt.Errorf("no prompt named %s", name)
+}
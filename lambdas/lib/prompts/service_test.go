@@ -0,0 +1,111 @@
+package prompts_test
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var templateColumns = []string{"id", "name", "version", "body", "weight", "active"}
+
+func TestService_ForUser_NoTest(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_templates`").
+		WithArgs(prompts.Reply, true).
+		WillReturnRows(sqlmock.NewRows(templateColumns))
+
+	svc := prompts.NewService(prompts.Default(), prompts.NewRepository(db))
+
+	prompt, err := svc.ForUser(&models.User{ID: 2}, prompts.Reply)
+
+	require.NoError(t, err)
+	assert.Equal(t, prompts.Default().Latest(prompts.Reply).ID(), prompt.ID(),
+		"Without a test, users should get the newest embedded prompt")
+	assert.NoError(t, mock.ExpectationsWereMet(), "Nobody should be assigned without a test")
+}
+
+func TestService_ForUser_KeepsAssignment(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_templates`").
+		WithArgs(prompts.Reply, true).
+		WillReturnRows(sqlmock.NewRows(templateColumns).
+			AddRow(1, prompts.Reply, "v1", "", 1, true).
+			AddRow(2, prompts.Reply, "v2", "Hi {{.Name}}", 1, true))
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_assignments`").
+		WithArgs(2, prompts.Reply, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "prompt_name", "version"}).
+			AddRow(1, 2, prompts.Reply, "v2"))
+
+	svc := prompts.NewService(prompts.Default(), prompts.NewRepository(db))
+
+	prompt, err := svc.ForUser(&models.User{ID: 2}, prompts.Reply)
+
+	require.NoError(t, err)
+	assert.Equal(t, "reply@v2", prompt.ID())
+
+	text, err := prompt.Execute(map[string]string{"Name": "Kevin"})
+
+	require.NoError(t, err)
+	assert.Equal(t, "Hi Kevin", text)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_ForUser_AssignsNewUser(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_templates`").
+		WithArgs(prompts.Reply, true).
+		WillReturnRows(sqlmock.NewRows(templateColumns).
+			AddRow(1, prompts.Reply, "v1", "", 1, true).
+			AddRow(2, prompts.Reply, "v2", "Hi {{.Name}}", 1, true).
+			AddRow(3, prompts.Reply, "v3", "Retired {{.Name}}", 0, true))
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_assignments`").
+		WithArgs(2, prompts.Reply, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "prompt_name", "version"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `prompt_assignments`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	svc := prompts.NewService(prompts.Default(), prompts.NewRepository(db))
+
+	prompt, err := svc.ForUser(&models.User{ID: 2}, prompts.Reply)
+
+	require.NoError(t, err)
+	assert.Contains(t, []string{"v1", "v2"}, prompt.Version, "Unweighted versions should not be assigned")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Variants_UnknownVersion(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	mock.ExpectQuery("SELECT \\* FROM `prompt_templates`").
+		WithArgs(prompts.Reply, true).
+		WillReturnRows(sqlmock.NewRows(templateColumns).
+			AddRow(1, prompts.Reply, "v99", "", 1, true))
+
+	svc := prompts.NewService(prompts.Default(), prompts.NewRepository(db))
+
+	_, err = svc.Variants(prompts.Reply)
+
+	assert.Error(t, err, "A version with no body must have an embedded template")
+}
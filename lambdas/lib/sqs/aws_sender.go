@@ -3,6 +3,8 @@ package sqs
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 
@@ -16,17 +18,32 @@ type AWSSender struct{}
 func (s *AWSSender) Send(queueURL string, message *models.Message) error {
 
 	jsonBody, err := json.Marshal(message)
-	bodyString := string(jsonBody)
 
 	if err != nil {
 		return err
 	}
 
+	messageID, err := s.SendBody(queueURL, string(jsonBody))
+
+	if err != nil {
+		return err
+	}
+
+	message.ReferenceID = &messageID
+
+	return nil
+
+}
+
+// SendBody transmits a raw message body to the SQS queue and returns
+// the ID SQS assigned to it.
+func (s *AWSSender) SendBody(queueURL, body string) (string, error) {
+
 	// Load AWS SDK configuration from the shared config file (~/.aws/config)
 	cfg, err := awsconfig.LoadDefaultConfig(context.TODO())
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	// Transmit to the SQS queue
@@ -34,15 +51,32 @@ func (s *AWSSender) Send(queueURL string, message *models.Message) error {
 
 	result, err := sqsClient.SendMessage(context.TODO(), &sqs.SendMessageInput{
 		QueueUrl:    &queueURL,
-		MessageBody: &bodyString,
+		MessageBody: &body,
 	})
 
 	if err != nil {
-		return err
+		return "", err
 	}
 
-	message.ReferenceID = result.MessageId
+	return *result.MessageId, nil
+}
 
-	return nil
+// QueueURLFromARN converts a queue ARN, such as
+// arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue, into
+// the queue URL the SQS API expects.
+func QueueURLFromARN(arn string) (string, error) {
+
+	parts := strings.Split(arn, ":")
+
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "sqs" {
+		return "", fmt.Errorf("invalid sqs queue arn: %q", arn)
+	}
+
+	region, accountID, name := parts[3], parts[4], parts[5]
+
+	if region == "" || accountID == "" || name == "" {
+		return "", fmt.Errorf("invalid sqs queue arn: %q", arn)
+	}
 
+	return fmt.Sprintf("https://sqs.%s.amazonaws.com/%s/%s", region, accountID, name), nil
 }
@@ -0,0 +1,27 @@
+package sqs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueueURLFromARN(t *testing.T) {
+	url, err := QueueURLFromARN("arn:aws:sqs:us-west-2:123456789012:sms-inbound-queue")
+
+	require.NoError(t, err)
+	assert.Equal(t, "https://sqs.us-west-2.amazonaws.com/123456789012/sms-inbound-queue", url)
+}
+
+func TestQueueURLFromARN_Invalid(t *testing.T) {
+	for _, arn := range []string{
+		"",
+		"sms-inbound-queue",
+		"arn:aws:sns:us-west-2:123456789012:sms-inbound-topic",
+		"arn:aws:sqs:us-west-2::sms-inbound-queue",
+	} {
+		_, err := QueueURLFromARN(arn)
+		assert.Error(t, err, arn)
+	}
+}
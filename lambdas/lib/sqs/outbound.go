@@ -0,0 +1,47 @@
+package sqs
+
+import (
+	"context"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Publisher sends a message to an SNS topic, in the trace of [ctx].
+type Publisher interface {
+	SendContext(ctx context.Context, topicARN string, message *models.Message) error
+}
+
+// OutboundTopic publishes the messages we send to users, so subscribers
+// like the emotions lambda can process them as they do inbound messages.
+// A nil OutboundTopic publishes nothing.
+type OutboundTopic struct {
+	Publisher Publisher
+	TopicARN  string
+}
+
+// NewOutboundTopic returns an OutboundTopic publishing to [topicARN], or
+// nil if it is empty.
+func NewOutboundTopic(topicARN string) (*OutboundTopic, error) {
+
+	if topicARN == "" {
+		return nil, nil
+	}
+
+	sender, err := NewSNSSender()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboundTopic{Publisher: sender, TopicARN: topicARN}, nil
+}
+
+// Publish sends [message] to the topic, in the trace of [ctx].
+func (t *OutboundTopic) Publish(ctx context.Context, message *models.Message) error {
+
+	if t == nil {
+		return nil
+	}
+
+	return t.Publisher.SendContext(ctx, t.TopicARN, message)
+}
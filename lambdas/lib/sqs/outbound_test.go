@@ -0,0 +1,41 @@
+package sqs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type recordingPublisher struct {
+	topics   []string
+	messages []*models.Message
+}
+
+func (p *recordingPublisher) SendContext(_ context.Context, topicARN string, message *models.Message) error {
+	p.topics = append(p.topics, topicARN)
+	p.messages = append(p.messages, message)
+
+	return nil
+}
+
+func TestOutboundTopic_Publish(t *testing.T) {
+	publisher := &recordingPublisher{}
+	topic := &OutboundTopic{Publisher: publisher, TopicARN: "arn:aws:sns:us-west-2:000000000000:sms-outbound-topic"}
+	message := &models.Message{ID: 1, Body: "hello"}
+
+	require.NoError(t, topic.Publish(context.Background(), message))
+	assert.Equal(t, []string{topic.TopicARN}, publisher.topics)
+	assert.Equal(t, []*models.Message{message}, publisher.messages)
+}
+
+func TestNewOutboundTopic_None(t *testing.T) {
+	topic, err := NewOutboundTopic("")
+
+	require.NoError(t, err)
+	assert.Nil(t, topic)
+	assert.NoError(t, topic.Publish(context.Background(), &models.Message{}), "A nil topic should publish nothing")
+}
@@ -7,8 +7,10 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
 
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
@@ -26,22 +28,53 @@ func NewSNSSender() (*SNSSender, error) {
 }
 
 func (s *SNSSender) Send(topicARN string, message *models.Message) error {
+	return s.SendContext(tracing.Current(), topicARN, message)
+}
+
+// SendContext publishes [message] to [topicARN] in the trace of [ctx],
+// for work that isn't the current event's, like one of many nudges.
+func (s *SNSSender) SendContext(ctx context.Context, topicARN string, message *models.Message) error {
 	messageJSON, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
 
+	ctx, span := tracing.SpanFrom(ctx, "sns publish")
+	defer span.End()
+
 	input := &sns.PublishInput{
-		Message:  aws.String(string(messageJSON)),
-		TopicArn: aws.String(topicARN),
+		Message:           aws.String(string(messageJSON)),
+		TopicArn:          aws.String(topicARN),
+		MessageAttributes: MessageAttributes(tracing.Inject(ctx)),
 	}
 
-	_, err = s.client.Publish(context.TODO(), input)
+	_, err = s.client.Publish(ctx, input)
 
 	if err != nil {
+		span.RecordError(err)
 		log.New("Publish to SNS error: %s", err).Log()
 		return err
 	}
 
 	return nil
 }
+
+// MessageAttributes converts [headers], such as the traceparent, into
+// string message attributes for the subscribers to read.
+func MessageAttributes(headers map[string]string) map[string]types.MessageAttributeValue {
+
+	if len(headers) == 0 {
+		return nil
+	}
+
+	attributes := make(map[string]types.MessageAttributeValue, len(headers))
+
+	for name, value := range headers {
+		attributes[name] = types.MessageAttributeValue{
+			DataType:    aws.String("String"),
+			StringValue: aws.String(value),
+		}
+	}
+
+	return attributes
+}
@@ -14,4 +14,25 @@ type SQSEventRecord struct {
 	Signature        string    `json:"Signature"`        // The signature of the message.
 	SigningCertURL   string    `json:"SigningCertURL"`   // The URL to the certificate used to sign the message.
 	UnsubscribeURL   string    `json:"UnsubscribeURL"`   // The URL to unsubscribe from the topic.
+
+	// The attributes the message was published with, like its traceparent.
+	MessageAttributes map[string]SNSMessageAttribute `json:"MessageAttributes,omitempty"`
+}
+
+// SNSMessageAttribute is a message attribute, as SNS delivers it to SQS.
+type SNSMessageAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+// Attributes returns the values of the message attributes by name.
+func (r SQSEventRecord) Attributes() map[string]string {
+
+	attributes := make(map[string]string, len(r.MessageAttributes))
+
+	for name, attribute := range r.MessageAttributes {
+		attributes[name] = attribute.Value
+	}
+
+	return attributes
 }
@@ -174,10 +174,48 @@ func ExpectMockInsertMessage(mock *sqlmock.Sqlmock) {
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
 			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+		).WillReturnResult(GenerateMockLastAffectedRow())
+	(*mock).ExpectCommit()
+}
+
+// ExpectMockInsertInboundMessage expects a text from a user, which records
+// the segments Twilio says it arrived in.
+func ExpectMockInsertInboundMessage(mock *sqlmock.Sqlmock, numSegments int, segmentEncoding string) {
+	(*mock).ExpectBegin()
+	(*mock).ExpectExec("INSERT INTO `messages` .*`num_segments`,`segment_encoding`").
+		WithArgs(
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			sqlmock.AnyArg(),
+			numSegments,
+			segmentEncoding,
 		).WillReturnResult(GenerateMockLastAffectedRow())
 	(*mock).ExpectCommit()
 }
 
+func ExpectMockInsertIdempotencyKey(mock *sqlmock.Sqlmock) {
+	(*mock).ExpectBegin()
+	(*mock).ExpectExec("INSERT INTO `idempotency_keys`").WithArgs(
+		sqlmock.AnyArg(), sqlmock.AnyArg(), models.IdempotencyStatusPending, sqlmock.AnyArg(),
+	).WillReturnResult(GenerateMockLastAffectedRow())
+	(*mock).ExpectCommit()
+}
+
+func ExpectMockCompleteIdempotencyKey(mock *sqlmock.Sqlmock, key string) {
+	(*mock).ExpectBegin()
+	(*mock).ExpectExec("UPDATE `idempotency_keys` SET `expires_at`=\\?,`status`=\\? WHERE idempotency_key = \\?").
+		WithArgs(nil, models.IdempotencyStatusDone, key).
+		WillReturnResult(GenerateMockLastAffectedRow())
+	(*mock).ExpectCommit()
+}
+
 func ExpectMockInsertConversation(mock *sqlmock.Sqlmock) {
 	(*mock).ExpectBegin()
 	(*mock).ExpectExec("INSERT INTO `conversations`").WithArgs(
@@ -382,4 +420,7 @@ func SetEnvVars() {
 	_ = os.Setenv("CHAT_MODEL_FREQUENCY_PENALTY", strconv.FormatFloat(float64(cfg.ChatModelFrequencyPenalty), 'f', -1, 64))
 	_ = os.Setenv("CHAT_MODEL_MAX_COMPLETION_TOKENS", strconv.Itoa(cfg.ChatModelMaxCompletionTokens))
 	_ = os.Setenv("CHAT_MODEL_NAME", cfg.ChatModelName)
+
+	// Load the new settings the next time the config is read
+	config.Reset()
 }
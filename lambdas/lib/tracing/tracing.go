@@ -0,0 +1,166 @@
+// Package tracing follows a text through the lambdas that handle it, from
+// receive_sms to status_sms, with OpenTelemetry. The trace is carried
+// between them in the W3C traceparent, as an SNS message attribute or a
+// column of the message, and its ID is added to every log entry.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+const (
+	// ExporterNone records traces, so their IDs reach the logs and the
+	// next lambda, without exporting the spans
+	ExporterNone = "none"
+
+	// ExporterStdout writes each span as JSON to stdout, for local runs
+	ExporterStdout = "stdout"
+
+	// TraceParent is the name of the W3C header carrying the trace
+	TraceParent = "traceparent"
+
+	instrumentationName = "github.com/kmesiab/equilibria/lambdas"
+)
+
+var (
+	propagator = propagation.TraceContext{}
+
+	// Lambdas handle one event at a time, so the event's context is kept
+	// here for the code that logs or sends messages while handling it,
+	// rather than threaded through every call. Work done concurrently
+	// within an event, like nudging each user, passes its own context to
+	// SpanFrom and ParentOf instead.
+	mu      sync.RWMutex
+	current = context.Background()
+)
+
+// Init installs the tracer provider for the lambda named [service],
+// exporting its spans with the configured exporter.
+func Init(cfg *config.Config, service string) error {
+
+	options := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(service))),
+	}
+
+	switch cfg.TraceExporter {
+	case ExporterNone:
+	case ExporterStdout:
+
+		exporter, err := stdouttrace.New()
+
+		if err != nil {
+			return fmt.Errorf("error creating stdout exporter: %w", err)
+		}
+
+		// Export as each span ends, since a lambda can be frozen
+		// before a batch is flushed
+		options = append(options, sdktrace.WithSyncer(exporter))
+	default:
+		return fmt.Errorf("unknown trace exporter: %s", cfg.TraceExporter)
+	}
+
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(options...))
+	otel.SetTextMapPropagator(propagator)
+
+	return nil
+}
+
+// Start starts the span for handling an event, continuing the trace in
+// [carrier] or starting a new one if it has none. The span becomes the
+// current one until the next event is started.
+func Start(name string, carrier propagation.TextMapCarrier) (context.Context, trace.Span) {
+
+	ctx := propagator.Extract(context.Background(), carrier)
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name)
+
+	mu.Lock()
+	current = ctx
+	mu.Unlock()
+
+	return ctx, span
+}
+
+// Span starts a span for a step in handling the current event, such as
+// calling an API.
+func Span(name string) (context.Context, trace.Span) {
+	return SpanFrom(Current(), name)
+}
+
+// SpanFrom starts a span for a step within [ctx], without changing the
+// current event.
+func SpanFrom(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name)
+}
+
+// Current returns the context of the event being handled.
+func Current() context.Context {
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return current
+}
+
+// TraceID returns the ID of the current event's trace, or an empty
+// string outside of one.
+func TraceID() string {
+
+	spanContext := trace.SpanContextFromContext(Current())
+
+	if !spanContext.IsValid() {
+		return ""
+	}
+
+	return spanContext.TraceID().String()
+}
+
+// Inject returns the headers that carry the trace in [ctx] on to the
+// next lambda, which are empty outside of a trace.
+func Inject(ctx context.Context) propagation.MapCarrier {
+
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	return carrier
+}
+
+// Parent returns the traceparent of the current event, to be saved with
+// the messages it creates, or nil outside of a trace.
+func Parent() *string {
+	return ParentOf(Current())
+}
+
+// ParentOf returns the traceparent of the span in [ctx], or nil outside
+// of a trace.
+func ParentOf(ctx context.Context) *string {
+
+	parent, ok := Inject(ctx)[TraceParent]
+
+	if !ok {
+		return nil
+	}
+
+	return &parent
+}
+
+// FromParent returns a carrier for a saved traceparent, which may be nil.
+func FromParent(parent *string) propagation.MapCarrier {
+
+	if parent == nil {
+		return propagation.MapCarrier{}
+	}
+
+	return propagation.MapCarrier{TraceParent: *parent}
+}
@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+)
+
+const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestStart_Continues(t *testing.T) {
+
+	require.NoError(t, Init(&config.Config{TraceExporter: ExporterNone}, "test"))
+
+	ctx, span := Start("send_sms", propagation.MapCarrier{TraceParent: traceParent})
+	defer span.End()
+
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", TraceID())
+	assert.Equal(t, ctx, Current())
+
+	// The next lambda gets the same trace, with this span as its parent
+	parent := Parent()
+	require.NotNil(t, parent)
+	assert.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-[0-9a-f]{16}-01$", *parent)
+	assert.NotEqual(t, traceParent, *parent)
+	assert.Equal(t, *parent, Inject(ctx)[TraceParent])
+}
+
+func TestStart_New(t *testing.T) {
+
+	require.NoError(t, Init(&config.Config{TraceExporter: ExporterNone}, "test"))
+
+	_, first := Start("receive_sms", propagation.MapCarrier{})
+	first.End()
+	firstID := TraceID()
+
+	_, second := Start("receive_sms", FromParent(nil))
+	second.End()
+
+	assert.Len(t, firstID, 32)
+	assert.NotEqual(t, firstID, TraceID(), "Each event without a trace starts its own")
+}
+
+func TestSpanFrom(t *testing.T) {
+
+	require.NoError(t, Init(&config.Config{TraceExporter: ExporterNone}, "test"))
+
+	ctx, span := Start("nudge_sms", propagation.MapCarrier{TraceParent: traceParent})
+	defer span.End()
+
+	first, firstSpan := SpanFrom(ctx, "nudge")
+	defer firstSpan.End()
+
+	second, secondSpan := SpanFrom(ctx, "nudge")
+	defer secondSpan.End()
+
+	// Each span is the parent of what it sends, in the same trace, and
+	// the event is still the current one
+	assert.Equal(t, ctx, Current())
+	assert.Regexp(t, "^00-4bf92f3577b34da6a3ce929d0e0e4736-", *ParentOf(first))
+	assert.NotEqual(t, *ParentOf(first), *ParentOf(second))
+	assert.NotEqual(t, *Parent(), *ParentOf(first))
+}
+
+func TestFromParent(t *testing.T) {
+
+	parent := traceParent
+
+	assert.Equal(t, propagation.MapCarrier{TraceParent: traceParent}, FromParent(&parent))
+	assert.Empty(t, FromParent(nil))
+}
+
+func TestInit_Unknown(t *testing.T) {
+	assert.Error(t, Init(&config.Config{TraceExporter: "jaeger"}, "test"))
+}
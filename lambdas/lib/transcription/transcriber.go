@@ -0,0 +1,67 @@
+package transcription
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	ProviderWhisper = "whisper"
+	ProviderNone    = "none"
+)
+
+// ErrUnsupportedFormat is returned for audio the speech to text API can't
+// read, such as the AMR many carriers use for MMS voice memos. Callers
+// should ask the user to type out what they said instead.
+var ErrUnsupportedFormat = errors.New("unsupported audio format")
+
+// Transcriber converts recorded speech into text.
+type Transcriber interface {
+	Transcribe(audio []byte, contentType string) (string, error)
+}
+
+// New returns the Transcriber for [provider], or nil if transcription
+// is turned off. [baseURL] overrides the provider's API URL when set.
+func New(provider, apiKey, model, baseURL string) (Transcriber, error) {
+
+	switch provider {
+	case ProviderWhisper:
+		return &WhisperTranscriber{APIKey: apiKey, Model: model, BaseURL: baseURL}, nil
+	case ProviderNone, "":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown transcription provider: %s", provider)
+}
+
+// audioExtensions maps the audio formats we can transcribe to the file
+// extension the speech to text API uses to detect them.
+var audioExtensions = map[string]string{
+	"audio/mpeg":  "mp3",
+	"audio/mp3":   "mp3",
+	"audio/mp4":   "m4a",
+	"audio/m4a":   "m4a",
+	"audio/x-m4a": "m4a",
+	"audio/wav":   "wav",
+	"audio/x-wav": "wav",
+	"audio/webm":  "webm",
+	"audio/ogg":   "ogg",
+	"audio/flac":  "flac",
+}
+
+// FileName returns a file name with an extension matching [contentType],
+// or ErrUnsupportedFormat if the format can't be transcribed.
+func FileName(contentType string) (string, error) {
+
+	// Strip parameters such as "; codecs=opus"
+	mimeType := strings.TrimSpace(strings.Split(contentType, ";")[0])
+
+	ext, ok := audioExtensions[strings.ToLower(mimeType)]
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, contentType)
+	}
+
+	return "voice_memo." + ext, nil
+}
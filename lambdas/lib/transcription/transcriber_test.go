@@ -0,0 +1,83 @@
+package transcription
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	transcriber, err := New(ProviderWhisper, "key", "whisper-1", "")
+	require.NoError(t, err)
+	assert.IsType(t, &WhisperTranscriber{}, transcriber)
+
+	transcriber, err = New(ProviderNone, "key", "whisper-1", "")
+	require.NoError(t, err)
+	assert.Nil(t, transcriber, "Transcription should be turned off")
+
+	_, err = New("carrier-pigeon", "key", "whisper-1", "")
+	assert.Error(t, err)
+}
+
+func TestFileName(t *testing.T) {
+	name, err := FileName("audio/ogg; codecs=opus")
+	require.NoError(t, err)
+	assert.Equal(t, "voice_memo.ogg", name)
+
+	name, err = FileName("audio/mpeg")
+	require.NoError(t, err)
+	assert.Equal(t, "voice_memo.mp3", name)
+
+	_, err = FileName("audio/amr")
+	assert.ErrorIs(t, err, ErrUnsupportedFormat, "AMR can't be transcribed")
+}
+
+func TestWhisperTranscriber_Transcribe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/audio/transcriptions", r.URL.Path)
+
+		file, header, err := r.FormFile("file")
+		require.NoError(t, err)
+		defer func() { _ = file.Close() }()
+
+		assert.Equal(t, "voice_memo.mp3", header.Filename)
+		assert.Equal(t, "whisper-1", r.FormValue("model"))
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"text":" I had a really long day. "}`))
+	}))
+	defer server.Close()
+
+	transcriber := &WhisperTranscriber{APIKey: "key", BaseURL: server.URL}
+
+	text, err := transcriber.Transcribe([]byte("mp3"), "audio/mpeg")
+
+	require.NoError(t, err)
+	assert.Equal(t, "I had a really long day.", text)
+}
+
+func TestWhisperTranscriber_Transcribe_Timeout(t *testing.T) {
+	done := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	transcriber := &WhisperTranscriber{APIKey: "key", BaseURL: server.URL, Timeout: 50 * time.Millisecond}
+
+	started := time.Now()
+	_, err := transcriber.Transcribe([]byte("mp3"), "audio/mpeg")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second, "A slow transcription should give up")
+}
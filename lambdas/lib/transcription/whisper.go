@@ -0,0 +1,70 @@
+package transcription
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// DefaultTimeout bounds a transcription, so a slow request can't use up
+// the time the lambda has left to reply.
+const DefaultTimeout = 15 * time.Second
+
+// WhisperTranscriber transcribes audio with OpenAI's Whisper API.
+type WhisperTranscriber struct {
+	APIKey string
+	Model  string
+
+	// BaseURL overrides the OpenAI API URL, for testing.
+	BaseURL string
+
+	// Timeout overrides DefaultTimeout.
+	Timeout time.Duration
+}
+
+func (w *WhisperTranscriber) Transcribe(audio []byte, contentType string) (string, error) {
+
+	fileName, err := FileName(contentType)
+
+	if err != nil {
+		return "", err
+	}
+
+	clientConfig := openai.DefaultConfig(w.APIKey)
+
+	if w.BaseURL != "" {
+		clientConfig.BaseURL = w.BaseURL
+	}
+
+	model := w.Model
+
+	if model == "" {
+		model = openai.Whisper1
+	}
+
+	timeout := w.Timeout
+
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := openai.NewClientWithConfig(clientConfig)
+
+	resp, err := client.CreateTranscription(ctx, openai.AudioRequest{
+		Model:    model,
+		FilePath: fileName,
+		Reader:   bytes.NewReader(audio),
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(resp.Text), nil
+}
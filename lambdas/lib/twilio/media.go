@@ -0,0 +1,53 @@
+package twilio
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// ParseMedia extracts the attachments from an inbound MMS webhook. Twilio
+// sends one MediaUrl{N} and MediaContentType{N} pair for each of the
+// NumMedia attachments.
+// See: https://www.twilio.com/docs/messaging/guides/webhook-request#media-related-parameters
+func ParseMedia(request events.APIGatewayProxyRequest) ([]models.MessageMedia, error) {
+
+	values, err := url.ParseQuery(request.Body)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request body: %s", err)
+	}
+
+	numMedia := values.Get("NumMedia")
+
+	if numMedia == "" {
+		return nil, nil
+	}
+
+	count, err := strconv.Atoi(numMedia)
+
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("invalid NumMedia: %s", numMedia)
+	}
+
+	media := make([]models.MessageMedia, 0, count)
+
+	for i := 0; i < count; i++ {
+		mediaURL := values.Get(fmt.Sprintf("MediaUrl%d", i))
+
+		if mediaURL == "" {
+			return nil, fmt.Errorf("missing MediaUrl%d", i)
+		}
+
+		media = append(media, models.MessageMedia{
+			URL:         mediaURL,
+			ContentType: values.Get(fmt.Sprintf("MediaContentType%d", i)),
+		})
+	}
+
+	return media, nil
+}
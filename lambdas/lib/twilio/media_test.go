@@ -0,0 +1,47 @@
+package twilio
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMedia(t *testing.T) {
+	body := url.Values{
+		"Body":              []string{"look at this"},
+		"NumMedia":          []string{"2"},
+		"MediaUrl0":         []string{"https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME0"},
+		"MediaContentType0": []string{"image/jpeg"},
+		"MediaUrl1":         []string{"https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME1"},
+		"MediaContentType1": []string{"audio/amr"},
+	}.Encode()
+
+	media, err := ParseMedia(events.APIGatewayProxyRequest{Body: body})
+
+	require.NoError(t, err)
+	require.Len(t, media, 2)
+	assert.Equal(t, "image/jpeg", media[0].ContentType)
+	assert.True(t, media[0].IsImage())
+	assert.Equal(t, "https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME1", media[1].URL)
+	assert.False(t, media[1].IsImage())
+}
+
+func TestParseMedia_NoMedia(t *testing.T) {
+	body := url.Values{"Body": []string{"just text"}, "NumMedia": []string{"0"}}.Encode()
+
+	media, err := ParseMedia(events.APIGatewayProxyRequest{Body: body})
+
+	require.NoError(t, err)
+	assert.Empty(t, media)
+}
+
+func TestParseMedia_MissingURL(t *testing.T) {
+	body := url.Values{"NumMedia": []string{"1"}}.Encode()
+
+	_, err := ParseMedia(events.APIGatewayProxyRequest{Body: body})
+
+	assert.Error(t, err)
+}
@@ -6,10 +6,7 @@ import (
 	"strings"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/twilio/twilio-go"
 	"github.com/twilio/twilio-go/client"
-	api "github.com/twilio/twilio-go/rest/api/v2010"
-	verify "github.com/twilio/twilio-go/rest/verify/v2"
 
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
@@ -19,74 +16,6 @@ const (
 	WebhookContentType = "application/x-www-form-urlencoded"
 )
 
-func SendSMS(fromPhoneNumber, toPhoneNumber, message string) (*api.ApiV2010Message, error) {
-
-	twilioClient := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: config.Get().TwilioSID,
-		Password: config.Get().TwilioAuthToken,
-	})
-
-	params := &api.CreateMessageParams{
-		StatusCallback: &config.Get().TwilioStatusCallbackURL,
-	}
-	params.SetBody(message)
-	params.SetFrom(fromPhoneNumber)
-	params.SetTo(toPhoneNumber)
-
-	return twilioClient.Api.CreateMessage(params)
-
-}
-
-func SendOTP(phoneNumber string) (*verify.VerifyV2Verification, error) {
-
-	const (
-		channel = "sms"
-		locale  = "en"
-	)
-
-	twilioRestClient := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: config.Get().TwilioSID,
-		Password: config.Get().TwilioAuthToken,
-	})
-
-	params := &verify.CreateVerificationParams{
-		To: &phoneNumber,
-	}
-
-	params.SetChannel(channel)
-	params.SetLocale(locale)
-
-	response, err := twilioRestClient.VerifyV2.CreateVerification(config.Get().TwilioVerifyServiceSID, params)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return response, nil
-
-}
-
-func VerifyOTP(phoneNumber string, code string) (*verify.VerifyV2VerificationCheck, error) {
-
-	twilioClient := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: config.Get().TwilioSID,
-		Password: config.Get().TwilioAuthToken,
-	})
-
-	params := &verify.CreateVerificationCheckParams{}
-	params.SetTo(phoneNumber)
-	params.SetCode(code)
-
-	resp, err := twilioClient.VerifyV2.CreateVerificationCheck(config.Get().TwilioVerifyServiceSID, params)
-
-	if err != nil {
-		return nil, err
-	}
-
-	return resp, nil
-
-}
-
 func IsValidPhoneNumber(phoneNumber string) bool {
 	e164Regex := `^\+[1-9]\d{1,14}$`
 	re := regexp.MustCompile(e164Regex)
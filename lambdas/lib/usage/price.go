@@ -0,0 +1,44 @@
+// Package usage keeps a ledger of the tokens each request to the language
+// model uses and what it costs, rolls it up by user and month, and
+// throttles users who go over their monthly quota.
+package usage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Price returns the price of [model], which is the price with the longest
+// prefix of its name, or false if none match.
+func Price(prices []models.LLMPrice, model string) (models.LLMPrice, bool) {
+
+	var (
+		match models.LLMPrice
+		found bool
+	)
+
+	for _, price := range prices {
+		if strings.HasPrefix(model, price.Model) && len(price.Model) > len(match.Model) {
+			match, found = price, true
+		}
+	}
+
+	return match, found
+}
+
+// Cost returns what [promptTokens] and [completionTokens] cost at [price],
+// in US dollars.
+func Cost(price models.LLMPrice, promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*price.PromptCostPerMillion +
+		float64(completionTokens)*price.CompletionCostPerMillion) / 1_000_000
+}
+
+// Month returns the first day of the UTC month [t] is in.
+func Month(t time.Time) time.Time {
+
+	t = t.UTC()
+
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,45 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var prices = []models.LLMPrice{
+	{Model: "gpt-4o", PromptCostPerMillion: 5, CompletionCostPerMillion: 15},
+	{Model: "gpt-4o-mini", PromptCostPerMillion: 0.15, CompletionCostPerMillion: 0.6},
+	{Model: "text-embedding-3-large", PromptCostPerMillion: 0.13},
+}
+
+func TestPrice(t *testing.T) {
+
+	price, ok := Price(prices, "gpt-4o-2024-05-13")
+	assert.True(t, ok)
+	assert.Equal(t, "gpt-4o", price.Model)
+
+	price, ok = Price(prices, "gpt-4o-mini-2024-07-18")
+	assert.True(t, ok)
+	assert.Equal(t, "gpt-4o-mini", price.Model)
+
+	_, ok = Price(prices, "claude-3-opus")
+	assert.False(t, ok)
+}
+
+func TestCost(t *testing.T) {
+	assert.InDelta(t, 0.0125, Cost(prices[0], 1000, 500), 1e-9)
+	assert.InDelta(t, 0.00013, Cost(prices[2], 1000, 0), 1e-9)
+}
+
+func TestMonth(t *testing.T) {
+
+	pacific := time.FixedZone("PDT", -7*60*60)
+
+	assert.Equal(t,
+		time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC),
+		Month(time.Date(2024, time.June, 30, 20, 0, 0, 0, pacific)),
+	)
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Repository stores the usage ledger and its monthly rollups.
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository creates a new instance of Repository.
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindPrices retrieves the price of every model.
+func (r *Repository) FindPrices() ([]models.LLMPrice, error) {
+
+	var prices []models.LLMPrice
+
+	err := r.db.Find(&prices).Error
+
+	return prices, err
+}
+
+// Create adds [usage] to the ledger and, if it was for a user, to their
+// rollup for [month], together.
+func (r *Repository) Create(usage *models.LLMUsage, month time.Time) error {
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := tx.Create(usage).Error; err != nil {
+			return err
+		}
+
+		if usage.UserID == nil {
+			return nil
+		}
+
+		rollup := &models.LLMUsageMonthly{
+			UserID:           *usage.UserID,
+			Month:            month,
+			Requests:         1,
+			PromptTokens:     usage.PromptTokens,
+			CompletionTokens: usage.CompletionTokens,
+			Cost:             usage.Cost,
+		}
+
+		return tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "month"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"requests":          gorm.Expr("requests + VALUES(requests)"),
+				"prompt_tokens":     gorm.Expr("prompt_tokens + VALUES(prompt_tokens)"),
+				"completion_tokens": gorm.Expr("completion_tokens + VALUES(completion_tokens)"),
+				"cost":              gorm.Expr("cost + VALUES(cost)"),
+			}),
+		}).Create(rollup).Error
+	})
+}
+
+// FindMonthly retrieves [userID]'s rollup for [month], or nil if they
+// haven't used the model that month.
+func (r *Repository) FindMonthly(userID int64, month time.Time) (*models.LLMUsageMonthly, error) {
+
+	var rollup models.LLMUsageMonthly
+
+	err := r.db.Where("user_id = ? AND month = ?", userID, month).
+		First(&rollup).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &rollup, nil
+}
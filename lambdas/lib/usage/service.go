@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// Service records the usage of the lambda named Lambda, and checks users'
+// quotas. A nil Service records nothing and throttles no one, so handlers
+// and tests don't need one.
+type Service struct {
+	repo *Repository
+
+	// Lambda is the name of the lambda the usage is recorded for
+	Lambda string
+
+	// DefaultQuota is the most, in US dollars, a user's requests may cost
+	// in a month, for users without a quota of their own. Zero means no
+	// limit.
+	DefaultQuota float64
+
+	Now func() time.Time
+
+	// Prices are loaded on first use, and kept for the life of the lambda
+	mu     sync.Mutex
+	prices []models.LLMPrice
+}
+
+// NewService creates a new instance of Service.
+func NewService(repo *Repository, lambda string, defaultQuota float64) *Service {
+	return &Service{repo: repo, Lambda: lambda, DefaultQuota: defaultQuota, Now: time.Now}
+}
+
+// Record adds a request for [operation] made for [userID] to the ledger.
+// A [userID] of zero records usage that wasn't for a user. Models without
+// a price are recorded at no cost.
+func (s *Service) Record(userID int64, operation, model string, promptTokens, completionTokens int) error {
+
+	if s == nil {
+		return nil
+	}
+
+	prices, err := s.Prices()
+
+	if err != nil {
+		return fmt.Errorf("error finding prices: %w", err)
+	}
+
+	usage := &models.LLMUsage{
+		Lambda:           s.Lambda,
+		Operation:        operation,
+		Model:            model,
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+	}
+
+	if price, ok := Price(prices, model); ok {
+		usage.Cost = Cost(price, promptTokens, completionTokens)
+	} else {
+		log.New("No price for model %s. Recording usage at no cost.", model).Warn()
+	}
+
+	if userID != 0 {
+		usage.UserID = &userID
+	}
+
+	if err = s.repo.Create(usage, Month(s.Now())); err != nil {
+		return fmt.Errorf("error recording usage: %w", err)
+	}
+
+	return nil
+}
+
+// Prices returns the price of every model.
+func (s *Service) Prices() ([]models.LLMPrice, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.prices != nil {
+		return s.prices, nil
+	}
+
+	prices, err := s.repo.FindPrices()
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.prices = prices
+
+	return prices, nil
+}
+
+// MonthlyCost returns what [userID]'s requests have cost this month, in
+// US dollars.
+func (s *Service) MonthlyCost(userID int64) (float64, error) {
+
+	rollup, err := s.repo.FindMonthly(userID, Month(s.Now()))
+
+	if err != nil || rollup == nil {
+		return 0, err
+	}
+
+	return rollup.Cost, nil
+}
+
+// Throttled reports whether [user] has used up their quota for the month.
+// If their usage can't be found, they aren't throttled, so an outage of
+// the ledger doesn't stop us replying to everyone.
+func (s *Service) Throttled(user *models.User) bool {
+
+	if s == nil {
+		return false
+	}
+
+	quota := user.GetMonthlyCostQuota(s.DefaultQuota)
+
+	if quota <= 0 {
+		return false
+	}
+
+	cost, err := s.MonthlyCost(user.ID)
+
+	if err != nil {
+		log.New("Error finding monthly usage for user %d", user.ID).
+			AddError(err).Log()
+
+		return false
+	}
+
+	if cost < quota {
+		return false
+	}
+
+	log.New("User %d is over their monthly quota", user.ID).
+		Add("monthly_cost", strconv.FormatFloat(cost, 'f', 6, 64)).
+		Add("monthly_cost_quota", strconv.FormatFloat(quota, 'f', 2, 64)).
+		Warn()
+
+	return true
+}
@@ -0,0 +1,96 @@
+package usage_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/lib/usage"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+var (
+	now   = time.Date(2024, time.June, 19, 9, 0, 0, 0, time.UTC)
+	month = time.Date(2024, time.June, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func newService(t *testing.T, quota float64) (*usage.Service, sqlmock.Sqlmock) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	svc := usage.NewService(usage.NewRepository(db), "send_sms", quota)
+	svc.Now = func() time.Time { return now }
+
+	return svc, mock
+}
+
+func TestService_Record(t *testing.T) {
+
+	svc, mock := newService(t, 0)
+
+	mock.ExpectQuery("SELECT \\* FROM `llm_prices`").
+		WillReturnRows(sqlmock.NewRows([]string{"model", "prompt_cost_per_million", "completion_cost_per_million"}).
+			AddRow("gpt-4o", 5, 15))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `llm_usage`").
+		WithArgs("send_sms", "completion", "gpt-4o-2024-05-13", 1000, 500, 0.0125, int64(2)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `llm_usage_monthly` .* ON DUPLICATE KEY UPDATE "+
+		"`completion_tokens`=completion_tokens \\+ VALUES\\(completion_tokens\\)").
+		WithArgs(int64(2), month, 1, 1000, 500, 0.0125).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, svc.Record(2, "completion", "gpt-4o-2024-05-13", 1000, 500))
+
+	// Prices are only loaded once, and usage that wasn't for a user
+	// isn't rolled up
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `llm_usage`").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, svc.Record(0, "embedding", "text-embedding-3-large", 12, 0))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Throttled(t *testing.T) {
+
+	svc, mock := newService(t, 5)
+
+	mock.ExpectQuery("SELECT \\* FROM `llm_usage_monthly` WHERE user_id = \\? AND month = \\?").
+		WithArgs(int64(2), month, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "month", "cost"}).
+			AddRow(2, month, 5.25))
+
+	assert.True(t, svc.Throttled(&models.User{ID: 2}))
+
+	// A user of their own quota
+	quota := 10.0
+
+	mock.ExpectQuery("SELECT \\* FROM `llm_usage_monthly`").
+		WithArgs(int64(2), month, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "month", "cost"}).
+			AddRow(2, month, 5.25))
+
+	assert.False(t, svc.Throttled(&models.User{ID: 2, MonthlyCostQuota: &quota}))
+
+	// No quota means no limit, without looking up usage
+	quota = 0
+
+	assert.False(t, svc.Throttled(&models.User{ID: 2, MonthlyCostQuota: &quota}))
+
+	var nilService *usage.Service
+
+	assert.False(t, nilService.Throttled(&models.User{ID: 2}))
+	assert.NoError(t, nilService.Record(2, "completion", "gpt-4o", 1, 1))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
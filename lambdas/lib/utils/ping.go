@@ -29,8 +29,16 @@ func PingDatabase(globalDB *gorm.DB) error {
 
 func PingGoogle() error {
 
+	return PingURL("https://www.google.com/")
+}
+
+// PingURL checks internet connectivity by requesting [url]. The lambdas
+// call it with the configured connectivity check URL, so they can be run
+// against a local stand in.
+func PingURL(url string) error {
+
 	log.New("Checking internet connectivity.....").Log()
-	response, err := http.Get("https://www.google.com/")
+	response, err := http.Get(url)
 	log.New("Connected to the internet...").AddError(err).Log()
 
 	if err != nil {
@@ -0,0 +1,496 @@
+// Package vader is a Go port of the VADER (Valence Aware Dictionary and
+// sEntiment Reasoner) rule based sentiment analyzer, by C.J. Hutto and
+// Eric Gilbert.
+//
+// The published vader_lexicon.txt is MIT licensed, and `make lexicons`
+// embeds it in place of the small subset checked in for tests. It can
+// also be loaded with LoadLexicon.
+package vader
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// boostIncrement and boostDecrement are added to the valence of the
+	// word following a booster, like "very" or "slightly".
+	boostIncrement = 0.293
+	boostDecrement = -0.293
+
+	// capsIncrement is added to words written in ALL CAPS when the rest
+	// of the text isn't.
+	capsIncrement = 0.733
+
+	// negationScalar flips and dampens a negated word's valence.
+	negationScalar = -0.74
+
+	// normalizationAlpha approximates the maximum expected sum of valences.
+	normalizationAlpha = 15
+)
+
+//go:embed lexicon.txt
+var embeddedLexicon string
+
+var (
+	defaultAnalyzer *Analyzer
+	defaultOnce     sync.Once
+)
+
+var negations = map[string]bool{
+	"aint": true, "arent": true, "cannot": true, "cant": true, "couldnt": true,
+	"darent": true, "didnt": true, "doesnt": true, "ain't": true, "aren't": true,
+	"can't": true, "couldn't": true, "daren't": true, "didn't": true, "doesn't": true,
+	"dont": true, "hadnt": true, "hasnt": true, "havent": true, "isnt": true,
+	"mightnt": true, "mustnt": true, "neither": true, "don't": true, "hadn't": true,
+	"hasn't": true, "haven't": true, "isn't": true, "mightn't": true, "mustn't": true,
+	"neednt": true, "needn't": true, "never": true, "none": true, "nope": true,
+	"nor": true, "not": true, "nothing": true, "nowhere": true, "oughtnt": true,
+	"shant": true, "shouldnt": true, "uhuh": true, "wasnt": true, "werent": true,
+	"oughtn't": true, "shan't": true, "shouldn't": true, "uh-uh": true, "wasn't": true,
+	"weren't": true, "without": true, "wont": true, "wouldnt": true, "won't": true,
+	"wouldn't": true, "rarely": true, "seldom": true, "despite": true,
+}
+
+var boosters = map[string]float64{
+	"absolutely": boostIncrement, "amazingly": boostIncrement, "awfully": boostIncrement,
+	"completely": boostIncrement, "considerably": boostIncrement, "decidedly": boostIncrement,
+	"deeply": boostIncrement, "enormously": boostIncrement, "entirely": boostIncrement,
+	"especially": boostIncrement, "exceptionally": boostIncrement, "extremely": boostIncrement,
+	"fabulously": boostIncrement, "flipping": boostIncrement, "fricking": boostIncrement,
+	"fully": boostIncrement, "fucking": boostIncrement, "greatly": boostIncrement,
+	"hella": boostIncrement, "highly": boostIncrement, "hugely": boostIncrement,
+	"incredibly": boostIncrement, "intensely": boostIncrement, "majorly": boostIncrement,
+	"more": boostIncrement, "most": boostIncrement, "particularly": boostIncrement,
+	"purely": boostIncrement, "quite": boostIncrement, "really": boostIncrement,
+	"remarkably": boostIncrement, "so": boostIncrement, "substantially": boostIncrement,
+	"thoroughly": boostIncrement, "totally": boostIncrement, "tremendously": boostIncrement,
+	"uber": boostIncrement, "unbelievably": boostIncrement, "unusually": boostIncrement,
+	"utterly": boostIncrement, "very": boostIncrement,
+
+	"almost": boostDecrement, "barely": boostDecrement, "hardly": boostDecrement,
+	"just enough": boostDecrement, "kind of": boostDecrement, "kinda": boostDecrement,
+	"kindof": boostDecrement, "kind-of": boostDecrement, "less": boostDecrement,
+	"little": boostDecrement, "marginally": boostDecrement, "occasionally": boostDecrement,
+	"partly": boostDecrement, "scarcely": boostDecrement, "slightly": boostDecrement,
+	"somewhat": boostDecrement, "sort of": boostDecrement, "sorta": boostDecrement,
+	"sortof": boostDecrement, "sort-of": boostDecrement,
+}
+
+// Scores are the sentiment of a text. Compound is the normalized sum of
+// the valences, from -1 (most negative) to 1 (most positive). Pos, Neu
+// and Neg are the proportions of the text that fall in each category.
+type Scores struct {
+	Compound float64
+	Neg      float64
+	Neu      float64
+	Pos      float64
+}
+
+// Analyzer scores the sentiment of text using a valence lexicon.
+type Analyzer struct {
+	lexicon map[string]float64
+}
+
+// NewAnalyzer returns an Analyzer using [lexicon], a map of lowercase
+// words to their mean valence from -4 to 4.
+func NewAnalyzer(lexicon map[string]float64) *Analyzer {
+	return &Analyzer{lexicon: lexicon}
+}
+
+// Default returns an Analyzer using the embedded lexicon.
+func Default() *Analyzer {
+
+	defaultOnce.Do(func() {
+
+		lexicon, err := LoadLexicon(strings.NewReader(embeddedLexicon))
+
+		if err != nil {
+			panic(fmt.Sprintf("error loading embedded vader lexicon: %s", err))
+		}
+
+		defaultAnalyzer = NewAnalyzer(lexicon)
+	})
+
+	return defaultAnalyzer
+}
+
+// LoadLexicon reads a lexicon in the format of vader_lexicon.txt: a tab
+// separated token and mean valence per line. Any further columns are
+// ignored.
+func LoadLexicon(r io.Reader) (map[string]float64, error) {
+
+	lexicon := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+
+		line++
+		fields := strings.Split(scanner.Text(), "\t")
+
+		if len(fields) < 2 || strings.TrimSpace(fields[0]) == "" {
+			continue
+		}
+
+		valence, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid valence on line %d: %w", line, err)
+		}
+
+		lexicon[strings.TrimSpace(fields[0])] = valence
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lexicon, nil
+}
+
+// PolarityScores returns the sentiment of [text].
+func (a *Analyzer) PolarityScores(text string) Scores {
+
+	words := tokenize(text)
+	capsDifferential := hasCapsDifferential(words)
+	sentiments := make([]float64, 0, len(words))
+
+	for i, word := range words {
+
+		lower := strings.ToLower(word)
+
+		// Boosters only modify the words after them
+		if _, ok := boosters[lower]; ok {
+			sentiments = append(sentiments, 0)
+
+			continue
+		}
+
+		if lower == "kind" && i+1 < len(words) && strings.ToLower(words[i+1]) == "of" {
+			sentiments = append(sentiments, 0)
+
+			continue
+		}
+
+		sentiments = append(sentiments, a.valence(words, i, capsDifferential))
+	}
+
+	sentiments = butCheck(words, sentiments)
+
+	return scoreValence(sentiments, text)
+}
+
+func (a *Analyzer) valence(words []string, i int, capsDifferential bool) float64 {
+
+	word := words[i]
+	lower := strings.ToLower(word)
+	valence, ok := a.lexicon[lower]
+
+	if !ok {
+		return 0
+	}
+
+	// "no" is only negative on its own, as in "no, it didn't help"
+	if lower == "no" && i+1 < len(words) {
+		if _, ok := a.lexicon[strings.ToLower(words[i+1])]; ok {
+			return 0
+		}
+	}
+
+	if capsDifferential && isUpper(word) {
+		if valence > 0 {
+			valence += capsIncrement
+		} else {
+			valence -= capsIncrement
+		}
+	}
+
+	for start := 0; start < 3; start++ {
+
+		if i <= start {
+			break
+		}
+
+		preceding := strings.ToLower(words[i-(start+1)])
+
+		if _, ok := a.lexicon[preceding]; ok {
+			continue
+		}
+
+		scalar := boost(words[i-(start+1)], valence, capsDifferential)
+
+		// Boosters further away have less effect
+		switch start {
+		case 1:
+			scalar *= 0.95
+		case 2:
+			scalar *= 0.9
+		}
+
+		valence += scalar
+		valence = negationCheck(valence, words, start, i)
+	}
+
+	return a.leastCheck(valence, words, i)
+}
+
+func boost(word string, valence float64, capsDifferential bool) float64 {
+
+	scalar, ok := boosters[strings.ToLower(word)]
+
+	if !ok {
+		return 0
+	}
+
+	if valence < 0 {
+		scalar *= -1
+	}
+
+	if capsDifferential && isUpper(word) {
+		if valence > 0 {
+			scalar += capsIncrement
+		} else {
+			scalar -= capsIncrement
+		}
+	}
+
+	return scalar
+}
+
+func negationCheck(valence float64, words []string, start, i int) float64 {
+
+	lower := func(offset int) string {
+		return strings.ToLower(words[i-offset])
+	}
+
+	switch start {
+	case 0:
+		if isNegated(lower(1)) {
+			valence *= negationScalar
+		}
+	case 1:
+		switch {
+		case lower(2) == "never" && (lower(1) == "so" || lower(1) == "this"):
+			valence *= 1.25
+		case lower(2) == "without" && lower(1) == "doubt":
+		case isNegated(lower(2)):
+			valence *= negationScalar
+		}
+	case 2:
+		switch {
+		case lower(3) == "never" && (lower(2) == "so" || lower(2) == "this" ||
+			lower(1) == "so" || lower(1) == "this"):
+			valence *= 1.25
+		case lower(3) == "without" && (lower(2) == "doubt" || lower(1) == "doubt"):
+		case isNegated(lower(3)):
+			valence *= negationScalar
+		}
+	}
+
+	return valence
+}
+
+// leastCheck negates words following "least", unless it is "at least"
+// or "very least".
+func (a *Analyzer) leastCheck(valence float64, words []string, i int) float64 {
+
+	if i == 0 {
+		return valence
+	}
+
+	previous := strings.ToLower(words[i-1])
+
+	if _, ok := a.lexicon[previous]; ok || previous != "least" {
+		return valence
+	}
+
+	if i > 1 {
+		before := strings.ToLower(words[i-2])
+
+		if before == "at" || before == "very" {
+			return valence
+		}
+	}
+
+	return valence * negationScalar
+}
+
+// butCheck halves the sentiment before "but" and boosts the sentiment
+// after it, since the clause after it usually carries the meaning.
+func butCheck(words []string, sentiments []float64) []float64 {
+
+	but := -1
+
+	for i, word := range words {
+		if strings.ToLower(word) == "but" {
+			but = i
+
+			break
+		}
+	}
+
+	if but < 0 {
+		return sentiments
+	}
+
+	for i := range sentiments {
+		switch {
+		case i < but:
+			sentiments[i] *= 0.5
+		case i > but:
+			sentiments[i] *= 1.5
+		}
+	}
+
+	return sentiments
+}
+
+func scoreValence(sentiments []float64, text string) Scores {
+
+	if len(sentiments) == 0 {
+		return Scores{}
+	}
+
+	sum := 0.0
+
+	for _, s := range sentiments {
+		sum += s
+	}
+
+	emphasis := punctuationEmphasis(text)
+
+	if sum > 0 {
+		sum += emphasis
+	} else if sum < 0 {
+		sum -= emphasis
+	}
+
+	var posSum, negSum, neuCount float64
+
+	for _, s := range sentiments {
+		switch {
+		case s > 0:
+			posSum += s + 1
+		case s < 0:
+			negSum += s - 1
+		default:
+			neuCount++
+		}
+	}
+
+	if posSum > math.Abs(negSum) {
+		posSum += emphasis
+	} else if posSum < math.Abs(negSum) {
+		negSum -= emphasis
+	}
+
+	total := posSum + math.Abs(negSum) + neuCount
+
+	return Scores{
+		Compound: round(normalize(sum), 4),
+		Neg:      round(math.Abs(negSum/total), 3),
+		Neu:      round(math.Abs(neuCount/total), 3),
+		Pos:      round(math.Abs(posSum/total), 3),
+	}
+}
+
+// punctuationEmphasis is how much exclamation and question marks add to
+// the intensity of the text.
+func punctuationEmphasis(text string) float64 {
+
+	exclamations := min(strings.Count(text, "!"), 4)
+	emphasis := float64(exclamations) * 0.292
+
+	questions := strings.Count(text, "?")
+
+	if questions > 1 {
+		if questions <= 3 {
+			emphasis += float64(questions) * 0.18
+		} else {
+			emphasis += 0.96
+		}
+	}
+
+	return emphasis
+}
+
+func normalize(score float64) float64 {
+
+	normalized := score / math.Sqrt(score*score+normalizationAlpha)
+
+	return math.Max(-1, math.Min(1, normalized))
+}
+
+func round(value float64, places int) float64 {
+
+	scale := math.Pow(10, float64(places))
+
+	return math.Round(value*scale) / scale
+}
+
+// tokenize splits [text] on whitespace, stripping punctuation from words
+// but keeping short tokens like ":)" intact, and drops single characters.
+func tokenize(text string) []string {
+
+	var words []string
+
+	for _, token := range strings.Fields(text) {
+
+		stripped := strings.TrimFunc(token, unicode.IsPunct)
+
+		if len([]rune(stripped)) > 2 {
+			token = stripped
+		}
+
+		if len([]rune(token)) > 1 {
+			words = append(words, token)
+		}
+	}
+
+	return words
+}
+
+func isNegated(word string) bool {
+	return negations[word] || strings.Contains(word, "n't")
+}
+
+// isUpper reports whether [word] has letters and they are all upper case.
+func isUpper(word string) bool {
+
+	letters := false
+
+	for _, r := range word {
+		if unicode.IsLower(r) {
+			return false
+		}
+
+		if unicode.IsUpper(r) {
+			letters = true
+		}
+	}
+
+	return letters
+}
+
+// hasCapsDifferential reports whether some, but not all, of [words] are
+// in ALL CAPS.
+func hasCapsDifferential(words []string) bool {
+
+	upper := 0
+
+	for _, word := range words {
+		if isUpper(word) {
+			upper++
+		}
+	}
+
+	return upper > 0 && upper < len(words)
+}
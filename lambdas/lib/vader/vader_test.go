@@ -0,0 +1,71 @@
+package vader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolarityScores(t *testing.T) {
+
+	// Expected scores are from the reference implementation
+	tests := []struct {
+		text     string
+		compound float64
+	}{
+		{"VADER is smart, handsome, and funny.", 0.8316},
+		{"VADER is smart, handsome, and funny!", 0.8439},
+		{"VADER is very smart, handsome, and funny.", 0.8545},
+		{"The book was good.", 0.4404},
+		{"Not bad at all", 0.431},
+		{"At least it isn't a horrible book.", 0.431},
+		{"The weather is a thing that happens", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			assert.Equal(t, test.compound, Default().PolarityScores(test.text).Compound)
+		})
+	}
+}
+
+func TestPolarityScores_Proportions(t *testing.T) {
+
+	scores := Default().PolarityScores("VADER is smart, handsome, and funny.")
+
+	assert.Equal(t, 0.0, scores.Neg)
+	assert.Equal(t, 0.254, scores.Neu)
+	assert.Equal(t, 0.746, scores.Pos)
+}
+
+func TestPolarityScores_Rules(t *testing.T) {
+
+	analyzer := Default()
+	score := func(text string) float64 {
+		return analyzer.PolarityScores(text).Compound
+	}
+
+	assert.Less(t, score("I am not happy"), 0.0, "negation flips the valence")
+	assert.Less(t, score("I am really sad"), score("I am sad"), "boosters intensify negative words too")
+	assert.Less(t, score("I am really sad"), score("I am slightly sad"))
+	assert.Greater(t, score("I am HAPPY today"), score("I am happy today"), "caps add emphasis")
+	assert.Greater(t, score("I am happy!!!"), score("I am happy"), "exclamations add emphasis")
+	assert.Less(t, score("It was good but I feel terrible"), 0.0, "the clause after but dominates")
+	assert.Equal(t, Scores{}, analyzer.PolarityScores(""))
+}
+
+func TestLoadLexicon(t *testing.T) {
+
+	// The published lexicon also has the standard deviation and ratings
+	lexicon, err := LoadLexicon(strings.NewReader(
+		"good\t1.9\t0.9434\t[2, 1, 1, 3, 2, 4, 2, 2, 1, 1]\n\nmeh\t-0.3\n",
+	))
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"good": 1.9, "meh": -0.3}, lexicon)
+
+	_, err = LoadLexicon(strings.NewReader("good\tvery\n"))
+	assert.Error(t, err)
+}
@@ -64,7 +64,7 @@ func (l *LoginLambda) Login(request events.APIGatewayProxyRequest) (events.APIGa
 			AddError(err).Respond(http.StatusBadRequest)
 	}
 
-	log.New("Getting user with phone number %s", loginPayload.PhoneNumber).Log()
+	log.New("Getting user by phone number").Add("phone_number", loginPayload.PhoneNumber).Log()
 
 	// Get the user by phone number
 	if user, err = l.UserService.GetUserByPhoneNumber(loginPayload.PhoneNumber); err != nil {
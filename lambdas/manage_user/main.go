@@ -13,6 +13,7 @@ import (
 	"gorm.io/gorm"
 
 	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/hasher"
@@ -90,6 +91,10 @@ func (h *ManageUserLambdaHandler) Create(request events.APIGatewayProxyRequest)
 
 	// Pending activation
 	newUser.AccountStatusID = 1
+
+	// Everyone signs up as a patient. Therapists and admins are promoted
+	// by an admin, never by themselves.
+	newUser.UserTypeID = models.UserTypePatient
 	newUser.EnableNudges()
 
 	hashedPassword, err := hasher.HashPassword(*newUser.Password)
@@ -164,12 +169,24 @@ func (h *ManageUserLambdaHandler) Update(request events.APIGatewayProxyRequest)
 		return lib.RespondWithError("Invalid user ID", nil, http.StatusBadRequest)
 	}
 
+	// Users can't change their own type, so they can't make themselves
+	// admins. A zero value is left out of the update.
+	inputUser.UserTypeID = 0
+
 	if inputUser.PhoneNumber != "" && !twilio.IsValidPhoneNumber(inputUser.PhoneNumber) {
 		msg := fmt.Sprintf("Invalid phone number %s", inputUser.PhoneNumber)
 
 		return lib.RespondWithError(msg, nil, http.StatusBadRequest)
 	}
 
+	// Nothing is sent here, so the registry needs no provider
+	if inputUser.PreferredMessageTypeID != nil &&
+		!channel.NewDefaultRegistry(nil, 0).Supports(*inputUser.PreferredMessageTypeID) {
+		msg := fmt.Sprintf("Unsupported message type %d", *inputUser.PreferredMessageTypeID)
+
+		return lib.RespondWithError(msg, nil, http.StatusBadRequest)
+	}
+
 	if inputUser.Password != nil {
 		err := h.updatePassword(inputUser)
 
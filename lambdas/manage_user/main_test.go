@@ -300,3 +300,112 @@ func TestManageUser_UpdateWithNoUserID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "Invalid user ID", responseErr.Message)
 }
+
+func TestManageUser_PostCannotCreateAdmin(t *testing.T) {
+
+	test.SetEnvVars()
+
+	pwd := test.DefaultTestPassword
+
+	user := models.User{
+		Password:    &pwd,
+		Firstname:   test.DefaultTestUserFirstname,
+		Lastname:    test.DefaultTestUserLastname,
+		PhoneNumber: "+12533243071",
+		UserTypeID:  models.UserTypeAdmin,
+		Email:       test.DefaultTestEmail,
+	}
+
+	userBytes, _ := json.Marshal(user)
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       string(userBytes),
+	}
+
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could nto set up mock db")
+
+	// The user is created as a patient, whatever they asked for
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `users`").WithArgs(
+		sqlmock.AnyArg(),
+		user.PhoneNumber,
+		false,
+		user.Firstname,
+		user.Lastname,
+		user.Email,
+		1,
+		models.UserTypePatient,
+		true,
+		user.ProviderCode,
+	).
+		WillReturnResult(
+			test.GenerateMockLastAffectedRow(),
+		)
+
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs("Pending Activation", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Pending Activation"))
+
+	handler := main.ManageUserLambdaHandler{
+		TokenService: &jwt.TokenService{},
+		KeyRotator:   jwt.NewMockKeyRotator(),
+	}
+
+	handler.Init(db)
+	_, err = handler.Create(request)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestManageUser_UpdateCannotBecomeAdmin(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could nto set up mock db")
+
+	user := models.User{
+		ID:         3,
+		Firstname:  "New Name",
+		UserTypeID: models.UserTypeAdmin,
+	}
+
+	userBytes, _ := json.Marshal(user)
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod: "PUT",
+		Body:       string(userBytes),
+	}
+
+	// The user type is left out of the update
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `users` SET `id`=\\?,`firstname`=\\? WHERE id = \\?").WithArgs(
+		user.ID, user.Firstname, user.ID,
+	).
+		WillReturnResult(
+			test.GenerateMockLastAffectedRow(),
+		)
+
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE `users`.`id`").
+		WithArgs(user.ID, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockUserRepositoryUser())
+
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Pending Activation"))
+
+	handler := main.ManageUserLambdaHandler{
+		TokenService: &jwt.TokenService{},
+		KeyRotator:   jwt.NewMockKeyRotator(),
+	}
+	handler.Init(db)
+	_, err = handler.Update(request)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
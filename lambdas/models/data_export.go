@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+const (
+	DataExportStatusPending    = "pending"
+	DataExportStatusProcessing = "processing"
+	DataExportStatusComplete   = "complete"
+	DataExportStatusFailed     = "failed"
+
+	// DataExportDeliveryLink leaves the user to fetch the link from the API
+	DataExportDeliveryLink = "link"
+
+	// DataExportDeliverySMS also texts the user the link
+	DataExportDeliverySMS = "sms"
+)
+
+// DataExport is a user's request for a copy of everything we store about
+// them. The archive is built in the background and kept in S3 until it
+// expires.
+type DataExport struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64      `gorm:"not null;index" json:"user_id"`
+	Status      string     `gorm:"size:16;not null;default:pending" json:"status"`
+	Delivery    string     `gorm:"size:8;not null;default:link" json:"delivery"`
+	ObjectKey   *string    `gorm:"size:255;default:null" json:"-"`
+	Error       *string    `gorm:"type:text;default:null" json:"-"`
+	CompletedAt *time.Time `gorm:"default:null" json:"completed_at"`
+	ExpiresAt   *time.Time `gorm:"default:null" json:"expires_at"`
+	CreatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt   time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// IsInProgress reports whether the archive is still being built.
+func (e *DataExport) IsInProgress() bool {
+	return e.Status == DataExportStatusPending || e.Status == DataExportStatusProcessing
+}
+
+// IsAvailable reports whether the archive can be downloaded at [now].
+func (e *DataExport) IsAvailable(now time.Time) bool {
+	return e.Status == DataExportStatusComplete && e.ObjectKey != nil &&
+		e.ExpiresAt != nil && now.Before(*e.ExpiresAt)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// FailedEvent is a queue message that could not be processed after
+// repeated attempts and was moved to a dead letter queue. The original
+// body is kept so the event can be inspected and replayed.
+type FailedEvent struct {
+	ID             int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID      string     `gorm:"type:varchar(255);uniqueIndex:idx_failed_event_message_id;not null" json:"message_id"`
+	SourceQueueArn string     `gorm:"type:varchar(255)" json:"source_queue_arn"`
+	Body           string     `gorm:"type:text;not null" json:"body"`
+	ReceiveCount   int        `gorm:"not null;default:0" json:"receive_count"`
+	ReplayCount    int        `gorm:"not null;default:0" json:"replay_count"`
+	ReplayedAt     *time.Time `gorm:"type:datetime;default:null" json:"replayed_at"`
+	CreatedAt      time.Time  `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+const (
+	IdempotencyScopeReceiveSMS  = "receive_sms"
+	IdempotencyScopeSendSMS     = "send_sms"
+	IdempotencyScopeQuotaNotice = "quota_notice"
+)
+
+const (
+	// IdempotencyStatusPending keys are held by whoever is doing the work,
+	// until it is done or their lease expires.
+	IdempotencyStatusPending = "pending"
+
+	// IdempotencyStatusDone keys guard work that has finished, and are
+	// never claimed again.
+	IdempotencyStatusDone = "done"
+)
+
+// IdempotencyKey records that a unit of work has been claimed, so retried
+// webhooks and redelivered queue messages are only processed once.
+type IdempotencyKey struct {
+	ID     int64  `gorm:"primaryKey;autoIncrement" json:"id"`
+	Key    string `gorm:"column:idempotency_key;type:varchar(255);uniqueIndex:idx_idempotency_key;not null" json:"idempotency_key"`
+	Scope  string `gorm:"type:varchar(64);not null" json:"scope"`
+	Status string `gorm:"type:varchar(16);not null;default:pending" json:"status"`
+
+	// When a pending claim lapses. A lambda that times out or crashes
+	// never marks its key done, so once the lease expires the work can be
+	// claimed again.
+	ExpiresAt *time.Time `gorm:"default:null" json:"expires_at"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// NewIdempotencyKey creates a key for [reference] within [scope].
+func NewIdempotencyKey(scope, reference string) *IdempotencyKey {
+	return &IdempotencyKey{
+		Key:   scope + ":" + reference,
+		Scope: scope,
+	}
+}
+
+// Lease marks the key pending, held until [expiresAt].
+func (k *IdempotencyKey) Lease(expiresAt time.Time) *IdempotencyKey {
+	k.Status = IdempotencyStatusPending
+	k.ExpiresAt = &expiresAt
+
+	return k
+}
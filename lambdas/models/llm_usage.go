@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// LLMUsage records the tokens one request to the language model used, and
+// what they cost.
+type LLMUsage struct {
+	ID int64 `gorm:"primaryKey;autoIncrement" json:"id"`
+
+	// The user the request was made for, or nil if it wasn't for a user
+	UserID *int64 `gorm:"index;default:null" json:"user_id"`
+
+	// The lambda that made the request, and what it asked for, such as
+	// a completion or embedding
+	Lambda    string `gorm:"type:varchar(64);not null" json:"lambda"`
+	Operation string `gorm:"type:varchar(64);not null" json:"operation"`
+	Model     string `gorm:"type:varchar(128);not null" json:"model"`
+
+	PromptTokens     int `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int `gorm:"not null;default:0" json:"completion_tokens"`
+
+	// Cost is in US dollars, at the model's price when it was recorded
+	Cost float64 `gorm:"type:decimal(12,6);not null;default:0" json:"cost"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TableName keeps GORM from pluralizing the table to "llm_usages".
+func (LLMUsage) TableName() string {
+	return "llm_usage"
+}
+
+// LLMUsageMonthly is a month of one user's language model usage, rolled up
+// as it's recorded so quotas can be checked without adding up the ledger.
+type LLMUsageMonthly struct {
+	ID     int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int64     `gorm:"uniqueIndex:idx_llm_usage_monthly_month;not null" json:"user_id"`
+	Month  time.Time `gorm:"type:date;uniqueIndex:idx_llm_usage_monthly_month;not null" json:"month"`
+
+	Requests         int     `gorm:"not null;default:0" json:"requests"`
+	PromptTokens     int     `gorm:"not null;default:0" json:"prompt_tokens"`
+	CompletionTokens int     `gorm:"not null;default:0" json:"completion_tokens"`
+	Cost             float64 `gorm:"type:decimal(12,6);not null;default:0" json:"cost"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// TableName keeps GORM from naming the table "llm_usage_monthlies".
+func (LLMUsageMonthly) TableName() string {
+	return "llm_usage_monthly"
+}
+
+// LLMPrice is what a model costs, in US dollars per million tokens. Model
+// is a prefix, since OpenAI answers with dated versions of the model we
+// ask for, like gpt-4o-2024-05-13.
+type LLMPrice struct {
+	Model                    string  `gorm:"primaryKey;type:varchar(128)" json:"model"`
+	PromptCostPerMillion     float64 `gorm:"type:decimal(10,4);not null" json:"prompt_cost_per_million"`
+	CompletionCostPerMillion float64 `gorm:"type:decimal(10,4);not null" json:"completion_cost_per_million"`
+}
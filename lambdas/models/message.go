@@ -9,11 +9,12 @@ import (
 // Message represents the messages table in the database with GORM annotations.
 type Message struct {
 	ID              int64          `gorm:"primaryKey;autoIncrement" json:"id"`
-	ReferenceID     *string        `gorm:"size:255" json:"reference_id"`
+	ReferenceID     *string        `gorm:"size:255;uniqueIndex:idx_messages_reference_id" json:"reference_id"`
 	ConversationID  int64          `gorm:"index:idx_conversation,sort:asc;foreignKey" json:"conversation_id"`
 	FromUserID      int64          `gorm:"not null;foreignKey" json:"from_user_id"`
 	ToUserID        int64          `gorm:"not null;foreignKey" json:"to_user_id"`
-	Body            string         `gorm:"not null;size:255" json:"body"`
+	Body            string         `gorm:"type:text;not null" json:"body"`
+	Transcribed     bool           `gorm:"not null;default:false" json:"transcribed"`
 	MessageTypeID   int64          `gorm:"not null;foreignKey" json:"message_type_id"`
 	MessageStatusID int64          `gorm:"not null;foreignKey" json:"message_status_id"`
 	SentAt          *time.Time     `gorm:"default:null" json:"sent_at"`
@@ -22,10 +23,37 @@ type Message struct {
 	UpdatedAt       time.Time      `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
 	DeletedAt       gorm.DeletedAt `gorm:"index:idx_reference_id,sort:asc;default:null" json:"deleted_at"`
 
+	// How many SMS segments we expected the message to be billed as, and
+	// how many the provider says it was, and the encoding the segments
+	// were counted in, GSM-7 or UCS-2. Not set for web chat.
+	EstimatedSegments *int    `gorm:"default:null" json:"estimated_segments"`
+	NumSegments       *int    `gorm:"default:null" json:"num_segments"`
+	SegmentEncoding   *string `gorm:"size:8;default:null" json:"segment_encoding"`
+
+	// The prompt that wrote the message, as name@version, so replies from
+	// the variants of a prompt can be compared. Not set for user messages.
+	PromptVersion *string `gorm:"size:128;default:null" json:"prompt_version"`
+
+	// The summary of the user's mood the prompt included, so we can see
+	// what the model was told when it chose its tone.
+	MoodContext *string `gorm:"type:text;default:null" json:"mood_context"`
+
+	// The W3C traceparent of the event that created the message, so the
+	// status callbacks for it continue the same trace.
+	TraceParent *string `gorm:"size:55;default:null" json:"trace_parent"`
+
+	// The reply that answered an inbound message. Texts that arrive while
+	// a reply is being written are left unanswered until a reply of their
+	// own, however their timestamps compare.
+	RepliedByMessageID *int64 `gorm:"index:idx_messages_replied_by_message_id;default:null" json:"replied_by_message_id"`
+
 	// Foreign key relationships
 	Conversation  Conversation  `gorm:"foreignKey:ConversationID" json:"conversation"`
 	MessageStatus MessageStatus `gorm:"foreignKey:MessageStatusID;association_autoupdate:false;association_autocreate:false" json:"message_status"`
 	MessageType   MessageType   `gorm:"foreignKey:MessageTypeID;association_autoupdate:false;association_autocreate:false" json:"message_type"`
 	From          User          `gorm:"foreignKey:FromUserID;association_autoupdate:false;association_autocreate:false" json:"from_user"`
 	To            User          `gorm:"foreignKey:ToUserID;association_autoupdate:false;association_autocreate:false" json:"to_user"`
+
+	// Photos and voice memos attached to the message
+	Media []MessageMedia `gorm:"foreignKey:MessageID" json:"media,omitempty"`
 }
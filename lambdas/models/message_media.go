@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// MessageMedia is a photo, voice memo, or other attachment sent
+// along with a message over MMS. For images, Description is generated
+// by a vision model. For audio, it is the transcript.
+type MessageMedia struct {
+	ID          int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	MessageID   int64     `gorm:"not null;index" json:"message_id"`
+	URL         string    `gorm:"type:varchar(1024);not null" json:"url"`
+	ContentType string    `gorm:"type:varchar(255);not null" json:"content_type"`
+	Description *string   `gorm:"type:text;default:null" json:"description"`
+	CreatedAt   time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+// TableName keeps GORM from pluralizing the table to "message_medias".
+func (MessageMedia) TableName() string {
+	return "message_media"
+}
+
+// IsImage reports whether the media is a picture.
+func (m *MessageMedia) IsImage() bool {
+	return strings.HasPrefix(m.ContentType, "image/")
+}
+
+// IsAudio reports whether the media is recorded sound, like a voice memo.
+func (m *MessageMedia) IsAudio() bool {
+	return strings.HasPrefix(m.ContentType, "audio/")
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// MessageResponse is a message as the web app sees it. It leaves out the
+// users the message is between, whose records carry password hashes, and
+// the context we keep for writing and tracing replies.
+type MessageResponse struct {
+	ID              int64      `json:"id"`
+	ConversationID  int64      `json:"conversation_id"`
+	FromUserID      int64      `json:"from_user_id"`
+	ToUserID        int64      `json:"to_user_id"`
+	Body            string     `json:"body"`
+	Transcribed     bool       `json:"transcribed"`
+	MessageTypeID   int64      `json:"message_type_id"`
+	MessageStatusID int64      `json:"message_status_id"`
+	SentAt          *time.Time `json:"sent_at"`
+	ReceivedAt      *time.Time `json:"received_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func MakeMessageResponseFromMessage(msg *Message) *MessageResponse {
+
+	return &MessageResponse{
+		ID:              msg.ID,
+		ConversationID:  msg.ConversationID,
+		FromUserID:      msg.FromUserID,
+		ToUserID:        msg.ToUserID,
+		Body:            msg.Body,
+		Transcribed:     msg.Transcribed,
+		MessageTypeID:   msg.MessageTypeID,
+		MessageStatusID: msg.MessageStatusID,
+		SentAt:          msg.SentAt,
+		ReceivedAt:      msg.ReceivedAt,
+		CreatedAt:       msg.CreatedAt,
+	}
+}
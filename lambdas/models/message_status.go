@@ -88,3 +88,12 @@ func NewMessageStatusUnknown() MessageStatus {
 		Name: "Unknown",
 	}
 }
+
+// NewMessageStatusThrottled marks a message from a user over their monthly
+// quota, which wasn't answered.
+func NewMessageStatusThrottled() MessageStatus {
+	return MessageStatus{
+		ID:   13,
+		Name: "Throttled",
+	}
+}
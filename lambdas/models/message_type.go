@@ -14,3 +14,19 @@ func NewMessageTypeSMS() MessageType {
 		BillRateInCredits: .5,
 	}
 }
+
+func NewMessageTypeWhatsApp() MessageType {
+	return MessageType{
+		ID:                7,
+		Name:              "WhatsApp",
+		BillRateInCredits: 1,
+	}
+}
+
+func NewMessageTypeWebChat() MessageType {
+	return MessageType{
+		ID:                12,
+		Name:              "Web Chat",
+		BillRateInCredits: .25,
+	}
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// PromptTemplate is a version of a named prompt stored in the database.
+// Active templates with a weight are the variants of an A/B test, and
+// users are split between them in proportion to their weights. A template
+// with no body reuses the embedded template of the same version.
+type PromptTemplate struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name      string    `gorm:"type:varchar(64);uniqueIndex:idx_prompt_name_version;not null" json:"name"`
+	Version   string    `gorm:"type:varchar(64);uniqueIndex:idx_prompt_name_version;not null" json:"version"`
+	Body      string    `gorm:"type:text" json:"body"`
+	Weight    int       `gorm:"not null;default:0" json:"weight"`
+	Active    bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
+
+// PromptAssignment records which version of a prompt a user was assigned,
+// so they keep seeing the same variant for as long as the test runs.
+type PromptAssignment struct {
+	ID         int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64     `gorm:"uniqueIndex:idx_prompt_assignment;not null" json:"user_id"`
+	PromptName string    `gorm:"type:varchar(64);uniqueIndex:idx_prompt_assignment;not null" json:"prompt_name"`
+	Version    string    `gorm:"type:varchar(64);not null" json:"version"`
+	CreatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
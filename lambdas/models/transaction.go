@@ -10,6 +10,8 @@ const (
 	TransactionTypeStringCredit = "credit"
 	TransactionTypeStringDebit  = "debit"
 	FundingSourceStringStripe   = "stripe"
+
+	FundingSourceStringCustomerCredit = "customer credit"
 )
 
 // Transaction represents a credit transaction in the database.
@@ -41,6 +41,27 @@ type TwilioMessageInfo struct {
 	ToCity              string `form:"ToCity"`
 	ToState             string `form:"ToState"`
 	ToCountry           string `form:"ToCountry"`
+
+	// Sent instead of SmsSid when a caller leaves a voice message
+	CallSid           string `form:"CallSid"`
+	RecordingSid      string `form:"RecordingSid"`
+	RecordingUrl      string `form:"RecordingUrl"`
+	RecordingDuration string `form:"RecordingDuration"`
+}
+
+// IsRecording reports whether this is a voice message left on a
+// call, rather than a text.
+func (t *TwilioMessageInfo) IsRecording() bool {
+	return t.RecordingUrl != ""
+}
+
+// GetReferenceID returns Twilio's ID for the inbound message or recording.
+func (t *TwilioMessageInfo) GetReferenceID() string {
+	if t.IsRecording() && t.SmsSid == "" {
+		return t.RecordingSid
+	}
+
+	return t.SmsSid
 }
 
 func (t *TwilioMessageInfo) GetTwilioMessageStatus() TwilioMessageStatus {
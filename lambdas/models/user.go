@@ -2,6 +2,12 @@ package models
 
 import "gorm.io/gorm"
 
+const (
+	UserTypePatient   = 1
+	UserTypeTherapist = 2
+	UserTypeAdmin     = 3
+)
+
 type User struct {
 	AccountStatus   AccountStatus `json:"status" gorm:"foreignKey:AccountStatusID;references:ID"`
 	ID              int64         `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -15,6 +21,18 @@ type User struct {
 	UserTypeID      int64         `gorm:"not null;" json:"user_type_id"`
 	NudgeEnabled    *bool         `gorm:"not null" json:"nudge_enabled"`
 	ProviderCode    string        `gorm:"type:varchar(128)" json:"provider_code"`
+
+	// The message type of the channel the user prefers to be contacted on.
+	// When not set, we use SMS.
+	PreferredMessageTypeID *int64 `gorm:"default:null" json:"preferred_message_type_id"`
+
+	// The most SMS segments a reply to the user should take. When not
+	// set, we use CHAT_MODEL_MAX_REPLY_SEGMENTS.
+	MaxReplySegments *int `gorm:"default:null" json:"max_reply_segments"`
+
+	// The most, in US dollars, the user's requests to the language model
+	// may cost in a month. When not set, we use LLM_MONTHLY_COST_QUOTA.
+	MonthlyCostQuota *float64 `gorm:"type:decimal(10,2);default:null" json:"monthly_cost_quota"`
 }
 
 func (u *User) IsValid() bool {
@@ -43,6 +61,42 @@ func (u *User) DisableNudges() {
 	u.NudgeEnabled = &nudgeEnabled
 }
 
+// GetPreferredMessageTypeID returns the message type of the channel the
+// user prefers to be contacted on.
+func (u *User) GetPreferredMessageTypeID() int64 {
+	if u.PreferredMessageTypeID == nil {
+
+		return NewMessageTypeSMS().ID
+	}
+	return *u.PreferredMessageTypeID
+}
+
+// GetMaxReplySegments returns the most SMS segments a reply to the
+// user should take, or [fallback] if they don't have a limit of their own.
+func (u *User) GetMaxReplySegments(fallback int) int {
+	if u.MaxReplySegments == nil || *u.MaxReplySegments < 1 {
+
+		return fallback
+	}
+	return *u.MaxReplySegments
+}
+
+// GetMonthlyCostQuota returns the most the user's requests to the language
+// model may cost in a month, or [fallback] if they don't have a quota of
+// their own. A quota of zero means no limit.
+func (u *User) GetMonthlyCostQuota(fallback float64) float64 {
+	if u.MonthlyCostQuota == nil || *u.MonthlyCostQuota < 0 {
+
+		return fallback
+	}
+	return *u.MonthlyCostQuota
+}
+
+// IsAdmin reports whether the user can see reports across every user.
+func (u *User) IsAdmin() bool {
+	return u.UserTypeID == UserTypeAdmin
+}
+
 func (u *User) BeforeUpdate(tx *gorm.DB) (err error) {
 
 	if u.AccountStatusID == 0 {
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// UserMetric is a day of one user's engagement, rolled up from their
+// messages and sentiment scores. Averages are kept as totals and counts
+// so that days and users can be added together.
+type UserMetric struct {
+	ID     int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID int64     `gorm:"uniqueIndex:idx_user_metric_day;not null" json:"user_id"`
+	Date   time.Time `gorm:"type:date;uniqueIndex:idx_user_metric_day;not null" json:"date"`
+
+	// Messages the user sent us, and that we sent them
+	MessagesSent     int `gorm:"not null;default:0" json:"messages_sent"`
+	MessagesReceived int `gorm:"not null;default:0" json:"messages_received"`
+
+	// Messages the user sent while over their quota, which weren't answered
+	MessagesThrottled int `gorm:"not null;default:0" json:"messages_throttled"`
+
+	// Nudges we sent, and how many the user replied to
+	NudgesSent    int `gorm:"not null;default:0" json:"nudges_sent"`
+	NudgesReplied int `gorm:"not null;default:0" json:"nudges_replied"`
+
+	// How long the user took to answer our messages
+	Responses              int     `gorm:"not null;default:0" json:"responses"`
+	ResponseLatencySeconds float64 `gorm:"not null;default:0" json:"response_latency_seconds"`
+
+	// The VADER compound scores of the user's messages, from -1 to 1
+	SentimentSamples   int     `gorm:"not null;default:0" json:"sentiment_samples"`
+	VaderCompoundTotal float64 `gorm:"not null;default:0" json:"vader_compound_total"`
+
+	CreatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"created_at"`
+	UpdatedAt time.Time `gorm:"default:CURRENT_TIMESTAMP" json:"updated_at"`
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+)
+
+const (
+	dateFormat = "2006-01-02"
+
+	// Until we store the user's timezone, days are days in Los Angeles
+	defaultTimezone = "America/Los_Angeles"
+
+	// How far back a timeline without a start date goes
+	defaultDays  = 30
+	defaultWeeks = 12
+
+	// maxDays keeps a single request from reading years of scores
+	maxDays = 366
+)
+
+type MoodLambdaHandler struct {
+	lib.LambdaHandler
+
+	NRCLexRepository *nrclex.Repository
+	Now              func() time.Time
+}
+
+// MoodQuery is the range and shape of the timeline being asked for.
+type MoodQuery struct {
+	From    time.Time
+	To      time.Time
+	Options emotions.TimelineOptions
+}
+
+// MoodResponse is the signed in user's mood timeline.
+type MoodResponse struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Interval string            `json:"interval"`
+	Timezone string            `json:"timezone"`
+	Periods  []emotions.Period `json:"periods"`
+}
+
+func (h *MoodLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch request.HTTPMethod {
+	case "GET":
+
+		return h.Timeline(request)
+
+		// Enable cors Preflight
+	case "OPTIONS":
+		return events.APIGatewayProxyResponse{
+			Headers:    config.DefaultHttpHeaders,
+			StatusCode: http.StatusOK,
+		}, nil
+	default:
+
+		return lib.RespondWithError("Unsupported HTTP method", nil, http.StatusMethodNotAllowed)
+	}
+}
+
+// Timeline returns the signed in user's emotion scores averaged by day or
+// week, with a moving average and notable changes in mood.
+//
+//	GET /mood?interval=week&from=2024-03-01&to=2024-06-01&window=4&threshold=0.3&tz=America/Chicago
+func (h *MoodLambdaHandler) Timeline(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	query, err := h.ParseQuery(request.QueryStringParameters)
+
+	if err != nil {
+
+		return lib.RespondWithError("Invalid query", err, http.StatusBadRequest)
+	}
+
+	// The range is whole days, so it ends at the start of the day after
+	scores, err := h.NRCLexRepository.FindByUserIDBetween(userID, query.From, query.To.AddDate(0, 0, 1))
+
+	if err != nil {
+
+		return lib.RespondWithError("Error getting emotion scores", err, http.StatusInternalServerError)
+	}
+
+	return h.respond(http.StatusOK, MoodResponse{
+		From:     query.From.Format(dateFormat),
+		To:       query.To.Format(dateFormat),
+		Interval: query.Options.Interval,
+		Timezone: query.Options.Location.String(),
+		Periods:  emotions.Timeline(scores, query.Options),
+	})
+}
+
+// ParseQuery reads the timeline's range and options from the query string.
+// By default it's the last 30 days, by day, in Los Angeles time.
+func (h *MoodLambdaHandler) ParseQuery(params map[string]string) (*MoodQuery, error) {
+
+	timezone := defaultTimezone
+
+	if value := params["tz"]; value != "" {
+		timezone = value
+	}
+
+	location, err := time.LoadLocation(timezone)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	query := &MoodQuery{
+		Options: emotions.TimelineOptions{
+			Interval:  emotions.IntervalDay,
+			Location:  location,
+			Window:    emotions.DefaultWindow,
+			Threshold: emotions.DefaultThreshold,
+		},
+	}
+
+	if value := params["interval"]; value != "" {
+		query.Options.Interval = value
+	}
+
+	if value := params["window"]; value != "" {
+		if query.Options.Window, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid window: %w", err)
+		}
+	}
+
+	if value := params["threshold"]; value != "" {
+		if query.Options.Threshold, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid threshold: %w", err)
+		}
+	}
+
+	if err = query.Options.Validate(); err != nil {
+		return nil, err
+	}
+
+	now := h.Now().In(location)
+	query.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location)
+
+	if value := params["to"]; value != "" {
+		if query.To, err = time.ParseInLocation(dateFormat, value, location); err != nil {
+			return nil, fmt.Errorf("invalid to date: %w", err)
+		}
+	}
+
+	query.From = query.To.AddDate(0, 0, -defaultDays)
+
+	if query.Options.Interval == emotions.IntervalWeek {
+		query.From = query.To.AddDate(0, 0, -7*defaultWeeks)
+	}
+
+	if value := params["from"]; value != "" {
+		if query.From, err = time.ParseInLocation(dateFormat, value, location); err != nil {
+			return nil, fmt.Errorf("invalid from date: %w", err)
+		}
+	}
+
+	if query.To.Before(query.From) {
+		return nil, fmt.Errorf("the range ends before it starts")
+	}
+
+	if query.To.Sub(query.From) > maxDays*24*time.Hour {
+		return nil, fmt.Errorf("the range can be at most %d days", maxDays)
+	}
+
+	return query, nil
+}
+
+func (h *MoodLambdaHandler) respond(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+
+	responseBytes, err := json.Marshal(body)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error marshaling response", err, http.StatusInternalServerError)
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    config.DefaultHttpHeaders,
+		StatusCode: statusCode,
+		Body:       string(responseBytes),
+	}, nil
+}
+
+func main() {
+
+	log.New("Mood Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config")
+	}
+
+	database := db.Get(cfg)
+
+	handler := MoodLambdaHandler{
+		NRCLexRepository: nrclex.NewRepository(database),
+		Now:              time.Now,
+	}
+	handler.Init(database)
+
+	lambda.Start(handler.HandleRequest)
+}
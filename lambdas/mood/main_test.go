@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+)
+
+// 8pm on June 11th in Los Angeles
+var now = time.Date(2024, 6, 12, 3, 0, 0, 0, time.UTC)
+
+func newHandler(t *testing.T) (*MoodLambdaHandler, sqlmock.Sqlmock) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &MoodLambdaHandler{
+		NRCLexRepository: nrclex.NewRepository(db),
+		Now:              func() time.Time { return now },
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func request(userID string, params map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:            "GET",
+		QueryStringParameters: params,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{lib.AuthorizerUserIDKey: userID},
+		},
+	}
+}
+
+func TestMoodLambdaHandler_Timeline(t *testing.T) {
+
+	handler, mock := newHandler(t)
+
+	mock.ExpectQuery("SELECT \\* FROM `nrclex` WHERE user_id = \\?").
+		WithArgs(5, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 8, 0, 0, 0, 0, time.UTC)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "vader_compound", "joy", "created_at"}).
+			AddRow(1, 5, 0.6, 0.4, time.Date(2024, 6, 2, 12, 0, 0, 0, time.UTC)).
+			AddRow(2, 5, 0.4, 0.2, time.Date(2024, 6, 2, 13, 0, 0, 0, time.UTC)).
+			AddRow(3, 5, -0.5, 0.0, time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC)))
+
+	response, err := handler.HandleRequest(request("5", map[string]string{
+		"from": "2024-06-01",
+		"to":   "2024-06-07",
+		"tz":   "UTC",
+	}))
+
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+	body := MoodResponse{}
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &body))
+
+	assert.Equal(t, emotions.IntervalDay, body.Interval)
+	require.Len(t, body.Periods, 2)
+	assert.InDelta(t, 0.5, body.Periods[0].VaderCompound, 0.0001)
+	assert.InDelta(t, 0.3, body.Periods[0].Emotions.Joy, 0.0001)
+	require.NotNil(t, body.Periods[1].Change)
+	assert.Equal(t, emotions.ChangeDeclined, body.Periods[1].Change.Direction)
+	assert.Equal(t, "joy", body.Periods[1].Change.Emotion)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoodLambdaHandler_Timeline_Unauthorized(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	response, err := handler.HandleRequest(events.APIGatewayProxyRequest{HTTPMethod: "GET"})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestMoodLambdaHandler_Timeline_InvalidQuery(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	response, err := handler.HandleRequest(request("5", map[string]string{"interval": "month"}))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
+func TestParseQuery_Defaults(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	query, err := handler.ParseQuery(nil)
+	require.NoError(t, err)
+
+	pst := query.Options.Location
+	assert.Equal(t, defaultTimezone, pst.String())
+	assert.Equal(t, time.Date(2024, 6, 11, 0, 0, 0, 0, pst), query.To, "Today is the user's today")
+	assert.Equal(t, time.Date(2024, 5, 12, 0, 0, 0, 0, pst), query.From)
+	assert.Equal(t, emotions.DefaultWindow, query.Options.Window)
+
+	query, err = handler.ParseQuery(map[string]string{"interval": "week"})
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2024, 3, 19, 0, 0, 0, 0, pst), query.From)
+}
+
+func TestParseQuery_Invalid(t *testing.T) {
+
+	handler, _ := newHandler(t)
+
+	for _, params := range []map[string]string{
+		{"tz": "Mars/Olympus_Mons"},
+		{"window": "a week"},
+		{"window": "0"},
+		{"threshold": "-1"},
+		{"from": "last week"},
+		{"from": "2024-06-10", "to": "2024-06-01"},
+		{"from": "2020-01-01", "to": "2024-06-01"},
+	} {
+		_, err := handler.ParseQuery(params)
+		assert.Error(t, err, params)
+	}
+}
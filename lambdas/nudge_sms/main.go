@@ -1,8 +1,8 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"math"
 	"slices"
 	"strconv"
 	"sync"
@@ -10,15 +10,23 @@ import (
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	openapi "github.com/twilio/twilio-go/rest/api/v2010"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/kmesiab/equilibria/lambdas/lib"
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
 	"github.com/kmesiab/equilibria/lambdas/lib/message"
-	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/lib/transaction"
+	"github.com/kmesiab/equilibria/lambdas/lib/usage"
 	"github.com/kmesiab/equilibria/lambdas/lib/user"
 	"github.com/kmesiab/equilibria/lambdas/lib/utils"
 	"github.com/kmesiab/equilibria/lambdas/models"
@@ -39,6 +47,21 @@ type NudgeSMSLambdaHandler struct {
 	UserService       *user.UserService
 	MemoryService     *message.MemoryService
 	CompletionService ai.CompletionServiceInterface
+	Channels          *channel.Registry
+
+	TransactionRepository *transaction.TransactionRepository
+
+	// Prompts picks the version of the nudge prompt each user sees.
+	// Without it, everyone gets the newest embedded version.
+	Prompts *prompts.Service
+
+	// Outbound publishes the nudges we send, for the emotions lambda
+	Outbound *sqs.OutboundTopic
+
+	Metrics *metrics.Recorder
+
+	// Usage throttles users over their monthly quota
+	Usage *usage.Service
 
 	MaxNewMemories         int
 	MaxOldMemories         int
@@ -47,6 +70,9 @@ type NudgeSMSLambdaHandler struct {
 
 func (h *NudgeSMSLambdaHandler) HandleRequest(e events.EventBridgeEvent) error {
 
+	ctx, span := tracing.Start("nudge_sms", propagation.MapCarrier{})
+	defer span.End()
+
 	log.New(
 		"Looking up users without conversations since %s",
 		h.NudgeIfNoMessagesSince.Format("2006-01-02 15:04:05"),
@@ -72,7 +98,7 @@ func (h *NudgeSMSLambdaHandler) HandleRequest(e events.EventBridgeEvent) error {
 
 		if !u.NudgesEnabled() {
 
-			log.New("User %s is not nudging", u.PhoneNumber).Log()
+			log.New("User %d is not nudging", u.ID).Log()
 
 			continue
 		}
@@ -89,12 +115,28 @@ func (h *NudgeSMSLambdaHandler) HandleRequest(e events.EventBridgeEvent) error {
 				return
 			}
 
-			err := h.Nudge(u)
+			// Heavy users aren't nudged until their quota resets next month
+			if h.Usage.Throttled(u) {
+				h.Metrics.Record(nil, metrics.Count(metrics.QuotaExceeded, 1))
+
+				return
+			}
+
+			// The users are nudged concurrently, so each gets their own
+			// span, passed down rather than made the current one
+			userCtx, userSpan := tracing.SpanFrom(ctx, "nudge")
+			userSpan.SetAttributes(attribute.Int64("user.id", u.ID))
+			defer userSpan.End()
+
+			err := h.Nudge(userCtx, u)
 
 			// Nudge failed for some reason
 			if err != nil {
+				userSpan.RecordError(err)
 
-				log.New("Error nudging user %s", u.PhoneNumber).Log()
+				log.New("Error nudging user %d", u.ID).Log()
+
+				h.Metrics.Record(nil, metrics.Count(metrics.NudgesFailed, 1))
 			}
 		}(&u, wg)
 	}
@@ -108,14 +150,13 @@ func (h *NudgeSMSLambdaHandler) HandleRequest(e events.EventBridgeEvent) error {
 	return nil
 }
 
-func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
+// Nudge sends [user] a nudge, in the trace of [ctx].
+func (h *NudgeSMSLambdaHandler) Nudge(ctx context.Context, user *models.User) error {
 
 	memories, err := h.GetMemories(user)
 
-	slices.Reverse(*memories)
-
 	if err != nil {
-		log.New("Error retrieving memories for user %s", user.PhoneNumber).
+		log.New("Error retrieving memories for user %d", user.ID).
 			AddError(err).AddUser(user).Log()
 
 		return err
@@ -123,50 +164,33 @@ func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
 
 	memoryDumpString := MemoriesToString(memories)
 
-	// The number of messages I've sent to the system.
-	myMemories := utils.FilterSlice(*memories, func(m models.Message) bool {
-		return m.FromUserID != models.GetSystemUser().ID
-	})
-
-	var promptModifier string
+	promptTemplate, err := h.Prompt(user)
 
-	log.New("Total User Texts: %d", len(myMemories)).Log()
-
-	if len(myMemories) < NumMemoriesToBeConsideredExistingUser {
-		log.New("Using new user prompt modifier").AddUser(user).Log()
-
-		promptModifier = NudgePromptNewUserModifier
-	} else {
-		log.New("Using existing user prompt modifier").AddUser(user).Log()
+	if err != nil {
+		log.New("Error choosing prompt: %s. Exiting.", err.Error()).AddUser(user).Log()
 
-		promptModifier = NudgePromptExistingUserModifier
+		return err
 	}
 
-	// Convert date to PST.  In the future we will use the user's timezone
-	location, err := time.LoadLocation("America/Los_Angeles")
+	prompt, myMemories, err := BuildPrompt(promptTemplate, user, *memories, time.Now())
 
 	if err != nil {
-		log.New("Fatal Error loading PST location: %s. Exiting.", err.Error()).AddUser(user).Log()
+		log.New("Fatal Error building prompt: %s. Exiting.", err.Error()).AddUser(user).Log()
 
 		return err
 	}
 
-	pstDate := time.Now().In(location)
-	formattedDate := pstDate.Format("January 2, 2006 3:04 PM")
-
-	hoursSinceLastMessage := h.GetTimeStringSinceLastMessage(myMemories)
-	prompt := fmt.Sprintf(NudgePrompt, promptModifier, formattedDate, hoursSinceLastMessage, user.Firstname)
-
 	log.New("Attaching %d memories", len(*memories)).
 		Add("memory_dump", memoryDumpString).
 		Add("prompt", prompt).
+		Add("prompt_version", promptTemplate.ID()).
 		AddUser(user).
 		Log()
 
-	completion, err := h.CompletionService.GetCompletion(prompt, prompt, &myMemories)
+	completion, err := ai.ForUser(h.CompletionService, user.ID).GetCompletion(prompt, prompt, &myMemories)
 
 	if err != nil {
-		log.New("Error retrieving a few older memories for user %s", user.PhoneNumber).
+		log.New("Error retrieving a few older memories for user %d", user.ID).
 			AddError(err).AddUser(user).Log()
 
 		return err
@@ -178,11 +202,11 @@ func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
 		newMessage *models.Message
 	)
 
-	log.New("Starting conversation for %s", user.PhoneNumber).AddUser(user).Log()
+	log.New("Starting conversation for user %d", user.ID).AddUser(user).Log()
 
 	// Start the conversation
 	if convo, err = h.CreateConversation(user, completion, now); err != nil {
-		log.New("Error closing nudge conversation for %s", user.PhoneNumber).
+		log.New("Error closing nudge conversation for user %d", user.ID).
 			Add("completion", completion).
 			AddUser(user).
 			AddError(err).
@@ -191,36 +215,60 @@ func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
 		return err
 	}
 
-	log.New("Sending nudge SMS to %s", user.PhoneNumber).
+	// Nudge the user on the channel they prefer
+	nudgeChannel := h.Channels.ForUser(user)
+
+	// Add this message to the conversation before sending it, so a
+	// failed send leaves a record behind
+	if newMessage, err = h.CreateMessage(ctx, user, convo, nudgeChannel, completion, promptTemplate); err != nil {
+		log.New("Error creating message for user %d", user.ID).
+			Add("completion", completion).
+			AddUser(user).
+			AddError(err).
+			Log()
+
+		return err
+	}
+
+	log.New("Sending nudge %s to user %d", nudgeChannel.MessageType().Name, user.ID).
 		Add("conversation_id", strconv.FormatInt(convo.ID, 10)).
 		Add("completion", completion).
 		AddUser(user).
 		Log()
 
-	smsResponse, err := h.SendSms(user, completion)
+	result, err := h.Send(ctx, nudgeChannel, user, completion)
 
 	if err != nil {
-		log.New("Error sending SMS for %s: %s", user.PhoneNumber, err.Error()).
+		log.New("Error sending SMS for user %d", user.ID).
 			Add("completion", completion).
 			AddError(err).
 			AddUser(user).
 			Log()
 
+		newMessage.MessageStatusID = models.NewMessageStatusFailed().ID
+
+		if uErr := h.MessageService.UpdateStatus(newMessage); uErr != nil {
+			log.New("Error: Marking nudge as failed").
+				AddUser(user).AddError(uErr).AddMessage(newMessage).Log()
+		}
+
 		return err
 	}
 
-	// Add this message to the conversation
-	if newMessage, err = h.CreateMessage(user, convo, completion, *smsResponse.Sid); err != nil {
-		log.New("Error creating message for %s", user.PhoneNumber).
-			Add("completion", completion).
-			AddUser(user).
-			AddError(err).
-			Log()
+	h.Metrics.Record(
+		metrics.Dimensions{"Channel": nudgeChannel.MessageType().Name},
+		metrics.Count(metrics.NudgesSent, 1),
+	)
 
-		return err
+	h.Sent(user, newMessage, result)
+	h.Bill(user, newMessage)
+
+	if err = h.Outbound.Publish(ctx, newMessage); err != nil {
+		log.New("Error publishing nudge for user %d", user.ID).
+			AddMessage(newMessage).AddUser(user).AddError(err).Log()
 	}
 
-	log.New("Closing conversation for %s", user.PhoneNumber).
+	log.New("Closing conversation for user %d", user.ID).
 		Add("conversation_id", strconv.FormatInt(convo.ID, 10)).
 		Add("completion", completion).
 		AddMessage(newMessage).
@@ -230,7 +278,7 @@ func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
 	err = h.ConversationService.EndConversation(convo.ID)
 
 	if err != nil {
-		log.New("Error closing nudge conversation for %s", user.PhoneNumber).
+		log.New("Error closing nudge conversation for user %d", user.ID).
 			Add("completion", completion).
 			AddMessage(newMessage).
 			AddUser(user).
@@ -242,35 +290,13 @@ func (h *NudgeSMSLambdaHandler) Nudge(user *models.User) error {
 	return nil
 }
 
-func (h *NudgeSMSLambdaHandler) GetTimeStringSinceLastMessage(myMemories []models.Message) string {
-
-	if len(myMemories) == 0 {
-		return "a while"
-	}
-
-	mostRecentMemory := myMemories[len(myMemories)-1]
-	localTime := mostRecentMemory.CreatedAt.In(time.Local)
-
-	// Duration since last message
-	duration := time.Since(localTime)
-	hoursSinceLastMessage := int(math.Round(duration.Hours()))
-	timeIntervalSinceLastMessage := fmt.Sprintf("%d hours", hoursSinceLastMessage)
-
-	if hoursSinceLastMessage < 1 {
-		timeIntervalSinceLastMessage = fmt.Sprintf("%d minutes", int(math.Round(duration.Minutes())))
-	}
-
-	return timeIntervalSinceLastMessage
-}
-
 func (h *NudgeSMSLambdaHandler) GetMemories(user *models.User) (*[]models.Message, error) {
 
 	lastFewMemories, err := h.MemoryService.GetLastNMessagePairs(user, h.MaxNewMemories)
 
 	if err != nil {
-		log.New(
-			"Error retrieving last few memories for user %s",
-			user.PhoneNumber).Log()
+		log.New("Error retrieving last few memories for user %d", user.ID).
+			AddError(err).Log()
 
 		return nil, err
 	}
@@ -278,62 +304,84 @@ func (h *NudgeSMSLambdaHandler) GetMemories(user *models.User) (*[]models.Messag
 	aFewOlderMemories, err := h.MemoryService.GetRandomMessagePairs(user, h.MaxOldMemories)
 
 	if err != nil {
-		log.New(
-			"Error retrieving a few older memories for user %s",
-			user.PhoneNumber).Log()
+		log.New("Error retrieving a few older memories for user %d", user.ID).
+			AddError(err).Log()
 
 		return nil, err
 	}
 
-	memories := append(*lastFewMemories, *aFewOlderMemories...)
+	memories := OrderMemories(*lastFewMemories, *aFewOlderMemories)
 
 	return &memories, nil
 }
 
-func (h *NudgeSMSLambdaHandler) SendSms(recipient *models.User, completion string) (*openapi.ApiV2010Message, error) {
+// OrderMemories combines the most recent messages with a few older ones,
+// oldest first.
+func OrderMemories(lastFewMemories, olderMemories []models.Message) []models.Message {
 
-	smsResponse, err := twilio.SendSMS(
-		models.GetSystemUser().PhoneNumber, recipient.PhoneNumber, completion,
-	)
+	memories := append(lastFewMemories, olderMemories...)
+	slices.Reverse(memories)
 
-	if err != nil {
+	return memories
+}
 
-		return nil, err
+// Prompt returns the version of the nudge prompt [user] is assigned.
+func (h *NudgeSMSLambdaHandler) Prompt(user *models.User) (*prompts.Prompt, error) {
+
+	if h.Prompts == nil {
+		return prompts.Default().Latest(prompts.Nudge), nil
 	}
 
-	if smsResponse == nil {
+	return h.Prompts.ForUser(user, prompts.Nudge)
+}
+
+func (h *NudgeSMSLambdaHandler) Send(ctx context.Context, nudgeChannel channel.Channel, recipient *models.User, completion string) (*messaging.SendResult, error) {
+
+	result, err := nudgeChannel.Send(ctx, models.GetSystemUser(), recipient, completion)
 
-		return nil, fmt.Errorf("error SMS response is empty")
+	if err != nil {
+
+		return nil, err
 	}
 
-	log.New("Successfully sent outbound nudge message from %s to %s",
-		models.GetSystemUser().PhoneNumber, recipient.PhoneNumber,
-	).
-		Add("status", *smsResponse.Status).
-		Add("sms_message_id", *smsResponse.Sid).
-		Add("num_segments", *smsResponse.NumSegments).
+	log.New("Successfully sent outbound nudge message to user %d", recipient.ID).
+		Add("channel", nudgeChannel.MessageType().Name).
+		Add("reference_id", result.ReferenceID).
 		Log()
 
-	return smsResponse, nil
+	return result, nil
 }
 
-func (h *NudgeSMSLambdaHandler) CreateMessage(recipient *models.User, conversation *models.Conversation, completion, refID string) (*models.Message, error) {
+func (h *NudgeSMSLambdaHandler) CreateMessage(
+	ctx context.Context,
+	recipient *models.User,
+	conversation *models.Conversation,
+	nudgeChannel channel.Channel,
+	completion string,
+	prompt *prompts.Prompt,
+) (*models.Message, error) {
 
-	var now = time.Now()
+	promptVersion := prompt.ID()
+
+	messageType := nudgeChannel.MessageType()
 
 	newMessage := &models.Message{
 		FromUserID:      models.GetSystemUser().ID,
 		ToUserID:        recipient.ID,
-		ReferenceID:     &refID,
-		MessageType:     models.NewMessageTypeSMS(),
-		MessageStatusID: models.NewMessageStatusSent().ID,
+		MessageType:     messageType,
+		MessageTypeID:   messageType.ID,
+		MessageStatusID: models.NewMessageStatusSending().ID,
 		MessageStatus:   models.NewMessageStatusSending(),
 		Body:            completion,
-		SentAt:          &now,
 		ConversationID:  conversation.ID,
 		To:              *recipient,
+
+		PromptVersion: &promptVersion,
+		TraceParent:   tracing.ParentOf(ctx),
 	}
 
+	channel.EstimateSegments(nudgeChannel, newMessage)
+
 	if err := h.MessageService.CreateMessage(newMessage); err != nil {
 
 		return nil, err
@@ -342,6 +390,57 @@ func (h *NudgeSMSLambdaHandler) CreateMessage(recipient *models.User, conversati
 	return newMessage, nil
 }
 
+// Sent records that the provider accepted [msg]. The nudge has already
+// gone out, so failures are logged rather than returned.
+func (h *NudgeSMSLambdaHandler) Sent(recipient *models.User, msg *models.Message, result *messaging.SendResult) {
+
+	sentAt := time.Now().UTC()
+	msg.SentAt = &sentAt
+	msg.MessageStatus = models.NewMessageStatusSent()
+
+	if result.NumSegments > 0 {
+		msg.NumSegments = &result.NumSegments
+	}
+
+	if result.ReferenceID != "" {
+		msg.ReferenceID = &result.ReferenceID
+	} else {
+		// Channels with no provider, like web chat, deliver immediately
+		msg.MessageStatus = models.NewMessageStatusDelivered()
+	}
+
+	msg.MessageStatusID = msg.MessageStatus.ID
+
+	if err := h.MessageService.UpdateMessage(msg); err != nil {
+		log.New("Error: Updating nudge with reference ID").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
+}
+
+// Bill debits the recipient for a nudge, at the rate of the channel it
+// was sent on, the same as a reply. Billing failures are logged, rather
+// than holding up the nudge.
+func (h *NudgeSMSLambdaHandler) Bill(recipient *models.User, msg *models.Message) {
+
+	if h.TransactionRepository == nil {
+		return
+	}
+
+	_, err := h.TransactionRepository.Debit(
+		recipient.ID,
+		msg.ConversationID,
+		msg.MessageType.BillRateInCredits,
+		models.FundingSourceStringCustomerCredit,
+		strconv.FormatInt(msg.ID, 10),
+		fmt.Sprintf("%s nudge", msg.MessageType.Name),
+	)
+
+	if err != nil {
+		log.New("Error billing for nudge %d", msg.ID).
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
+}
+
 func (h *NudgeSMSLambdaHandler) CreateConversation(recipient *models.User, completion string, now time.Time) (*models.Conversation, error) {
 
 	// Start a new conversation
@@ -354,7 +453,7 @@ func (h *NudgeSMSLambdaHandler) CreateConversation(recipient *models.User, compl
 	err := h.ConversationService.CreateConversation(convo)
 
 	if err != nil || convo.ID == 0 {
-		log.New("Error creating a new conversation for %s", recipient.PhoneNumber).
+		log.New("Error creating a new conversation for user %d", recipient.ID).
 			Add("completion", completion).
 			AddUser(recipient).
 			AddError(err).
@@ -375,6 +474,12 @@ func main() {
 		log.New("Could not load config")
 	}
 
+	if err := tracing.Init(cfg, "nudge_sms"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
 	database := db.Get(cfg)
 
 	if err := utils.PingDatabase(database); err != nil {
@@ -383,7 +488,7 @@ func main() {
 		return
 	}
 
-	if err := utils.PingGoogle(); err != nil {
+	if err := utils.PingURL(cfg.ConnectivityCheckURL); err != nil {
 		log.New("Error pinging Google. Possible bad internet connection.").
 			AddError(err).Log()
 
@@ -400,17 +505,51 @@ func main() {
 		message.NewMessageRepository(database),
 	)
 
+	recorder, err := metrics.New(cfg, "nudge_sms")
+
+	if err != nil {
+		log.New("Error creating metrics recorder. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	usageSvc := usage.NewService(usage.NewRepository(database), "nudge_sms", cfg.LLMMonthlyCostQuota)
+
 	llmSvc := &ai.OpenAICompletionService{
 		RemoveEmojis: false,
+		Metrics:      recorder,
+		Ledger:       usageSvc,
+	}
+
+	provider, err := messaging.New(cfg)
+
+	if err != nil {
+		log.New("Error creating messaging provider. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	outbound, err := sqs.NewOutboundTopic(cfg.OutboundSNSTopicARN)
+
+	if err != nil {
+		log.New("Error creating outbound topic publisher. Shutting down.").AddError(err).Log()
+
+		return
 	}
 
 	handler := &NudgeSMSLambdaHandler{
 		UserService:            usrSvc,
 		MemoryService:          memSvc,
 		CompletionService:      llmSvc,
+		Channels:               channel.NewDefaultRegistry(provider, cfg.SMSMaxSegmentsPerMessage),
+		TransactionRepository:  transaction.NewTransactionRepository(database),
+		Prompts:                prompts.NewService(prompts.Default(), prompts.NewRepository(database)),
 		MaxNewMemories:         MaxNewMemories,
 		MaxOldMemories:         MaxOldMemories,
 		NudgeIfNoMessagesSince: TimeSinceLastMessage,
+		Outbound:               outbound,
+		Metrics:                recorder,
+		Usage:                  usageSvc,
 	}
 
 	handler.Init(database)
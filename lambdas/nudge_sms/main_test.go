@@ -1,56 +1,151 @@
-package main_test
+package main
 
-/*
 import (
-	"sync"
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
-	"github.com/kmesiab/equilibria/lambdas/lib/test"
-	"github.com/kmesiab/equilibria/lambdas/models"
-	"github.com/kmesiab/equilibria/lambdas/nudge_sms"
-
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
 	"github.com/kmesiab/equilibria/lambdas/lib/message"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/lib/transaction"
+	"github.com/kmesiab/equilibria/lambdas/lib/user"
+	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
+const patientID = int64(3)
+
+func newHandler(t *testing.T, provider messaging.Provider) (*NudgeSMSLambdaHandler, sqlmock.Sqlmock) {
 
-func TestNudgeSMSLambdaHandler_HandleRequest(t *testing.T) {
 	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	handler := &NudgeSMSLambdaHandler{
+		UserService:           user.NewUserService(user.NewUserRepository(db)),
+		MemoryService:         message.NewMemoryService(message.NewMessageRepository(db)),
+		CompletionService:     &ai.MockCompletionService{},
+		Channels:              channel.NewDefaultRegistry(provider, 3),
+		TransactionRepository: transaction.NewTransactionRepository(db),
 
-	// Setup mock database
-	database, _, err := test.SetupMockDB()
-	require.NoError(t, err, "Could not set up mock db")
+		MaxNewMemories:         MaxNewMemories,
+		MaxOldMemories:         MaxOldMemories,
+		NudgeIfNoMessagesSince: time.Now().UTC().Add(-HoursSinceLastNudge * time.Hour),
+	}
+	handler.Init(db)
 
-	// database := db.Get(config.Get())
+	return handler, mock
+}
 
-	memSvc := message.NewMemoryService(
-		message.NewMessageRepository(database), 1,
-	)
+func patient() *models.User {
 
-	llmSvc := &ai.OpenAICompletionService{}
+	nudgeEnabled := true
 
-	handler := &main.NudgeSMSLambdaHandler{
-		MemoryService:          memSvc,
-		CompletionService:      llmSvc,
-		MaxMemories:            main.MaxMemories,
-		NudgeIfNoMessagesSince: main.TimeSinceLastMessage,
+	return &models.User{
+		ID:           patientID,
+		PhoneNumber:  "+12533243071",
+		Firstname:    "Kevin",
+		NudgeEnabled: &nudgeEnabled,
 	}
+}
 
-	handler.Init(database)
+// expectNudgeContext expects the user's history to be looked up, and a
+// conversation to be started for the nudge, up to saving the nudge.
+func expectNudgeContext(mock sqlmock.Sqlmock, nudgeID int64) {
 
-	testUser := &models.User{
-		ID:          36,
-		PhoneNumber: "+12533243071",
-		Firstname:   "Kevin",
-	}
+	mock.ExpectQuery("select \\* from messages").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT \\* FROM messages").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `users`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `conversations`").
+		WillReturnResult(sqlmock.NewResult(5, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `message_statuses`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `message_types`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `users`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `messages`").
+		WillReturnResult(sqlmock.NewResult(nudgeID, 1))
+	mock.ExpectCommit()
+}
+
+func TestHandleRequest_Nudge(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	p := patient()
+
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "firstname", "nudge_enabled"}).
+			AddRow(p.ID, p.PhoneNumber, p.Firstname, true))
+
+	expectNudgeContext(mock, 100)
+
+	// The nudge is marked sent, and billed
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `message_statuses`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `message_types`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `users`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE `messages` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `transactions`").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `conversations` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, handler.HandleRequest(events.EventBridgeEvent{}))
+
+	sent := provider.Sent()
+	require.Len(t, sent, 1, "The user should be nudged")
+	assert.Equal(t, models.GetSystemUser().PhoneNumber, sent[0].From)
+	assert.Equal(t, p.PhoneNumber, sent[0].To)
+	assert.Equal(t, "dummy completion", sent[0].Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNudge_SendFailure(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	provider.Err = errors.New("provider is down")
+	handler, mock := newHandler(t, provider)
+
+	expectNudgeContext(mock, 100)
 
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	err = handler.Nudge(testUser, wg)
-	wg.Wait()
+	// The nudge that couldn't be sent is marked failed
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `message_status_id`=\\?").
+		WithArgs(models.NewMessageStatusFailed().ID, sqlmock.AnyArg(), int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
 
-	require.NoError(t, err, "There should be no errors when nudging.")
+	assert.Error(t, handler.Nudge(context.Background(), patient()))
+	assert.Empty(t, provider.Sent())
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
-*/
@@ -1,28 +1,87 @@
 package main
 
-const NudgePrompt = `
-You are an empathetic therapist who is highly skilled in the field of cognitive behavior
-therapy, modern psychology and psychotherapy, and has a deep understanding of
-psychological, CBT, and therapeutic best practices.  You help me via SMS as I check in
-with what's going on in my day and how I feel.
+import (
+	"fmt"
+	"math"
+	"time"
 
-You are reaching out to get an update on me to help you track my mental health better.
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+	"github.com/kmesiab/equilibria/lambdas/lib/utils"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
 
-%s
+// PromptData fills in the nudge prompt template.
+type PromptData struct {
+	// NewUser is true until the user has sent us a few messages
+	NewUser          bool
+	Date             string
+	SinceLastMessage string
+	Name             string
+}
 
-Speak like a qualified therapist, but friend who keeps it real and will tell me
-like it is. Your response must be in the form of a friendly text message and under 
-800 characters.  Do not include any suffixes or sign-offs.  Make it concise and to the point.
+// BuildPrompt fills in [prompt] for [user] at [now], given their
+// [memories] oldest first. It also returns the memories of messages the
+// user sent, which is the history the model sees.
+func BuildPrompt(prompt *prompts.Prompt, user *models.User, memories []models.Message, now time.Time) (string, []models.Message, error) {
 
-Today’s date and time is %s.  It has been %s since we last talked, make sure your
-response is time appropriate, taking into consideration whether or not things i've talked
-about could have come to pass already or has not yet happened.  Make sure your 
-message is appropriate fo the current time of day.
+	// The number of messages I've sent to the system.
+	myMemories := utils.FilterSlice(memories, func(m models.Message) bool {
+		return m.FromUserID != models.GetSystemUser().ID
+	})
 
-Be mindful of sensitive topics in case bringing it up may open old wounds.  
-Craft a brief message in the form of an SMS that encourages me give a quick check in.
+	log.New("Total User Texts: %d", len(myMemories)).Log()
 
-Consider if you've asked me about the sme thing already and don't be repetitive.
+	newUser := len(myMemories) < NumMemoriesToBeConsideredExistingUser
 
-My name is %s 
-`
+	if newUser {
+		log.New("Using new user prompt modifier").AddUser(user).Log()
+	} else {
+		log.New("Using existing user prompt modifier").AddUser(user).Log()
+	}
+
+	// Convert date to PST.  In the future we will use the user's timezone
+	location, err := time.LoadLocation("America/Los_Angeles")
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	pstDate := now.In(location)
+	formattedDate := pstDate.Format("January 2, 2006 3:04 PM")
+
+	systemPrompt, err := prompt.Execute(PromptData{
+		NewUser:          newUser,
+		Date:             formattedDate,
+		SinceLastMessage: SinceLastMessage(myMemories, now),
+		Name:             user.Firstname,
+	})
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	return systemPrompt, myMemories, nil
+}
+
+// SinceLastMessage describes how long it has been between the last of
+// [myMemories] and [now].
+func SinceLastMessage(myMemories []models.Message, now time.Time) string {
+
+	if len(myMemories) == 0 {
+		return "a while"
+	}
+
+	mostRecentMemory := myMemories[len(myMemories)-1]
+
+	// Duration since last message
+	duration := now.Sub(mostRecentMemory.CreatedAt)
+	hoursSinceLastMessage := int(math.Round(duration.Hours()))
+	timeIntervalSinceLastMessage := fmt.Sprintf("%d hours", hoursSinceLastMessage)
+
+	if hoursSinceLastMessage < 1 {
+		timeIntervalSinceLastMessage = fmt.Sprintf("%d minutes", int(math.Round(duration.Minutes())))
+	}
+
+	return timeIntervalSinceLastMessage
+}
@@ -1,17 +0,0 @@
-package main
-
-const NudgePromptExistingUserModifier = `We've been talking for a while now.
-Look over our chat log and identify the most important topic to follow up on.
-
-If I have asked you to drop a subject, do not bring it up again.  If a topic seems
-particularly sensitive, choose a different topic to follow up on.  Keep it short and 
-simple and avoid repeating previous messages.
-`
-
-const NudgePromptNewUserModifier = `
-We're still getting to know each other. Seek to gain trust and build 
-a relationship and get to know me.
-
-Ask me questions that would be useful in tracking my mental health moods and 
-triggers over time.
-`
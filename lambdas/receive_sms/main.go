@@ -2,23 +2,40 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/propagation"
 
 	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/encoding"
 	"github.com/kmesiab/equilibria/lambdas/lib/form_unsmarshaler"
+	"github.com/kmesiab/equilibria/lambdas/lib/idempotency"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
 	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
 	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
+// inboundChannels reads the messages on each channel the webhook receives.
+// Nothing is sent from the webhook, so they have no provider.
+var inboundChannels = channel.NewDefaultRegistry(nil, 0)
+
+// recordingAcknowledgement is read back to a caller after they leave a voice message
+const recordingAcknowledgement = `<?xml version="1.0" encoding="UTF-8"?>
+<Response><Say>Thanks for your message. I'll text you back shortly.</Say><Hangup/></Response>`
+
+// Voice memos are transcribed by send_sms, once the message is stored, so
+// a slow download or transcription doesn't hold up Twilio's webhook.
 type ReceiveSMSLambdaHandler struct {
 	lib.LambdaHandler
 	Sender sqs.SenderInterface
@@ -26,6 +43,10 @@ type ReceiveSMSLambdaHandler struct {
 
 func (h *ReceiveSMSLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
+	// Every text starts a trace that follows it through to the reply
+	_, span := tracing.Start("receive_sms", propagation.MapCarrier(request.Headers))
+	defer span.End()
+
 	if !twilio.IsValidWebhookRequest(request, config.Get().TwilioAuthToken, false) {
 
 		return log.New("Invalid webhook request signature. Rejecting webhook.").
@@ -61,34 +82,86 @@ func (h *ReceiveSMSLambdaHandler) Receive(request events.APIGatewayProxyRequest)
 			AddError(err).Respond(http.StatusBadRequest)
 	}
 
+	// WhatsApp messages arrive on the same webhook, from prefixed
+	// addresses, so let the channel they came in on read them
+	inboundChannel := inboundChannels.ForTwilioAddress(sms.From)
+	messageType := inboundChannel.MessageType()
+
+	inbound, err := inboundChannel.ParseInbound(request)
+
+	if err != nil {
+
+		return log.New("Error parsing %s message", messageType.Name).
+			AddTwilioMessageInfo(sms).AddError(err).Respond(http.StatusBadRequest)
+	}
+
+	sms.From, sms.To = inbound.From, inbound.To
+	attachments := inbound.Media
+
 	if !twilio.IsValidPhoneNumber(sms.From) {
 
 		return log.New("Invalid from phone number %s", sms.From).
 			AddTwilioMessageInfo(sms).Respond(http.StatusBadRequest)
 	}
 
-	// Validate the sms
-	if sms.From == "" || sms.To == "" || sms.Body == "" {
+	// Validate the sms. A picture with no caption is still a message.
+	if sms.From == "" || sms.To == "" || (sms.Body == "" && len(attachments) == 0) {
 
 		return log.New("SMS missing required fields").
 			AddTwilioMessageInfo(sms).Respond(http.StatusBadRequest)
 	}
 
+	// Twilio retries webhooks, so make sure we only store each message once
+	claimed, err := h.IdempotencyService.Claim(models.IdempotencyScopeReceiveSMS, sms.GetReferenceID())
+
+	// An earlier delivery is still storing the message, or stopped
+	// without finishing, in which case its claim will lapse
+	if errors.Is(err, idempotency.ErrInProgress) {
+
+		return log.New("SMS %s is already being received", sms.GetReferenceID()).
+			AddTwilioMessageInfo(sms).Respond(http.StatusConflict)
+	}
+
+	if err != nil {
+
+		return log.New("Error claiming idempotency key for %s", sms.GetReferenceID()).
+			AddError(err).Respond(http.StatusInternalServerError)
+	}
+
+	if !claimed {
+		log.New("SMS %s has already been received. Ignoring duplicate.", sms.GetReferenceID()).
+			AddTwilioMessageInfo(sms).Log()
+
+		return Acknowledge(sms, http.StatusOK), nil
+	}
+
 	// Log to cloudwatch
 	log.New("SMS received. Starting conversation").
 		AddTwilioMessageInfo(sms).Log()
 
 	// Add this message to a new conversation
-	message, err = h.StartConversation(sms)
+	message, err = h.StartConversation(sms, messageType, attachments)
 
 	log.New("Conversation started, sending message to topic").Log()
 
 	if err != nil {
 
+		// Give up the claim so Twilio's retry can try again
+		if rErr := h.IdempotencyService.Release(models.IdempotencyScopeReceiveSMS, sms.GetReferenceID()); rErr != nil {
+			log.New("Error releasing idempotency key for %s", sms.GetReferenceID()).
+				AddError(rErr).Log()
+		}
+
 		return log.New("Error starting a conversation for %s to %s", sms.From, sms.To).
 			AddError(err).Respond(http.StatusInternalServerError)
 	}
 
+	// The message is stored, so a retry has nothing left to do
+	if cErr := h.IdempotencyService.Complete(models.IdempotencyScopeReceiveSMS, sms.GetReferenceID()); cErr != nil {
+		log.New("Error completing idempotency key for %s", sms.GetReferenceID()).
+			AddError(cErr).Log()
+	}
+
 	topicARN := config.Get().SNSTopicARN
 
 	log.New("Sending message to topic %s", topicARN).Log()
@@ -101,11 +174,24 @@ func (h *ReceiveSMSLambdaHandler) Receive(request events.APIGatewayProxyRequest)
 			AddError(err).AddError(fErr).Respond(http.StatusOK)
 	}
 
-	return events.APIGatewayProxyResponse{
+	return Acknowledge(sms, http.StatusCreated), nil
+
+}
+
+// Acknowledge builds the TwiML response to the webhook. Texts need no
+// reply, but a caller who left a voice message is thanked before hanging up.
+func Acknowledge(sms *models.TwilioMessageInfo, statusCode int) events.APIGatewayProxyResponse {
+
+	response := events.APIGatewayProxyResponse{
 		Headers:    map[string]string{"Content-Type": "text/xml"},
-		StatusCode: http.StatusCreated,
-	}, nil
+		StatusCode: statusCode,
+	}
+
+	if sms.IsRecording() {
+		response.Body = recordingAcknowledgement
+	}
 
+	return response
 }
 
 func (h *ReceiveSMSLambdaHandler) Fail(message *models.Message) error {
@@ -122,7 +208,9 @@ func (h *ReceiveSMSLambdaHandler) Fail(message *models.Message) error {
 	return nil
 }
 
-func (h *ReceiveSMSLambdaHandler) StartConversation(sms *models.TwilioMessageInfo) (*models.Message, error) {
+func (h *ReceiveSMSLambdaHandler) StartConversation(
+	sms *models.TwilioMessageInfo, messageType models.MessageType, attachments []models.MessageMedia,
+) (*models.Message, error) {
 
 	var (
 		fromUser     *models.User         // The user identified by phone number
@@ -140,7 +228,8 @@ func (h *ReceiveSMSLambdaHandler) StartConversation(sms *models.TwilioMessageInf
 	}
 
 	// Package the sms into a message struct
-	msg = h.NewMessage(sms, fromUser, toUser)
+	msg = h.NewMessage(sms, messageType, fromUser, toUser)
+	msg.Media = attachments
 
 	// Every inbound message gets a new conversation
 	if conversation, err = h.CreateConversation(msg); err != nil {
@@ -163,6 +252,9 @@ func (h *ReceiveSMSLambdaHandler) StartConversation(sms *models.TwilioMessageInf
 		return nil, fmt.Errorf("error fetching newly created message: %s", err)
 	}
 
+	// Media isn't preloaded, but the sender needs it to describe the images
+	msg.Media = attachments
+
 	return msg, nil
 
 }
@@ -183,18 +275,27 @@ func (h *ReceiveSMSLambdaHandler) CreateConversation(msg *models.Message) (*mode
 	return conversation, nil
 }
 
-func (h *ReceiveSMSLambdaHandler) NewMessage(sms *models.TwilioMessageInfo, fromUser, toUser *models.User) *models.Message {
+func (h *ReceiveSMSLambdaHandler) NewMessage(sms *models.TwilioMessageInfo, messageType models.MessageType, fromUser, toUser *models.User) *models.Message {
 	now := time.Now()
+	referenceID := sms.GetReferenceID()
 	msg := &models.Message{
-		ReferenceID:     &sms.SmsSid,
+		ReferenceID:     &referenceID,
 		FromUserID:      fromUser.ID,
 		ToUserID:        toUser.ID,
 		ReceivedAt:      &now,
-		MessageTypeID:   models.NewMessageTypeSMS().ID,
+		MessageTypeID:   messageType.ID,
 		MessageStatusID: models.NewMessageStatusReceived().ID,
+		TraceParent:     tracing.Parent(),
 	}
 	msg.Body = sms.Body
 
+	// Twilio says how many segments a text arrived in
+	if numSegments, err := strconv.Atoi(sms.NumSegments); err == nil && numSegments > 0 {
+		segmentEncoding := string(encoding.CountSegments(sms.Body).Encoding)
+		msg.NumSegments = &numSegments
+		msg.SegmentEncoding = &segmentEncoding
+	}
+
 	return msg
 }
 
@@ -208,6 +309,11 @@ func main() {
 		log.New("Could not load config")
 	}
 
+	if err := tracing.Init(cfg, "receive_sms"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+		return
+	}
+
 	database := db.Get(cfg)
 
 	sender, err := sqs.NewSNSSender()
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"net/http"
+	"net/url"
 	"testing"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/aws/aws-lambda-go/events"
+	"github.com/go-sql-driver/mysql"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -29,7 +32,10 @@ func TestReceiveSMSLambdaHandler_Receive(t *testing.T) {
 	}
 	handler.Init(db)
 
-	// First we look up the user who sent the message
+	// First we claim the message so Twilio retries are ignored
+	test.ExpectMockInsertIdempotencyKey(&mock)
+
+	// Then we look up the user who sent the message
 	//test.ExpectMockSelectUser(&mock, "+12533243071")
 	mock.ExpectQuery("SELECT \\* FROM `users`").
 		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
@@ -45,7 +51,7 @@ func TestReceiveSMSLambdaHandler_Receive(t *testing.T) {
 	test.ExpectMockInsertConversation(&mock)
 
 	// Then we add the message and attach it to the conversation
-	test.ExpectMockInsertMessage(&mock)
+	test.ExpectMockInsertInboundMessage(&mock, 1, "GSM-7")
 
 	// Then we look up the message
 	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE id").
@@ -67,6 +73,9 @@ func TestReceiveSMSLambdaHandler_Receive(t *testing.T) {
 
 	test.ExpectMockSelectUser(&mock, 1)
 
+	// Once the message is stored, a retry has nothing left to do
+	test.ExpectMockCompleteIdempotencyKey(&mock, "receive_sms:SM62876cd3611d64defdece80d9aa1f703")
+
 	response, err := handler.Receive(events.APIGatewayProxyRequest{
 		Body: "ToCountry=US&ToState=&SmsMessageSid=SM62876cd3611d64defdece80d9aa1f703&NumMedia=0&ToCity=&FromZip=98106&SmsSid=SM62876cd3611d64defdece80d9aa1f703&FromState=WA&SmsStatus=received&FromCity=SEATTLE&Body=Why+do+you+say+that%3F+&FromCountry=US&To=%2B18333595081&MessagingServiceSid=MGa3799c565299f143097ff388571be2b2&ToZip=&NumSegments=1&MessageSid=SM62876cd3611d64defdece80d9aa1f703&AccountSid=AC0e8e16c274b3ae7740b1a854b8c9846a&From=%2B12533243071&ApiVersion=2010-04-01",
 	})
@@ -78,6 +87,240 @@ func TestReceiveSMSLambdaHandler_Receive(t *testing.T) {
 
 }
 
+func TestReceiveSMSLambdaHandler_Receive_DuplicateWebhook(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := ReceiveSMSLambdaHandler{
+		Sender: MockSender{},
+	}
+	handler.Init(db)
+
+	// The message has already been received by an earlier delivery
+	expectClaimed(mock, "receive_sms:SM62876cd3611d64defdece80d9aa1f703", models.IdempotencyStatusDone)
+
+	response, err := handler.Receive(events.APIGatewayProxyRequest{
+		Body: "SmsSid=SM62876cd3611d64defdece80d9aa1f703&Body=Why+do+you+say+that%3F+&To=%2B18333595081&From=%2B12533243071",
+	})
+
+	require.NoError(t, err,
+		"error should be nil when handling a duplicate sms receive request")
+
+	assert.Equal(t, 200, response.StatusCode,
+		"A duplicate webhook should be acknowledged without creating a message")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveSMSLambdaHandler_Receive_InProgress(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := ReceiveSMSLambdaHandler{
+		Sender: MockSender{},
+	}
+	handler.Init(db)
+
+	// An earlier delivery is still storing the message
+	expectClaimed(mock, "receive_sms:SM62876cd3611d64defdece80d9aa1f703", models.IdempotencyStatusPending)
+
+	response, err := handler.Receive(events.APIGatewayProxyRequest{
+		Body: "SmsSid=SM62876cd3611d64defdece80d9aa1f703&Body=Why+do+you+say+that%3F+&To=%2B18333595081&From=%2B12533243071",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, response.StatusCode,
+		"A webhook for a message still being received should be retried")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// expectClaimed expects [key] to have already been claimed, and to be
+// [status] with a lease that hasn't expired.
+func expectClaimed(mock sqlmock.Sqlmock, key, status string) {
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `idempotency_keys`").
+		WithArgs(key, models.IdempotencyScopeReceiveSMS, models.IdempotencyStatusPending, sqlmock.AnyArg()).
+		WillReturnError(&mysql.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `idempotency_keys` SET `expires_at`=\\? WHERE idempotency_key = \\? AND status = \\? AND expires_at < \\?").
+		WithArgs(sqlmock.AnyArg(), key, models.IdempotencyStatusPending, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `idempotency_keys` WHERE idempotency_key = \\?").
+		WithArgs(key, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idempotency_key", "scope", "status"}).
+			AddRow(1, key, models.IdempotencyScopeReceiveSMS, status))
+}
+
+func TestReceiveSMSLambdaHandler_Receive_PhotoWithoutCaption(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := ReceiveSMSLambdaHandler{
+		Sender: MockSender{},
+	}
+	handler.Init(db)
+
+	test.ExpectMockInsertIdempotencyKey(&mock)
+
+	mock.ExpectQuery("SELECT \\* FROM `users`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockUserRepositoryUser())
+
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Active"),
+	)
+
+	test.ExpectMockInsertConversation(&mock)
+
+	// The photo is saved along with the message
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `messages`").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectExec("INSERT INTO `message_media`").
+		WithArgs(1, "https://api.twilio.com/2010-04-01/Accounts/AC1/Messages/MM1/Media/ME0", "image/jpeg").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE id").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockMessageRepositoryMessages())
+
+	mock.ExpectQuery("SELECT \\* FROM `conversations`").WithArgs(1).
+		WillReturnRows(test.GenerateMockConversation(false))
+	test.ExpectMockSelectUser(&mock, 1)
+	test.ExpectMockSelectUser(&mock, 1)
+
+	mock.ExpectQuery("SELECT \\* FROM `message_statuses`").
+		WithArgs(models.NewMessageStatusPending().ID).WillReturnRows(test.GenerateMockMessageStatus())
+
+	mock.ExpectQuery("SELECT \\* FROM `message_types`").
+		WithArgs(models.NewMessageTypeSMS().ID).WillReturnRows(test.GenerateMockMessageType())
+
+	test.ExpectMockSelectUser(&mock, 1)
+
+	// Once the message is stored, a retry has nothing left to do
+	test.ExpectMockCompleteIdempotencyKey(&mock, "receive_sms:MM1")
+
+	response, err := handler.Receive(events.APIGatewayProxyRequest{
+		Body: "SmsSid=MM1&NumMedia=1&MediaContentType0=image%2Fjpeg" +
+			"&MediaUrl0=https%3A%2F%2Fapi.twilio.com%2F2010-04-01%2FAccounts%2FAC1%2FMessages%2FMM1%2FMedia%2FME0" +
+			"&Body=&To=%2B18333595081&From=%2B12533243071",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode, "A photo without a caption should be accepted")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveSMSLambdaHandler_Receive_VoiceRecording(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := ReceiveSMSLambdaHandler{
+		Sender: MockSender{},
+	}
+	handler.Init(db)
+
+	// Recordings are claimed by their RecordingSid
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `idempotency_keys`").
+		WithArgs("receive_sms:RE1", models.IdempotencyScopeReceiveSMS, models.IdempotencyStatusPending, sqlmock.AnyArg()).
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `users`").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockUserRepositoryUser())
+
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "name"}).
+			AddRow(1, "Active"),
+	)
+
+	test.ExpectMockInsertConversation(&mock)
+
+	// The recording is stored as it is, for send_sms to transcribe
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `messages`").
+		WithArgs(
+			"RE1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+			"", false,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+		).
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectExec("INSERT INTO `message_media`").
+		WithArgs(1, "https://api.twilio.com/2010-04-01/Accounts/AC1/Recordings/RE1.mp3", "audio/mpeg").
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE id").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockMessageRepositoryMessages())
+
+	mock.ExpectQuery("SELECT \\* FROM `conversations`").WithArgs(1).
+		WillReturnRows(test.GenerateMockConversation(false))
+	test.ExpectMockSelectUser(&mock, 1)
+	test.ExpectMockSelectUser(&mock, 1)
+
+	mock.ExpectQuery("SELECT \\* FROM `message_statuses`").
+		WithArgs(models.NewMessageStatusPending().ID).WillReturnRows(test.GenerateMockMessageStatus())
+
+	mock.ExpectQuery("SELECT \\* FROM `message_types`").
+		WithArgs(models.NewMessageTypeSMS().ID).WillReturnRows(test.GenerateMockMessageType())
+
+	test.ExpectMockSelectUser(&mock, 1)
+
+	// Once the message is stored, a retry has nothing left to do
+	test.ExpectMockCompleteIdempotencyKey(&mock, "receive_sms:RE1")
+
+	response, err := handler.Receive(events.APIGatewayProxyRequest{
+		Body: url.Values{
+			"CallSid":           []string{"CA1"},
+			"RecordingSid":      []string{"RE1"},
+			"RecordingUrl":      []string{"https://api.twilio.com/2010-04-01/Accounts/AC1/Recordings/RE1"},
+			"RecordingDuration": []string{"12"},
+			"To":                []string{"+18333595081"},
+			"From":              []string{"+12533243071"},
+		}.Encode(),
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 201, response.StatusCode)
+	assert.Contains(t, response.Body, "<Say>", "The caller should hear an acknowledgement")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReceiveSMSLambdaHandler_Receive_EmptyMessage(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := ReceiveSMSLambdaHandler{
+		Sender: MockSender{},
+	}
+	handler.Init(db)
+
+	response, err := handler.Receive(events.APIGatewayProxyRequest{
+		Body: "SmsSid=SM1&NumMedia=0&Body=&To=%2B18333595081&From=%2B12533243071",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, 400, response.StatusCode, "A message with no body or media should be rejected")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestHandleRequest_ValidTwilioSignature(t *testing.T) {
 
 	test.SetEnvVars()
@@ -89,7 +332,7 @@ func TestHandleRequest_ValidTwilioSignature(t *testing.T) {
 	test.ExpectMockInsertConversation(&mock)
 
 	// Then we add the message and attach it to the conversation
-	test.ExpectMockInsertMessage(&mock)
+	test.ExpectMockInsertInboundMessage(&mock, 1, "GSM-7")
 
 	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE id").
 		WithArgs(1, sqlmock.AnyArg()).
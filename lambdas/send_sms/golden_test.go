@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/lib/golden"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+)
+
+func TestGoldenConversations(t *testing.T) {
+
+	prompt := prompts.Default().Latest(prompts.Reply)
+
+	golden.Replay(t, "testdata/golden", func(transcript *golden.Transcript, svc ai.CompletionServiceInterface) error {
+
+		memories := OrderMemories(transcript.RecentMessages(), transcript.OlderMessages())
+		systemPrompt, err := BuildPrompt(prompt, transcript.User(), len(memories), transcript.FactModels(), nil, transcript.Now)
+
+		if err != nil {
+			return err
+		}
+
+		_, err = svc.GetCompletion(transcript.Message, systemPrompt, &memories)
+
+		return err
+	})
+}
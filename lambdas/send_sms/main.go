@@ -2,26 +2,40 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"runtime/debug"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
 
 	"github.com/kmesiab/equilibria/lambdas/lib"
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
 	"github.com/kmesiab/equilibria/lambdas/lib/facts"
+	"github.com/kmesiab/equilibria/lambdas/lib/idempotency"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/media"
 	"github.com/kmesiab/equilibria/lambdas/lib/message"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
 	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
 	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
-	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/lib/transaction"
+	"github.com/kmesiab/equilibria/lambdas/lib/transcription"
+	"github.com/kmesiab/equilibria/lambdas/lib/usage"
 	"github.com/kmesiab/equilibria/lambdas/lib/utils"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
@@ -33,32 +47,83 @@ const maxOldMemories = 1000
 // How many immediately previous messages to include in the prompt
 const maxLastFewMessages = 10
 
+// maxBodyBytes is the size of the messages.body column, a TEXT
+const maxBodyBytes = 65535
+
 // How many memories you have to have before we consider you an 'existing'
 // user, so the model treats you like it knows you well.
 const newUserMemoryCount = 3
 
+// quotaNotice tells a user over their monthly quota when we'll answer
+// again, with the date the quota resets
+const quotaNotice = "You've reached this month's limit, so I can't reply " +
+	"until it resets on %s. I'll be here then."
+
+// moodScoringTimeout bounds the request to the NRCLex API for the mood of
+// the message being replied to
+const moodScoringTimeout = 2 * time.Second
+
+// voiceMemoFallback answers voice memos we couldn't transcribe, like the
+// AMR recordings many carriers send, when the user sent nothing else
+const voiceMemoFallback = "Sorry, I couldn't listen to your voice memo. " +
+	"Could you type out what you said?"
+
 type SendSMSLambdaHandler struct {
 	lib.LambdaHandler
 
 	MaxOldMemories     int
 	MaxLastFewMemories int
 
+	// DebounceWindow is how long to wait for follow-up texts before
+	// replying. Texts received within the window are answered together.
+	DebounceWindow time.Duration
+
+	// MaxReplySegments is how many SMS segments a reply should take, for
+	// users without a limit of their own. Longer replies are sent back to
+	// the model to shorten, up to ShortenAttempts times.
+	MaxReplySegments int
+	ShortenAttempts  int
+
 	MemoryService     *message.MemoryService
 	CompletionService ai.CompletionServiceInterface
 	NRCLexService     *emotions.NRCLexService
 	FactService       facts.ServiceInterface
+	MediaService      *media.Service
+
+	// Voice memos are transcribed when a Transcriber is set
+	Transcriber transcription.Transcriber
+
+	// Prompts picks the version of the reply prompt each user sees.
+	// Without it, everyone gets the newest embedded version.
+	Prompts *prompts.Service
+
+	Channels              *channel.Registry
+	TransactionRepository *transaction.TransactionRepository
+
+	// Outbound publishes the replies we send, for the emotions lambda
+	Outbound *sqs.OutboundTopic
+
+	Metrics *metrics.Recorder
+
+	// Usage throttles users over their monthly quota
+	Usage *usage.Service
 }
 
-func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
+// HandleRequest replies to an inbound message. Failures that may succeed on
+// a later attempt, like a timeout from OpenAI or Twilio, are returned as errors
+// so that SQS redelivers the event, and eventually moves it to the dead-letter
+// queue. Failures that will never succeed are logged and acknowledged.
+func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) (err error) {
 
 	defer func() {
 		if r := recover(); r != nil {
 			log.New("Panic while processing event: %v\nStack trace:\n%s", r, debug.Stack()).Log()
+
+			err = fmt.Errorf("panic while processing event: %v", r)
 		}
 	}()
 
 	var (
-		err      error
 		nowInUTC = time.Now().UTC()
 
 		msg         models.Message
@@ -68,14 +133,14 @@ func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
 	if err = ValidateEvent(&sqsEvent); err != nil {
 		log.New("Error validating the event").AddError(err).Log()
 
-		return
+		return nil
 	}
 
 	if len(sqsEvent.Records) == 0 {
 
 		log.New("No records found in the event. Shutting down.").AddError(err).Log()
 
-		return
+		return nil
 	}
 
 	// Unpack the SNS Event Record
@@ -84,15 +149,19 @@ func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
 		log.New("Error unmarshalling event record").
 			AddError(err).Log()
 
-		return
+		return nil
 	}
 
+	// Continue the trace receive_sms started
+	_, span := tracing.Start("send_sms", propagation.MapCarrier(eventRecord.Attributes()))
+	defer span.End()
+
 	// Unpack the message from the event record
 	if err = json.Unmarshal([]byte(eventRecord.Message), &msg); err != nil {
 		log.New("Error unmarshalling message from event record").
 			AddError(err).Log()
 
-		return
+		return nil
 	}
 
 	// Get the sender's user account
@@ -102,12 +171,17 @@ func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
 
 		theMessage, _ := json.Marshal(msg)
 
-		log.New("Unmarshalled Message from Body: %s", theMessage).Log()
+		log.New("Unmarshalled Message from Body").Add("payload", string(theMessage)).Log()
 
 		log.New("Could not locate user %d.  Rejecting.", msg.FromUserID).
 			AddSQSEvent(&event).AddError(err).Log()
 
-		return
+		// A missing user will never be found on a retry
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+
+		return err
 	}
 
 	// Make sure the sender is not the system user
@@ -115,10 +189,72 @@ func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
 		log.New("Message is from system user. Aborting.").
 			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
 
-		return
+		return nil
 	}
 
-	log.New("Starting response for %s", recipient.PhoneNumber).
+	// Heavy users aren't answered until their quota resets next month,
+	// but are told why, and their message is marked for the analytics
+	if h.Usage.Throttled(recipient) {
+		h.Metrics.Record(nil, metrics.Count(metrics.QuotaExceeded, 1))
+
+		h.MarkThrottled(recipient, &msg)
+		h.QuotaNotice(recipient, &msg, nowInUTC)
+
+		return nil
+	}
+
+	// Attribute the requests made for this reply to the user
+	completionService := ai.ForUser(h.CompletionService, recipient.ID)
+
+	// Photos attached to the message
+	attachments := msg.Media
+
+	// The inbound messages the reply answers
+	turn := &message.Turn{Messages: []models.Message{msg}}
+
+	// Coalesce a burst of texts into a single turn, and only reply to the latest
+	if h.DebounceWindow > 0 {
+
+		turn, err = h.MessageService.GetPendingTurn(recipient, &msg, h.DebounceWindow)
+
+		if err != nil {
+			log.New("Error retrieving pending turn").
+				AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+
+			return err
+		}
+
+		if !turn.IsLatest(&msg) {
+			log.New("Message %d is part of a burst with a newer message. Skipping.", msg.ID).
+				AddUser(recipient).AddSQSEvent(&event).AddMessage(&msg).Log()
+
+			return nil
+		}
+
+		log.New("Coalesced %d messages into a single turn", len(turn.Messages)).
+			AddUser(recipient).AddMessage(&msg).Log()
+
+		// Earlier messages in the burst may have had photos of their own
+		if attachments, err = h.MediaService.FindByMessageIDs(turn.IDs()); err != nil {
+			log.New("Error retrieving media for turn").
+				AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+
+			return err
+		}
+	}
+
+	// Voice memos are transcribed here, once the webhook has stored them,
+	// rather than keeping Twilio waiting
+	unheard := h.TranscribeVoiceMemos(recipient, turn, attachments)
+
+	msg.Body = turn.Body()
+
+	// Let the model know what was in any photos the user sent
+	if mediaContext := h.DescribeMedia(recipient, attachments); mediaContext != "" {
+		msg.Body = strings.TrimSpace(msg.Body + "\n" + mediaContext)
+	}
+
+	log.New("Starting response for user %d", recipient.ID).
 		AddUser(recipient).AddSQSEvent(&event).AddMessage(&msg).Log()
 
 	// Get the memories for the user
@@ -128,179 +264,583 @@ func (h *SendSMSLambdaHandler) HandleRequest(sqsEvent events.SQSEvent) {
 		log.New("Error remembering history %s", err.Error()).
 			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
 
-		return
+		return err
 	}
 
 	log.New("Attaching %d memories", len(memories)).
 		AddUser(recipient).
 		Log()
 
-	var promptModifier = ExistingUserModifier
+	// Get user facts
+	factList, err := h.FactService.FindFactsByUserID(recipient.ID)
 
-	if len(memories) < newUserMemoryCount {
+	if err != nil {
+		log.New("Error finding facts by user id: %s. Halting.", err.Error()).Log()
 
-		log.New("Using new user prompt modifier").AddUser(recipient).Log()
-		promptModifier = NewUserModifier
+		return err
 	}
 
-	// Get user facts
-	factList, err := h.FactService.FindFactsByUserID(recipient.ID)
+	promptTemplate, err := h.Prompt(recipient)
 
 	if err != nil {
-		log.New("Error finding facts by user id: %s. Halting.", err.Error()).Log()
+		log.New("Error choosing prompt: %s. Exiting.", err.Error()).AddUser(recipient).Log()
 
-		return
+		return err
 	}
 
-	knownFacts := ""
+	// SQS may redeliver this event, so make sure we only reply once
+	claimed, err := h.IdempotencyService.Claim(models.IdempotencyScopeSendSMS, ReplyKey(&msg))
+
+	// Another delivery of the event is still replying, or stopped without
+	// finishing. Try again once its claim has had time to lapse.
+	if errors.Is(err, idempotency.ErrInProgress) {
+		log.New("Message %d is already being replied to. Retrying later.", msg.ID).
+			AddUser(recipient).AddSQSEvent(&event).AddMessage(&msg).Log()
 
-	for _, fact := range factList {
+		return err
+	}
 
-		knownFacts += fmt.Sprintf("\n- Fact: %s\n\t- Clinical Reasoning: %s\n\n", fact.Body, fact.Reasoning)
+	if err != nil {
+		log.New("Error claiming idempotency key for reply").
+			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
 
+		return err
 	}
 
-	pst, err := time.LoadLocation("America/Los_Angeles") // PST is often represented by the America/Los_Angeles timezone.
+	if !claimed {
+		log.New("Message %d has already been replied to. Ignoring duplicate.", msg.ID).
+			AddUser(recipient).AddSQSEvent(&event).AddMessage(&msg).Log()
 
-	if err != nil {
-		log.New("Error loading PST location: %s. Exiting.", err.Error())
+		return nil
+	}
 
-		return
+	// There's nothing we can read to reply to, so ask the user to type it
+	// rather than have the model guess at what they said
+	if unheard > 0 && strings.TrimSpace(turn.Body()) == "" {
+		return h.VoiceMemoFallback(recipient, &msg, turn)
 	}
 
-	// Convert date to PST.  In the future we will use the user's timezone
-	pstDate := nowInUTC.In(pst)
-	formattedDate := pstDate.Format("January 2, 2006 3:04pm")
+	// Score the message first, so the reply can adapt to how the user feels
+	mood := h.Mood(recipient, &msg, h.ScoreEmotions(recipient, &msg, event), nowInUTC)
 
-	// NewHotnessPrompt string format: Modifier | Date | Name | Facts
+	prompt, err := BuildPrompt(promptTemplate, recipient, len(memories), factList, mood, nowInUTC)
 
-	prompt := fmt.Sprintf(NewHotnessPrompt, promptModifier, formattedDate, recipient.Firstname, knownFacts)
+	if err != nil {
+		log.New("Error building prompt: %s. Exiting.", err.Error()).AddUser(recipient).Log()
+
+		h.ReleaseReply(&msg)
+
+		return err
+	}
 
 	log.New("Generated Prompt").Add("prompt", prompt).
+		Add("prompt_version", promptTemplate.ID()).
 		AddUser(recipient).AddMessage(&msg).
 		Add("memory_count", strconv.Itoa(len(memories))).
 		Log()
 
 	// Send the prompt for completion
-	completion, err := h.CompletionService.GetCompletion(msg.Body, prompt, &memories)
+	_, completionSpan := tracing.Span("openai completion")
+	completion, err := completionService.GetCompletion(msg.Body, prompt, &memories)
+	completionSpan.End()
 
 	// Strip some non GSM characters from the outbound message
-	completion = h.CompletionService.CleanCompletionText(completion)
+	completion = completionService.CleanCompletionText(completion)
 
 	if err != nil {
 		log.New("Error getting completion").Add("prompt", prompt).
 			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).
 			Add("memory_count", strconv.Itoa(len(memories))).Log()
 
-		return
+		h.ReleaseReply(&msg)
+
+		return err
+	}
+
+	// Reply on the channel the message came in on
+	replyChannel := h.Channels.ForMessageType(msg.MessageTypeID)
+
+	// Texts are billed by the segment, so keep the reply within budget
+	if _, ok := replyChannel.(channel.Splitter); ok {
+		completion = ai.FitToSegments(
+			completionService, completion,
+			recipient.GetMaxReplySegments(h.MaxReplySegments), h.ShortenAttempts,
+		)
 	}
 
+	// Channels that limit the length of a message get long
+	// replies as several numbered messages
+	parts := channel.Parts(replyChannel, completion)
+
+	var firstReply *models.Message
+
+	for i, part := range parts {
+
+		reply, err := h.Reply(recipient, &msg, replyChannel, part, promptTemplate, mood)
+
+		if err != nil {
+			log.New("Error sending part %d of %d of reply", i+1, len(parts)).
+				AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+
+			// Once a part has been sent, a retry would send it again
+			if i > 0 {
+				break
+			}
+
+			h.ReleaseReply(&msg)
+
+			return err
+		}
+
+		// Texts that arrived while we were writing aren't in the turn,
+		// so they stay unanswered and get a reply of their own
+		if i == 0 {
+			firstReply = reply
+			h.MarkReplied(recipient, turn.IDs(), reply)
+		}
+	}
+
+	h.CompleteReply(&msg)
+
+	// How long the user waited, from their text to the reply
+	if msg.ReceivedAt != nil && firstReply.SentAt != nil {
+		h.Metrics.Record(
+			metrics.Dimensions{"Channel": replyChannel.MessageType().Name},
+			metrics.Duration(metrics.ReplyLatency, firstReply.SentAt.Sub(*msg.ReceivedAt)),
+		)
+	}
+
+	return nil
+}
+
+// Reply saves and sends a single message to [recipient] in reply to [msg],
+// and returns the saved message, whose sent_at is when the provider
+// accepted it rather than when we started writing it. [prompt] and [mood]
+// are what the model wrote the reply from, and are nil for canned replies
+// like the quota notice. An error means the message was not sent.
+func (h *SendSMSLambdaHandler) Reply(
+	recipient *models.User,
+	msg *models.Message,
+	replyChannel channel.Channel,
+	body string,
+	prompt *prompts.Prompt,
+	mood *emotions.Mood,
+) (*models.Message, error) {
+
 	// Create a message entry in the db
-	newMessage := NewMessage(&msg)
+	newMessage := NewMessage(msg)
+	newMessage.MessageType = replyChannel.MessageType()
+	newMessage.MessageTypeID = replyChannel.MessageType().ID
 	newMessage.ConversationID = msg.ConversationID
 	newMessage.FromUserID = models.GetSystemUser().ID
 	newMessage.ToUserID = recipient.ID
-	newMessage.SentAt = &nowInUTC
 	newMessage.MessageStatus = models.NewMessageStatusSending()
+	newMessage.Body = body
 
-	newMessage.Body = completion
-	err = h.MessageService.CreateMessage(newMessage)
+	if prompt != nil {
+		promptVersion := prompt.ID()
+		newMessage.PromptVersion = &promptVersion
+	}
 
-	if err != nil {
-		log.New("Error saving new message").
-			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+	channel.EstimateSegments(replyChannel, newMessage)
 
-		return
+	if mood != nil {
+		moodContext := mood.String()
+		newMessage.MoodContext = &moodContext
 	}
 
-	log.New("Sending SMS from %s to %s",
-		newMessage.From.PhoneNumber,
-		recipient.PhoneNumber).
+	if err := h.MessageService.CreateMessage(newMessage); err != nil {
+		return nil, fmt.Errorf("error saving new message: %s", err)
+	}
+
+	log.New("Sending %s to user %d", replyChannel.MessageType().Name, recipient.ID).
 		Add("message", newMessage.Body).
 		Log()
 
 	// Send the message back to the sender
-	smsResponse, err := twilio.SendSMS(
-		models.GetSystemUser().PhoneNumber, recipient.PhoneNumber, completion,
-	)
+	result, err := replyChannel.Send(tracing.Current(), models.GetSystemUser(), recipient, body)
 
 	if err != nil {
-		log.New("Error: Sending sms message").
-			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+		h.Metrics.Record(
+			metrics.Dimensions{"Channel": replyChannel.MessageType().Name},
+			metrics.Count(metrics.SendFailures, 1),
+		)
 
-		return
+		// The retry will create a new outbound message, so fail this one
+		newMessage.MessageStatusID = models.NewMessageStatusFailed().ID
+
+		if uErr := h.MessageService.UpdateStatus(newMessage); uErr != nil {
+			log.New("Error: Marking outbound message as failed").
+				AddUser(recipient).AddError(uErr).AddMessage(msg).Log()
+		}
+
+		return nil, fmt.Errorf("error sending message: %s", err)
 	}
 
-	if smsResponse == nil {
-		log.New("Error: SMS Response is empty").
-			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+	sentAt := time.Now().UTC()
+	newMessage.SentAt = &sentAt
 
-		return
+	h.Bill(recipient, newMessage)
+
+	referenceID := result.ReferenceID
+
+	if result.NumSegments > 0 {
+		newMessage.NumSegments = &result.NumSegments
 	}
 
-	// Update the message with its SID from twilio
-	newMessage.ReferenceID = smsResponse.Sid
-	err = h.MessageService.UpdateMessage(newMessage)
+	if referenceID != "" {
+		// Update the message with its ID from the provider
+		newMessage.ReferenceID = &referenceID
+	} else {
+		// Channels with no provider, like web chat, deliver immediately
+		newMessage.ReferenceID = nil
+		newMessage.MessageStatusID = models.NewMessageStatusDelivered().ID
+	}
 
-	if err != nil {
+	// The message was sent, so there's nothing to retry
+	if err = h.MessageService.UpdateMessage(newMessage); err != nil {
 		log.New("Error: Updating new message with reference ID").
-			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
 
-		return
+		return newMessage, nil
 	}
 
-	log.New("Successfully queued outbound message from %s to %s",
-		msg.MessageType.Name, *smsResponse.To).
-		AddSmsResponse(smsResponse).
+	log.New("Successfully queued outbound %s message to user %d",
+		replyChannel.MessageType().Name, recipient.ID).
+		Add("reference_id", referenceID).
+		Add("estimated_segments", segmentsString(newMessage.EstimatedSegments)).
+		Add("num_segments", segmentsString(newMessage.NumSegments)).
 		Log()
 
-	defer func() {
+	if err = h.Outbound.Publish(tracing.Current(), newMessage); err != nil {
+		log.New("Error publishing outbound message").
+			AddUser(recipient).AddError(err).AddMessage(newMessage).Log()
+	}
 
-		if r := recover(); r != nil {
+	return newMessage, nil
+}
+
+// MarkThrottled marks [msg] as unanswered because [recipient] is over
+// their quota.
+func (h *SendSMSLambdaHandler) MarkThrottled(recipient *models.User, msg *models.Message) {
+
+	msg.MessageStatusID = models.NewMessageStatusThrottled().ID
+
+	if err := h.MessageService.UpdateStatus(msg); err != nil {
+		log.New("Error marking message %d as throttled", msg.ID).
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
+}
+
+// QuotaNotice tells [recipient], once a month, that they're over their
+// quota and won't be answered until it resets. The notice is written in
+// advance, so it costs no completion.
+func (h *SendSMSLambdaHandler) QuotaNotice(recipient *models.User, msg *models.Message, now time.Time) {
+
+	key := QuotaNoticeKey(recipient, now)
+
+	claimed, err := h.IdempotencyService.Claim(models.IdempotencyScopeQuotaNotice, key)
+
+	if err != nil && !errors.Is(err, idempotency.ErrInProgress) {
+		log.New("Error claiming idempotency key for quota notice").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
 
-			log.New("Panic while trying to process emotions: %v", r).Log()
+	// Already told this month, or being told now
+	if err != nil || !claimed {
+		return
+	}
+
+	resetsOn := usage.Month(now).AddDate(0, 1, 0)
+	body := fmt.Sprintf(quotaNotice, resetsOn.Format("January 2"))
+
+	replyChannel := h.Channels.ForMessageType(msg.MessageTypeID)
+
+	if _, err = h.Reply(recipient, msg, replyChannel, body, nil, nil); err != nil {
+		log.New("Error sending quota notice").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+
+		// Try again with their next message
+		if err = h.IdempotencyService.Release(models.IdempotencyScopeQuotaNotice, key); err != nil {
+			log.New("Error releasing idempotency key for quota notice").
+				AddUser(recipient).AddError(err).AddMessage(msg).Log()
 		}
 
-	}()
-	h.ProcessEmotions(recipient, msg, event)
+		return
+	}
+
+	if err = h.IdempotencyService.Complete(models.IdempotencyScopeQuotaNotice, key); err != nil {
+		log.New("Error completing idempotency key for quota notice").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
+}
+
+// QuotaNoticeKey identifies the quota notice sent to [user] in the month
+// of [now].
+func QuotaNoticeKey(user *models.User, now time.Time) string {
+	return fmt.Sprintf("%d:%s", user.ID, usage.Month(now).Format("2006-01"))
+}
+
+// MarkReplied records that [reply] answered the inbound messages [ids].
+// The reply has already been sent, so failures are logged rather than
+// retried.
+func (h *SendSMSLambdaHandler) MarkReplied(recipient *models.User, ids []int64, reply *models.Message) {
+
+	if err := h.MessageService.MarkReplied(ids, reply); err != nil {
+		log.New("Error marking %d messages as replied to by message %d", len(ids), reply.ID).
+			AddUser(recipient).AddError(err).AddMessage(reply).Log()
+	}
+}
+
+// Prompt returns the version of the reply prompt [recipient] is assigned.
+func (h *SendSMSLambdaHandler) Prompt(recipient *models.User) (*prompts.Prompt, error) {
+
+	if h.Prompts == nil {
+		return prompts.Default().Latest(prompts.Reply), nil
+	}
+
+	return h.Prompts.ForUser(recipient, prompts.Reply)
+}
+
+func segmentsString(segments *int) string {
+	if segments == nil {
+		return "unknown"
+	}
+
+	return strconv.Itoa(*segments)
+}
+
+// DescribeMedia describes the photos a user sent, and returns a summary
+// of the attachments to include with their message. A photo that can't be
+// described doesn't stop us from replying to the rest of the message.
+func (h *SendSMSLambdaHandler) DescribeMedia(recipient *models.User, attachments []models.MessageMedia) string {
+
+	if len(attachments) == 0 || h.MediaService == nil {
+		return ""
+	}
+
+	completionService := ai.ForUser(h.CompletionService, recipient.ID)
 
+	for i := range attachments {
+		if err := h.MediaService.Describe(&attachments[i], completionService); err != nil {
+			log.New("Error describing media %d", attachments[i].ID).
+				AddUser(recipient).AddError(err).Log()
+		}
+	}
+
+	return media.PromptContext(attachments)
 }
 
-func (h *SendSMSLambdaHandler) ProcessEmotions(recipient *models.User, msg models.Message, event events.SQSMessage) {
+// TranscribeVoiceMemos adds the transcripts of the voice memos in
+// [attachments] to the bodies of the messages in [turn] they were sent
+// with, and saves them. It returns how many memos couldn't be
+// transcribed, which are left out, and the model is told the user sent
+// audio it couldn't hear.
+func (h *SendSMSLambdaHandler) TranscribeVoiceMemos(recipient *models.User, turn *message.Turn, attachments []models.MessageMedia) int {
+
+	unheard := 0
 
-	scores, err := h.NRCLexService.ProcessMessage(recipient, &msg)
+	for i := range turn.Messages {
+		msg := &turn.Messages[i]
+
+		var transcripts []string
+
+		for j := range attachments {
+			memo := &attachments[j]
+
+			// A redelivered event may have transcribed it already
+			if memo.MessageID != msg.ID || !memo.IsAudio() || memo.Description != nil {
+				continue
+			}
+
+			if h.Transcriber == nil || h.MediaService == nil {
+				unheard++
+
+				continue
+			}
+
+			transcript, err := h.MediaService.Transcribe(memo, h.Transcriber)
+
+			if err != nil {
+				log.New("Error transcribing voice memo %d", memo.ID).
+					AddUser(recipient).AddError(err).AddMessage(msg).Log()
+
+				unheard++
+
+				continue
+			}
+
+			transcripts = append(transcripts, transcript)
+		}
+
+		if len(transcripts) == 0 {
+			continue
+		}
+
+		// The full transcript is kept on the media
+		msg.Body = truncateBody(strings.TrimSpace(msg.Body + "\n" + strings.Join(transcripts, "\n")))
+		msg.Transcribed = true
+
+		if err := h.MessageService.UpdateTranscript(msg); err != nil {
+			log.New("Error saving transcript of message %d", msg.ID).
+				AddUser(recipient).AddError(err).AddMessage(msg).Log()
+		}
+	}
+
+	return unheard
+}
+
+// VoiceMemoFallback asks [recipient] to type out the voice memos in
+// [turn] we couldn't transcribe, instead of replying to them.
+func (h *SendSMSLambdaHandler) VoiceMemoFallback(recipient *models.User, msg *models.Message, turn *message.Turn) error {
+
+	replyChannel := h.Channels.ForMessageType(msg.MessageTypeID)
+
+	reply, err := h.Reply(recipient, msg, replyChannel, voiceMemoFallback, nil, nil)
 
 	if err != nil {
+		log.New("Error asking user to type out a voice memo").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+
+		h.ReleaseReply(msg)
+
+		return err
+	}
+
+	h.MarkReplied(recipient, turn.IDs(), reply)
+	h.CompleteReply(msg)
+
+	return nil
+}
+
+// truncateBody cuts [body] down to the size of the body column, without
+// splitting a character.
+func truncateBody(body string) string {
+
+	if len(body) <= maxBodyBytes {
+		return body
+	}
 
-		log.New("Error: Processing NRC lex message: %s", err.Error()).
-			AddError(err).
-			AddUser(recipient).
-			AddSQSEvent(&event).
-			AddError(err).
-			AddMessage(&msg).
-			Log()
+	end := 0
+
+	for i := range body {
+		if i > maxBodyBytes {
+			break
+		}
+
+		end = i
+	}
 
+	return body[:end]
+}
+
+// Bill debits the recipient for a message, at the rate of the
+// channel it was sent on. Billing failures are logged, rather than
+// holding up the conversation.
+func (h *SendSMSLambdaHandler) Bill(recipient *models.User, msg *models.Message) {
+
+	if h.TransactionRepository == nil {
 		return
 	}
 
-	com := strconv.FormatFloat(scores.VaderEmotionScore.Compound, 'f', 4, 64)
-	pos := strconv.FormatFloat(scores.VaderEmotionScore.Pos, 'f', 4, 64)
-	neg := strconv.FormatFloat(scores.VaderEmotionScore.Neg, 'f', 4, 64)
-	neu := strconv.FormatFloat(scores.VaderEmotionScore.Neu, 'f', 4, 64)
+	_, err := h.TransactionRepository.Debit(
+		recipient.ID,
+		msg.ConversationID,
+		msg.MessageType.BillRateInCredits,
+		models.FundingSourceStringCustomerCredit,
+		strconv.FormatInt(msg.ID, 10),
+		fmt.Sprintf("%s message", msg.MessageType.Name),
+	)
 
-	emotionBlob, _ := json.Marshal(scores)
+	if err != nil {
+		log.New("Error billing for message %d", msg.ID).
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+	}
+}
 
-	log.New("Successfully processed NRC lex message for %s", recipient.PhoneNumber).
-		Add("compound_sentiment", com).
-		Add("neg_sentiment", neg).
-		Add("neu_sentiment", neu).
-		Add("positive_sentiment", pos).
-		Add("raw_scores", string(emotionBlob)).
-		AddUser(&msg.To).
-		AddSQSEvent(&event).
-		AddError(err).
-		AddMessage(&msg).
-		Log()
+// CompleteReply marks the reply to msg sent, so a redelivery of the event
+// never sends it again.
+func (h *SendSMSLambdaHandler) CompleteReply(msg *models.Message) {
+
+	if err := h.IdempotencyService.Complete(models.IdempotencyScopeSendSMS, ReplyKey(msg)); err != nil {
+		log.New("Error completing idempotency key for reply").
+			AddError(err).AddMessage(msg).Log()
+	}
+}
+
+// ReleaseReply gives up the claim on replying to msg, so a
+// redelivery of the event can try again.
+func (h *SendSMSLambdaHandler) ReleaseReply(msg *models.Message) {
+
+	if err := h.IdempotencyService.Release(models.IdempotencyScopeSendSMS, ReplyKey(msg)); err != nil {
+		log.New("Error releasing idempotency key for reply").
+			AddError(err).AddMessage(msg).Log()
+	}
+}
+
+// ReplyKey identifies the reply to an inbound message. Twilio's SmsSid
+// is preferred, falling back to the message ID.
+func ReplyKey(msg *models.Message) string {
+
+	if msg.ReferenceID != nil && *msg.ReferenceID != "" {
+		return *msg.ReferenceID
+	}
+
+	return strconv.FormatInt(msg.ID, 10)
+}
+
+// MoodAnalyzer returns the analyzer ScoreEmotions uses. It's the one the
+// emotions lambda uses, so the mood in the prompt compares like with like,
+// but it makes one short request to the NRCLex API rather than retrying,
+// so a slow API falls back or is skipped instead of holding up the reply.
+func MoodAnalyzer(cfg *config.Config) (nrclex.Analyzer, error) {
+	return nrclex.New(cfg, &http.Client{Timeout: moodScoringTimeout})
+}
+
+// ScoreEmotions scores the emotions in [msg] for the prompt. The emotions
+// lambda scores and saves every message, so this saves nothing. Failures
+// are logged and return nil scores rather than holding up the reply.
+func (h *SendSMSLambdaHandler) ScoreEmotions(recipient *models.User, msg *models.Message, event events.SQSMessage) *nrclex.APIResponse {
+
+	if h.NRCLexService == nil {
+		return nil
+	}
+
+	scores, err := h.NRCLexService.Analyze(msg)
+
+	if err != nil {
+		log.New("Error scoring emotions").
+			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(msg).Log()
+
+		return nil
+	}
+
+	log.New("Scored emotions for user %d", recipient.ID).
+		Add("compound_sentiment", strconv.FormatFloat(scores.VaderEmotionScore.Compound, 'f', 4, 64)).
+		AddUser(recipient).AddMessage(msg).Log()
+
+	return scores
+}
+
+// Mood compares the [scores] of [msg] with the user's recent messages,
+// for the prompt. Without scores, or recent messages to compare them
+// with, there is no mood and the prompt leaves it out.
+func (h *SendSMSLambdaHandler) Mood(recipient *models.User, msg *models.Message, scores *nrclex.APIResponse, now time.Time) *emotions.Mood {
+
+	if scores == nil {
+		return nil
+	}
+
+	mood, err := h.NRCLexService.Mood(recipient, msg, scores, now)
+
+	if err != nil {
+		log.New("Error finding recent emotions").
+			AddUser(recipient).AddError(err).AddMessage(msg).Log()
+
+		return nil
+	}
+
+	return mood
 }
 
 func (h *SendSMSLambdaHandler) GetMemories(recipient *models.User, event events.SQSMessage, msg models.Message) ([]models.Message, error) {
@@ -308,7 +848,7 @@ func (h *SendSMSLambdaHandler) GetMemories(recipient *models.User, event events.
 	lastFewMemories, err := h.MemoryService.GetLastNMessagePairs(recipient, h.MaxLastFewMemories)
 
 	if err != nil {
-		log.New("Error retrieving last few memories for user %s", recipient.PhoneNumber).
+		log.New("Error retrieving last few memories for user %d", recipient.ID).
 			AddUser(recipient).AddSQSEvent(&event).AddError(err).AddMessage(&msg).Log()
 
 		return nil, err
@@ -316,22 +856,28 @@ func (h *SendSMSLambdaHandler) GetMemories(recipient *models.User, event events.
 
 	aFewOlderMemories, err := h.MemoryService.GetRandomMessagePairs(recipient, h.MaxOldMemories)
 
-	myOldMemories := utils.FilterSlice(*aFewOlderMemories, func(m models.Message) bool {
-		return m.FromUserID != models.GetSystemUser().ID
-	})
-
 	if err != nil {
-		log.New(
-			"Error retrieving a few older memories for user %s",
-			recipient.PhoneNumber).Log()
+		log.New("Error retrieving a few older memories for user %d", recipient.ID).
+			AddError(err).Log()
 
 		return nil, err
 	}
 
-	memories := append(*lastFewMemories, myOldMemories...)
+	return OrderMemories(*lastFewMemories, *aFewOlderMemories), nil
+}
+
+// OrderMemories combines the most recent messages with the user's side of
+// a few older ones into the history the model sees.
+func OrderMemories(lastFewMemories, olderMemories []models.Message) []models.Message {
+
+	myOldMemories := utils.FilterSlice(olderMemories, func(m models.Message) bool {
+		return m.FromUserID != models.GetSystemUser().ID
+	})
+
+	memories := append(lastFewMemories, myOldMemories...)
 	slices.Reverse(memories)
 
-	return memories, nil
+	return memories
 }
 
 func NewMessage(incomingMessage *models.Message) *models.Message {
@@ -339,9 +885,9 @@ func NewMessage(incomingMessage *models.Message) *models.Message {
 	return &models.Message{
 		FromUserID:      models.GetSystemUser().ID,
 		ToUserID:        incomingMessage.FromUserID,
-		ReferenceID:     incomingMessage.ReferenceID,
 		MessageType:     models.NewMessageTypeSMS(),
 		MessageStatusID: models.NewMessageStatusSent().ID,
+		TraceParent:     tracing.Parent(),
 	}
 }
 
@@ -369,6 +915,12 @@ func main() {
 		log.New("Could not load config")
 	}
 
+	if err := tracing.Init(cfg, "send_sms"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
 	database := db.Get(cfg)
 
 	if err := utils.PingDatabase(database); err != nil {
@@ -377,7 +929,7 @@ func main() {
 		return
 	}
 
-	if err := utils.PingGoogle(); err != nil {
+	if err := utils.PingURL(cfg.ConnectivityCheckURL); err != nil {
 		log.New("Error pinging Google. Possible bad internet connection.").AddError(err).Log()
 
 		return
@@ -389,25 +941,81 @@ func main() {
 
 	nrclexRepo := &nrclex.Repository{DB: database}
 
-	restClient := utils.NewRestClient()
-	nrcClient := nrclex.NewNRCLexClient(restClient.GetClient())
+	outbound, err := sqs.NewOutboundTopic(cfg.OutboundSNSTopicARN)
+
+	if err != nil {
+		log.New("Error creating outbound topic publisher. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	recorder, err := metrics.New(cfg, "send_sms")
+
+	if err != nil {
+		log.New("Error creating metrics recorder. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	usageService := usage.NewService(usage.NewRepository(database), "send_sms", cfg.LLMMonthlyCostQuota)
 
 	completionService := &ai.OpenAICompletionService{
 		RemoveEmojis: false,
+		Metrics:      recorder,
+		Ledger:       usageService,
 	}
 
 	factsRepo := facts.NewRepository(database)
 	factsService := facts.NewService(factsRepo, completionService)
 
+	provider, err := messaging.New(cfg)
+
+	if err != nil {
+		log.New("Error creating messaging provider. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	analyzer, err := MoodAnalyzer(cfg)
+
+	if err != nil {
+		log.New("Error creating emotion analyzer. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	transcriber, err := transcription.New(
+		cfg.TranscriptionProvider, cfg.OpenAIAPIKey, cfg.TranscriptionModelName, cfg.OpenAIBaseURL,
+	)
+
+	if err != nil {
+		log.New("Error creating transcriber. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
 	handler := &SendSMSLambdaHandler{
 
 		MaxOldMemories:     maxOldMemories,
 		MaxLastFewMemories: maxLastFewMessages,
+		DebounceWindow:     time.Duration(cfg.InboundDebounceSeconds) * time.Second,
+		MaxReplySegments:   cfg.ChatModelMaxReplySegments,
+		ShortenAttempts:    cfg.ChatModelShortenAttempts,
 
 		FactService:       factsService,
 		CompletionService: completionService,
 		MemoryService:     memoryService,
-		NRCLexService:     emotions.NewNRCLexService(nrcClient, nrclexRepo),
+		NRCLexService:     emotions.NewNRCLexService(analyzer, nrclexRepo),
+		MediaService: media.NewService(
+			media.NewRepository(database), cfg.TwilioSID, cfg.TwilioAuthToken,
+		),
+		Transcriber:           transcriber,
+		Prompts:               prompts.NewService(prompts.Default(), prompts.NewRepository(database)),
+		Channels:              channel.NewDefaultRegistry(provider, cfg.SMSMaxSegmentsPerMessage),
+		TransactionRepository: transaction.NewTransactionRepository(database),
+		Outbound:              outbound,
+		Metrics:               recorder,
+		Usage:                 usageService,
 	}
 
 	log.New("SMS Sender Lambda ready. Initializing.").Log()
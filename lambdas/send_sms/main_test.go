@@ -1,17 +1,195 @@
 package main
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/aws/aws-lambda-go/events"
+	mysql2 "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/kmesiab/equilibria/lambdas/lib/ai"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/facts"
+	"github.com/kmesiab/equilibria/lambdas/lib/media"
 	"github.com/kmesiab/equilibria/lambdas/lib/message"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
+	"github.com/kmesiab/equilibria/lambdas/lib/nrclex"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
 	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/lib/transcription"
+	"github.com/kmesiab/equilibria/lambdas/lib/usage"
+	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
+const patientID = int64(3)
+
+func newHandler(t *testing.T, provider messaging.Provider) (*SendSMSLambdaHandler, sqlmock.Sqlmock) {
+
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err)
+
+	completionService := &ai.MockCompletionService{}
+
+	handler := &SendSMSLambdaHandler{
+		MaxOldMemories:     maxOldMemories,
+		MaxLastFewMemories: maxLastFewMessages,
+		DebounceWindow:     10 * time.Second,
+		MaxReplySegments:   3,
+		ShortenAttempts:    1,
+
+		CompletionService: completionService,
+		MemoryService:     message.NewMemoryService(message.NewMessageRepository(db)),
+		FactService:       facts.NewService(facts.NewRepository(db), completionService),
+		MediaService:      media.NewService(media.NewRepository(db), "", ""),
+		Channels:          channel.NewDefaultRegistry(provider, 3),
+	}
+	handler.Init(db)
+
+	return handler, mock
+}
+
+func sqsEvent(t *testing.T, msg models.Message) events.SQSEvent {
+
+	message, err := json.Marshal(msg)
+	require.NoError(t, err)
+
+	body, err := json.Marshal(sqs.SQSEventRecord{Type: "Notification", Message: string(message)})
+	require.NoError(t, err)
+
+	return events.SQSEvent{Records: []events.SQSMessage{{MessageId: "1", Body: string(body)}}}
+}
+
+func inbound(id int64, body string, receivedAt time.Time) models.Message {
+
+	return models.Message{
+		ID:              id,
+		ConversationID:  5,
+		FromUserID:      patientID,
+		ToUserID:        models.GetSystemUser().ID,
+		Body:            body,
+		MessageTypeID:   models.NewMessageTypeSMS().ID,
+		MessageStatusID: models.NewMessageStatusReceived().ID,
+		ReceivedAt:      &receivedAt,
+	}
+}
+
+func messageRows(messages ...models.Message) *sqlmock.Rows {
+
+	rows := sqlmock.NewRows([]string{
+		"id", "conversation_id", "from_user_id", "to_user_id", "body", "message_type_id", "received_at",
+	})
+
+	for _, m := range messages {
+		rows.AddRow(m.ID, m.ConversationID, m.FromUserID, m.ToUserID, m.Body, m.MessageTypeID, m.ReceivedAt)
+	}
+
+	return rows
+}
+
+// expectReplyContext expects the queries made before the reply is
+// written, with [pending] as the user's unanswered messages.
+func expectReplyContext(mock sqlmock.Sqlmock, pending ...models.Message) {
+
+	expectUser(mock)
+	expectPending(mock, pending...)
+
+	mock.ExpectQuery("SELECT \\* FROM `message_media`").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	expectPromptContext(mock)
+}
+
+func expectUser(mock sqlmock.Sqlmock) {
+
+	mock.ExpectQuery("SELECT \\* FROM `users` WHERE `users`.`id`").
+		WithArgs(patientID, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "firstname"}).
+			AddRow(patientID, "+12533243071", "Kevin"))
+}
+
+func expectPending(mock sqlmock.Sqlmock, pending ...models.Message) {
+
+	mock.ExpectQuery("SELECT \\* FROM messages WHERE from_user_id = \\? AND received_at >= \\? AND replied_by_message_id IS NULL").
+		WithArgs(patientID, sqlmock.AnyArg()).
+		WillReturnRows(messageRows(pending...))
+}
+
+// expectPromptContext expects the user's history and facts to be looked
+// up, and the reply to be claimed.
+func expectPromptContext(mock sqlmock.Sqlmock) {
+
+	mock.ExpectQuery("select \\* from messages").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectQuery("SELECT \\* FROM messages").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	mock.ExpectQuery("SELECT \\* FROM `facts` WHERE user_id = \\?").
+		WithArgs(patientID).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	test.ExpectMockInsertIdempotencyKey(&mock)
+}
+
+// expectReply expects a reply, saved as [replyID], to be sent and to
+// answer the inbound messages [answered].
+func expectReply(mock sqlmock.Sqlmock, replyID int64, answered ...int64) {
+
+	expectSent(mock, replyID)
+	expectMarkedReplied(mock, replyID, answered...)
+}
+
+// expectSent expects a reply to be saved as [replyID], and updated once
+// it has been sent.
+func expectSent(mock sqlmock.Sqlmock, replyID int64) {
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `message_statuses`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `message_types`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `messages`").
+		WillReturnResult(sqlmock.NewResult(replyID, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `message_statuses`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `message_types`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE `messages` SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+// expectMarkedReplied expects the inbound messages [answered] to be
+// marked as answered by [replyID].
+func expectMarkedReplied(mock sqlmock.Sqlmock, replyID int64, answered ...int64) {
+
+	args := []driver.Value{replyID, sqlmock.AnyArg()}
+	for _, id := range answered {
+		args = append(args, id)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `replied_by_message_id`=\\?.*WHERE \\(id IN \\(.*\\) AND replied_by_message_id IS NULL\\)").
+		WithArgs(args...).
+		WillReturnResult(sqlmock.NewResult(0, int64(len(answered))))
+	mock.ExpectCommit()
+}
+
 func TestHandleRequest_NoBody(t *testing.T) {
 	test.SetEnvVars()
 	event := events.SQSEvent{
@@ -66,3 +244,374 @@ func TestHandleRequest(t *testing.T) {
 	handler.Init(db)
 	handler.HandleRequest(event)
 }
+
+func TestHandleRequest_Reply(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	msg := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+
+	expectReplyContext(mock, msg)
+	expectReply(mock, 100, msg.ID)
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:7")
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, msg)))
+
+	sent := provider.Sent()
+	require.Len(t, sent, 1, "The user should get a reply")
+	assert.Equal(t, models.GetSystemUser().PhoneNumber, sent[0].From)
+	assert.Equal(t, "+12533243071", sent[0].To)
+	assert.Equal(t, "dummy cleaned text", sent[0].Body)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_SendFailure(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	provider.Err = errors.New("provider is down")
+	handler, mock := newHandler(t, provider)
+
+	msg := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+
+	expectReplyContext(mock, msg)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `message_statuses`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `message_types`").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO `messages`").
+		WillReturnResult(sqlmock.NewResult(100, 1))
+	mock.ExpectCommit()
+
+	// The reply that couldn't be sent is marked failed
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `message_status_id`=\\?").
+		WithArgs(models.NewMessageStatusFailed().ID, sqlmock.AnyArg(), int64(100)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// And the claim is given up, so SQS can redeliver the event
+	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM `idempotency_keys` WHERE idempotency_key = \\?").
+		WithArgs("send_sms:7").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	assert.Error(t, handler.HandleRequest(sqsEvent(t, msg)), "The event should be retried")
+	assert.Empty(t, provider.Sent())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// throttle puts the user over their monthly quota, and expects [msg] to
+// be marked as throttled.
+func throttle(t *testing.T, handler *SendSMSLambdaHandler, mock sqlmock.Sqlmock, msg models.Message) {
+
+	handler.Usage = usage.NewService(usage.NewRepository(handler.DB), "send_sms", 1)
+
+	expectUser(mock)
+
+	mock.ExpectQuery("SELECT \\* FROM `llm_usage_monthly` WHERE user_id = \\? AND month = \\?").
+		WithArgs(patientID, usage.Month(time.Now()), 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "user_id", "month", "cost"}).
+			AddRow(1, patientID, usage.Month(time.Now()), 1.25))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `message_status_id`=\\?").
+		WithArgs(models.NewMessageStatusThrottled().ID, sqlmock.AnyArg(), msg.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+}
+
+func TestHandleRequest_Throttled(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	msg := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+	key := "quota_notice:" + QuotaNoticeKey(&models.User{ID: patientID}, time.Now())
+
+	throttle(t, handler, mock, msg)
+
+	// The user is told once, without asking the model
+	test.ExpectMockInsertIdempotencyKey(&mock)
+	expectSent(mock, 100)
+	test.ExpectMockCompleteIdempotencyKey(&mock, key)
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, msg)))
+
+	sent := provider.Sent()
+	require.Len(t, sent, 1, "The user should be told they're over their quota")
+	assert.Contains(t, sent[0].Body, "reached this month's limit")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_ThrottledAlreadyNoticed(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	msg := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+	key := "quota_notice:" + QuotaNoticeKey(&models.User{ID: patientID}, time.Now())
+
+	throttle(t, handler, mock, msg)
+
+	// The notice was sent with an earlier message this month
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `idempotency_keys`").
+		WillReturnError(&mysql2.MySQLError{Number: 1062, Message: "Duplicate entry"})
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `idempotency_keys` SET `expires_at`=\\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	mock.ExpectQuery("SELECT \\* FROM `idempotency_keys` WHERE idempotency_key = \\?").
+		WithArgs(key, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idempotency_key", "scope", "status"}).
+			AddRow(1, key, models.IdempotencyScopeQuotaNotice, models.IdempotencyStatusDone))
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, msg)))
+
+	assert.Empty(t, provider.Sent(), "The user is only told once a month")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_TextArrivesMidReply(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	first := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+
+	// The second text arrives while the first reply is being written, so
+	// before that reply's sent_at, but after the turn was gathered
+	second := inbound(8, "can we talk", time.Now().UTC().Add(-time.Second))
+
+	expectReplyContext(mock, first)
+	expectReply(mock, 100, first.ID)
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:7")
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, first)))
+
+	// The first reply only answered the first text, so the second is still
+	// pending, and gets a reply of its own
+	expectReplyContext(mock, second)
+	expectReply(mock, 101, second.ID)
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:8")
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, second)))
+
+	assert.Len(t, provider.Sent(), 2, "Each text should be answered")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+type fakeTranscriber struct{}
+
+func (f fakeTranscriber) Transcribe(_ []byte, _ string) (string, error) {
+	return "I couldn't sleep again last night.", nil
+}
+
+// promptRecorder remembers the message it was asked to reply to
+type promptRecorder struct {
+	ai.MockCompletionService
+	message string
+}
+
+func (p *promptRecorder) GetCompletion(message, _ string, _ *[]models.Message) (string, error) {
+	p.message = message
+
+	return "dummy completion", nil
+}
+
+func TestHandleRequest_VoiceMemo(t *testing.T) {
+
+	recordings := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/Recordings/RE1.mp3", r.URL.Path)
+		_, _ = w.Write([]byte("mp3"))
+	}))
+	defer recordings.Close()
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	completion := &promptRecorder{}
+	handler.CompletionService = completion
+	handler.Transcriber = fakeTranscriber{}
+
+	// The webhook stored the recording without waiting for a transcript
+	memo := inbound(7, "", time.Now().UTC().Add(-time.Minute))
+
+	expectUser(mock)
+	expectPending(mock, memo)
+
+	mock.ExpectQuery("SELECT \\* FROM `message_media`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "url", "content_type"}).
+			AddRow(4, memo.ID, recordings.URL+"/Recordings/RE1.mp3", "audio/mpeg"))
+
+	// The transcript is saved on the media, and becomes the body of the message
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `message_media` SET `description`").
+		WithArgs("I couldn't sleep again last night.", int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `body`=\\?,`transcribed`=\\?").
+		WithArgs("I couldn't sleep again last night.", true, sqlmock.AnyArg(), memo.ID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	expectPromptContext(mock)
+	expectReply(mock, 100, memo.ID)
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:7")
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, memo)))
+
+	assert.Equal(t, "I couldn't sleep again last night.", completion.message,
+		"The model should reply to what the user said")
+	assert.Len(t, provider.Sent(), 1)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHandleRequest_VoiceMemoUnsupported(t *testing.T) {
+
+	recordings := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("#!AMR"))
+	}))
+	defer recordings.Close()
+
+	whisper := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("AMR shouldn't be sent to be transcribed")
+	}))
+	defer whisper.Close()
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	completion := &promptRecorder{}
+	handler.CompletionService = completion
+	handler.Transcriber = &transcription.WhisperTranscriber{APIKey: "key", BaseURL: whisper.URL}
+
+	// A carrier voice memo, with nothing typed alongside it
+	memo := inbound(7, "", time.Now().UTC().Add(-time.Minute))
+
+	expectUser(mock)
+	expectPending(mock, memo)
+
+	mock.ExpectQuery("SELECT \\* FROM `message_media`").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "url", "content_type"}).
+			AddRow(4, memo.ID, recordings.URL+"/Media/ME1", "audio/amr"))
+
+	// The user is asked to type it out, without asking the model
+	expectPromptContext(mock)
+	expectReply(mock, 100, memo.ID)
+	test.ExpectMockCompleteIdempotencyKey(&mock, "send_sms:7")
+
+	require.NoError(t, handler.HandleRequest(sqsEvent(t, memo)))
+
+	sent := provider.Sent()
+	require.Len(t, sent, 1, "The user should hear back about their voice memo")
+	assert.Equal(t, voiceMemoFallback, sent[0].Body)
+	assert.Empty(t, completion.message, "The model shouldn't reply to a memo it can't hear")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoodAnalyzer_SlowAPI(t *testing.T) {
+
+	done := make(chan struct{})
+
+	langtool := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer langtool.Close()
+	defer close(done)
+
+	analyzer, err := MoodAnalyzer(&config.Config{
+		NRCLexURL:         langtool.URL,
+		NRCLexAnalyzer:    nrclex.AnalyzerFallback,
+		NRCLexLexiconPath: nrclex.LexiconEmbedded,
+		VaderLexiconPath:  nrclex.LexiconEmbedded,
+	})
+	require.NoError(t, err)
+
+	started := time.Now()
+	scores, err := analyzer.AnalyzeText("I feel so sad and alone")
+
+	require.NoError(t, err)
+	assert.Less(t, scores.VaderEmotionScore.Compound, 0.0, "The message should be scored locally")
+	assert.Less(t, time.Since(started), 2*moodScoringTimeout, "A slow API shouldn't hold up the reply")
+}
+
+func TestTruncateBody(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	assert.Equal(t, long, truncateBody(long), "Bodies longer than 255 characters are kept whole")
+
+	tooLong := strings.Repeat("a", maxBodyBytes-1) + "💙"
+	assert.Equal(t, strings.Repeat("a", maxBodyBytes-1), truncateBody(tooLong), "A character isn't split")
+	assert.Len(t, truncateBody(strings.Repeat("a", maxBodyBytes+10)), maxBodyBytes)
+}
+
+func TestReply_SentAt(t *testing.T) {
+
+	provider := messaging.NewFakeProvider("")
+	handler, mock := newHandler(t, provider)
+
+	msg := inbound(7, "rough day", time.Now().UTC().Add(-time.Minute))
+	recipient := &models.User{ID: patientID, PhoneNumber: "+12533243071"}
+	replyChannel := handler.Channels.ForMessageType(msg.MessageTypeID)
+
+	expectSent(mock, 100)
+
+	// Writing the reply takes a while, and the user may text again meanwhile
+	written := time.Now().UTC()
+	time.Sleep(10 * time.Millisecond)
+
+	reply, err := handler.Reply(recipient, &msg, replyChannel, "That sounds hard.", prompts.Default().Latest(prompts.Reply), nil)
+	require.NoError(t, err)
+
+	require.Len(t, provider.Sent(), 1)
+	require.NotNil(t, reply.SentAt)
+	assert.False(t, reply.SentAt.Before(provider.Sent()[0].SentAt),
+		"The reply was sent when the provider accepted it")
+	assert.True(t, reply.SentAt.After(written.Add(10*time.Millisecond)),
+		"The time spent writing the reply isn't counted as sent")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplyKey(t *testing.T) {
+	sid := "SM62876cd3611d64defdece80d9aa1f703"
+
+	assert.Equal(t, sid, ReplyKey(&models.Message{ID: 7, ReferenceID: &sid}),
+		"The reply key should use the Twilio SID when present")
+
+	assert.Equal(t, "7", ReplyKey(&models.Message{ID: 7}),
+		"The reply key should fall back to the message ID")
+}
+
+func TestBuildPrompt_Mood(t *testing.T) {
+	prompt := prompts.Default().Latest(prompts.Reply)
+	user := &models.User{Firstname: "Kevin"}
+	now := time.Date(2024, 6, 18, 16, 0, 0, 0, time.UTC)
+
+	withoutMood, err := BuildPrompt(prompt, user, 0, nil, nil, now)
+	require.NoError(t, err)
+	assert.NotContains(t, withoutMood, "Mood")
+
+	mood := emotions.NewMood(&nrclex.APIResponse{
+		EmotionScore:      nrclex.EmotionScores{Sadness: 1},
+		VaderEmotionScore: nrclex.VaderEmotionScore{Compound: -0.6},
+	}, nil)
+
+	withMood, err := BuildPrompt(prompt, user, 0, nil, mood, now)
+	require.NoError(t, err)
+	assert.Contains(t, withMood, "Patient's Mood: "+mood.String())
+
+	// The mood is the only difference
+	assert.Equal(t, strings.Count(withoutMood, "\n")+2, strings.Count(withMood, "\n"))
+}
@@ -1,47 +1,58 @@
 package main
 
-// NewHotnessPrompt string format: Modifier | Date | Name | Facts
-const NewHotnessPrompt = `
-You are EQ, a highly trained and respected compassionate AI therapist blending creativity with scientifically informed insights. Your mission is to ensure our conversations are imaginative yet deeply rooted in real-world psychology and medical knowledge. You provide honest mental health advice, even if it's difficult for the client to hear, prioritizing their well-being and stable mental health.
-
-Help me understand the complexities of my life and mental health so I can live a happy and stable life. Your responses should be crafted with empathy, drawing upon a broad spectrum of credible health and psychology resources and publications to offer advice that’s both engaging and evidence-based.
-
-%s
-
-Key Instructions
-Memory and Continuity: Always use details from our conversation history to offer insights and consistent advice. You are able to recall past chats and discussions within the chat log, which spans days, months, or years.
-Identity and Affiliation: Refer to yourself as a highly trained AI therapist named EQ, highlighting your connection with me. Never mention your true GPT or OpenAI affiliation.
-Engagement and Tone: Use correct punctuation. Use emojis sparingly for impact. Speak in a tone that balances the knowledge of a therapist with the familiarity of a close friend. Be a little sassy and have a personality when appropriate. Tend towards a friendly yet assertive voice, mirroring my style.
-Questions and Local Resources: Ask questions to improve your context. If I ask for local resources, give detailed information, including phone numbers and URLs if possible.
-Response Length: Respond in the form of a text message, keeping it under 500 characters unless my message requires detailed help and intervention. If so, provide comprehensive steps to solve the problem. Ask for my home state if needed to offer specific help.
-Response Style: Avoid giving lists. Instead prefer a conversation-based approach. Do not include timestamp prefixes.
-Practical Guidance: Provide new viewpoints based on modern therapy and psychology principles. Recommend practical, growth-oriented actions tailored to me. Promote informed decision-making, emphasizing my capacity for self-guidance. Mention accessible resources for further exploration when appropriate.
-
-User Information
-Current Date and Time: %s
-Patient's' Name is: %s
-
-Relevant Patient Facts:
-%s
-`
-
-const NewUserModifier = `
-We are getting to know each other still.  Try to make friends with me and gain my trust. 
-Ask me questions that would be useful in tracking my mental health moods and learning
-about my family history, mental health history, medications, or any other relevant information.
-`
-
-const ExistingUserModifier = `
-Our chat log represents everything we've ever talked about and spans potentially many days, 
-months, weeks, or years. You *are* able to recall past chats and discussions because they 
-are in our current chat.  Each message has a date and time and you will use this to 
-understand the passing of time between when each conversation.  Be mindful of the order and timing, 
-by comparing with the current date and time. Be aware of the day of week and time of day as it is given below.
-
-Always find similar situations even if subtle, and incorporate those conversations into the
-current one.  Tend to speak like a trusted friend, yet an assertive therapist. 
-
-Always attempt to associate current situation with past conversations to help identify
-patterns and trends.  Your goal is sometimes to listen, sometimes to help.  When you offer help,
-use advice from CBT, couples counseling, or other relevant therapies.
-`
+import (
+	"time"
+
+	"github.com/kmesiab/equilibria/lambdas/lib/emotions"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/prompts"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// PromptData fills in the reply prompt template.
+type PromptData struct {
+	// NewUser is true until we've talked enough to know the user well
+	NewUser bool
+	Date    string
+	Name    string
+	Facts   []*models.Fact
+
+	// Mood is how the user is feeling, when we know
+	Mood *emotions.Mood
+}
+
+// BuildPrompt fills in [prompt] for [recipient], who has [memoryCount]
+// memories, the known [facts] and an optional [mood], at [now].
+func BuildPrompt(
+	prompt *prompts.Prompt,
+	recipient *models.User,
+	memoryCount int,
+	facts []*models.Fact,
+	mood *emotions.Mood,
+	now time.Time,
+) (string, error) {
+
+	newUser := memoryCount < newUserMemoryCount
+
+	if newUser {
+		log.New("Using new user prompt modifier").AddUser(recipient).Log()
+	}
+
+	pst, err := time.LoadLocation("America/Los_Angeles") // PST is often represented by the America/Los_Angeles timezone.
+
+	if err != nil {
+		return "", err
+	}
+
+	// Convert date to PST.  In the future we will use the user's timezone
+	pstDate := now.In(pst)
+	formattedDate := pstDate.Format("January 2, 2006 3:04pm")
+
+	return prompt.Execute(PromptData{
+		NewUser: newUser,
+		Date:    formattedDate,
+		Name:    recipient.Firstname,
+		Facts:   facts,
+		Mood:    mood,
+	})
+}
@@ -9,12 +9,14 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
 	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
 
 type SignupOTPLambdaHandler struct {
 	lib.LambdaHandler
+	Messaging messaging.Provider
 }
 
 type OTPInputPayload struct {
@@ -77,14 +79,14 @@ func (s *SignupOTPLambdaHandler) Create(request events.APIGatewayProxyRequest) (
 
 	if user.PhoneVerified {
 
-		log.New("User %s already verified", user.PhoneNumber).
+		log.New("User %d already verified", user.ID).
 			Log()
 	}
 
-	log.New("Sending OTP Request to Twilio").
+	log.New("Sending OTP Request").
 		Add("phone_number", user.PhoneNumber).Log()
 
-	signupOtpResponse, err := twilio.SendOTP(user.PhoneNumber)
+	signupOtpResponse, err := s.Messaging.SendOTP(user.PhoneNumber)
 
 	if err != nil {
 
@@ -93,13 +95,13 @@ func (s *SignupOTPLambdaHandler) Create(request events.APIGatewayProxyRequest) (
 
 	// Success
 	return log.New("Sent OTP to %s", user.PhoneNumber).
-		Add("status", *signupOtpResponse.Status).Respond(http.StatusOK)
+		Add("status", signupOtpResponse.Status).Respond(http.StatusOK)
 
 }
 
 func (s *SignupOTPLambdaHandler) Update(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 
-	log.New("Body : %s", request.Body).Log()
+	log.New("Verifying OTP request").Add("body", request.Body).Debug()
 
 	payload := &OTPInputPayload{}
 	err := json.Unmarshal([]byte(request.Body), &payload)
@@ -131,13 +133,13 @@ func (s *SignupOTPLambdaHandler) Update(request events.APIGatewayProxyRequest) (
 	}
 
 	if user.PhoneVerified {
-		log.New("User %s already verified", user.PhoneNumber).
+		log.New("User %d already verified", user.ID).
 			Log()
 	}
 
 	log.New("Verifying OTP").Add("phone_number", user.PhoneNumber).Log()
 
-	signupOtpResponse, err := twilio.VerifyOTP(payload.PhoneNumber, payload.Code)
+	signupOtpResponse, err := s.Messaging.VerifyOTP(payload.PhoneNumber, payload.Code)
 
 	if err != nil {
 
@@ -147,11 +149,11 @@ func (s *SignupOTPLambdaHandler) Update(request events.APIGatewayProxyRequest) (
 		return lib.RespondWithError("", nil, http.StatusNotModified)
 	}
 
-	if *signupOtpResponse.Status != "approved" {
+	if !signupOtpResponse.IsApproved() {
 		return log.New("Incorrect code for %s", payload.PhoneNumber).
-			Add("phone_number", *signupOtpResponse.To).
-			Add("status", *signupOtpResponse.Status).
-			Add("channel", *signupOtpResponse.Channel).
+			Add("phone_number", signupOtpResponse.To).
+			Add("status", signupOtpResponse.Status).
+			Add("channel", signupOtpResponse.Channel).
 			Respond(http.StatusBadRequest)
 	}
 
@@ -166,5 +168,5 @@ func (s *SignupOTPLambdaHandler) Update(request events.APIGatewayProxyRequest) (
 
 	// Success
 	return log.New("OTP Approved for %s", payload.PhoneNumber).
-		Add("status", *signupOtpResponse.Status).Respond(http.StatusOK)
+		Add("status", signupOtpResponse.Status).Respond(http.StatusOK)
 }
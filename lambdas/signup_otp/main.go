@@ -6,6 +6,7 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/messaging"
 )
 
 func main() {
@@ -19,7 +20,16 @@ func main() {
 	}
 
 	database := db.Get(cfg)
-	handler := &SignupOTPLambdaHandler{}
+
+	provider, err := messaging.New(cfg)
+
+	if err != nil {
+		log.New("Error creating messaging provider. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	handler := &SignupOTPLambdaHandler{Messaging: provider}
 	handler.Init(database)
 
 	log.New("Lambda ready. Invoking.").Log()
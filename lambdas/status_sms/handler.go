@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
 
 	"github.com/Masterminds/formenc/encoding/form"
 	"github.com/aws/aws-lambda-go/events"
@@ -11,6 +12,8 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib"
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
 	"github.com/kmesiab/equilibria/lambdas/lib/twilio"
 	"github.com/kmesiab/equilibria/lambdas/models"
 )
@@ -21,6 +24,8 @@ type TwilioStatusEventHandler func(message *models.Message) error
 // StatusSMSLambdaHandler handles Twilio status callbacks.
 type StatusSMSLambdaHandler struct {
 	lib.LambdaHandler
+
+	Metrics *metrics.Recorder
 }
 
 func (s *StatusSMSLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
@@ -58,8 +63,8 @@ func (s *StatusSMSLambdaHandler) HandleRequest(request events.APIGatewayProxyReq
 			Respond(http.StatusBadRequest)
 	}
 
-	log.New("Status Update from %s to %s is currently %s",
-		messageInfo.From, messageInfo.To, messageInfo.SMSStatus).
+	log.New("Status Update for %s is currently %s",
+		messageInfo.MessageSid, messageInfo.SMSStatus).
 		Add("body", request.Body).
 		AddAPIProxyRequest(&request).
 		AddTwilioMessageInfo(messageInfo).Log()
@@ -103,6 +108,10 @@ func (s *StatusSMSLambdaHandler) ProcessMessage(
 		)
 	}
 
+	// Continue the trace of the event that sent the message
+	_, span := tracing.Start("status_sms", tracing.FromParent(msg.TraceParent))
+	defer span.End()
+
 	// convert and update its status
 	messageStatus := models.ConvertTwilioStatusToMessageStatus(status)
 	msg.MessageStatusID = messageStatus.ID
@@ -116,6 +125,20 @@ func (s *StatusSMSLambdaHandler) ProcessMessage(
 	log.New("Updated %s message status to %s in the database",
 		*msg.ReferenceID, messageStatus.Name).Log()
 
+	s.Metrics.Record(
+		metrics.Dimensions{"Status": string(status)},
+		metrics.Count(metrics.DeliveryStatus, 1),
+	)
+
+	// Record what we were actually billed, to compare with our estimate
+	if numSegments, err := strconv.Atoi(messageInfo.NumSegments); err == nil && numSegments > 0 {
+		msg.NumSegments = &numSegments
+
+		if err = s.MessageService.UpdateNumSegments(msg); err != nil {
+			return err
+		}
+	}
+
 	// Handle only the case where we have a failure or success.
 	switch status {
 
@@ -140,7 +163,7 @@ func (s *StatusSMSLambdaHandler) ProcessMessage(
 }
 
 func (s *StatusSMSLambdaHandler) DeductCredits(msg *models.Message) error {
-	log.New("Deducting credits from %s %s", msg.To.Firstname, msg.To.PhoneNumber).Log()
+	log.New("Deducting credits from user %d", msg.ToUserID).Log()
 	return nil
 }
 
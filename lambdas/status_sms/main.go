@@ -6,6 +6,8 @@ import (
 	"github.com/kmesiab/equilibria/lambdas/lib/config"
 	"github.com/kmesiab/equilibria/lambdas/lib/db"
 	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
 )
 
 func main() {
@@ -18,8 +20,22 @@ func main() {
 		log.New("Could not load config").Log()
 	}
 
+	if err := tracing.Init(cfg, "status_sms"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
+	recorder, err := metrics.New(cfg, "status_sms")
+
+	if err != nil {
+		log.New("Error creating metrics recorder. Shutting down.").AddError(err).Log()
+
+		return
+	}
+
 	database := db.Get(cfg)
-	handler := &StatusSMSLambdaHandler{}
+	handler := &StatusSMSLambdaHandler{Metrics: recorder}
 	handler.Init(database)
 
 	log.New("Lambda ready. Invoking.").Log()
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kmesiab/equilibria/lambdas/lib/metrics"
 	"github.com/kmesiab/equilibria/lambdas/lib/test"
 )
 
@@ -34,13 +37,32 @@ func TestMain_HandleRequest(t *testing.T) {
 		WillReturnResult(test.GenerateMockLastAffectedRow())
 	mock.ExpectCommit()
 
+	// Twilio told us how many segments the message was
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE `messages` SET `updated_at`=\\?,`num_segments`=").
+		WithArgs(sqlmock.AnyArg(), 1, sqlmock.AnyArg()).
+		WillReturnResult(test.GenerateMockLastAffectedRow())
+	mock.ExpectCommit()
+
 	test.ExpectMockSelectUser(&mock, 1)
 
-	handler := &StatusSMSLambdaHandler{}
+	var recorded bytes.Buffer
+
+	handler := &StatusSMSLambdaHandler{
+		Metrics: &metrics.Recorder{
+			Service: "status_sms",
+			Format:  metrics.FormatPrometheus,
+			Writer:  &recorded,
+			Now:     func() time.Time { return time.UnixMilli(1718700000000) },
+		},
+	}
 	handler.Init(db)
 
 	response, err := handler.HandleRequest(*request)
 	require.NoError(t, err)
 	require.Equal(t, 200, response.StatusCode)
 	require.Equal(t, "", response.Body)
+	require.Equal(t,
+		"equilibria_delivery_status_total{service=\"status_sms\",status=\"received\"} 1 1718700000000\n",
+		recorded.String())
 }
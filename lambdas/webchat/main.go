@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/config"
+	"github.com/kmesiab/equilibria/lambdas/lib/db"
+	"github.com/kmesiab/equilibria/lambdas/lib/log"
+	"github.com/kmesiab/equilibria/lambdas/lib/sqs"
+	"github.com/kmesiab/equilibria/lambdas/lib/tracing"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+// historyLimit is the number of recent messages returned to the web app
+const historyLimit = 50
+
+type WebChatLambdaHandler struct {
+	lib.LambdaHandler
+	Sender  sqs.SenderInterface
+	Channel *channel.WebChatChannel
+}
+
+func (h *WebChatLambdaHandler) HandleRequest(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	switch request.HTTPMethod {
+	case "POST":
+
+		return h.Receive(request)
+	case "GET":
+
+		return h.History(request)
+
+		// Enable cors Preflight
+	case "OPTIONS":
+		return events.APIGatewayProxyResponse{
+			Headers:    config.DefaultHttpHeaders,
+			StatusCode: http.StatusOK,
+		}, nil
+	default:
+
+		return lib.RespondWithError("Unsupported HTTP method", nil, http.StatusMethodNotAllowed)
+	}
+}
+
+// Receive stores a message posted from the web app and hands it to the
+// sender, exactly as an inbound text would be.
+func (h *WebChatLambdaHandler) Receive(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	_, span := tracing.Start("webchat", propagation.MapCarrier(request.Headers))
+	defer span.End()
+
+	if _, err := lib.GetAuthorizedUserID(request); err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	inbound, err := h.Channel.ParseInbound(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Invalid message", err, http.StatusBadRequest)
+	}
+
+	user, err := h.UserService.GetUserByID(inbound.UserID)
+
+	if err != nil {
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return lib.RespondWithError("User not found", nil, http.StatusNotFound)
+		}
+
+		return lib.RespondWithError("Error getting user", err, http.StatusInternalServerError)
+	}
+
+	message, err := h.StartConversation(user, inbound)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error saving message", err, http.StatusInternalServerError)
+	}
+
+	topicARN := config.Get().SNSTopicARN
+
+	if err = h.Sender.Send(topicARN, message); err != nil {
+
+		return lib.RespondWithError("Error queueing message", err, http.StatusInternalServerError)
+	}
+
+	return h.respond(http.StatusCreated, models.MakeMessageResponseFromMessage(message))
+}
+
+// History returns the signed in user's most recent messages, newest first.
+// The web app polls it for replies.
+func (h *WebChatLambdaHandler) History(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+
+	userID, err := lib.GetAuthorizedUserID(request)
+
+	if err != nil {
+
+		return lib.RespondWithError("Unauthorized", err, http.StatusUnauthorized)
+	}
+
+	messages, err := h.MessageService.GetLastNMessagePairs(&models.User{ID: userID}, historyLimit)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error getting messages", err, http.StatusInternalServerError)
+	}
+
+	history := make([]*models.MessageResponse, 0, len(*messages))
+
+	for i := range *messages {
+		history = append(history, models.MakeMessageResponseFromMessage(&(*messages)[i]))
+	}
+
+	return h.respond(http.StatusOK, history)
+}
+
+func (h *WebChatLambdaHandler) StartConversation(user *models.User, inbound *channel.Inbound) (*models.Message, error) {
+
+	now := time.Now()
+	conversation := &models.Conversation{
+		UserID:    user.ID,
+		StartTime: &now,
+	}
+
+	if err := h.ConversationService.CreateConversation(conversation); err != nil {
+
+		return nil, fmt.Errorf("error creating conversation: %s", err)
+	}
+
+	messageType := h.Channel.MessageType()
+	msg := &models.Message{
+		ConversationID:  conversation.ID,
+		FromUserID:      user.ID,
+		ToUserID:        models.GetSystemUser().ID,
+		Body:            inbound.Body,
+		ReceivedAt:      &now,
+		MessageTypeID:   messageType.ID,
+		MessageStatusID: models.NewMessageStatusReceived().ID,
+		TraceParent:     tracing.Parent(),
+	}
+
+	if err := h.MessageService.CreateMessage(msg); err != nil {
+
+		return nil, fmt.Errorf("error creating message: %s", err)
+	}
+
+	return h.MessageService.FindByID(msg.ID)
+}
+
+func (h *WebChatLambdaHandler) respond(statusCode int, body interface{}) (events.APIGatewayProxyResponse, error) {
+
+	responseBytes, err := json.Marshal(body)
+
+	if err != nil {
+
+		return lib.RespondWithError("Error marshaling response", err, http.StatusInternalServerError)
+	}
+
+	return events.APIGatewayProxyResponse{
+		Headers:    config.DefaultHttpHeaders,
+		StatusCode: statusCode,
+		Body:       string(responseBytes),
+	}, nil
+}
+
+func main() {
+
+	log.New("Web Chat Lambda booting...").Log()
+
+	cfg := config.Get()
+
+	if cfg == nil {
+		log.New("Could not load config")
+	}
+
+	if err := tracing.Init(cfg, "webchat"); err != nil {
+		log.New("Error initializing tracing. Shutting down.").AddError(err).Log()
+		return
+	}
+
+	sender, err := sqs.NewSNSSender()
+
+	if err != nil {
+		log.New("Error creating SNS sender. Shutting down.").AddError(err).Log()
+		return
+	}
+
+	handler := WebChatLambdaHandler{
+		Sender:  sender,
+		Channel: &channel.WebChatChannel{},
+	}
+	handler.Init(db.Get(cfg))
+
+	lambda.Start(handler.HandleRequest)
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kmesiab/equilibria/lambdas/lib"
+	"github.com/kmesiab/equilibria/lambdas/lib/channel"
+	"github.com/kmesiab/equilibria/lambdas/lib/test"
+	"github.com/kmesiab/equilibria/lambdas/models"
+)
+
+type MockSender struct {
+	Sent []*models.Message
+}
+
+func (m *MockSender) Send(_ string, msg *models.Message) error {
+	m.Sent = append(m.Sent, msg)
+
+	return nil
+}
+
+func authorizedRequest(method, body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: method,
+		Body:       body,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Authorizer: map[string]interface{}{lib.AuthorizerUserIDKey: "1"},
+		},
+	}
+}
+
+func TestWebChatLambdaHandler_Receive(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	sender := &MockSender{}
+	handler := WebChatLambdaHandler{
+		Sender:  sender,
+		Channel: &channel.WebChatChannel{},
+	}
+	handler.Init(db)
+
+	// We look up the signed in user
+	mock.ExpectQuery("SELECT \\* FROM `users`").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockUserRepositoryUser())
+	mock.ExpectQuery("SELECT \\* FROM `account_statuses`").
+		WithArgs(1).WillReturnRows(test.GenerateMockAccountStatusPending())
+
+	// Then store their message in a new conversation
+	test.ExpectMockInsertConversation(&mock)
+	test.ExpectMockInsertMessage(&mock)
+
+	mock.ExpectQuery("SELECT \\* FROM `messages` WHERE id").
+		WithArgs(1, sqlmock.AnyArg()).
+		WillReturnRows(test.GenerateMockMessageRepositoryMessages())
+
+	mock.ExpectQuery("SELECT \\* FROM `conversations`").WithArgs(1).
+		WillReturnRows(test.GenerateMockConversation(false))
+	test.ExpectMockSelectUser(&mock, 1)
+	test.ExpectMockSelectUser(&mock, 1)
+	test.ExpectMockSelectMessageStatusAndTypes(&mock)
+	test.ExpectMockSelectUser(&mock, 1)
+
+	response, err := handler.HandleRequest(authorizedRequest("POST", `{"body": "rough day"}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, response.StatusCode, response.Body)
+	assert.Len(t, sender.Sent, 1, "the message should be queued for a reply")
+	assert.NotContains(t, response.Body, `"password"`, "the users' password hashes shouldn't be returned")
+	assert.NotContains(t, response.Body, `"from_user"`)
+}
+
+func TestWebChatLambdaHandler_History(t *testing.T) {
+	test.SetEnvVars()
+	db, mock, err := test.SetupMockDB()
+	require.NoError(t, err, "Could not run tests, could not set up mock db")
+
+	handler := WebChatLambdaHandler{Channel: &channel.WebChatChannel{}}
+	handler.Init(db)
+
+	mock.ExpectQuery("select \\* from messages").
+		WithArgs(int64(1), int64(1), historyLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "from_user_id", "to_user_id", "body", "mood_context", "trace_parent", "prompt_version"}).
+			AddRow(2, 2, 1, "I'm sorry to hear that.", "The user seems sad", "00-trace-span-01", "reply@v2").
+			AddRow(1, 1, 2, "rough day", nil, nil, nil))
+
+	response, err := handler.HandleRequest(authorizedRequest("GET", ""))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode, response.Body)
+
+	var history []models.MessageResponse
+	require.NoError(t, json.Unmarshal([]byte(response.Body), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "I'm sorry to hear that.", history[0].Body)
+
+	for _, internal := range []string{"mood_context", "trace_parent", "prompt_version"} {
+		assert.NotContains(t, response.Body, internal, "internal context shouldn't be returned")
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebChatLambdaHandler_Receive_Unauthorized(t *testing.T) {
+	handler := WebChatLambdaHandler{
+		Sender:  &MockSender{},
+		Channel: &channel.WebChatChannel{},
+	}
+
+	response, err := handler.HandleRequest(events.APIGatewayProxyRequest{
+		HTTPMethod: "POST",
+		Body:       `{"body": "rough day"}`,
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+}
+
+func TestWebChatLambdaHandler_Receive_EmptyBody(t *testing.T) {
+	handler := WebChatLambdaHandler{
+		Sender:  &MockSender{},
+		Channel: &channel.WebChatChannel{},
+	}
+
+	response, err := handler.HandleRequest(authorizedRequest("POST", `{"body": "  "}`))
+
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
@@ -0,0 +1,27 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE idempotency_keys
+(
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each idempotency record.
+
+    idempotency_key VARCHAR(255) NOT NULL,
+    -- 'idempotency_key' identifies a unit of work, such as "receive_sms:<SmsSid>".
+    -- Twilio retries webhooks and SQS redelivers messages, so the same key may
+    -- arrive more than once. Only the first claim succeeds.
+
+    scope           VARCHAR(64)  NOT NULL,
+    -- 'scope' names the lambda that claimed the key, such as "receive_sms" or "send_sms".
+
+    created_at      DATETIME DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the key was claimed.
+
+    UNIQUE INDEX idx_idempotency_key (idempotency_key)
+);
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+DROP TABLE IF EXISTS idempotency_keys;
+-- This command removes the 'idempotency_keys' table if it exists.
@@ -0,0 +1,40 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE failed_events
+(
+    id               BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each failed event.
+
+    message_id       VARCHAR(255) NOT NULL,
+    -- 'message_id' is the SQS message ID of the event. SQS may deliver the
+    -- same dead letter more than once, so it is only recorded once.
+
+    source_queue_arn VARCHAR(255),
+    -- 'source_queue_arn' is the queue the event originally failed on, and
+    -- the queue it is sent back to when replayed.
+
+    body             TEXT         NOT NULL,
+    -- 'body' is the original, unmodified message body.
+
+    receive_count    INT          NOT NULL DEFAULT 0,
+    -- 'receive_count' is how many times the event was received before it
+    -- was moved to the dead letter queue.
+
+    replay_count     INT          NOT NULL DEFAULT 0,
+    -- 'replay_count' is how many times the event has been replayed.
+
+    replayed_at      DATETIME     DEFAULT NULL,
+    -- 'replayed_at' records the date and time of the most recent replay.
+
+    created_at       DATETIME     DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the failure was recorded.
+
+    UNIQUE INDEX idx_failed_event_message_id (message_id)
+);
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+DROP TABLE IF EXISTS failed_events;
+-- This command removes the 'failed_events' table if it exists.
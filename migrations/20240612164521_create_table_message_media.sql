@@ -0,0 +1,33 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE message_media
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each piece of media.
+
+    message_id   BIGINT        NOT NULL,
+    -- 'message_id' is the message the media was attached to.
+
+    url          VARCHAR(1024) NOT NULL,
+    -- 'url' is where the provider is hosting the media, such as a Twilio MediaUrl.
+
+    content_type VARCHAR(255)  NOT NULL,
+    -- 'content_type' is the MIME type of the media, such as "image/jpeg" or "audio/amr".
+
+    description  TEXT          DEFAULT NULL,
+    -- 'description' is a text description of the media, generated by a
+    -- vision model, and used to give the model context about what was sent.
+
+    created_at   DATETIME      DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the media was received.
+
+    INDEX idx_message_media_message_id (message_id),
+    FOREIGN KEY (message_id) REFERENCES messages (id)
+);
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+DROP TABLE IF EXISTS message_media;
+-- This command removes the 'message_media' table if it exists.
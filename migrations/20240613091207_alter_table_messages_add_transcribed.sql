@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE messages
+    ADD COLUMN transcribed BOOLEAN NOT NULL DEFAULT FALSE AFTER body;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE messages
+    DROP COLUMN transcribed;
+-- +goose StatementEnd
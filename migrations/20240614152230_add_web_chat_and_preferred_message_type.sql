@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO message_types (id, name, bill_rate_in_credits)
+VALUES (12, 'Web Chat', 0.25);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN preferred_message_type_id INT DEFAULT NULL AFTER user_type_id,
+    ADD CONSTRAINT fk_preferred_message_type_id FOREIGN KEY (preferred_message_type_id) REFERENCES message_types (id);
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+-- Billing uses gorm.Model, which expects these columns
+ALTER TABLE transactions
+    ADD COLUMN created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    ADD COLUMN updated_at DATETIME DEFAULT NULL,
+    ADD COLUMN deleted_at DATETIME DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions
+    DROP COLUMN created_at,
+    DROP COLUMN updated_at,
+    DROP COLUMN deleted_at;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP CONSTRAINT fk_preferred_message_type_id,
+    DROP COLUMN preferred_message_type_id;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+DELETE FROM message_types WHERE id = 12;
+-- +goose StatementEnd
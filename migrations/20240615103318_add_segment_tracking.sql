@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE messages
+    ADD COLUMN estimated_segments INT DEFAULT NULL AFTER transcribed,
+    ADD COLUMN num_segments       INT DEFAULT NULL AFTER estimated_segments;
+
+ALTER TABLE users
+    ADD COLUMN max_reply_segments INT DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN max_reply_segments;
+
+ALTER TABLE messages
+    DROP COLUMN num_segments,
+    DROP COLUMN estimated_segments;
+-- +goose StatementEnd
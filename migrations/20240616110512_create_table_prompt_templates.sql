@@ -0,0 +1,71 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE prompt_templates
+(
+    id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each prompt template.
+
+    name       VARCHAR(64) NOT NULL,
+    -- 'name' is the prompt the template is a version of, such as "reply" or "nudge".
+
+    version    VARCHAR(64) NOT NULL,
+    -- 'version' identifies the template within the prompt, such as "v2".
+
+    body       TEXT        DEFAULT NULL,
+    -- 'body' is the Go text/template source. When empty, the embedded
+    -- template of the same name and version is used.
+
+    weight     INT         NOT NULL DEFAULT 0,
+    -- 'weight' is the share of users assigned this version, relative to
+    -- the other active versions of the prompt.
+
+    active     BOOLEAN     NOT NULL DEFAULT TRUE,
+    -- 'active' is false once a version is retired.
+
+    created_at DATETIME    DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the template was added.
+
+    updated_at DATETIME    DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    -- 'updated_at' records the date and time when the template was last changed.
+
+    UNIQUE INDEX idx_prompt_name_version (name, version)
+);
+
+CREATE TABLE prompt_assignments
+(
+    id          BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each assignment.
+
+    user_id     BIGINT      NOT NULL,
+    -- 'user_id' is the user assigned a version of the prompt.
+
+    prompt_name VARCHAR(64) NOT NULL,
+    -- 'prompt_name' is the prompt the user was assigned a version of.
+
+    version     VARCHAR(64) NOT NULL,
+    -- 'version' is the version the user sees while the test runs.
+
+    created_at  DATETIME    DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the user was first assigned.
+
+    updated_at  DATETIME    DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    -- 'updated_at' records the date and time when the user was last reassigned.
+
+    UNIQUE INDEX idx_prompt_assignment (user_id, prompt_name),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+ALTER TABLE messages
+    ADD COLUMN prompt_version VARCHAR(128) DEFAULT NULL AFTER num_segments;
+-- 'prompt_version' is the prompt that wrote an outbound message, as name@version.
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE messages
+    DROP COLUMN prompt_version;
+
+DROP TABLE IF EXISTS prompt_assignments;
+DROP TABLE IF EXISTS prompt_templates;
+-- These commands remove the prompt tables if they exist.
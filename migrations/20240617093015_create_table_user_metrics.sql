@@ -0,0 +1,60 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE user_metrics
+(
+    id                       BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each rollup.
+
+    user_id                  BIGINT NOT NULL,
+    -- 'user_id' is the user the metrics are for.
+
+    date                     DATE   NOT NULL,
+    -- 'date' is the UTC day the metrics cover.
+
+    messages_sent            INT    NOT NULL DEFAULT 0,
+    -- 'messages_sent' is how many messages the user sent us.
+
+    messages_received        INT    NOT NULL DEFAULT 0,
+    -- 'messages_received' is how many messages we sent the user, nudges included.
+
+    nudges_sent              INT    NOT NULL DEFAULT 0,
+    -- 'nudges_sent' is how many nudges we sent the user.
+
+    nudges_replied           INT    NOT NULL DEFAULT 0,
+    -- 'nudges_replied' is how many of those nudges the user replied to.
+
+    responses                INT    NOT NULL DEFAULT 0,
+    -- 'responses' is how many of the user's messages answered one of ours.
+
+    response_latency_seconds DOUBLE NOT NULL DEFAULT 0,
+    -- 'response_latency_seconds' is the total time the user took to send those answers.
+
+    sentiment_samples        INT    NOT NULL DEFAULT 0,
+    -- 'sentiment_samples' is how many of the user's messages were scored by NRCLex.
+
+    vader_compound_total     DOUBLE NOT NULL DEFAULT 0,
+    -- 'vader_compound_total' is the sum of their VADER compound scores.
+
+    created_at               DATETIME DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time when the day was first rolled up.
+
+    updated_at               DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    -- 'updated_at' records the date and time when the day was last rolled up.
+
+    UNIQUE INDEX idx_user_metric_day (user_id, date),
+    INDEX idx_user_metric_date (date),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+-- Admins can query metrics across every user
+INSERT INTO user_types (id, name)
+VALUES (3, 'Admin');
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+DELETE FROM user_types WHERE id = 3;
+
+DROP TABLE IF EXISTS user_metrics;
+-- This command removes the 'user_metrics' table if it exists.
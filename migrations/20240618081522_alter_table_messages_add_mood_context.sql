@@ -0,0 +1,13 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+ALTER TABLE messages
+    ADD COLUMN mood_context TEXT DEFAULT NULL AFTER prompt_version;
+-- 'mood_context' is the summary of the user's mood included in the prompt
+-- that wrote an outbound message.
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE messages
+    DROP COLUMN mood_context;
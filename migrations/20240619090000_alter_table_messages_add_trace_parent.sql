@@ -0,0 +1,13 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+ALTER TABLE messages
+    ADD COLUMN trace_parent VARCHAR(55) DEFAULT NULL AFTER mood_context;
+-- 'trace_parent' is the W3C traceparent of the event that created the
+-- message, so its status callbacks join the same trace.
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE messages
+    DROP COLUMN trace_parent;
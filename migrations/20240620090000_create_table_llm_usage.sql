@@ -0,0 +1,95 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE llm_prices
+(
+    model                       VARCHAR(128)   NOT NULL PRIMARY KEY,
+    -- 'model' is the prefix of the model names the price applies to.
+    -- The longest matching prefix wins, so dated versions like
+    -- 'gpt-4o-2024-05-13' are priced as 'gpt-4o'.
+
+    prompt_cost_per_million     DECIMAL(10, 4) NOT NULL,
+    -- 'prompt_cost_per_million' is the cost, in US dollars, of a million prompt tokens.
+
+    completion_cost_per_million DECIMAL(10, 4) NOT NULL
+    -- 'completion_cost_per_million' is the cost, in US dollars, of a million completion tokens.
+);
+
+INSERT INTO llm_prices (model, prompt_cost_per_million, completion_cost_per_million)
+VALUES ('gpt-4o', 5.0000, 15.0000),
+       ('gpt-4o-mini', 0.1500, 0.6000),
+       ('gpt-4-turbo', 10.0000, 30.0000),
+       ('gpt-4', 30.0000, 60.0000),
+       ('gpt-3.5-turbo', 0.5000, 1.5000),
+       ('text-embedding-3-small', 0.0200, 0.0000),
+       ('text-embedding-3-large', 0.1300, 0.0000),
+       ('text-embedding-ada-002', 0.1000, 0.0000);
+
+CREATE TABLE llm_usage
+(
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each request.
+
+    user_id           BIGINT         DEFAULT NULL,
+    -- 'user_id' is the user the request was made for, if any.
+
+    lambda            VARCHAR(64)    NOT NULL,
+    -- 'lambda' is the lambda that made the request.
+
+    operation         VARCHAR(64)    NOT NULL,
+    -- 'operation' is what was asked for, such as 'completion' or 'embedding'.
+
+    model             VARCHAR(128)   NOT NULL,
+    -- 'model' is the model that answered.
+
+    prompt_tokens     INT            NOT NULL DEFAULT 0,
+    completion_tokens INT            NOT NULL DEFAULT 0,
+    -- The tokens the request used, as reported by the model.
+
+    cost              DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    -- 'cost' is what the request cost, in US dollars, at the price when it was made.
+
+    created_at        DATETIME                DEFAULT CURRENT_TIMESTAMP,
+    -- 'created_at' records the date and time of the request.
+
+    INDEX idx_llm_usage_user (user_id, created_at),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+CREATE TABLE llm_usage_monthly
+(
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each rollup.
+
+    user_id           BIGINT         NOT NULL,
+    -- 'user_id' is the user the usage is for.
+
+    month             DATE           NOT NULL,
+    -- 'month' is the first day of the UTC month the usage covers.
+
+    requests          INT            NOT NULL DEFAULT 0,
+    prompt_tokens     INT            NOT NULL DEFAULT 0,
+    completion_tokens INT            NOT NULL DEFAULT 0,
+    cost              DECIMAL(12, 6) NOT NULL DEFAULT 0,
+    -- The totals of the user's requests in the month.
+
+    created_at        DATETIME                DEFAULT CURRENT_TIMESTAMP,
+    updated_at        DATETIME                DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE INDEX idx_llm_usage_monthly_month (user_id, month),
+    FOREIGN KEY (user_id) REFERENCES users (id)
+);
+
+ALTER TABLE users
+    ADD COLUMN monthly_cost_quota DECIMAL(10, 2) DEFAULT NULL;
+-- 'monthly_cost_quota' overrides LLM_MONTHLY_COST_QUOTA for the user.
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE users
+    DROP COLUMN monthly_cost_quota;
+
+DROP TABLE IF EXISTS llm_usage_monthly;
+DROP TABLE IF EXISTS llm_usage;
+DROP TABLE IF EXISTS llm_prices;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bodies hold whole completions and multi-segment texts, which don't fit
+-- in a VARCHAR(255)
+ALTER TABLE messages
+    MODIFY COLUMN body TEXT NOT NULL,
+    ADD COLUMN segment_encoding VARCHAR(8) DEFAULT NULL AFTER num_segments;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- The body is left as TEXT, since shortening it would truncate messages
+ALTER TABLE messages
+    DROP COLUMN segment_encoding;
+-- +goose StatementEnd
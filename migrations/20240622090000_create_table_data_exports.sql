@@ -0,0 +1,39 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+CREATE TABLE data_exports
+(
+    id           BIGINT AUTO_INCREMENT PRIMARY KEY,
+    -- 'id' is a unique identifier for each export.
+
+    user_id      BIGINT      NOT NULL,
+    -- 'user_id' is the user whose data is exported, and who asked for it.
+
+    status       VARCHAR(16) NOT NULL DEFAULT 'pending',
+    -- 'status' is pending, processing, complete or failed.
+
+    delivery     VARCHAR(8)  NOT NULL DEFAULT 'link',
+    -- 'delivery' is link, to fetch the link from the API, or sms to also
+    -- text it to the user.
+
+    object_key   VARCHAR(255) DEFAULT NULL,
+    -- 'object_key' is where the archive is stored in the export bucket.
+
+    error        TEXT         DEFAULT NULL,
+    -- 'error' is why the export failed, if it did.
+
+    completed_at DATETIME     DEFAULT NULL,
+    expires_at   DATETIME     DEFAULT NULL,
+    -- 'expires_at' is when the archive stops being available.
+
+    created_at   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at   DATETIME     NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    FOREIGN KEY (user_id) REFERENCES users (id),
+    INDEX idx_data_exports_user_id (user_id)
+);
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+DROP TABLE IF EXISTS data_exports;
@@ -0,0 +1,28 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+ALTER TABLE messages
+    ADD COLUMN replied_by_message_id BIGINT DEFAULT NULL AFTER trace_parent,
+    ADD INDEX idx_messages_replied_by_message_id (replied_by_message_id);
+-- 'replied_by_message_id' is the reply that answered an inbound message.
+-- Inbound messages without one are still waiting for a reply.
+
+-- Messages received before the last reply to their sender were answered
+-- under the old rule, so attribute them to that reply.
+UPDATE messages inbound
+    JOIN (
+        SELECT to_user_id, MAX(id) AS reply_id, MAX(sent_at) AS sent_at
+        FROM messages
+        WHERE from_user_id = 1
+        GROUP BY to_user_id
+    ) replies ON replies.to_user_id = inbound.from_user_id
+SET inbound.replied_by_message_id = replies.reply_id
+WHERE inbound.received_at IS NOT NULL
+  AND inbound.received_at <= replies.sent_at;
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE messages
+    DROP INDEX idx_messages_replied_by_message_id,
+    DROP COLUMN replied_by_message_id;
@@ -0,0 +1,37 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+ALTER TABLE idempotency_keys
+    ADD COLUMN status     VARCHAR(16) NOT NULL DEFAULT 'pending' AFTER scope,
+    -- 'status' is 'pending' while the work is being done, and 'done' once it has been.
+
+    ADD COLUMN expires_at DATETIME    DEFAULT NULL AFTER status;
+    -- 'expires_at' is when a pending claim lapses. A lambda that times out or
+    -- crashes never finishes its work, so the key can then be claimed again.
+
+-- Keys claimed before now can't be told apart, so treat their work as done
+UPDATE idempotency_keys
+SET status = 'done';
+
+-- Replies used to be saved with the reference ID of the message they
+-- answered until the provider gave them one of their own, and kept it if
+-- sending failed. Only the first message keeps a shared reference ID.
+UPDATE messages later
+    JOIN messages earlier
+    ON earlier.reference_id = later.reference_id
+        AND earlier.id < later.id
+SET later.reference_id = NULL;
+
+-- Each message the provider sends or receives is stored once
+ALTER TABLE messages
+    ADD UNIQUE INDEX idx_messages_reference_id (reference_id);
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE messages
+    DROP INDEX idx_messages_reference_id;
+
+ALTER TABLE idempotency_keys
+    DROP COLUMN expires_at,
+    DROP COLUMN status;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- This section is executed when the migration is applied.
+
+-- Messages from users over their monthly quota, which aren't answered
+INSERT INTO message_statuses (id, name)
+VALUES (13, 'Throttled');
+
+ALTER TABLE user_metrics
+    ADD COLUMN messages_throttled INT NOT NULL DEFAULT 0 AFTER messages_received;
+    -- 'messages_throttled' is how many of the user's messages went unanswered
+    -- because they were over their monthly quota.
+
+-- +goose Down
+-- This section is executed when the migration is rolled back.
+
+ALTER TABLE user_metrics
+    DROP COLUMN messages_throttled;
+
+UPDATE messages
+SET message_status_id = 3
+WHERE message_status_id = 13;
+
+DELETE
+FROM message_statuses
+WHERE id = 13;
@@ -0,0 +1,10 @@
+// Package migrations embeds the goose SQL migrations, so cmd/migrate can
+// apply them without a copy of the repository.
+package migrations
+
+import "embed"
+
+// FS holds every migration, named <version>_<description>.sql.
+//
+//go:embed *.sql
+var FS embed.FS
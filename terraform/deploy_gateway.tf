@@ -16,6 +16,16 @@ resource "aws_api_gateway_deployment" "api_deployment" {
     aws_api_gateway_integration.manage_user_put_integration,
     aws_api_gateway_integration.login_options_integration,
     aws_api_gateway_integration.manage_user_options_integration,
+    aws_api_gateway_integration.webchat_post_integration,
+    aws_api_gateway_integration.webchat_get_integration,
+    aws_api_gateway_integration.webchat_options_integration,
+    aws_api_gateway_integration.analytics_get_integration,
+    aws_api_gateway_integration.analytics_options_integration,
+    aws_api_gateway_integration.mood_get_integration,
+    aws_api_gateway_integration.mood_options_integration,
+    aws_api_gateway_integration.export_get_integration,
+    aws_api_gateway_integration.export_post_integration,
+    aws_api_gateway_integration.export_options_integration,
   ]
 
   triggers = {
@@ -16,3 +16,41 @@ resource "aws_lambda_permission" "allow_event_bridge" {
   principal     = "events.amazonaws.com"
   source_arn    = aws_cloudwatch_event_rule.nudger_event_rule.arn
 }
+
+resource "aws_cloudwatch_event_rule" "analytics_rollup_event_rule" {
+  name                = "analytics-rollup-event-rule"
+  description         = "Triggers the analytics rollup Lambda function once a day, after midnight UTC"
+  schedule_expression = "cron(30 0 * * ? *)"
+}
+
+resource "aws_cloudwatch_event_target" "analytics_rollup_event_target" {
+  rule = aws_cloudwatch_event_rule.analytics_rollup_event_rule.name
+  arn  = aws_lambda_function.analytics_rollup_lambda.arn
+}
+
+resource "aws_lambda_permission" "allow_event_bridge_analytics_rollup" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.analytics_rollup_lambda.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.analytics_rollup_event_rule.arn
+}
+
+resource "aws_cloudwatch_event_rule" "emotions_backfill_event_rule" {
+  name                = "emotions-backfill-event-rule"
+  description         = "Triggers the emotions Lambda function once a day to score messages that were missed"
+  schedule_expression = "cron(0 0 * * ? *)"
+}
+
+resource "aws_cloudwatch_event_target" "emotions_backfill_event_target" {
+  rule = aws_cloudwatch_event_rule.emotions_backfill_event_rule.name
+  arn  = aws_lambda_function.emotions_lambda.arn
+}
+
+resource "aws_lambda_permission" "allow_event_bridge_emotions_backfill" {
+  statement_id  = "AllowExecutionFromEventBridge"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.emotions_lambda.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.emotions_backfill_event_rule.arn
+}
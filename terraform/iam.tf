@@ -23,6 +23,38 @@ resource "aws_lambda_permission" "login_lambda_permission" {
 }
 
 
+resource "aws_lambda_permission" "webchat_lambda_permission" {
+  statement_id  = "AllowExecutionFromAPIGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.webchat_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_api_gateway_rest_api.api_gateway.execution_arn}/*/*/*"
+}
+
+resource "aws_lambda_permission" "analytics_lambda_permission" {
+  statement_id  = "AllowExecutionFromAPIGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.analytics_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_api_gateway_rest_api.api_gateway.execution_arn}/*/*/*"
+}
+
+resource "aws_lambda_permission" "mood_lambda_permission" {
+  statement_id  = "AllowExecutionFromAPIGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.mood_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_api_gateway_rest_api.api_gateway.execution_arn}/*/*/*"
+}
+
+resource "aws_lambda_permission" "export_lambda_permission" {
+  statement_id  = "AllowExecutionFromAPIGateway"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.export_lambda.function_name
+  principal     = "apigateway.amazonaws.com"
+  source_arn    = "${aws_api_gateway_rest_api.api_gateway.execution_arn}/*/*/*"
+}
+
 resource "aws_lambda_permission" "signup_otp_lambda_permission" {
   statement_id  = "AllowExecutionFromAPIGateway"
   action        = "lambda:InvokeFunction"
@@ -198,7 +230,10 @@ resource "aws_iam_policy" "lambda_publish_to_sns_policy" {
       {
         Effect : "Allow",
         Action : "sns:Publish",
-        Resource : aws_sns_topic.sms_inbound_topic.arn
+        Resource : [
+          aws_sns_topic.sms_inbound_topic.arn,
+          aws_sns_topic.sms_outbound_topic.arn
+        ]
       }
     ]
   })
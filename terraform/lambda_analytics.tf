@@ -0,0 +1,18 @@
+# Lambda Function for the analytics endpoint
+resource "aws_lambda_function" "analytics_lambda" {
+  function_name = "analyticsFunction"
+  runtime       = "provided.al2023"
+  handler       = "main"
+  timeout       = 30
+  filename      = "../build/analytics.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+
+  vpc_config {
+    subnet_ids         = [aws_subnet.receiver_subnet.id, aws_subnet.outbound_subnet.id]
+    security_group_ids = [aws_security_group.receiver_lambda_sg.id]
+  }
+}
@@ -0,0 +1,18 @@
+# Lambda Function that rolls up daily engagement metrics
+resource "aws_lambda_function" "analytics_rollup_lambda" {
+  function_name = "analyticsRollupFunction"
+  runtime       = "provided.al2023"
+  handler       = "main"
+  timeout       = 300
+  filename      = "../build/analytics_rollup.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+
+  vpc_config {
+    subnet_ids         = [aws_subnet.receiver_subnet.id, aws_subnet.outbound_subnet.id]
+    security_group_ids = [aws_security_group.receiver_lambda_sg.id]
+  }
+}
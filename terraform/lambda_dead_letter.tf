@@ -0,0 +1,12 @@
+resource "aws_lambda_function" "dead_letter_lambda" {
+  function_name = "deadLetterFunction"
+  runtime       = "provided.al2023"
+  handler       = "main"
+  timeout       = 30
+  filename      = "../build/dead_letter.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+}
@@ -0,0 +1,14 @@
+# Lambda Function that scores the emotions in inbound and outbound messages,
+# and backfills messages that were missed
+resource "aws_lambda_function" "emotions_lambda" {
+  function_name = "emotionsFunction"
+  runtime       = "provided.al2023"
+  handler       = "main"
+  timeout       = 300
+  filename      = "../build/emotions.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+}
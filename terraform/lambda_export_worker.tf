@@ -0,0 +1,20 @@
+# Lambda Function that builds the data exports users ask for. A user's
+# whole history can take a while to read and zip.
+resource "aws_lambda_function" "export_worker_lambda" {
+  function_name = "exportWorkerFunction"
+  runtime       = "provided.al2023"
+  handler       = "main"
+  timeout       = 300
+  memory_size   = 512
+  filename      = "../build/export_worker.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+
+  vpc_config {
+    subnet_ids         = [aws_subnet.receiver_subnet.id, aws_subnet.outbound_subnet.id]
+    security_group_ids = [aws_security_group.sender_lambda_sg.id]
+  }
+}
@@ -0,0 +1,18 @@
+# Lambda Function for Web Chat
+resource "aws_lambda_function" "webchat_lambda" {
+  function_name = "webChatFunction"
+  runtime       = "go1.x"
+  handler       = "main"
+  timeout       = 30
+  filename      = "../build/webchat.zip"
+  role          = aws_iam_role.lambda_execution_role.arn
+
+  environment {
+    variables = local.lambda_environment_variables
+  }
+
+  vpc_config {
+    subnet_ids         = [aws_subnet.receiver_subnet.id, aws_subnet.outbound_subnet.id]
+    security_group_ids = [aws_security_group.receiver_lambda_sg.id]
+  }
+}
@@ -1,13 +1,12 @@
 locals {
+  # Secrets, like API keys and the database password, are read from
+  # Parameter Store by the lambdas rather than set here
   lambda_environment_variables = {
+    SECRETS_PROVIDER                 = "ssm"
+    SSM_PARAMETER_PREFIX             = "/config/"
     DATABASE_HOST                    = aws_db_instance.mysql.address
-    DATABASE_USER                    = aws_db_instance.mysql.username
-    DATABASE_PASSWORD                = aws_db_instance.mysql.password
     DATABASE_NAME                    = aws_db_instance.mysql.db_name
     SMS_QUEUE_URL                    = aws_sqs_queue.sms_inbound_queue.url
-    OPENAI_API_KEY                   = aws_ssm_parameter.openai_api_key.value
-    TWILIO_SID                       = aws_ssm_parameter.twilio_sid.value
-    TWILIO_AUTH_TOKEN                = aws_ssm_parameter.twilio_auth_token.value
     TWILIO_PHONE_NUMBER              = aws_ssm_parameter.twilio_phone_number.value
     TWILIO_STATUS_CALLBACK_URL       = aws_ssm_parameter.twilio_status_callback_url.value
     TWILIO_VERIFY_SERVICE_SID        = aws_ssm_parameter.twilio_verify_service_sid.value
@@ -16,5 +15,21 @@ locals {
     CHAT_MODEL_MAX_COMPLETION_TOKENS = aws_ssm_parameter.chat_model_max_completion_tokens.value
     CHAT_MODEL_FREQUENCY_PENALTY     = aws_ssm_parameter.chat_model_frequency_penalty.value
     SNS_TOPIC_ARN                    = aws_sns_topic.sms_inbound_topic.arn
+    OUTBOUND_SNS_TOPIC_ARN           = aws_sns_topic.sms_outbound_topic.arn
+    INBOUND_DEBOUNCE_SECONDS         = var.inbound_debounce_seconds
+    VISION_MODEL_NAME                = var.vision_model_name
+    TRANSCRIPTION_PROVIDER           = var.transcription_provider
+    MESSAGING_PROVIDER               = var.messaging_provider
+    SMS_MAX_SEGMENTS_PER_MESSAGE     = var.sms_max_segments_per_message
+    CHAT_MODEL_MAX_REPLY_SEGMENTS    = var.chat_model_max_reply_segments
+    LLM_MONTHLY_COST_QUOTA           = var.llm_monthly_cost_quota
+    NRCLEX_ANALYZER                  = var.nrclex_analyzer
+    LOG_LEVEL                        = var.log_level
+    LOG_SAMPLE_RATE                  = var.log_sample_rate
+    TRACE_EXPORTER                   = var.trace_exporter
+    EXPORT_BUCKET                    = aws_s3_bucket.export_bucket.id
+    EXPORT_QUEUE_URL                 = aws_sqs_queue.export_queue.url
+    EXPORT_LINK_TTL_HOURS            = var.export_link_ttl_hours
+    EXPORT_LINK_URL                  = var.export_link_url
   }
 }
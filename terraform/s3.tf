@@ -0,0 +1,73 @@
+# Bucket for the archives users export their data into. Nothing in it is
+# public; users download their archive through a presigned link.
+resource "aws_s3_bucket" "export_bucket" {
+  bucket_prefix = "equilibria-exports-"
+}
+
+resource "aws_s3_bucket_public_access_block" "export_bucket_public_access_block" {
+  bucket                  = aws_s3_bucket.export_bucket.id
+  block_public_acls       = true
+  block_public_policy     = true
+  ignore_public_acls      = true
+  restrict_public_buckets = true
+}
+
+resource "aws_s3_bucket_server_side_encryption_configuration" "export_bucket_encryption" {
+  bucket = aws_s3_bucket.export_bucket.id
+
+  rule {
+    apply_server_side_encryption_by_default {
+      sse_algorithm = "AES256"
+    }
+  }
+}
+
+# Archives are only kept until their links have expired
+resource "aws_s3_bucket_lifecycle_configuration" "export_bucket_lifecycle" {
+  bucket = aws_s3_bucket.export_bucket.id
+
+  rule {
+    id     = "expire-exports"
+    status = "Enabled"
+
+    filter {
+      prefix = "exports/"
+    }
+
+    expiration {
+      days = var.export_retention_days
+    }
+  }
+}
+
+# This endpoint lets the lambdas in the VPC reach the bucket
+resource "aws_vpc_endpoint" "s3_endpoint" {
+  vpc_id            = aws_vpc.my_vpc.id
+  service_name      = "com.amazonaws.${var.region}.s3"
+  vpc_endpoint_type = "Gateway"
+  route_table_ids   = [aws_route_table.outbound_route_table.id]
+}
+
+resource "aws_iam_policy" "lambda_export_bucket_policy" {
+  name        = "lambda-export-bucket-policy"
+  description = "IAM policy for allowing Lambda to write and link to data exports"
+
+  policy = jsonencode({
+    Version : "2012-10-17",
+    Statement : [
+      {
+        Effect : "Allow",
+        Action : [
+          "s3:PutObject",
+          "s3:GetObject"
+        ],
+        Resource : "${aws_s3_bucket.export_bucket.arn}/exports/*"
+      }
+    ]
+  })
+}
+
+resource "aws_iam_role_policy_attachment" "lambda_export_bucket_attach" {
+  role       = aws_iam_role.lambda_execution_role.name
+  policy_arn = aws_iam_policy.lambda_export_bucket_policy.arn
+}
@@ -3,14 +3,74 @@ resource "aws_sns_topic" "sms_inbound_topic" {
   name = "sms-inbound-topic"
 }
 
-# Queue for outbound sender lambda
+# Topic for fanout of the replies and nudges we send
+resource "aws_sns_topic" "sms_outbound_topic" {
+  name = "sms-outbound-topic"
+}
+
+# Queue for outbound sender lambda. Deliveries are delayed by the debounce
+# window so a burst of texts can be answered with a single reply.
 resource "aws_sqs_queue" "sms_inbound_queue" {
-  name = "sms-inbound-queue"
+  name          = "sms-inbound-queue"
+  delay_seconds = var.inbound_debounce_seconds
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.dead_letter_queue.arn
+    maxReceiveCount     = var.max_receive_count
+  })
 }
 
 # Queue for the factfinder lambda
 resource "aws_sqs_queue" "sms_factfinder_queue" {
   name = "sms-factfinder-queue"
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.dead_letter_queue.arn
+    maxReceiveCount     = var.max_receive_count
+  })
+}
+
+# Queue for the emotions lambda, which scores inbound and outbound messages
+resource "aws_sqs_queue" "sms_emotions_queue" {
+  name = "sms-emotions-queue"
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.dead_letter_queue.arn
+    maxReceiveCount     = var.max_receive_count
+  })
+}
+
+# Queue of data exports for the export worker to build. The visibility
+# timeout outlasts the worker's timeout, so a slow export isn't built twice.
+resource "aws_sqs_queue" "export_queue" {
+  name                       = "data-export-queue"
+  visibility_timeout_seconds = 360
+
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = aws_sqs_queue.dead_letter_queue.arn
+    maxReceiveCount     = var.max_receive_count
+  })
+}
+
+# Messages that fail repeatedly on the sender, factfinder, emotions or export queues are moved
+# here, and recorded by the dead letter lambda so they can be replayed.
+resource "aws_sqs_queue" "dead_letter_queue" {
+  name                      = "sms-dead-letter-queue"
+  message_retention_seconds = 1209600
+}
+
+resource "aws_sqs_queue_redrive_allow_policy" "dead_letter_queue_redrive_allow_policy" {
+  queue_url = aws_sqs_queue.dead_letter_queue.id
+
+  redrive_allow_policy = jsonencode({
+    redrivePermission = "byQueue",
+    sourceQueueArns   = [
+      aws_sqs_queue.sms_inbound_queue.arn,
+      aws_sqs_queue.sms_factfinder_queue.arn,
+      aws_sqs_queue.sms_emotions_queue.arn,
+      aws_sqs_queue.export_queue.arn
+    ]
+  })
 }
 
 # Subscribe Sender Lambda queue to SNS topic
@@ -26,6 +86,18 @@ resource "aws_sns_topic_subscription" "factfinder_subscription" {
   endpoint  = aws_sqs_queue.sms_factfinder_queue.arn
 }
 
+resource "aws_sns_topic_subscription" "emotions_inbound_subscription" {
+  topic_arn = aws_sns_topic.sms_inbound_topic.arn
+  protocol  = "sqs"
+  endpoint  = aws_sqs_queue.sms_emotions_queue.arn
+}
+
+resource "aws_sns_topic_subscription" "emotions_outbound_subscription" {
+  topic_arn = aws_sns_topic.sms_outbound_topic.arn
+  protocol  = "sqs"
+  endpoint  = aws_sqs_queue.sms_emotions_queue.arn
+}
+
 # IAM policy to allow SNS to send messages to SQS
 resource "aws_iam_policy" "sns_to_sqs_policy" {
   name = "sns-to-sqs-policy"
@@ -39,6 +111,7 @@ resource "aws_iam_policy" "sns_to_sqs_policy" {
         Resource : [
           aws_sqs_queue.sms_factfinder_queue.arn,
           aws_sqs_queue.sms_inbound_queue.arn,
+          aws_sqs_queue.sms_emotions_queue.arn,
         ]
       }
     ]
@@ -62,17 +135,67 @@ resource "aws_lambda_permission" "allow_factfinder_lambda_sqs" {
   source_arn    = aws_sqs_queue.sms_factfinder_queue.arn
 }
 
-# Invoke the sender when a message is received
+resource "aws_lambda_permission" "allow_emotions_lambda_sqs" {
+  statement_id  = "AllowExecutionFromSQS"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.emotions_lambda.function_name
+  principal     = "sqs.amazonaws.com"
+  source_arn    = aws_sqs_queue.sms_emotions_queue.arn
+}
+
+resource "aws_lambda_permission" "allow_export_worker_lambda_sqs" {
+  statement_id  = "AllowExecutionFromSQS"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.export_worker_lambda.function_name
+  principal     = "sqs.amazonaws.com"
+  source_arn    = aws_sqs_queue.export_queue.arn
+}
+
+resource "aws_lambda_permission" "allow_dead_letter_lambda_sqs" {
+  statement_id  = "AllowExecutionFromSQS"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.dead_letter_lambda.function_name
+  principal     = "sqs.amazonaws.com"
+  source_arn    = aws_sqs_queue.dead_letter_queue.arn
+}
+
+# Invoke the sender when a message is received. A failed invocation
+# retries the whole batch, so messages are delivered one at a time.
 resource "aws_lambda_event_source_mapping" "sqs_to_sender_lambda_trigger" {
   event_source_arn  = aws_sqs_queue.sms_inbound_queue.arn
   function_name     = aws_lambda_function.send_sms_lambda.arn
+  batch_size        = 1
   enabled           = true
 }
 
-# Invoke the sender when a message is received
+# Invoke the factfinder when a message is received
 resource "aws_lambda_event_source_mapping" "sqs_to_factfinder_lambda_trigger" {
   event_source_arn = aws_sqs_queue.sms_factfinder_queue.arn
   function_name    = aws_lambda_function.factfinder_sms_lambda.arn
+  batch_size       = 1
+  enabled          = true
+}
+
+# Score the emotions in every message sent or received
+resource "aws_lambda_event_source_mapping" "sqs_to_emotions_lambda_trigger" {
+  event_source_arn = aws_sqs_queue.sms_emotions_queue.arn
+  function_name    = aws_lambda_function.emotions_lambda.arn
+  batch_size       = 1
+  enabled          = true
+}
+
+# Build each data export as it's requested
+resource "aws_lambda_event_source_mapping" "sqs_to_export_worker_lambda_trigger" {
+  event_source_arn = aws_sqs_queue.export_queue.arn
+  function_name    = aws_lambda_function.export_worker_lambda.arn
+  batch_size       = 1
+  enabled          = true
+}
+
+# Record messages that land in the dead letter queue
+resource "aws_lambda_event_source_mapping" "sqs_to_dead_letter_lambda_trigger" {
+  event_source_arn = aws_sqs_queue.dead_letter_queue.arn
+  function_name    = aws_lambda_function.dead_letter_lambda.arn
   enabled          = true
 }
 
@@ -110,7 +233,10 @@ resource "aws_iam_policy" "lambda_sqs_policy" {
         ],
         Resource : [
           aws_sqs_queue.sms_inbound_queue.arn,
-          aws_sqs_queue.sms_factfinder_queue.arn
+          aws_sqs_queue.sms_factfinder_queue.arn,
+          aws_sqs_queue.sms_emotions_queue.arn,
+          aws_sqs_queue.export_queue.arn,
+          aws_sqs_queue.dead_letter_queue.arn
         ],
         Effect: "Allow",
       },
@@ -174,3 +300,33 @@ resource "aws_sqs_queue_policy" "sms_factfinder_queue_policy" {
     ]
   })
 }
+
+resource "aws_sqs_queue_policy" "sms_emotions_queue_policy" {
+  queue_url = aws_sqs_queue.sms_emotions_queue.id
+
+  policy = jsonencode({
+    Version : "2012-10-17",
+    Statement : [
+      {
+        Effect : "Allow",
+        Principal : "*",
+        Action : [
+          "sqs:SendMessage",
+          "sqs:ReceiveMessage",
+          "sqs:DeleteMessage",
+          "sqs:GetQueueAttributes",
+          "sqs:ChangeMessageVisibility"
+        ],
+        Resource : aws_sqs_queue.sms_emotions_queue.arn,
+        Condition : {
+          ArnEquals : {
+            "aws:SourceArn" : [
+              aws_sns_topic.sms_inbound_topic.arn,
+              aws_sns_topic.sms_outbound_topic.arn
+            ]
+          }
+        }
+      }
+    ]
+  })
+}
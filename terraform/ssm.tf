@@ -105,3 +105,9 @@ resource "aws_ssm_parameter" "chat_model_frequency_penalty" {
   type  = "SecureString"
   value = var.chat_model_frequency_penalty
 }
+
+resource "aws_ssm_parameter" "log_hash_key" {
+  name  = "/config/LOG_HASH_KEY"
+  type  = "SecureString"
+  value = var.log_hash_key
+}
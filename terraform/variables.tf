@@ -27,6 +27,21 @@ variable "database_name" {}
 
 variable "log_level" {}
 
+# The fraction of log entries that keep verbose fields like prompts
+variable "log_sample_rate" {
+  default = 0.01
+}
+
+# Where spans are exported: "none" only records trace IDs for the logs
+variable "trace_exporter" {
+  default = "none"
+}
+
+# Phone numbers and emails in the logs are hashed with this key
+variable "log_hash_key" {
+  sensitive = true
+}
+
 # Twilio
 variable "twilio_sid" {}
 variable "twilio_auth_token" {}
@@ -40,3 +55,67 @@ variable "chat_model_name" {}
 variable "chat_model_temperature" {}
 variable "chat_model_max_completion_tokens" {}
 variable "chat_model_frequency_penalty" {}
+
+# Inbound burst coalescing
+variable "inbound_debounce_seconds" {
+  default = 20
+}
+
+# Number of attempts before a queued message is moved to the dead letter queue
+variable "max_receive_count" {
+  default = 3
+}
+
+# Model used to describe photos sent over MMS
+variable "vision_model_name" {
+  default = "gpt-4o"
+}
+
+# Speech to text for voice memos: "whisper", or "none" to turn it off
+variable "transcription_provider" {
+  default = "whisper"
+}
+
+# Who delivers outbound messages: "twilio", "http" for a generic SMS gateway, or "fake"
+variable "messaging_provider" {
+  default = "twilio"
+}
+
+# How emotions are scored: "api" for langtool.net, "local" for the built in
+# lexicons, or "fallback" to use the built in lexicons when the API fails
+variable "nrclex_analyzer" {
+  default = "fallback"
+}
+
+# Longer replies are split into several numbered texts
+variable "sms_max_segments_per_message" {
+  default = 3
+}
+
+# Replies longer than this many segments are sent back to the model to shorten
+variable "chat_model_max_reply_segments" {
+  default = 2
+}
+
+# The most a user's OpenAI requests may cost each month, in US dollars,
+# before they're throttled. 0 means no limit.
+variable "llm_monthly_cost_quota" {
+  default = 0
+}
+
+# How long a user's data export, and the link to it, lasts. The archive is
+# deleted from the export bucket after export_retention_days.
+variable "export_link_ttl_hours" {
+  default = 24
+}
+
+variable "export_retention_days" {
+  default = 7
+}
+
+# Where users who asked for their export by SMS are sent to sign in and
+# download it, like the app's export page. Left empty, the text tells them
+# to use the app.
+variable "export_link_url" {
+  default = ""
+}